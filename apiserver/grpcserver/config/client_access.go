@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"google.golang.org/grpc"
 
 	"github.com/polarismesh/polaris/common/metrics"
 	commontime "github.com/polarismesh/polaris/common/time"
@@ -76,7 +77,9 @@ func (g *ConfigGRPCServer) PublishConfigFile(ctx context.Context,
 // WatchConfigFiles 订阅配置变更
 func (g *ConfigGRPCServer) WatchConfigFiles(ctx context.Context,
 	request *apiconfig.ClientWatchConfigFileRequest) (*apiconfig.ConfigClientResponse, error) {
-	ctx = utils.ConvertGRPCContext(ctx)
+	// 客户端断开连接时需要结束等待
+	ctx, cancel := utils.WithCancelFrom(utils.ConvertGRPCContext(ctx), ctx)
+	defer cancel()
 
 	// 阻塞等待响应
 	callback, err := g.configServer.LongPullWatchFile(ctx, request)
@@ -86,6 +89,48 @@ func (g *ConfigGRPCServer) WatchConfigFiles(ctx context.Context,
 	return callback(), nil
 }
 
+// StreamWatchConfigFiles 通过双向流订阅配置变更，客户端每次发送新增或者取消的订阅，服务端持续推送变更
+func (g *ConfigGRPCServer) StreamWatchConfigFiles(stream grpc.ServerStream) error {
+	ctx := utils.ConvertGRPCContext(stream.Context())
+	return g.configServer.StreamWatchFile(ctx, &watchConfigFilesStream{ServerStream: stream})
+}
+
+// watchConfigFilesStream 将 grpc.ServerStream 适配为 config.WatchFileStream
+type watchConfigFilesStream struct {
+	grpc.ServerStream
+}
+
+func (s *watchConfigFilesStream) Send(rsp *apiconfig.ConfigClientResponse) error {
+	return s.ServerStream.SendMsg(rsp)
+}
+
+func (s *watchConfigFilesStream) Recv() (*apiconfig.ClientWatchConfigFileRequest, error) {
+	req := &apiconfig.ClientWatchConfigFileRequest{}
+	if err := s.ServerStream.RecvMsg(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// DownloadConfigFile 分片下载超过单个消息大小限制的配置文件，客户端发送一次请求后服务端持续推送分片
+func (g *ConfigGRPCServer) DownloadConfigFile(stream grpc.ServerStream) error {
+	ctx := utils.ConvertGRPCContext(stream.Context())
+	req := &apiconfig.ClientConfigFileInfo{}
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return g.configServer.DownloadConfigFile(ctx, req, &configFileChunkStream{ServerStream: stream})
+}
+
+// configFileChunkStream 将 grpc.ServerStream 适配为 config.ConfigFileChunkStream
+type configFileChunkStream struct {
+	grpc.ServerStream
+}
+
+func (s *configFileChunkStream) Send(rsp *apiconfig.ConfigClientResponse) error {
+	return s.ServerStream.SendMsg(rsp)
+}
+
 func (g *ConfigGRPCServer) GetConfigFileMetadataList(ctx context.Context,
 	req *apiconfig.ConfigFileGroupRequest) (*apiconfig.ConfigClientListResponse, error) {
 
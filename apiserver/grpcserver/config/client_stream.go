@@ -0,0 +1,64 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"google.golang.org/grpc"
+)
+
+// configStreamServer 配置中心客户端的流式接口，规范中的 PolarisConfigGRPC 服务没有定义这些方法，单独注册为一个服务
+type configStreamServer interface {
+	// StreamWatchConfigFiles 通过双向流订阅配置变更
+	StreamWatchConfigFiles(stream grpc.ServerStream) error
+	// DownloadConfigFile 分片下载超过单个消息大小限制的配置文件
+	DownloadConfigFile(stream grpc.ServerStream) error
+}
+
+// configStreamServiceDesc 配置中心客户端流式接口的服务描述
+var configStreamServiceDesc = grpc.ServiceDesc{
+	ServiceName: "v1.PolarisConfigStreamGRPC",
+	HandlerType: (*configStreamServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamWatchConfigFiles",
+			Handler:       streamWatchConfigFilesHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadConfigFile",
+			Handler:       downloadConfigFileHandler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpc_config_stream.proto",
+}
+
+func streamWatchConfigFilesHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(configStreamServer).StreamWatchConfigFiles(stream)
+}
+
+func downloadConfigFileHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(configStreamServer).DownloadConfigFile(stream)
+}
+
+// registerConfigStreamServer 注册配置中心客户端的流式接口
+func registerConfigStreamServer(server *grpc.Server, srv configStreamServer) {
+	server.RegisterService(&configStreamServiceDesc, srv)
+}
@@ -72,6 +72,7 @@ func (g *ConfigGRPCServer) Run(errCh chan error) {
 			case "client":
 				if apiConfig.Enable {
 					apiconfig.RegisterPolarisConfigGRPCServer(server, g)
+					registerConfigStreamServer(server, g)
 					openMethod, getErr := GetClientOpenMethod(g.GetProtocol())
 					if getErr != nil {
 						return getErr
@@ -137,6 +138,9 @@ func GetClientOpenMethod(protocol string) (map[string]bool, error) {
 		method := "/v1.PolarisConfig" + strings.ToUpper(protocol) + "/" + item
 		openMethod[method] = true
 	}
+	for _, item := range configStreamServiceDesc.Streams {
+		openMethod["/"+configStreamServiceDesc.ServiceName+"/"+item.StreamName] = true
+	}
 
 	return openMethod, nil
 }
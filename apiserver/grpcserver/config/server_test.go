@@ -0,0 +1,136 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/polarismesh/polaris/common/utils"
+	"github.com/polarismesh/polaris/config"
+)
+
+// testConfigCenterServer 只实现流式接口，其他接口调用时直接 panic
+type testConfigCenterServer struct {
+	config.ConfigCenterServer
+}
+
+func (s *testConfigCenterServer) StreamWatchFile(ctx context.Context, stream config.WatchFileStream) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	for _, file := range req.GetWatchFiles() {
+		if err := stream.Send(&apiconfig.ConfigClientResponse{ConfigFile: file}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testConfigCenterServer) DownloadConfigFile(ctx context.Context, req *apiconfig.ClientConfigFileInfo,
+	stream config.ConfigFileChunkStream) error {
+	for _, content := range []string{"hello ", "world"} {
+		if err := stream.Send(&apiconfig.ConfigClientResponse{ConfigFile: &apiconfig.ClientConfigFileInfo{
+			FileName: req.GetFileName(),
+			Content:  utils.NewStringValue(content),
+		}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newTestConfigStreamClient(t *testing.T) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	registerConfigStreamServer(server, &ConfigGRPCServer{configServer: &testConfigCenterServer{}})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return conn
+}
+
+func Test_StreamWatchConfigFiles(t *testing.T) {
+	conn := newTestConfigStreamClient(t)
+	stream, err := conn.NewStream(context.Background(), &configStreamServiceDesc.Streams[0],
+		"/v1.PolarisConfigStreamGRPC/StreamWatchConfigFiles")
+	assert.NoError(t, err)
+
+	assert.NoError(t, stream.SendMsg(&apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{{
+			Namespace: utils.NewStringValue("default"),
+			Group:     utils.NewStringValue("group"),
+			FileName:  utils.NewStringValue("file-1"),
+		}},
+	}))
+	rsp := &apiconfig.ConfigClientResponse{}
+	assert.NoError(t, stream.RecvMsg(rsp))
+	assert.Equal(t, "file-1", rsp.GetConfigFile().GetFileName().GetValue())
+}
+
+func Test_DownloadConfigFile(t *testing.T) {
+	conn := newTestConfigStreamClient(t)
+	stream, err := conn.NewStream(context.Background(), &configStreamServiceDesc.Streams[1],
+		"/v1.PolarisConfigStreamGRPC/DownloadConfigFile")
+	assert.NoError(t, err)
+	assert.NoError(t, stream.SendMsg(&apiconfig.ClientConfigFileInfo{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue("file-1"),
+	}))
+	assert.NoError(t, stream.CloseSend())
+
+	content := ""
+	for {
+		rsp := &apiconfig.ConfigClientResponse{}
+		if err := stream.RecvMsg(rsp); err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			break
+		}
+		assert.Equal(t, "file-1", rsp.GetConfigFile().GetFileName().GetValue())
+		content += rsp.GetConfigFile().GetContent().GetValue()
+	}
+	assert.Equal(t, "hello world", content)
+}
+
+func Test_GetClientOpenMethod(t *testing.T) {
+	openMethod, err := GetClientOpenMethod("grpc")
+	assert.NoError(t, err)
+	assert.True(t, openMethod["/v1.PolarisConfigGRPC/WatchConfigFiles"])
+	assert.True(t, openMethod["/v1.PolarisConfigStreamGRPC/StreamWatchConfigFiles"])
+	assert.True(t, openMethod["/v1.PolarisConfigStreamGRPC/DownloadConfigFile"])
+}
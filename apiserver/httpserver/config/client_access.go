@@ -20,6 +20,7 @@ package config
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/emicklei/go-restful/v3"
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
@@ -41,11 +42,17 @@ func (h *HTTPServer) ClientGetConfigFile(req *restful.Request, rsp *restful.Resp
 	}
 
 	version, _ := strconv.ParseUint(handler.Request.QueryParameter("version"), 10, 64)
+	// 客户端可以通过 md5 参数或者 If-None-Match 请求头携带当前持有配置的 md5，配置没有变化时不返回配置内容
+	md5 := handler.Request.QueryParameter("md5")
+	if md5 == "" {
+		md5 = parseIfNoneMatch(handler.Request.HeaderParameter("If-None-Match"))
+	}
 	configFile := &apiconfig.ClientConfigFileInfo{
 		Namespace: &wrapperspb.StringValue{Value: handler.Request.QueryParameter("namespace")},
 		Group:     &wrapperspb.StringValue{Value: handler.Request.QueryParameter("group")},
 		FileName:  &wrapperspb.StringValue{Value: handler.Request.QueryParameter("fileName")},
 		Version:   &wrapperspb.UInt64Value{Value: version},
+		Md5:       &wrapperspb.StringValue{Value: md5},
 	}
 
 	ctx := handler.ParseHeaderContext()
@@ -62,9 +69,19 @@ func (h *HTTPServer) ClientGetConfigFile(req *restful.Request, rsp *restful.Resp
 	}()
 
 	response := h.configServer.GetConfigFileForClient(ctx, configFile)
+	if fileMd5 := response.GetConfigFile().GetMd5().GetValue(); fileMd5 != "" {
+		rsp.AddHeader("ETag", "\""+fileMd5+"\"")
+	}
 	handler.WriteHeaderAndProto(response)
 }
 
+// parseIfNoneMatch 解析 If-None-Match 请求头中的 ETag，去掉弱校验前缀以及引号
+func parseIfNoneMatch(val string) string {
+	val = strings.TrimSpace(val)
+	val = strings.TrimPrefix(val, "W/")
+	return strings.Trim(val, "\"")
+}
+
 func (h *HTTPServer) ClientWatchConfigFile(req *restful.Request, rsp *restful.Response) {
 	handler := &httpcommon.Handler{
 		Request:  req,
@@ -78,8 +95,12 @@ func (h *HTTPServer) ClientWatchConfigFile(req *restful.Request, rsp *restful.Re
 		return
 	}
 
+	// 客户端断开连接时需要结束等待
+	ctx, cancel := utils.WithCancelFrom(handler.ParseHeaderContext(), req.Request.Context())
+	defer cancel()
+
 	// 阻塞等待响应
-	callback, err := h.configServer.LongPullWatchFile(handler.ParseHeaderContext(), watchConfigFileRequest)
+	callback, err := h.configServer.LongPullWatchFile(ctx, watchConfigFileRequest)
 	if err != nil {
 		handler.WriteHeaderAndProto(api.NewResponseWithMsg(apimodel.Code_ExecuteException, err.Error()))
 		return
@@ -87,6 +108,22 @@ func (h *HTTPServer) ClientWatchConfigFile(req *restful.Request, rsp *restful.Re
 	handler.WriteHeaderAndProto(callback())
 }
 
+// ClientGetConfigFileReleaseHistory 获取客户端已经订阅的配置文件最近的发布记录，用于排查发布问题
+func (h *HTTPServer) ClientGetConfigFileReleaseHistory(req *restful.Request, rsp *restful.Response) {
+	handler := &httpcommon.Handler{
+		Request:  req,
+		Response: rsp,
+	}
+
+	configFile := &apiconfig.ClientConfigFileInfo{
+		Namespace: &wrapperspb.StringValue{Value: handler.Request.QueryParameter("namespace")},
+		Group:     &wrapperspb.StringValue{Value: handler.Request.QueryParameter("group")},
+		FileName:  &wrapperspb.StringValue{Value: handler.Request.QueryParameter("fileName")},
+	}
+	handler.WriteHeaderAndProto(h.configServer.GetConfigFileReleaseHistoryForClient(
+		handler.ParseHeaderContext(), configFile))
+}
+
 // GetConfigFileMetadataList 统一发现接口
 func (h *HTTPServer) GetConfigFileMetadataList(req *restful.Request, rsp *restful.Response) {
 	handler := &httpcommon.Handler{
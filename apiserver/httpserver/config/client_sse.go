@@ -0,0 +1,201 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/emicklei/go-restful/v3"
+	"github.com/golang/protobuf/jsonpb"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	httpcommon "github.com/polarismesh/polaris/apiserver/httpserver/utils"
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// sseConfigEvent 配置变更通知的 SSE 事件名
+	sseConfigEvent = "config"
+)
+
+var (
+	errSSENotSupported = errors.New("response writer does not support flush")
+)
+
+// ClientWatchConfigFileEvents 通过 SSE 订阅配置变更，适用于无法使用 gRPC 的浏览器以及边缘客户端。
+// 连接建立后复用流式订阅的逻辑，客户端断开连接时订阅关系随之清理
+func (h *HTTPServer) ClientWatchConfigFileEvents(req *restful.Request, rsp *restful.Response) {
+	handler := &httpcommon.Handler{
+		Request:  req,
+		Response: rsp,
+	}
+
+	watchReq, err := parseSSEWatchRequest(handler)
+	if err != nil {
+		handler.WriteHeaderAndProto(api.NewResponseWithMsg(apimodel.Code_ParseException, err.Error()))
+		return
+	}
+	flusher, ok := rsp.ResponseWriter.(http.Flusher)
+	if !ok {
+		handler.WriteHeaderAndProto(api.NewResponseWithMsg(apimodel.Code_ExecuteException,
+			errSSENotSupported.Error()))
+		return
+	}
+
+	// 客户端断开连接时需要结束订阅
+	ctx, cancel := utils.WithCancelFrom(handler.ParseHeaderContext(), req.Request.Context())
+	defer cancel()
+
+	stream := &sseWatchFileStream{
+		ctx:     ctx,
+		writer:  rsp,
+		flusher: flusher,
+		req:     watchReq,
+	}
+	err = h.configServer.StreamWatchFile(ctx, stream)
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	if !stream.started() {
+		// 还没有开始推送时按照普通请求返回错误
+		handler.WriteHeaderAndProto(api.NewResponseWithMsg(apimodel.Code_ExecuteException, err.Error()))
+		return
+	}
+	configLog.Info("[Config][HTTP] sse watch config file finished", utils.RequestID(ctx),
+		zap.String("client", utils.ParseClientAddress(ctx)), zap.Error(err))
+}
+
+// parseSSEWatchRequest POST 请求从请求体中解析订阅列表，GET 请求（浏览器 EventSource）从查询参数中解析单个配置文件
+func parseSSEWatchRequest(handler *httpcommon.Handler) (*apiconfig.ClientWatchConfigFileRequest, error) {
+	watchReq := &apiconfig.ClientWatchConfigFileRequest{}
+	if handler.Request.Request.Method == http.MethodPost {
+		if _, err := handler.Parse(watchReq); err != nil {
+			return nil, err
+		}
+		return watchReq, nil
+	}
+	version, err := parseSSEVersion(handler.Request.QueryParameter("version"))
+	if err != nil {
+		return nil, err
+	}
+	watchReq.WatchFiles = []*apiconfig.ClientConfigFileInfo{
+		{
+			Namespace: &wrapperspb.StringValue{Value: handler.Request.QueryParameter("namespace")},
+			Group:     &wrapperspb.StringValue{Value: handler.Request.QueryParameter("group")},
+			FileName:  &wrapperspb.StringValue{Value: handler.Request.QueryParameter("fileName")},
+			Version:   &wrapperspb.UInt64Value{Value: version},
+		},
+	}
+	return watchReq, nil
+}
+
+func parseSSEVersion(val string) (uint64, error) {
+	if val == "" {
+		return 0, nil
+	}
+	version, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %s", val)
+	}
+	return version, nil
+}
+
+// sseWatchFileStream 将 SSE 连接适配为 config.WatchFileStream，订阅列表只在连接建立时发送一次
+type sseWatchFileStream struct {
+	ctx     context.Context
+	writer  http.ResponseWriter
+	flusher http.Flusher
+	req     *apiconfig.ClientWatchConfigFileRequest
+
+	lock     sync.Mutex
+	received bool
+	header   bool
+}
+
+// Context .
+func (s *sseWatchFileStream) Context() context.Context {
+	return s.ctx
+}
+
+// Recv 第一次返回连接建立时的订阅列表，之后阻塞直到连接断开
+func (s *sseWatchFileStream) Recv() (*apiconfig.ClientWatchConfigFileRequest, error) {
+	s.lock.Lock()
+	if !s.received {
+		s.received = true
+		s.lock.Unlock()
+		return s.req, nil
+	}
+	s.lock.Unlock()
+	<-s.ctx.Done()
+	return nil, s.ctx.Err()
+}
+
+// Send 将通知写为一个 SSE 事件并立即刷新到客户端
+func (s *sseWatchFileStream) Send(rsp *apiconfig.ConfigClientResponse) error {
+	data, err := formatSSEEvent(rsp)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.header {
+		s.header = true
+		header := s.writer.Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		// 避免 nginx 等代理缓存事件
+		header.Set("X-Accel-Buffering", "no")
+		s.writer.WriteHeader(http.StatusOK)
+	}
+	if _, err := s.writer.Write(data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseWatchFileStream) started() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.header
+}
+
+// formatSSEEvent 事件 ID 为配置文件的版本，数据为单行的 JSON，方便客户端断线重连后对比版本
+func formatSSEEvent(rsp *apiconfig.ConfigClientResponse) ([]byte, error) {
+	m := jsonpb.Marshaler{EmitDefaults: true}
+	data, err := m.MarshalToString(rsp)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if file := rsp.GetConfigFile(); file != nil {
+		fmt.Fprintf(buf, "id: %d\n", file.GetVersion().GetValue())
+	}
+	fmt.Fprintf(buf, "event: %s\ndata: %s\n\n", sseConfigEvent, data)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,152 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful/v3"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/utils"
+	"github.com/polarismesh/polaris/config"
+)
+
+// testStreamWatchServer 模拟配置中心的流式订阅，收到 publish 后向订阅方推送通知
+type testStreamWatchServer struct {
+	config.ConfigCenterServer
+	requests chan *apiconfig.ClientWatchConfigFileRequest
+	publish  chan *apiconfig.ClientConfigFileInfo
+	closed   chan error
+}
+
+func (s *testStreamWatchServer) StreamWatchFile(ctx context.Context, stream config.WatchFileStream) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.requests <- req
+	recvErr := make(chan error, 1)
+	go func() {
+		_, err := stream.Recv()
+		recvErr <- err
+	}()
+	for {
+		select {
+		case file := <-s.publish:
+			if err := stream.Send(api.NewConfigClientResponse(apimodel.Code_ExecuteSuccess, file)); err != nil {
+				s.closed <- err
+				return err
+			}
+		case err := <-recvErr:
+			// 连接断开后需要清理订阅
+			s.closed <- err
+			return err
+		}
+	}
+}
+
+func Test_ClientWatchConfigFileEvents(t *testing.T) {
+	svr := &testStreamWatchServer{
+		requests: make(chan *apiconfig.ClientWatchConfigFileRequest, 1),
+		publish:  make(chan *apiconfig.ClientConfigFileInfo, 1),
+		closed:   make(chan error, 1),
+	}
+	h := NewServer(nil, nil, svr)
+	ws := new(restful.WebService)
+	assert.NoError(t, h.GetClientAccessServer(ws, nil))
+	container := restful.NewContainer()
+	container.Add(ws)
+	httpSvr := httptest.NewServer(container)
+	defer httpSvr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		httpSvr.URL+"/WatchConfigFileEvents?namespace=default&group=group&fileName=file-1&version=1", nil)
+	assert.NoError(t, err)
+	go func() {
+		// 等待订阅建立后发布配置
+		watchReq := <-svr.requests
+		assert.Equal(t, 1, len(watchReq.GetWatchFiles()))
+		assert.Equal(t, "file-1", watchReq.GetWatchFiles()[0].GetFileName().GetValue())
+		assert.Equal(t, uint64(1), watchReq.GetWatchFiles()[0].GetVersion().GetValue())
+		svr.publish <- &apiconfig.ClientConfigFileInfo{
+			Namespace: utils.NewStringValue("default"),
+			Group:     utils.NewStringValue("group"),
+			FileName:  utils.NewStringValue("file-1"),
+			Version:   utils.NewUInt64Value(2),
+			Md5:       utils.NewStringValue("md5"),
+		}
+	}()
+
+	rsp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer rsp.Body.Close()
+	assert.Equal(t, http.StatusOK, rsp.StatusCode)
+	assert.Equal(t, "text/event-stream", rsp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(rsp.Body)
+	lines := make([]string, 0, 3)
+	for {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	assert.Equal(t, 3, len(lines), lines)
+	assert.Equal(t, "id: 2", lines[0])
+	assert.Equal(t, "event: "+sseConfigEvent, lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "data: "))
+	assert.Contains(t, lines[2], `"fileName":"file-1"`)
+
+	// 客户端断开连接后流式订阅结束
+	cancel()
+	select {
+	case err := <-svr.closed:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(3 * time.Second):
+		t.Fatal("sse watch should be finished after connection closed")
+	}
+}
+
+func Test_ClientWatchConfigFileEvents_InvalidVersion(t *testing.T) {
+	h := NewServer(nil, nil, &testStreamWatchServer{})
+	ws := new(restful.WebService)
+	assert.NoError(t, h.GetClientAccessServer(ws, nil))
+	container := restful.NewContainer()
+	container.Add(ws)
+
+	req := httptest.NewRequest(http.MethodGet, "/WatchConfigFileEvents?fileName=file-1&version=abc", nil)
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, req)
+	assert.Equal(t, strconv.Itoa(int(apimodel.Code_ParseException)), recorder.Header().Get(utils.PolarisCode))
+}
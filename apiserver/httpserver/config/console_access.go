@@ -304,6 +304,23 @@ func (h *HTTPServer) PublishConfigFile(req *restful.Request, rsp *restful.Respon
 	handler.WriteHeaderAndProto(h.configServer.PublishConfigFile(ctx, configFile))
 }
 
+// ForceNotifyConfigFile 强制通知订阅配置文件的客户端
+func (h *HTTPServer) ForceNotifyConfigFile(req *restful.Request, rsp *restful.Response) {
+	handler := &httpcommon.Handler{
+		Request:  req,
+		Response: rsp,
+	}
+
+	release := &apiconfig.ConfigFileRelease{}
+	ctx, err := handler.Parse(release)
+	if err != nil {
+		handler.WriteHeaderAndProto(api.NewConfigFileReleaseResponseWithMessage(apimodel.Code_ParseException, err.Error()))
+		return
+	}
+
+	handler.WriteHeaderAndProto(h.configServer.ForceNotifyConfigFile(ctx, release))
+}
+
 // RollbackConfigFileReleases 获取配置文件最后一次发布内容
 func (h *HTTPServer) RollbackConfigFileReleases(req *restful.Request, rsp *restful.Response) {
 	handler := &httpcommon.Handler{
@@ -114,6 +114,8 @@ func (h *HTTPServer) addDefaultAccess(ws *restful.WebService) {
 
 	// 配置文件发布
 	ws.Route(docs.EnrichPublishConfigFileApiDocs(ws.POST("/configfiles/release").To(h.PublishConfigFile)))
+	ws.Route(docs.EnrichForceNotifyConfigFileApiDocs(ws.POST("/configfiles/release/forcenotify").
+		To(h.ForceNotifyConfigFile)))
 	ws.Route(docs.EnrichGetConfigFileReleaseApiDocs(ws.PUT("/configfiles/releases/rollback").To(h.RollbackConfigFileReleases)))
 	ws.Route(docs.EnrichGetConfigFileReleaseApiDocs(ws.GET("/configfiles/release").To(h.GetConfigFileRelease)))
 	ws.Route(docs.EnrichGetConfigFileReleaseApiDocs(ws.GET("/configfiles/releases").To(h.GetConfigFileReleases)))
@@ -153,7 +155,13 @@ func (h *HTTPServer) GetClientAccessServer(ws *restful.WebService, include []str
 func (h *HTTPServer) addDiscover(ws *restful.WebService) {
 	ws.Route(docs.EnrichGetConfigFileForClientApiDocs(ws.GET("/GetConfigFile").To(h.ClientGetConfigFile)))
 	ws.Route(docs.EnrichWatchConfigFileForClientApiDocs(ws.POST("/WatchConfigFile").To(h.ClientWatchConfigFile)))
+	ws.Route(docs.EnrichWatchConfigFileEventsForClientApiDocs(ws.GET("/WatchConfigFileEvents").
+		To(h.ClientWatchConfigFileEvents)))
+	ws.Route(docs.EnrichWatchConfigFileEventsForClientApiDocs(ws.POST("/WatchConfigFileEvents").
+		To(h.ClientWatchConfigFileEvents)))
 	ws.Route(docs.EnrichGetConfigFileMetadataList(ws.POST("/GetConfigFileMetadataList").To(h.GetConfigFileMetadataList)))
+	ws.Route(docs.EnrichGetConfigFileReleaseHistoryForClientApiDocs(ws.GET("/GetConfigFileReleaseHistory").
+		To(h.ClientGetConfigFileReleaseHistory)))
 }
 
 func (h *HTTPServer) addCreateFile(ws *restful.WebService) {
@@ -208,6 +208,14 @@ func EnrichPublishConfigFileApiDocs(r *restful.RouteBuilder) *restful.RouteBuild
 		Returns(0, "", BaseResponse{})
 }
 
+func EnrichForceNotifyConfigFileApiDocs(r *restful.RouteBuilder) *restful.RouteBuilder {
+	return r.
+		Doc("强制通知订阅配置文件的客户端重新拉取当前生效的配置").
+		Metadata(restfulspec.KeyOpenAPITags, configConsoleApiTags).
+		Reads(apiconfig.ConfigFileRelease{}).
+		Returns(0, "", BaseResponse{})
+}
+
 func EnrichGetConfigFileReleaseApiDocs(r *restful.RouteBuilder) *restful.RouteBuilder {
 	return r.
 		Doc("获取配置文件最后一次全量发布信息").
@@ -265,6 +273,8 @@ func EnrichGetConfigFileForClientApiDocs(r *restful.RouteBuilder) *restful.Route
 		Param(restful.QueryParameter("fileName", "配置文件名").DataType(typeNameString).Required(true)).
 		Param(restful.QueryParameter("version", "配置文件客户端版本号，刚启动时设置为 0").
 			DataType(typeNameInteger).Required(true)).
+		Param(restful.QueryParameter("md5", "客户端当前持有配置的 md5，配置没有变化时不返回配置内容，"+
+			"也可以通过 If-None-Match 请求头传递").DataType(typeNameString).Required(false)).
 		Returns(0, "", config_manage.ConfigClientResponse{})
 }
 
@@ -276,6 +286,20 @@ func EnrichWatchConfigFileForClientApiDocs(r *restful.RouteBuilder) *restful.Rou
 		Returns(0, "", config_manage.ConfigClientResponse{})
 }
 
+func EnrichWatchConfigFileEventsForClientApiDocs(r *restful.RouteBuilder) *restful.RouteBuilder {
+	return r.
+		Doc("以 SSE 的方式监听配置").
+		Metadata(restfulspec.KeyOpenAPITags, configClientApiTags).
+		Param(restful.QueryParameter("namespace", "命名空间，GET 请求时使用").DataType("string").Required(false)).
+		Param(restful.QueryParameter("group", "配置分组，GET 请求时使用").DataType("string").Required(false)).
+		Param(restful.QueryParameter("fileName", "配置文件名，GET 请求时使用").DataType("string").Required(false)).
+		Param(restful.QueryParameter("version", "客户端当前持有的版本，GET 请求时使用").DataType("integer").
+			Required(false)).
+		Reads(apiconfig.ClientWatchConfigFileRequest{}, "POST 请求时通过请求体携带订阅列表，"+
+			"服务端通过 text/event-stream 持续推送配置变更。").
+		Returns(0, "", config_manage.ConfigClientResponse{})
+}
+
 func EnrichGetConfigFileMetadataList(r *restful.RouteBuilder) *restful.RouteBuilder {
 	return r.
 		Doc("监听配置").
@@ -284,6 +308,16 @@ func EnrichGetConfigFileMetadataList(r *restful.RouteBuilder) *restful.RouteBuil
 		Returns(0, "", config_manage.ConfigClientResponse{})
 }
 
+func EnrichGetConfigFileReleaseHistoryForClientApiDocs(r *restful.RouteBuilder) *restful.RouteBuilder {
+	return r.
+		Doc("查询客户端已经订阅的配置文件最近的发布记录").
+		Metadata(restfulspec.KeyOpenAPITags, configClientApiTags).
+		Param(restful.QueryParameter("namespace", "命名空间").DataType(typeNameString).Required(true)).
+		Param(restful.QueryParameter("group", "配置文件分组").DataType(typeNameString).Required(true)).
+		Param(restful.QueryParameter("fileName", "配置文件名").DataType(typeNameString).Required(true)).
+		Returns(0, "", config_manage.ConfigClientListResponse{})
+}
+
 func EnrichGetAllConfigEncryptAlgorithms(r *restful.RouteBuilder) *restful.RouteBuilder {
 	return r.
 		Doc("返回当前配置加解密的算法").
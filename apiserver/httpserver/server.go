@@ -475,6 +475,13 @@ func (h *HTTPServer) enablePluginDebugAccess(wsContainer *restful.Container) {
 		}
 	}
 
+	if h.configServer != nil {
+		handlers := h.configServer.DebugHandlers()
+		for i := range handlers {
+			wsContainer.Handle(handlers[i].Path, handlers[i].Handler)
+		}
+	}
+
 	for _, item := range h.apiserverSlots {
 		if val, ok := item.(apiserver.EnrichApiserver); ok {
 			handlers := val.DebugHandlers()
@@ -102,6 +102,23 @@ func (h *Handler) postParseMessage(requestID string) (context.Context, error) {
 	if authToken != "" {
 		ctx = context.WithValue(ctx, utils.ContextAuthTokenKey, authToken)
 	}
+	if watchProtocol := h.Request.HeaderParameter(utils.HeaderWatchProtocolKey); watchProtocol != "" {
+		ctx = context.WithValue(ctx, utils.ContextWatchProtocolKey, watchProtocol)
+	}
+	if clientId := h.Request.HeaderParameter(utils.HeaderClientIdKey); clientId != "" {
+		ctx = context.WithValue(ctx, utils.ContextClientIdKey, clientId)
+	}
+	if clientVersion := h.Request.HeaderParameter(utils.HeaderClientVersionKey); clientVersion != "" {
+		ctx = context.WithValue(ctx, utils.ContextClientVersionKey, clientVersion)
+	}
+	if idempotencyKey := h.Request.HeaderParameter(utils.HeaderIdempotencyKey); idempotencyKey != "" {
+		ctx = context.WithValue(ctx, utils.ContextIdempotencyKey, idempotencyKey)
+	}
+	if delegationToken := h.Request.HeaderParameter(utils.HeaderDelegationTokenKey); delegationToken != "" {
+		ctx = context.WithValue(ctx, utils.ContextDelegationTokenKey, delegationToken)
+	}
+	ctx = utils.WithDryRunHeader(ctx, h.Request.HeaderParameter(utils.HeaderDryRunKey))
+	ctx = utils.WithPublishWaitHeader(ctx, h.Request.HeaderParameter(utils.HeaderPublishWaitKey))
 
 	var operator string
 	addrSlice := strings.Split(h.Request.Request.RemoteAddr, ":")
@@ -23,6 +23,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/golang/protobuf/ptypes/wrappers"
@@ -100,3 +101,59 @@ func Test_ParseJsonBody(t *testing.T) {
 	}
 
 }
+
+func Test_ParseDryRunHeader(t *testing.T) {
+	testCases := map[string]bool{
+		"":      false,
+		"true":  true,
+		"1":     true,
+		"false": false,
+		"abc":   false,
+	}
+	for val, expect := range testCases {
+		httpReq, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("{}"))
+		if val != "" {
+			httpReq.Header.Set(utils.HeaderDryRunKey, val)
+		}
+		h := Handler{
+			Request:  restful.NewRequest(httpReq),
+			Response: restful.NewResponse(httptest.NewRecorder()),
+		}
+		ctx, err := h.Parse(&apimodel.Namespace{})
+		if err != nil {
+			t.Fatalf("Parse err %v", err)
+		}
+		if utils.IsDryRun(ctx) != expect {
+			t.Errorf("IsDryRun with header %q = %v, want %v", val, !expect, expect)
+		}
+	}
+}
+
+func Test_ParsePublishWaitHeader(t *testing.T) {
+	testCases := map[string]time.Duration{
+		"":     0,
+		"3s":   3 * time.Second,
+		"1500": 1500 * time.Millisecond,
+		"10m":  utils.MaxPublishWait,
+		"-1s":  0,
+		"abc":  0,
+	}
+	for val, expect := range testCases {
+		httpReq, _ := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader("{}"))
+		if val != "" {
+			httpReq.Header.Set(utils.HeaderPublishWaitKey, val)
+		}
+		h := Handler{
+			Request:  restful.NewRequest(httpReq),
+			Response: restful.NewResponse(httptest.NewRecorder()),
+		}
+		ctx, err := h.Parse(&apimodel.Namespace{})
+		if err != nil {
+			t.Fatalf("Parse err %v", err)
+		}
+		timeout, ok := utils.GetPublishWait(ctx)
+		if timeout != expect || ok != (expect > 0) {
+			t.Errorf("GetPublishWait with header %q = %v, want %v", val, timeout, expect)
+		}
+	}
+}
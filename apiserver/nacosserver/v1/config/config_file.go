@@ -19,6 +19,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/url"
 	"strings"
@@ -121,8 +122,17 @@ func (n *ConfigServer) handleWatch(ctx context.Context, listenCtx *model.ConfigW
 	}
 	clientId := utils.ParseClientAddress(ctx) + "@" + utils.NewUUID()[0:8]
 	configSvr := n.originConfigSvr.(*config.Server)
-	watchCtx := configSvr.WatchCenter().AddWatcher(clientId, specWatchReq.GetWatchFiles(),
+	watchCtx, err := configSvr.WatchCenter().AddWatcher(clientId, specWatchReq.GetWatchFiles(),
 		BuildTimeoutWatchCtx(timeout))
+	if err != nil {
+		nacoslog.Warn("[NACOS-V1][Config] client add watcher fail", zap.String("client", clientId), zap.Error(err))
+		if errors.Is(err, config.ErrInvalidWatchFile) {
+			rsp.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		rsp.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
 	nacoslog.Info("[NACOS-V1][Config] client start waitting server send notify message")
 	notifyRet := (watchCtx.(*LongPollWatchContext)).GetNotifieResult()
 	notifyCode := notifyRet.GetCode().GetValue()
@@ -44,6 +44,11 @@ func (c *LongPollWatchContext) ShouldExpire(now time.Time) bool {
 	return now.After(c.finishTime)
 }
 
+// ExpireTime .
+func (c *LongPollWatchContext) ExpireTime() time.Time {
+	return c.finishTime
+}
+
 // ClientID .
 func (c *LongPollWatchContext) ClientID() string {
 	return c.clientId
@@ -95,6 +95,7 @@ func (h *Handler) ParseHeaderContext() context.Context {
 	}
 	ctx = context.WithValue(ctx, utils.StringContext("operator"), operator)
 	ctx = context.WithValue(ctx, utils.ContextClientAddress, h.Request.Request.RemoteAddr)
+	ctx = utils.WithDryRunHeader(ctx, h.Request.HeaderParameter(utils.HeaderDryRunKey))
 	return ctx
 }
 
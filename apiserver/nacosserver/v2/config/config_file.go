@@ -19,6 +19,7 @@ package config
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/polarismesh/specification/source/go/api/v1/config_manage"
@@ -28,6 +29,7 @@ import (
 	nacosmodel "github.com/polarismesh/polaris/apiserver/nacosserver/model"
 	nacospb "github.com/polarismesh/polaris/apiserver/nacosserver/v2/pb"
 	"github.com/polarismesh/polaris/apiserver/nacosserver/v2/remote"
+	api "github.com/polarismesh/polaris/common/api/v1"
 	"github.com/polarismesh/polaris/common/utils"
 	"github.com/polarismesh/polaris/config"
 )
@@ -158,7 +160,22 @@ func (h *ConfigServer) handleWatchConfigRequest(ctx context.Context, req nacospb
 	clientId := meta.ConnectionID
 	specReq := watchReq.ToSpec()
 	if watchReq.Listen {
-		_ = configSvr.WatchCenter().AddWatcher(clientId, specReq.GetWatchFiles(), h.BuildGrpcWatchCtx())
+		if _, err := configSvr.WatchCenter().AddWatcher(clientId, specReq.GetWatchFiles(),
+			h.BuildGrpcWatchCtx()); err != nil {
+			nacoslog.Warn("[NACOS-V2][Config] client add watcher fail", zap.String("client", clientId),
+				zap.Error(err))
+			errCode := api.ServiceTooBusy
+			if errors.Is(err, config.ErrInvalidWatchFile) {
+				errCode = api.InvalidWatchConfigFileFormat
+			}
+			listenResp.Response = &nacospb.Response{
+				Success:    false,
+				ResultCode: int(nacosmodel.Response_Fail.Code),
+				ErrorCode:  int(errCode),
+				Message:    err.Error(),
+			}
+			return listenResp, nil
+		}
 		for i := range specReq.GetWatchFiles() {
 			item := specReq.GetWatchFiles()[i]
 			namespace := item.GetNamespace().GetValue()
@@ -65,7 +65,10 @@ func (c *ConnectionClientManager) OnEvent(ctx context.Context, a any) error {
 	case remote.EventClientConnected:
 		// do nothing
 	case remote.EventClientDisConnected:
-		c.configSvr.WatchCenter().RemoveAllWatcher(event.ConnID)
+		if err := c.configSvr.WatchCenter().RemoveAllWatcher(event.ConnID); err != nil {
+			nacoslog.Error("[NACOS-V2][Config] remove watcher of disconnected client fail",
+				zap.String("conn-id", event.ConnID), zap.Error(err))
+		}
 	}
 
 	return nil
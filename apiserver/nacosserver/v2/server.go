@@ -436,5 +436,8 @@ func (h *NacosV2Server) ConvertContext(ctx context.Context) context.Context {
 	ctx = context.WithValue(ctx, remote.ClientIPKey{}, clientIP)
 	ctx = context.WithValue(ctx, remote.ConnIDKey{}, connID)
 	ctx = context.WithValue(ctx, remote.ConnectionInfoKey{}, connMeta)
+	if vals := meta.Get(utils.HeaderDryRunKey); len(vals) > 0 {
+		ctx = utils.WithDryRunHeader(ctx, vals[0])
+	}
 	return ctx
 }
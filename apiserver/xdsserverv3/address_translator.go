@@ -0,0 +1,55 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"fmt"
+	"sync"
+
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+)
+
+// AddressTranslateFunc 将实例注册的地址转换为 envoy 实际可以访问的地址，例如 overlay 网络下
+// 实例注册的是 POD IP，需要转换为 Node IP + NodePort，无需转换时原样返回 host 和 port
+type AddressTranslateFunc func(ins *apiservice.Instance, host string, port uint32) (string, uint32)
+
+var (
+	addressTranslatorsLock sync.RWMutex
+	addressTranslators     = map[string]AddressTranslateFunc{}
+)
+
+// RegisterAddressTranslator 注册地址转换器，通过 xds 配置项 addressTranslator 指定使用的转换器
+func RegisterAddressTranslator(name string, translator AddressTranslateFunc) {
+	addressTranslatorsLock.Lock()
+	defer addressTranslatorsLock.Unlock()
+	addressTranslators[name] = translator
+}
+
+// getAddressTranslator 查询已注册的地址转换器，name 为空时不做转换
+func getAddressTranslator(name string) (AddressTranslateFunc, error) {
+	if name == "" {
+		return nil, nil
+	}
+	addressTranslatorsLock.RLock()
+	defer addressTranslatorsLock.RUnlock()
+	translator, ok := addressTranslators[name]
+	if !ok {
+		return nil, fmt.Errorf("[XDSV3] address translator %s not found", name)
+	}
+	return translator, nil
+}
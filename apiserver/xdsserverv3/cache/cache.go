@@ -98,13 +98,32 @@ func (sc *XDSCache) Fetch(ctx context.Context, request *cachev3.Request) (cachev
 	return nil, errors.New("not implemented")
 }
 
-// DeltaUpdateResource .
+// DeltaUpdateResource current 为 key 下全量的资源，只有发生变化以及被删除的资源才会推送给订阅者
 func (sc *XDSCache) DeltaUpdateResource(key, typeUrl string, current map[string]types.Resource) error {
 	val, _ := sc.Caches.ComputeIfAbsent(key, func(_ string) cachev3.Cache {
 		return NewLinearCache(typeUrl)
 	})
-	linearCache, _ := val.(*LinearCache)
-	return linearCache.UpdateResources(current, []string{})
+	linearCache, ok := val.(*LinearCache)
+	if !ok {
+		return fmt.Errorf("cache %s is not linear cache", key)
+	}
+	if changed := linearCache.SyncResources(current); len(changed) != 0 {
+		log.Debug("[XDS][V3] delta update resources", zap.String("key", key), zap.Strings("changed", changed))
+	}
+	return nil
+}
+
+// UpdateEndpointWeight 只更新 key 下 cluster 中指定 endpoint 的权重，返回权重是否发生了变化
+func (sc *XDSCache) UpdateEndpointWeight(key, clusterName, host string, port, weight uint32) (bool, error) {
+	val, ok := sc.Caches.Load(key)
+	if !ok {
+		return false, nil
+	}
+	linearCache, ok := val.(*LinearCache)
+	if !ok {
+		return false, fmt.Errorf("cache %s is not linear cache", key)
+	}
+	return linearCache.UpdateEndpointWeight(clusterName, host, port, weight)
 }
 
 func classify(typeUrl string, resources []string, client *resource.XDSClient) []string {
@@ -144,6 +163,10 @@ var (
 			return true
 		},
 		resourcev3.EndpointType: func(typeUrl string, resources []string, client *resource.XDSClient) bool {
+			// OUTBOUND 的 EDS 按照节点单独构建的节点
+			if client.HasNodeEndpoints() {
+				return true
+			}
 			selfSvc := fmt.Sprintf("INBOUND|%s|%s", client.GetSelfNamespace(), client.GetSelfService())
 			for i := range resources {
 				if resources[i] == selfSvc {
@@ -152,6 +175,10 @@ var (
 			}
 			return false
 		},
+		resourcev3.ClusterType: func(typeUrl string, resources []string, client *resource.XDSClient) bool {
+			// 声明了只需要下发的服务的节点，OUTBOUND 的 CDS 按照节点单独构建
+			return client.GetRequestedServices() != nil
+		},
 		resourcev3.RouteType: func(typeUrl string, resources []string, client *resource.XDSClient) bool {
 			for i := range resources {
 				if resources[i] == resource.InBoundRouteConfigName {
@@ -0,0 +1,62 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+)
+
+func Test_classify_EndpointLocality(t *testing.T) {
+	const nodeId = "sidecar~default/12345~127.0.0.1"
+	outbound := []string{"OUTBOUND|default|svc"}
+
+	// 没有上报地域信息的节点，OUTBOUND 的 EDS 使用命名空间共享的资源
+	client := resource.ParseXDSClient(&corev3.Node{Id: nodeId})
+	assert.Equal(t, []string{resourcev3.EndpointType + "~default"},
+		classify(resourcev3.EndpointType, outbound, client))
+
+	// 上报了地域信息的节点优先使用按照节点构建的资源，还没有构建时回退到命名空间共享的资源
+	client = resource.ParseXDSClient(&corev3.Node{Id: nodeId, Locality: &corev3.Locality{Zone: "zone-a"}})
+	assert.Equal(t, []string{resourcev3.EndpointType + "~" + nodeId, resourcev3.EndpointType + "~default"},
+		classify(resourcev3.EndpointType, outbound, client))
+}
+
+func Test_classify_RequestedServices(t *testing.T) {
+	const nodeId = "sidecar~default/12345~127.0.0.1"
+	outbound := []string{"OUTBOUND|default|svc"}
+
+	// 没有声明需要的服务时，OUTBOUND 的 EDS、CDS 使用命名空间共享的资源
+	client := resource.ParseXDSClient(&corev3.Node{Id: nodeId})
+	assert.Equal(t, []string{resourcev3.ClusterType + "~default"}, classify(resourcev3.ClusterType, nil, client))
+
+	// 声明了需要的服务时，优先使用按照节点构建的资源
+	meta, err := structpb.NewStruct(map[string]interface{}{resource.SidecarRequestedServices: "svc"})
+	assert.NoError(t, err)
+	client = resource.ParseXDSClient(&corev3.Node{Id: nodeId, Metadata: meta})
+	assert.Equal(t, []string{resourcev3.EndpointType + "~" + nodeId, resourcev3.EndpointType + "~default"},
+		classify(resourcev3.EndpointType, outbound, client))
+	assert.Equal(t, []string{resourcev3.ClusterType + "~" + nodeId, resourcev3.ClusterType + "~default"},
+		classify(resourcev3.ClusterType, nil, client))
+}
@@ -26,18 +26,22 @@ import (
 
 	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
 	commonlog "github.com/polarismesh/polaris/common/log"
+	"github.com/polarismesh/polaris/common/metrics"
 )
 
-func NewCallback(log *commonlog.Scope, nodeMgr *resource.XDSNodeManager) *Callbacks {
+func NewCallback(log *commonlog.Scope, nodeMgr *resource.XDSNodeManager,
+	ackTracker *resource.XDSAckTracker) *Callbacks {
 	return &Callbacks{
-		log:     log,
-		nodeMgr: nodeMgr,
+		log:        log,
+		nodeMgr:    nodeMgr,
+		ackTracker: ackTracker,
 	}
 }
 
 type Callbacks struct {
-	log     *commonlog.Scope
-	nodeMgr *resource.XDSNodeManager
+	log        *commonlog.Scope
+	nodeMgr    *resource.XDSNodeManager
+	ackTracker *resource.XDSAckTracker
 }
 
 func (cb *Callbacks) Report() {
@@ -49,6 +53,9 @@ func (cb *Callbacks) OnStreamOpen(_ context.Context, id int64, typ string) error
 }
 
 func (cb *Callbacks) OnStreamClosed(id int64, node *corev3.Node) {
+	if nodeId := cb.streamNodeId(id, node); nodeId != "" && cb.ackTracker != nil {
+		cb.ackTracker.DelNode(nodeId)
+	}
 	cb.nodeMgr.DelNode(id)
 }
 
@@ -63,8 +70,10 @@ func (cb *Callbacks) OnStreamRequest(id int64, req *discovery.DiscoveryRequest)
 	cb.nodeMgr.AddNodeIfAbsent(id, req.GetNode())
 	node := req.Node
 	req.Node = nil
-	log.Info("[XDSV3][Receive] receive stream request", zap.Int64("stream-id", id), zap.String("node-id", node.Id), zap.Any("req", req))
+	log.Info("[XDSV3][Receive] receive stream request", zap.Int64("stream-id", id), zap.String("node-id", node.GetId()), zap.Any("req", req))
 	req.Node = node
+	cb.recordAck(id, req.GetNode(), req.GetTypeUrl(), req.GetVersionInfo(), req.GetResponseNonce(),
+		req.GetErrorDetail().GetCode(), req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
 	return nil
 }
 
@@ -72,7 +81,7 @@ func (cb *Callbacks) OnStreamResponse(_ context.Context, id int64, req *discover
 	resp *discovery.DiscoveryResponse) {
 	node := req.Node
 	req.Node = nil
-	log.Info("[XDSV3][Receive] send stream response", zap.Int64("stream-id", id), zap.String("node-id", node.Id), zap.Any("req", req))
+	log.Info("[XDSV3][Receive] send stream response", zap.Int64("stream-id", id), zap.String("node-id", node.GetId()), zap.Any("req", req))
 	req.Node = node
 }
 
@@ -80,16 +89,56 @@ func (cb *Callbacks) OnStreamDeltaRequest(id int64, req *discovery.DeltaDiscover
 	cb.nodeMgr.AddNodeIfAbsent(id, req.GetNode())
 	node := req.Node
 	req.Node = nil
-	log.Info("[XDSV3][Receive] receive delta stream request", zap.Int64("stream-id", id), zap.String("node-id", node.Id), zap.Any("req", req))
+	log.Info("[XDSV3][Receive] receive delta stream request", zap.Int64("stream-id", id), zap.String("node-id", node.GetId()), zap.Any("req", req))
 	req.Node = node
+	cb.recordAck(id, req.GetNode(), req.GetTypeUrl(), "", req.GetResponseNonce(),
+		req.GetErrorDetail().GetCode(), req.GetErrorDetail().GetMessage(), req.GetErrorDetail() != nil)
 	return nil
 }
 
+// recordAck 带有 response nonce 的请求是 envoy 对上一次推送的确认，携带 error detail 时表示 envoy 拒绝了推送
+func (cb *Callbacks) recordAck(id int64, node *corev3.Node, typeUrl, version, nonce string,
+	errCode int32, errMsg string, nack bool) {
+	if nonce == "" || cb.ackTracker == nil {
+		return
+	}
+	nodeId := cb.streamNodeId(id, node)
+	if nodeId == "" {
+		return
+	}
+	status := cb.ackTracker.Record(nodeId, resource.XDSAckStatus{
+		TypeUrl:     typeUrl,
+		Version:     version,
+		Nonce:       nonce,
+		Acked:       !nack,
+		ErrorCode:   errCode,
+		ErrorDetail: errMsg,
+	})
+	metrics.ReportXDSAck(typeUrl, !nack)
+	if nack {
+		log.Error("[XDSV3][Receive] envoy rejected xds resources", zap.Int64("stream-id", id),
+			zap.String("node-id", nodeId), zap.String("type-url", typeUrl), zap.String("version", version),
+			zap.String("nonce", nonce), zap.Int32("error-code", errCode), zap.String("error-detail", errMsg),
+			zap.Uint64("nack-count", status.NackCount))
+	}
+}
+
+// streamNodeId envoy 可以只在第一个请求中携带节点信息，之后的请求从 stream 关联的节点中获取
+func (cb *Callbacks) streamNodeId(id int64, node *corev3.Node) string {
+	if node.GetId() != "" {
+		return node.GetId()
+	}
+	if client := cb.nodeMgr.GetNodeByStreamID(id); client != nil && client.Node != nil {
+		return client.Node.GetId()
+	}
+	return ""
+}
+
 func (cb *Callbacks) OnStreamDeltaResponse(id int64, req *discovery.DeltaDiscoveryRequest,
 	resp *discovery.DeltaDiscoveryResponse) {
 	node := req.Node
 	req.Node = nil
-	log.Info("[XDSV3][Receive] send delta stream response", zap.Int64("stream-id", id), zap.String("node-id", node.Id), zap.Any("req", req))
+	log.Info("[XDSV3][Receive] send delta stream response", zap.Int64("stream-id", id), zap.String("node-id", node.GetId()), zap.Any("req", req))
 	req.Node = node
 }
 
@@ -0,0 +1,79 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package cache
+
+import (
+	"testing"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/status"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+	commonlog "github.com/polarismesh/polaris/common/log"
+)
+
+func TestCallbacks_RecordAck(t *testing.T) {
+	tracker := resource.NewXDSAckTracker()
+	cb := NewCallback(commonlog.GetScopeOrDefaultByName(commonlog.XDSLoggerName), resource.NewXDSNodeManager(), tracker)
+	const nodeId = "sidecar~default/12345~127.0.0.1"
+
+	// 第一次请求没有 response nonce，不是对推送的确认
+	assert.NoError(t, cb.OnStreamRequest(1, &discovery.DiscoveryRequest{
+		Node:    &corev3.Node{Id: nodeId},
+		TypeUrl: resourcev3.EndpointType,
+	}))
+	assert.Empty(t, tracker.GetNodeStatus(nodeId))
+
+	// envoy 接受了推送
+	assert.NoError(t, cb.OnStreamRequest(1, &discovery.DiscoveryRequest{
+		Node:          &corev3.Node{Id: nodeId},
+		TypeUrl:       resourcev3.EndpointType,
+		VersionInfo:   "v1",
+		ResponseNonce: "1",
+	}))
+	ackStatus := tracker.GetNodeStatus(nodeId)
+	assert.Equal(t, 1, len(ackStatus))
+	assert.True(t, ackStatus[0].Acked)
+	assert.Empty(t, tracker.ListNackedNodes())
+
+	// envoy 拒绝了推送，后续的请求没有携带节点信息时从 stream 中获取
+	assert.NoError(t, cb.OnStreamRequest(1, &discovery.DiscoveryRequest{
+		TypeUrl:       resourcev3.EndpointType,
+		VersionInfo:   "v1",
+		ResponseNonce: "2",
+		ErrorDetail:   &status.Status{Code: 3, Message: "malformed IP address: 1.2.3"},
+	}))
+	ackStatus = tracker.GetNodeStatus(nodeId)
+	assert.Equal(t, 1, len(ackStatus))
+	assert.False(t, ackStatus[0].Acked)
+	assert.Equal(t, "v1", ackStatus[0].Version)
+	assert.Equal(t, "2", ackStatus[0].Nonce)
+	assert.Equal(t, int32(3), ackStatus[0].ErrorCode)
+	assert.Equal(t, "malformed IP address: 1.2.3", ackStatus[0].ErrorDetail)
+	assert.Equal(t, uint64(1), ackStatus[0].NackCount)
+	nacked := tracker.ListNackedNodes()
+	assert.Equal(t, 1, len(nacked[nodeId]))
+	assert.Equal(t, resourcev3.EndpointType, nacked[nodeId][0].TypeUrl)
+
+	// 节点断开之后不再保留确认结果
+	cb.OnStreamClosed(1, nil)
+	assert.Empty(t, tracker.GetNodeStatus(nodeId))
+}
@@ -17,6 +17,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,9 +25,12 @@ import (
 	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpointv3 "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
 )
@@ -252,6 +256,87 @@ func (cache *LinearCache) UpdateResources(toUpdate map[string]types.Resource, to
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
+	cache.updateResourcesLocked(toUpdate, toDelete)
+	return nil
+}
+
+// SyncResources 将 cache 中的资源同步为当前全量的资源，只有内容发生变化的资源才会更新版本并通知订阅者，
+// 不在 resources 中的资源会被删除，返回发生变化以及被删除的资源名称。单个实例变化时只会推送受影响的资源
+func (cache *LinearCache) SyncResources(resources map[string]types.Resource) []string {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	toUpdate := make(map[string]types.Resource)
+	for name, res := range resources {
+		if old, ok := cache.resources[name]; ok && proto.Equal(old, res) {
+			continue
+		}
+		toUpdate[name] = res
+	}
+	var toDelete []string
+	for name := range cache.resources {
+		if _, ok := resources[name]; !ok {
+			toDelete = append(toDelete, name)
+		}
+	}
+	if len(toUpdate) == 0 && len(toDelete) == 0 {
+		return nil
+	}
+	changed := make([]string, 0, len(toUpdate)+len(toDelete))
+	for name := range toUpdate {
+		changed = append(changed, name)
+	}
+	changed = append(changed, toDelete...)
+	cache.updateResourcesLocked(toUpdate, toDelete)
+	return changed
+}
+
+// GetResourceVersion 返回资源最近一次发生变化时 cache 的版本
+func (cache *LinearCache) GetResourceVersion(name string) (uint64, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	version, ok := cache.versionVector[name]
+	return version, ok
+}
+
+// UpdateEndpointWeight 只修改 cluster 中指定 endpoint 的权重并更新资源版本，不需要重新生成整个 ClusterLoadAssignment，
+// 返回 endpoint 的权重是否发生了变化
+func (cache *LinearCache) UpdateEndpointWeight(name, host string, port, weight uint32) (bool, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	res, ok := cache.resources[name]
+	if !ok {
+		return false, nil
+	}
+	cla, ok := res.(*endpointv3.ClusterLoadAssignment)
+	if !ok {
+		return false, fmt.Errorf("resource %s is not cluster load assignment", name)
+	}
+	// cache 中的资源可能已经被推送给订阅者，需要拷贝一份再修改
+	cla = proto.Clone(cla).(*endpointv3.ClusterLoadAssignment)
+	changed := false
+	for _, localityEndpoints := range cla.GetEndpoints() {
+		for _, ep := range localityEndpoints.GetLbEndpoints() {
+			address := ep.GetEndpoint().GetAddress().GetSocketAddress()
+			if address.GetAddress() != host || address.GetPortValue() != port {
+				continue
+			}
+			if ep.GetLoadBalancingWeight().GetValue() == weight {
+				continue
+			}
+			ep.LoadBalancingWeight = wrapperspb.UInt32(weight)
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	cache.updateResourcesLocked(map[string]types.Resource{name: cla}, nil)
+	return true, nil
+}
+
+func (cache *LinearCache) updateResourcesLocked(toUpdate map[string]types.Resource, toDelete []string) {
 	cache.version++
 
 	modified := make(map[string]struct{}, len(toUpdate)+len(toDelete))
@@ -267,7 +352,6 @@ func (cache *LinearCache) UpdateResources(toUpdate map[string]types.Resource, to
 	}
 
 	cache.notifyAll(cache.watchClients, modified)
-	return nil
 }
 
 // SetResources replaces current resources with a new set of resources.
@@ -29,6 +29,7 @@ import (
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/structpb"
 
@@ -94,7 +95,8 @@ func (cds *CDSBuilder) GenerateByDirection(option *resource.BuildOption,
 		if isGateway && selfServiceKey.Equal(&svcKey) {
 			return true
 		}
-		return false
+		// 节点不需要的服务也不下发 cluster，避免 cluster 一直等待不会下发的 EDS
+		return !option.IsRequestedService(svcKey)
 	}
 
 	services := option.Services
@@ -141,6 +143,15 @@ func (cds *CDSBuilder) GenerateByDirection(option *resource.BuildOption,
 			}
 		}
 		clusters = append(clusters, c)
+		// 网关路由声明了 SNI 时，为每个 SNI 单独下发 cluster
+		for _, name := range resource.MakeGatewaySNIServiceNames(svcKey, direction, option) {
+			sniCluster := proto.Clone(c).(*cluster.Cluster)
+			sniCluster.Name = name
+			if sniCluster.GetEdsClusterConfig() != nil {
+				sniCluster.EdsClusterConfig.ServiceName = name
+			}
+			clusters = append(clusters, sniCluster)
+		}
 	}
 	return clusters, nil
 }
@@ -150,6 +161,16 @@ func (cds *CDSBuilder) makeCluster(svcInfo *resource.ServiceInfo,
 
 	name := resource.MakeServiceName(svcInfo.ServiceKey, trafficDirection, opt)
 
+	// 直接转发到请求的原始目的地址，由 envoy 根据连接信息选择上游，不使用 EDS
+	if resource.IsPassthroughService(svcInfo, opt) {
+		return &cluster.Cluster{
+			Name:                 name,
+			ConnectTimeout:       durationpb.New(5 * time.Second),
+			ClusterDiscoveryType: &cluster.Cluster_Type{Type: cluster.Cluster_ORIGINAL_DST},
+			LbPolicy:             cluster.Cluster_CLUSTER_PROVIDED,
+		}
+	}
+
 	return &cluster.Cluster{
 		Name:                 name,
 		ConnectTimeout:       durationpb.New(5 * time.Second),
@@ -0,0 +1,141 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	apifault "github.com/polarismesh/specification/source/go/api/v1/fault_tolerance"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func newTestHealthCheckService(rules ...*apifault.FaultDetectRule) *resource.ServiceInfo {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	return &resource.ServiceInfo{
+		Name:        svcKey.Name,
+		Namespace:   svcKey.Namespace,
+		ServiceKey:  svcKey,
+		Instances:   []*apiservice.Instance{newTestEDSInstance("127.0.0.1", 8080, 100, nil)},
+		FaultDetect: &apifault.FaultDetector{Rules: rules},
+	}
+}
+
+func TestCDSBuilder_FaultDetectHealthCheck(t *testing.T) {
+	svcInfo := newTestHealthCheckService(&apifault.FaultDetectRule{
+		Name: "http-rule",
+		TargetService: &apifault.FaultDetectRule_DestinationService{
+			Namespace: "default",
+			Service:   "svc",
+		},
+		Interval: 10,
+		Timeout:  2,
+		Port:     9090,
+		Protocol: apifault.FaultDetectRule_HTTP,
+		HttpConfig: &apifault.HttpProtocolConfig{
+			Method: "post",
+			Url:    "/health",
+			Headers: []*apifault.HttpProtocolConfig_MessageHeader{
+				{Key: "x-probe", Value: "polaris"},
+			},
+			Body: "ping",
+		},
+	}, &apifault.FaultDetectRule{
+		// 其他服务的探测规则不生效
+		Name: "other-rule",
+		TargetService: &apifault.FaultDetectRule_DestinationService{
+			Namespace: "default",
+			Service:   "other",
+		},
+		Protocol:  apifault.FaultDetectRule_TCP,
+		TcpConfig: &apifault.TcpProtocolConfig{},
+	})
+	option := &resource.BuildOption{RunType: resource.RunTypeSidecar,
+		Services: map[model.ServiceKey]*resource.ServiceInfo{svcInfo.ServiceKey: svcInfo}}
+
+	c := (&CDSBuilder{}).makeCluster(svcInfo, core.TrafficDirection_OUTBOUND, option)
+	expect := []*core.HealthCheck{
+		{
+			Timeout:            durationpb.New(2 * time.Second),
+			Interval:           durationpb.New(10 * time.Second),
+			UnhealthyThreshold: utils.NewUInt32Value(3),
+			HealthyThreshold:   utils.NewUInt32Value(1),
+			HealthChecker: &core.HealthCheck_HttpHealthCheck_{
+				HttpHealthCheck: &core.HealthCheck_HttpHealthCheck{
+					Path:   "/health",
+					Method: core.RequestMethod_POST,
+					Send: &core.HealthCheck_Payload{
+						Payload: &core.HealthCheck_Payload_Text{Text: hex.EncodeToString([]byte("ping"))},
+					},
+					RequestHeadersToAdd: []*core.HeaderValueOption{
+						{Header: &core.HeaderValue{Key: "x-probe", Value: "polaris"}},
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(t, len(expect), len(c.GetHealthChecks()))
+	for i := range expect {
+		assert.True(t, proto.Equal(expect[i], c.GetHealthChecks()[i]), c.GetHealthChecks()[i].String())
+	}
+
+	// 探测规则指定的端口通过 EDS 下发给每个 endpoint
+	resources := (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+	assert.Equal(t, 1, len(resources))
+	ep := resources[0].(*endpoint.ClusterLoadAssignment).GetEndpoints()[0].GetLbEndpoints()[0]
+	assert.Equal(t, uint32(8080), ep.GetEndpoint().GetAddress().GetSocketAddress().GetPortValue())
+	assert.Equal(t, uint32(9090), ep.GetEndpoint().GetHealthCheckConfig().GetPortValue())
+}
+
+func TestCDSBuilder_FaultDetectHealthCheckDefault(t *testing.T) {
+	// 没有设置探测间隔、超时时间以及探测路径时使用默认值
+	svcInfo := newTestHealthCheckService(&apifault.FaultDetectRule{
+		Name:       "http-rule",
+		Protocol:   apifault.FaultDetectRule_HTTP,
+		HttpConfig: &apifault.HttpProtocolConfig{Body: "ping"},
+	})
+	option := &resource.BuildOption{RunType: resource.RunTypeSidecar,
+		Services: map[model.ServiceKey]*resource.ServiceInfo{svcInfo.ServiceKey: svcInfo}}
+
+	c := (&CDSBuilder{}).makeCluster(svcInfo, core.TrafficDirection_OUTBOUND, option)
+	expect := &core.HealthCheck{
+		Timeout:            durationpb.New(time.Second),
+		Interval:           durationpb.New(30 * time.Second),
+		UnhealthyThreshold: utils.NewUInt32Value(3),
+		HealthyThreshold:   utils.NewUInt32Value(1),
+		HealthChecker: &core.HealthCheck_HttpHealthCheck_{
+			HttpHealthCheck: &core.HealthCheck_HttpHealthCheck{Path: "/"},
+		},
+	}
+	assert.Equal(t, 1, len(c.GetHealthChecks()))
+	assert.True(t, proto.Equal(expect, c.GetHealthChecks()[0]), c.GetHealthChecks()[0].String())
+
+	resources := (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+	ep := resources[0].(*endpoint.ClusterLoadAssignment).GetEndpoints()[0].GetLbEndpoints()[0]
+	assert.Nil(t, ep.GetEndpoint().GetHealthCheckConfig())
+}
@@ -0,0 +1,69 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	// 注册 gzip 压缩算法
+	_ "google.golang.org/grpc/encoding/gzip"
+)
+
+// checkCompressor 配置的压缩算法必须已经注册到 grpc，为空时不压缩
+func checkCompressor(name string) error {
+	if name == "" {
+		return nil
+	}
+	if encoding.GetCompressor(name) == nil {
+		return fmt.Errorf("[XDSV3] unsupported compression %s", name)
+	}
+	return nil
+}
+
+// compressionStreamInterceptor 按照配置的算法压缩下发给 envoy 的 xDS 响应，只有 envoy 在 grpc-accept-encoding 中
+// 声明支持该算法时才压缩，不支持的 envoy 仍然收到不压缩的数据
+func compressionStreamInterceptor(name string) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		if acceptCompressor(ss, name) {
+			if err := grpc.SetSendCompressor(ss.Context(), name); err != nil {
+				log.Warn("[XDS][V3] set send compressor fail", zap.String("method", info.FullMethod),
+					zap.String("compression", name), zap.Error(err))
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// acceptCompressor 客户端是否声明支持指定的压缩算法
+func acceptCompressor(ss grpc.ServerStream, name string) bool {
+	supported, err := grpc.ClientSupportedCompressors(ss.Context())
+	if err != nil {
+		return false
+	}
+	for _, item := range supported {
+		if strings.TrimSpace(item) == name {
+			return true
+		}
+	}
+	return false
+}
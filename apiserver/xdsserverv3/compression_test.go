@@ -0,0 +1,190 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	discoverygrpc "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/stats"
+)
+
+// compressionRecorder 记录客户端收到的响应头中的压缩算法
+type compressionRecorder struct {
+	lock        sync.Mutex
+	compression []string
+}
+
+func (r *compressionRecorder) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if header, ok := s.(*stats.InHeader); ok {
+		r.lock.Lock()
+		r.compression = append(r.compression, header.Compression)
+		r.lock.Unlock()
+	}
+}
+
+func (r *compressionRecorder) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (r *compressionRecorder) HandleConn(context.Context, stats.ConnStats) {}
+
+func (r *compressionRecorder) received() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.compression...)
+}
+
+// startTestADSServer 启动只包含一个 EDS 资源的 ADS 服务，返回监听的地址
+func startTestADSServer(t *testing.T, compression string) string {
+	snapshotCache := cachev3.NewSnapshotCache(true, cachev3.IDHash{}, nil)
+	snapshot, err := cachev3.NewSnapshot("1", map[resourcev3.Type][]types.Resource{
+		resourcev3.EndpointType: {&endpoint.ClusterLoadAssignment{ClusterName: "svc"}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, snapshotCache.SetSnapshot(context.Background(), "node-1", snapshot))
+
+	var opts []grpc.ServerOption
+	if compression != "" {
+		assert.NoError(t, checkCompressor(compression))
+		opts = append(opts, grpc.StreamInterceptor(compressionStreamInterceptor(compression)))
+	}
+	grpcServer := grpc.NewServer(opts...)
+	discoverygrpc.RegisterAggregatedDiscoveryServiceServer(grpcServer,
+		serverv3.NewServer(context.Background(), snapshotCache, nil))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	return listener.Addr().String()
+}
+
+func newTestEDSRequest() *discoverygrpc.DiscoveryRequest {
+	return &discoverygrpc.DiscoveryRequest{
+		Node:          &core.Node{Id: "node-1"},
+		TypeUrl:       resourcev3.EndpointType,
+		ResourceNames: []string{"svc"},
+	}
+}
+
+// fetchTestEDS 使用 grpc 客户端订阅一次 EDS，grpc 客户端会在 grpc-accept-encoding 中声明支持 gzip，
+// 返回客户端收到的响应头中的压缩算法
+func fetchTestEDS(t *testing.T, address string) []string {
+	recorder := &compressionRecorder{}
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(recorder))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := discoverygrpc.NewAggregatedDiscoveryServiceClient(conn).StreamAggregatedResources(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(newTestEDSRequest()))
+	rsp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(rsp.GetResources()))
+	return recorder.received()
+}
+
+// fetchTestEDSWithoutCompression 模拟不支持压缩的 envoy，请求中不携带 grpc-accept-encoding，
+// 返回响应头中的压缩算法以及第一个消息的压缩标识
+func fetchTestEDSWithoutCompression(t *testing.T, address string) (string, byte) {
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+	msg, err := proto.Marshal(newTestEDSRequest())
+	assert.NoError(t, err)
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+	// 请求体保持打开，ADS 是双向流，关闭请求体会结束订阅
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	go func() {
+		_, _ = io.Copy(writer, bytes.NewReader(frame))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+address+
+		"/envoy.service.discovery.v3.AggregatedDiscoveryService/StreamAggregatedResources", reader)
+	assert.NoError(t, err)
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	rsp, err := client.Do(req)
+	assert.NoError(t, err)
+	defer rsp.Body.Close()
+
+	header := make([]byte, 5)
+	_, err = io.ReadFull(rsp.Body, header)
+	assert.NoError(t, err)
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	_, err = io.ReadFull(rsp.Body, payload)
+	assert.NoError(t, err)
+	discoveryRsp := &discoverygrpc.DiscoveryResponse{}
+	assert.NoError(t, proto.Unmarshal(payload, discoveryRsp))
+	assert.Equal(t, 1, len(discoveryRsp.GetResources()))
+	return rsp.Header.Get("grpc-encoding"), header[0]
+}
+
+func TestCompressionStreamInterceptor(t *testing.T) {
+	// 没有配置压缩时不压缩
+	assert.Equal(t, []string{""}, fetchTestEDS(t, startTestADSServer(t, "")))
+
+	// envoy 声明支持 gzip 时 EDS 响应使用 gzip 压缩
+	address := startTestADSServer(t, "gzip")
+	assert.Equal(t, []string{"gzip"}, fetchTestEDS(t, address))
+
+	// 不支持压缩的 envoy 仍然收到不压缩的数据
+	encoding, compressed := fetchTestEDSWithoutCompression(t, address)
+	assert.Equal(t, "", encoding)
+	assert.Equal(t, byte(0), compressed)
+
+	// 没有注册的压缩算法不能使用
+	assert.Error(t, checkCompressor("snappy"))
+}
@@ -49,6 +49,26 @@ func (x *XDSServer) listXDSNodes(resp http.ResponseWriter, req *http.Request) {
 	_, _ = resp.Write([]byte(ret))
 }
 
+// listXDSAckStatus 查询指定节点的资源确认结果，没有指定节点时返回所有最近一次推送被拒绝的节点
+func (x *XDSServer) listXDSAckStatus(resp http.ResponseWriter, req *http.Request) {
+	var status interface{}
+	if nodeId := req.URL.Query().Get("node"); nodeId != "" {
+		status = x.GetNodeAckStatus(nodeId)
+	} else if x.ackTracker != nil {
+		status = x.ackTracker.ListNackedNodes()
+	}
+
+	data := map[string]interface{}{
+		"code": apimodel.Code_ExecuteSuccess,
+		"info": "execute success",
+		"data": status,
+	}
+
+	ret := utils.MustJson(data)
+	resp.WriteHeader(http.StatusOK)
+	_, _ = resp.Write([]byte(ret))
+}
+
 func (x *XDSServer) listXDSResources(resp http.ResponseWriter, req *http.Request) {
 	resources := map[string]interface{}{}
 	x.cache.Caches.ReadRange(func(key string, val cachev3.Cache) {
@@ -0,0 +1,117 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	_struct "github.com/golang/protobuf/ptypes/struct"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+)
+
+// EndpointResolveFunc 在 endpoint 构建完成之后改写 endpoint 的地址，例如多集群网格中远端集群的实例需要经过
+// 东西向网关访问，无需改写时原样返回
+type EndpointResolveFunc func(ins *apiservice.Instance, ep *endpoint.LbEndpoint) *endpoint.LbEndpoint
+
+// EastWestGatewayConfig 东西向网关配置
+type EastWestGatewayConfig struct {
+	// LocalCluster 当前集群的名称，属于当前集群以及没有声明集群的实例不做改写
+	LocalCluster string
+	// Gateways 远端集群 -> 东西向网关地址，格式为 host:port
+	Gateways map[string]string
+}
+
+type eastWestGateway struct {
+	host string
+	port uint32
+}
+
+// newEastWestGatewayResolver 远端集群的实例改写为所在集群东西向网关的地址，实例原始的集群、地址以及端口放在
+// endpoint 的 filter metadata 中；没有配置网关的远端集群实例原样下发
+func newEastWestGatewayResolver(cfg *EastWestGatewayConfig) (EndpointResolveFunc, error) {
+	if cfg == nil || len(cfg.Gateways) == 0 {
+		return nil, nil
+	}
+	gateways := make(map[string]eastWestGateway, len(cfg.Gateways))
+	for cluster, address := range cfg.Gateways {
+		host, rawPort, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("[XDSV3] invalid east-west gateway address %s of cluster %s: %w",
+				address, cluster, err)
+		}
+		port, err := strconv.ParseUint(rawPort, 10, 16)
+		if err != nil || port == 0 || host == "" {
+			return nil, fmt.Errorf("[XDSV3] invalid east-west gateway address %s of cluster %s", address, cluster)
+		}
+		gateways[cluster] = eastWestGateway{host: host, port: uint32(port)}
+	}
+	localCluster := cfg.LocalCluster
+	return func(ins *apiservice.Instance, ep *endpoint.LbEndpoint) *endpoint.LbEndpoint {
+		cluster := ins.GetMetadata()[resource.EndpointClusterTag]
+		if cluster == "" || cluster == localCluster {
+			return ep
+		}
+		gateway, ok := gateways[cluster]
+		if !ok {
+			return ep
+		}
+		addr := ep.GetEndpoint().GetAddress().GetSocketAddress()
+		if ep.Metadata == nil {
+			ep.Metadata = &core.Metadata{}
+		}
+		if ep.Metadata.FilterMetadata == nil {
+			ep.Metadata.FilterMetadata = map[string]*_struct.Struct{}
+		}
+		ep.Metadata.FilterMetadata[resource.EndpointEastWestMetaKey] = &_struct.Struct{
+			Fields: map[string]*_struct.Value{
+				"cluster": {Kind: &_struct.Value_StringValue{StringValue: cluster}},
+				"address": {Kind: &_struct.Value_StringValue{StringValue: addr.GetAddress()}},
+				"port":    {Kind: &_struct.Value_NumberValue{NumberValue: float64(addr.GetPortValue())}},
+			},
+		}
+		ep.GetEndpoint().Address = makeSocketAddress(gateway.host, gateway.port)
+		// 主动探测的端口属于远端实例，经过网关无法探测
+		ep.GetEndpoint().HealthCheckConfig = nil
+		return ep
+	}, nil
+}
+
+// parseEastWestGatewayConfig 解析 xds 配置项 eastWestGateway，没有配置时返回 nil
+func parseEastWestGatewayConfig(raw map[interface{}]interface{}) (*EastWestGatewayConfig, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	cfg := &EastWestGatewayConfig{Gateways: map[string]string{}}
+	cfg.LocalCluster, _ = raw["localCluster"].(string)
+	gateways, _ := raw["gateways"].(map[interface{}]interface{})
+	for k, v := range gateways {
+		cluster, _ := k.(string)
+		address, _ := v.(string)
+		if cluster == "" || address == "" {
+			return nil, fmt.Errorf("[XDSV3] eastWestGateway contains invalid gateway %v: %v", k, v)
+		}
+		cfg.Gateways[cluster] = address
+	}
+	return cfg, nil
+}
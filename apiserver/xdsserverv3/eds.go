@@ -18,13 +18,20 @@
 package xdsserverv3
 
 import (
-	"strconv"
+	"hash/fnv"
+	"math"
+	"net/netip"
+	"sort"
 	"strings"
+	"time"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+	"github.com/polarismesh/specification/source/go/api/v1/traffic_manage"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
@@ -36,8 +43,42 @@ import (
 // EDSBuilder .
 type EDSBuilder struct {
 	svr service.DiscoverServer
+	// maxEndpoints 每个 cluster 最多下发的实例数，小于等于 0 时不做限制
+	maxEndpoints int
+	// sampleMode 实例数超过上限时的抽样方式，为空时使用 EndpointSampleTruncate
+	sampleMode string
+	// degradedWeightRatio 降级实例的权重缩放比例，取值 (0, 1]，未设置时使用 defaultDegradedWeightRatio
+	degradedWeightRatio float64
+	// heartbeatStaleThreshold 开启健康检查的实例超过该时间没有心跳时不再下发，小于等于 0 时不做检查
+	heartbeatStaleThreshold time.Duration
+	// lastHeartbeat 查询实例最近一次心跳的时间
+	lastHeartbeat LastHeartbeatFunc
+	// minHealthyPercent 健康实例占比低于该百分比时，心跳过期的实例以 UNHEALTHY 状态重新下发，小于等于 0 时不生效
+	minHealthyPercent float64
+	// addressTranslator 实例地址的转换器，为空时直接使用实例注册的地址
+	addressTranslator AddressTranslateFunc
+	// endpointMetadataKeys 允许下发到 endpoint envoy.lb metadata 中的实例标签，为空时下发全部标签
+	endpointMetadataKeys map[string]struct{}
+	// defaultWeight 没有设置权重的实例下发的权重，未设置时使用 defaultEndpointWeight
+	defaultWeight uint32
+	// weightPolicy 实例下发权重的计算方式，为空时使用 EndpointWeightStatic
+	weightPolicy string
+	// endpointResolver 改写 endpoint 的地址，例如远端集群的实例改写为东西向网关的地址，为空时不做改写
+	endpointResolver EndpointResolveFunc
 }
 
+// LastHeartbeatFunc 查询实例最近一次心跳的时间，没有心跳记录时返回 false
+type LastHeartbeatFunc func(ins *apiservice.Instance) (time.Time, bool)
+
+// defaultDegradedWeightRatio 降级实例默认只保留一半的权重
+const defaultDegradedWeightRatio = 0.5
+
+// defaultEndpointWeight 没有设置权重的实例默认下发的权重，与 sidecar inbound endpoint 的权重保持一致
+const defaultEndpointWeight uint32 = 100
+
+// localityPriorityLevels 地域优先级的级数，同 zone、同 region 以及其他
+const localityPriorityLevels uint32 = 3
+
 func (eds *EDSBuilder) Init(svr service.DiscoverServer) {
 	eds.svr = svr
 }
@@ -67,55 +108,440 @@ func (eds *EDSBuilder) makeBoundEndpoints(option *resource.BuildOption,
 	services := option.Services
 	selfServiceKey := option.SelfService
 	isGateway := option.RunType == resource.RunTypeGateway
+	var clientLocality *core.Locality
+	if option.Client != nil {
+		clientLocality = option.Client.GetLocality()
+	}
 
 	var clusterLoads []types.Resource
 	for svcKey, serviceInfo := range services {
 		if isGateway && selfServiceKey.Equal(&svcKey) {
 			continue
 		}
+		if !option.IsRequestedService(svcKey) {
+			continue
+		}
+		// 直接转发到原始目的地址的服务，cluster 为 ORIGINAL_DST 类型，不需要下发 endpoint
+		if resource.IsPassthroughService(serviceInfo, option) {
+			continue
+		}
 
-		var lbEndpoints []*endpoint.LbEndpoint
+		instances := make([]*apiservice.Instance, 0, len(serviceInfo.Instances))
+		var staleInstances map[*apiservice.Instance]struct{}
+		healthyCount := 0
 		for _, instance := range serviceInfo.Instances {
 			// 处于隔离状态或者权重为0的实例不进行下发
 			if !resource.IsNormalEndpoint(instance) {
 				continue
 			}
-			ep := &endpoint.LbEndpoint{
-				HostIdentifier: &endpoint.LbEndpoint_Endpoint{
-					Endpoint: &endpoint.Endpoint{
-						Address: &core.Address{
-							Address: &core.Address_SocketAddress{
-								SocketAddress: &core.SocketAddress{
-									Protocol: core.SocketAddress_TCP,
-									Address:  instance.Host.Value,
-									PortSpecifier: &core.SocketAddress_PortValue{
-										PortValue: instance.Port.Value,
-									},
-								},
-							},
-						},
-					},
-				},
-				HealthStatus:        resource.FormatEndpointHealth(instance),
-				LoadBalancingWeight: utils.NewUInt32Value(instance.GetWeight().GetValue()),
-				Metadata:            resource.GenEndpointMetaFromPolarisIns(instance),
+			// 运维按照实例标签排除的实例，例如正在摘流的实例
+			if !option.AcceptEndpoint(instance) {
+				continue
+			}
+			// 只注册了其他协议端口的实例不能处理该服务的流量，例如 HTTP 服务的 cluster 中不能包含只提供 gRPC 的实例
+			if !resource.MatchServiceProtocol(serviceInfo, instance) {
+				continue
+			}
+			// 长时间没有心跳的实例虽然状态还没有变化，但实际上已经不可用了
+			if eds.isStaleEndpoint(instance) {
+				if staleInstances == nil {
+					staleInstances = map[*apiservice.Instance]struct{}{}
+				}
+				staleInstances[instance] = struct{}{}
+				continue
+			}
+			if instance.GetHealthy().GetValue() {
+				healthyCount++
+			}
+			instances = append(instances, instance)
+		}
+		// 健康实例过少时，把被过滤的实例以 UNHEALTHY 状态重新下发，让 envoy 的 panic 模式有更多的实例可以选择，避免故障扩散
+		if len(staleInstances) != 0 && eds.belowMinHealthy(healthyCount, len(instances)+len(staleInstances)) {
+			for _, instance := range serviceInfo.Instances {
+				if _, ok := staleInstances[instance]; ok {
+					instances = append(instances, instance)
+				}
+			}
+		} else {
+			staleInstances = nil
+		}
+		var sampledWeights map[*apiservice.Instance]uint32
+		if eds.sampleMode == EndpointSampleWeighted {
+			instances, sampledWeights = sampleInstancesWeighted(instances, eds.maxEndpoints, sampleSeed(option),
+				eds.instanceWeight)
+		} else {
+			instances = sampleInstances(instances, eds.maxEndpoints)
+		}
+
+		var lbEndpoints []*endpoint.LbEndpoint
+		// 路由规则指定了目标分组时，按照分组的优先级计算每个 endpoint 的优先级，
+		// 客户端上报了自身的地域信息时，同一个优先级内再按照与客户端的距离计算。只有按照节点构建的 EDS 才有客户端信息，
+		// 见 buildNodeEndpoints，按照命名空间共享构建的 OUTBOUND EDS 以及网关的 EDS 不区分地域
+		var priorities map[*endpoint.LbEndpoint]uint32
+		routeDestinations := resource.FilterRouteDestinations(serviceInfo)
+		if clientLocality != nil || len(routeDestinations) != 0 {
+			priorities = map[*endpoint.LbEndpoint]uint32{}
+		}
+		var endpointInstances map[*endpoint.LbEndpoint]*apiservice.Instance
+		if eds.endpointResolver != nil {
+			endpointInstances = map[*endpoint.LbEndpoint]*apiservice.Instance{}
+		}
+		// 探测规则指定了端口时，envoy 主动探测使用该端口而不是流量端口
+		healthCheckPort := resource.GetHealthCheckPort(serviceInfo)
+		for _, instance := range instances {
+			// 实例暴露了多个端口时，只下发与服务端口匹配的一个端口，避免不同协议的端口混在同一个 cluster 中，
+			// 以及实例的权重按照端口数量被重复计算
+			ep := eds.makeInstanceEndpoint(instance, resource.GetEndpointPort(serviceInfo, instance))
+			if healthCheckPort != 0 {
+				ep.GetEndpoint().HealthCheckConfig = &endpoint.Endpoint_HealthCheckConfig{PortValue: healthCheckPort}
+			}
+			if weight, ok := sampledWeights[instance]; ok {
+				ep.LoadBalancingWeight = utils.NewUInt32Value(weight)
+			}
+			if eds.weightPolicy == EndpointWeightLoad {
+				scaleLoadWeight(ep, instance)
+			}
+			eds.scaleDegradedWeight(ep)
+			if _, ok := staleInstances[instance]; ok {
+				ep.HealthStatus = core.HealthStatus_UNHEALTHY
+			}
+			if priorities != nil {
+				priorities[ep] = endpointPriority(routeDestinations, clientLocality, instance)
+			}
+			if endpointInstances != nil {
+				endpointInstances[ep] = instance
 			}
 			lbEndpoints = append(lbEndpoints, ep)
 		}
+		// 注册异常时可能存在多个相同 host:port 的实例，只保留最健康、权重最高的一个，避免重复的 endpoint 影响负载均衡
+		lbEndpoints = dedupEndpoints(lbEndpoints)
+		// 去重之后再改写地址，同一个网关后面的多个远端实例仍然作为独立的 endpoint 参与负载均衡
+		lbEndpoints = eds.resolveEndpoints(lbEndpoints, endpointInstances, priorities)
+		// headless 服务没有注册任何实例时，使用服务 metadata 中声明的域名作为逻辑 DNS endpoint，
+		// 避免下发空的 ClusterLoadAssignment 导致 envoy 认为所有实例都不可用
+		if len(serviceInfo.Instances) == 0 {
+			if ep := makeDNSEndpoint(serviceInfo); ep != nil {
+				lbEndpoints = append(lbEndpoints, ep)
+			}
+		}
+		sortEndpoints(lbEndpoints)
+		normalizeEndpointWeights(lbEndpoints)
 
 		cla := &endpoint.ClusterLoadAssignment{
 			ClusterName: resource.MakeServiceName(svcKey, direction, option),
-			Endpoints: []*endpoint.LocalityLbEndpoints{
-				{
-					LbEndpoints: lbEndpoints,
-				},
-			},
+			Endpoints:   makeLocalityLbEndpoints(lbEndpoints, priorities),
 		}
 		clusterLoads = append(clusterLoads, cla)
+		// 网关路由声明了 SNI 时，路由使用带有 SNI 的 cluster，endpoint 与原始的 cluster 相同
+		for _, name := range resource.MakeGatewaySNIServiceNames(svcKey, direction, option) {
+			sniCla := proto.Clone(cla).(*endpoint.ClusterLoadAssignment)
+			sniCla.ClusterName = name
+			clusterLoads = append(clusterLoads, sniCla)
+		}
 	}
 	return clusterLoads
 }
 
+// endpointPriority 路由规则的优先级高于地域的优先级
+func endpointPriority(destinations []*traffic_manage.DestinationGroup, client *core.Locality,
+	ins *apiservice.Instance) uint32 {
+	var priority uint32
+	if len(destinations) != 0 {
+		priority = resource.RouteDestinationPriority(destinations, ins) * localityPriorityLevels
+	}
+	if client != nil {
+		priority += localityPriority(client, ins)
+	}
+	return priority
+}
+
+// localityPriority 计算实例相对于客户端的优先级，同 zone 为 0，同 region 为 1，其余的为 2
+func localityPriority(client *core.Locality, ins *apiservice.Instance) uint32 {
+	loc := ins.GetLocation()
+	if client.GetRegion() != "" && client.GetRegion() != loc.GetRegion().GetValue() {
+		return 2
+	}
+	if client.GetZone() != loc.GetZone().GetValue() {
+		return 1
+	}
+	return 0
+}
+
+// makeLocalityLbEndpoints 按照优先级将 endpoint 分组，envoy 要求优先级从 0 开始连续，因此需要对优先级重新编号，
+// 没有优先级信息时所有 endpoint 放在同一个分组中
+func makeLocalityLbEndpoints(lbEndpoints []*endpoint.LbEndpoint,
+	priorities map[*endpoint.LbEndpoint]uint32) []*endpoint.LocalityLbEndpoints {
+	if len(priorities) == 0 {
+		return []*endpoint.LocalityLbEndpoints{
+			{
+				LbEndpoints: lbEndpoints,
+			},
+		}
+	}
+	groups := map[uint32][]*endpoint.LbEndpoint{}
+	levels := make([]uint32, 0, 3)
+	for _, ep := range lbEndpoints {
+		priority := priorities[ep]
+		if _, ok := groups[priority]; !ok {
+			levels = append(levels, priority)
+		}
+		groups[priority] = append(groups[priority], ep)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i] < levels[j]
+	})
+	ret := make([]*endpoint.LocalityLbEndpoints, 0, len(levels))
+	for i, level := range levels {
+		ret = append(ret, &endpoint.LocalityLbEndpoints{
+			LbEndpoints: groups[level],
+			Priority:    uint32(i),
+		})
+	}
+	return ret
+}
+
+// belowMinHealthy 健康实例的占比是否低于 minHealthyPercent
+func (eds *EDSBuilder) belowMinHealthy(healthy, total int) bool {
+	if eds.minHealthyPercent <= 0 || total == 0 {
+		return false
+	}
+	return float64(healthy)*100 < eds.minHealthyPercent*float64(total)
+}
+
+func (eds *EDSBuilder) isStaleEndpoint(ins *apiservice.Instance) bool {
+	if eds.heartbeatStaleThreshold <= 0 || eds.lastHeartbeat == nil {
+		return false
+	}
+	if !ins.GetEnableHealthCheck().GetValue() {
+		return false
+	}
+	lastHeartbeat, ok := eds.lastHeartbeat(ins)
+	if !ok {
+		return false
+	}
+	return time.Since(lastHeartbeat) > eds.heartbeatStaleThreshold
+}
+
+// sampleInstances 实例数超过上限时，按照实例 ID 的哈希值稳定地选出固定的一批实例，保证多次推送之间不会抖动
+func sampleInstances(instances []*apiservice.Instance, maxCount int) []*apiservice.Instance {
+	if maxCount <= 0 || len(instances) <= maxCount {
+		return instances
+	}
+	type scoredInstance struct {
+		score    uint64
+		instance *apiservice.Instance
+	}
+	scored := make([]scoredInstance, 0, len(instances))
+	for _, instance := range instances {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(instance.GetId().GetValue()))
+		scored = append(scored, scoredInstance{score: h.Sum64(), instance: instance})
+	}
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score < scored[j].score
+		}
+		return scored[i].instance.GetId().GetValue() < scored[j].instance.GetId().GetValue()
+	})
+	ret := make([]*apiservice.Instance, 0, maxCount)
+	for i := 0; i < maxCount; i++ {
+		ret = append(ret, scored[i].instance)
+	}
+	return ret
+}
+
+// sortEndpoints 按照 (address, port) 对 endpoint 排序，保证实例没有变化时生成的资源完全一致，避免 envoy 无意义的更新
+func sortEndpoints(lbEndpoints []*endpoint.LbEndpoint) {
+	sort.Slice(lbEndpoints, func(i, j int) bool {
+		a := lbEndpoints[i].GetEndpoint().GetAddress().GetSocketAddress()
+		b := lbEndpoints[j].GetEndpoint().GetAddress().GetSocketAddress()
+		if a.GetAddress() != b.GetAddress() {
+			return a.GetAddress() < b.GetAddress()
+		}
+		return a.GetPortValue() < b.GetPortValue()
+	})
+}
+
+// resolveEndpoints 使用 endpointResolver 改写 endpoint，改写后的 endpoint 沿用原来的优先级
+func (eds *EDSBuilder) resolveEndpoints(lbEndpoints []*endpoint.LbEndpoint,
+	instances map[*endpoint.LbEndpoint]*apiservice.Instance,
+	priorities map[*endpoint.LbEndpoint]uint32) []*endpoint.LbEndpoint {
+	if eds.endpointResolver == nil {
+		return lbEndpoints
+	}
+	for i, ep := range lbEndpoints {
+		resolved := eds.endpointResolver(instances[ep], ep)
+		if resolved == nil || resolved == ep {
+			continue
+		}
+		if priority, ok := priorities[ep]; ok {
+			delete(priorities, ep)
+			priorities[resolved] = priority
+		}
+		lbEndpoints[i] = resolved
+	}
+	return lbEndpoints
+}
+
+// dedupEndpoints 按照 (address, port) 对 endpoint 去重，重复时保留健康状态更好的，健康状态相同时保留权重更高的
+func dedupEndpoints(lbEndpoints []*endpoint.LbEndpoint) []*endpoint.LbEndpoint {
+	if len(lbEndpoints) < 2 {
+		return lbEndpoints
+	}
+	type hostPort struct {
+		host string
+		port uint32
+	}
+	index := make(map[hostPort]int, len(lbEndpoints))
+	ret := lbEndpoints[:0]
+	for _, ep := range lbEndpoints {
+		addr := ep.GetEndpoint().GetAddress().GetSocketAddress()
+		key := hostPort{host: addr.GetAddress(), port: addr.GetPortValue()}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(ret)
+			ret = append(ret, ep)
+			continue
+		}
+		exist := ret[i]
+		if rank, existRank := endpointHealthRank(ep), endpointHealthRank(exist); rank < existRank ||
+			(rank == existRank && ep.GetLoadBalancingWeight().GetValue() > exist.GetLoadBalancingWeight().GetValue()) {
+			ret[i] = ep
+		}
+	}
+	return ret
+}
+
+// endpointHealthRank 数值越小表示 endpoint 的健康状态越好
+func endpointHealthRank(ep *endpoint.LbEndpoint) int {
+	switch ep.GetHealthStatus() {
+	case core.HealthStatus_HEALTHY:
+		return 0
+	case core.HealthStatus_DEGRADED:
+		return 1
+	case core.HealthStatus_DRAINING:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func (eds *EDSBuilder) makeInstanceEndpoint(instance *apiservice.Instance, port uint32) *endpoint.LbEndpoint {
+	host := instance.GetHost().GetValue()
+	if eds.addressTranslator != nil {
+		host, port = eds.addressTranslator(instance, host, port)
+	}
+	return &endpoint.LbEndpoint{
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				Address:  makeSocketAddress(host, port),
+				Hostname: resource.GetEndpointHostname(instance),
+			},
+		},
+		HealthStatus:        resource.FormatEndpointHealth(instance),
+		LoadBalancingWeight: utils.NewUInt32Value(eds.instanceWeight(instance)),
+		Metadata:            resource.GenEndpointMetaFromPolarisIns(instance, eds.endpointMetadataKeys),
+	}
+}
+
+// instanceWeight 实例下发的权重，没有设置权重时使用默认权重
+func (eds *EDSBuilder) instanceWeight(instance *apiservice.Instance) uint32 {
+	if instance.GetWeight() != nil {
+		return instance.GetWeight().GetValue()
+	}
+	if eds.defaultWeight > 0 {
+		return eds.defaultWeight
+	}
+	return defaultEndpointWeight
+}
+
+// makeDNSEndpoint 根据服务 metadata 中声明的域名构建 DNS endpoint，没有声明时返回 nil
+func makeDNSEndpoint(svc *resource.ServiceInfo) *endpoint.LbEndpoint {
+	fqdn, port, ok := resource.GetServiceDNSEndpoint(svc)
+	if !ok {
+		return nil
+	}
+	return &endpoint.LbEndpoint{
+		HostIdentifier: &endpoint.LbEndpoint_Endpoint{
+			Endpoint: &endpoint.Endpoint{
+				Address:  makeSocketAddress(fqdn, port),
+				Hostname: fqdn,
+			},
+		},
+		HealthStatus: core.HealthStatus_HEALTHY,
+	}
+}
+
+// selfEndpointAddress sidecar 场景下 inbound 流量转发给业务 POD 的地址
+const selfEndpointAddress = "127.0.0.1"
+
+func makeSocketAddress(host string, port uint32) *core.Address {
+	return &core.Address{
+		Address: &core.Address_SocketAddress{
+			SocketAddress: &core.SocketAddress{
+				Protocol: core.SocketAddress_TCP,
+				Address:  normalizeEndpointAddress(host),
+				PortSpecifier: &core.SocketAddress_PortValue{
+					PortValue: port,
+				},
+			},
+		},
+	}
+}
+
+// normalizeEndpointAddress envoy 要求 SocketAddress.Address 中的 IPv6 地址不能带有中括号，
+// IPv4-mapped 的 IPv6 地址还原为 IPv4 地址，其余的 IP 统一转换为标准格式，非 IP 的地址保持不变
+func normalizeEndpointAddress(host string) string {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	return addr.Unmap().String()
+}
+
+// scaleDegradedWeight 降级的实例按比例降低权重，让流量逐步迁移到健康实例上，而不是直接摘除
+func (eds *EDSBuilder) scaleDegradedWeight(ep *endpoint.LbEndpoint) {
+	if ep.GetHealthStatus() != core.HealthStatus_DEGRADED {
+		return
+	}
+	ratio := eds.degradedWeightRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultDegradedWeightRatio
+	}
+	weight := uint32(float64(ep.GetLoadBalancingWeight().GetValue()) * ratio)
+	// 降级的实例仍然可以提供服务，至少保留 1 的权重
+	if weight == 0 {
+		weight = 1
+	}
+	ep.LoadBalancingWeight = utils.NewUInt32Value(weight)
+}
+
+// maxLocalityWeightSum envoy 要求同一个 locality 下所有 endpoint 的权重之和不能超过 uint32 的最大值
+const maxLocalityWeightSum uint64 = math.MaxUint32
+
+// normalizeEndpointWeights 当权重之和超过 envoy 的限制时，按比例将权重缩放到安全范围内
+func normalizeEndpointWeights(lbEndpoints []*endpoint.LbEndpoint) {
+	var total uint64
+	for _, ep := range lbEndpoints {
+		total += uint64(ep.GetLoadBalancingWeight().GetValue())
+	}
+	if total <= maxLocalityWeightSum {
+		return
+	}
+	for _, ep := range lbEndpoints {
+		weight := uint64(ep.GetLoadBalancingWeight().GetValue()) * maxLocalityWeightSum / total
+		// 权重为 0 的实例已经被过滤，缩放后至少保留 1，避免实例被 envoy 摘除
+		if weight == 0 {
+			weight = 1
+		}
+		ep.LoadBalancingWeight = utils.NewUInt32Value(uint32(weight))
+	}
+}
+
 func (eds *EDSBuilder) makeSelfEndpoint(option *resource.BuildOption) []types.Resource {
 	var clusterLoads []types.Resource
 	var lbEndpoints []*endpoint.LbEndpoint
@@ -127,20 +553,11 @@ func (eds *EDSBuilder) makeSelfEndpoint(option *resource.BuildOption) []types.Re
 		servicePorts = selfServiceInfo.Ports
 	} else {
 		// sidecar 的服务没有注册，那就看下 envoy metadata 上有没有设置 sidecar_bindports 标签
-		portsSlice := strings.Split(option.Client.Metadata[resource.SidecarBindPort], ",")
-		if len(portsSlice) > 0 {
-			for i := range portsSlice {
-				ret, err := strconv.ParseUint(portsSlice[i], 10, 64)
-				if err != nil {
-					continue
-				}
-				if ret <= 65535 {
-					servicePorts = append(servicePorts, &model.ServicePort{
-						Port:     uint32(ret),
-						Protocol: "TCP",
-					})
-				}
-			}
+		for _, port := range resource.ParsePorts(option.Client.Metadata[resource.SidecarBindPort]) {
+			servicePorts = append(servicePorts, &model.ServicePort{
+				Port:     port,
+				Protocol: "TCP",
+			})
 		}
 	}
 
@@ -148,20 +565,10 @@ func (eds *EDSBuilder) makeSelfEndpoint(option *resource.BuildOption) []types.Re
 		ep := &endpoint.LbEndpoint{
 			HostIdentifier: &endpoint.LbEndpoint_Endpoint{
 				Endpoint: &endpoint.Endpoint{
-					Address: &core.Address{
-						Address: &core.Address_SocketAddress{
-							SocketAddress: &core.SocketAddress{
-								Protocol: core.SocketAddress_TCP,
-								Address:  "127.0.0.1",
-								PortSpecifier: &core.SocketAddress_PortValue{
-									PortValue: port.Port,
-								},
-							},
-						},
-					},
+					Address: makeSocketAddress(selfEndpointAddress, port.Port),
 				},
 			},
-			LoadBalancingWeight: wrapperspb.UInt32(100),
+			LoadBalancingWeight: wrapperspb.UInt32(defaultEndpointWeight),
 			HealthStatus:        core.HealthStatus_HEALTHY,
 		}
 		lbEndpoints = append(lbEndpoints, ep)
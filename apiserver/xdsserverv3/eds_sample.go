@@ -0,0 +1,143 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+)
+
+const (
+	// EndpointSampleTruncate 实例数超过上限时按照实例 ID 的哈希值截断
+	EndpointSampleTruncate = "truncate"
+	// EndpointSampleWeighted 实例数超过上限时按照实例权重做加权随机抽样
+	EndpointSampleWeighted = "weighted"
+)
+
+// sampleSeed 加权抽样的随机种子。按照节点构建的 EDS（见 buildNodeEndpoints）使用节点 ID，
+// 同一个 envoy 节点每次推送得到相同的实例子集，不同节点之间的子集不同；按照命名空间共享构建的 OUTBOUND EDS
+// 没有节点信息，使用命名空间作为种子，该命名空间下使用共享资源的所有节点得到同一个实例子集
+func sampleSeed(option *resource.BuildOption) string {
+	if option.Client != nil && option.Client.Node != nil {
+		return option.Client.Node.GetId()
+	}
+	return option.Namespace
+}
+
+// sampleInstancesWeighted 实例数超过上限时按照权重做不放回的系统抽样，每个实例被选中的概率与权重成正比，
+// 权重过大的实例一定会被选中。抽样后的实例权重重新归一为 weight / 入选概率，使得 envoy 按照权重负载均衡时，
+// 每个实例期望承担的流量比例与全量实例时保持一致，weightOf 返回实例的权重。返回抽样的实例以及需要覆盖的权重
+func sampleInstancesWeighted(instances []*apiservice.Instance, maxCount int, seed string,
+	weightOf func(*apiservice.Instance) uint32) ([]*apiservice.Instance, map[*apiservice.Instance]uint32) {
+	if maxCount <= 0 || len(instances) <= maxCount {
+		return instances, nil
+	}
+
+	// 按照种子以及实例 ID 的哈希值打乱顺序，保证同一个种子的抽样结果稳定
+	ordered := make([]*apiservice.Instance, len(instances))
+	copy(ordered, instances)
+	scores := make(map[*apiservice.Instance]uint64, len(ordered))
+	for _, instance := range ordered {
+		scores[instance] = sampleHash(seed, instance.GetId().GetValue())
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if scores[a] != scores[b] {
+			return scores[a] < scores[b]
+		}
+		return a.GetId().GetValue() < b.GetId().GetValue()
+	})
+
+	probs := inclusionProbabilities(ordered, maxCount, weightOf)
+
+	// 系统抽样：在累计概率上以固定间隔 1 取点，恰好选出 maxCount 个实例
+	start := float64(sampleHash(seed, "")>>11) / float64(1<<53)
+	ret := make([]*apiservice.Instance, 0, maxCount)
+	weights := make(map[*apiservice.Instance]uint32, maxCount)
+	var cumulative float64
+	next := start
+	for i, instance := range ordered {
+		cumulative += probs[i]
+		// 入选概率不超过 1，因此每个实例的累计概率区间内最多只有一个取样点
+		if next >= cumulative || len(ret) >= maxCount {
+			continue
+		}
+		next++
+		ret = append(ret, instance)
+		weight := math.Round(float64(weightOf(instance)) / probs[i])
+		weights[instance] = uint32(math.Max(1, math.Min(weight, math.MaxUint32)))
+	}
+	return ret, weights
+}
+
+// inclusionProbabilities 计算每个实例的入选概率，概率与权重成正比且总和为 maxCount，超过 1 的实例固定入选
+func inclusionProbabilities(instances []*apiservice.Instance, maxCount int,
+	weightOf func(*apiservice.Instance) uint32) []float64 {
+	probs := make([]float64, len(instances))
+	capped := make([]bool, len(instances))
+	remain := maxCount
+	for {
+		var total float64
+		for i, instance := range instances {
+			if !capped[i] {
+				total += float64(weightOf(instance))
+			}
+		}
+		if total <= 0 || remain <= 0 {
+			break
+		}
+		changed := false
+		for i, instance := range instances {
+			if capped[i] {
+				continue
+			}
+			probs[i] = float64(remain) * float64(weightOf(instance)) / total
+			if probs[i] >= 1 {
+				probs[i] = 1
+				capped[i] = true
+				remain--
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	if remain <= 0 {
+		// 固定入选的实例已经占满名额
+		for i := range probs {
+			if !capped[i] {
+				probs[i] = 0
+			}
+		}
+	}
+	return probs
+}
+
+func sampleHash(seed, key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(seed))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,1670 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/stream/v3"
+	"github.com/golang/protobuf/ptypes"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+	"github.com/polarismesh/specification/source/go/api/v1/traffic_manage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	xdscache "github.com/polarismesh/polaris/apiserver/xdsserverv3/cache"
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func newTestEDSInstance(host string, port uint32, weight uint32, metadata map[string]string) *apiservice.Instance {
+	return &apiservice.Instance{
+		Id:       utils.NewStringValue(host),
+		Host:     utils.NewStringValue(host),
+		Port:     utils.NewUInt32Value(port),
+		Weight:   utils.NewUInt32Value(weight),
+		Healthy:  utils.NewBoolValue(true),
+		Isolate:  utils.NewBoolValue(false),
+		Metadata: metadata,
+	}
+}
+
+func buildTestEDS(t *testing.T, instances ...*apiservice.Instance) *endpoint.ClusterLoadAssignment {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		RunType: resource.RunTypeSidecar,
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {
+				Name:       svcKey.Name,
+				Namespace:  svcKey.Namespace,
+				ServiceKey: svcKey,
+				Instances:  instances,
+			},
+		},
+	}
+	eds := &EDSBuilder{}
+	resources := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+	assert.Equal(t, 1, len(resources))
+	return resources[0].(*endpoint.ClusterLoadAssignment)
+}
+
+func TestEDSBuilder_EndpointHostname(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+			resource.EndpointHostnameTag: "svc.example.com",
+		}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, nil),
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 2, len(lbEndpoints))
+	hostnames := map[string]string{}
+	for _, ep := range lbEndpoints {
+		addr := ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+		hostnames[addr] = ep.GetEndpoint().GetHostname()
+	}
+	assert.Equal(t, "svc.example.com", hostnames["127.0.0.1"])
+	assert.Equal(t, "", hostnames["127.0.0.2"])
+}
+
+func TestEDSBuilder_NormalizeWeight(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 1<<31, nil),
+		newTestEDSInstance("127.0.0.2", 8080, 1<<31, nil),
+		newTestEDSInstance("127.0.0.3", 8080, 1<<30, nil),
+	)
+	weights := map[string]uint64{}
+	var total uint64
+	for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+		addr := ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+		weights[addr] = uint64(ep.GetLoadBalancingWeight().GetValue())
+		total += weights[addr]
+	}
+	assert.LessOrEqual(t, total, maxLocalityWeightSum)
+	// 缩放后仍然保持 2:2:1 的比例
+	assert.Equal(t, weights["127.0.0.1"], weights["127.0.0.2"])
+	assert.InDelta(t, 2.0, float64(weights["127.0.0.1"])/float64(weights["127.0.0.3"]), 0.0001)
+
+	// 未超过限制时保持原始权重
+	cla = buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, nil),
+		newTestEDSInstance("127.0.0.2", 8080, 50, nil),
+	)
+	for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+		addr := ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()
+		if addr == "127.0.0.1" {
+			assert.Equal(t, uint32(100), ep.GetLoadBalancingWeight().GetValue())
+		} else {
+			assert.Equal(t, uint32(50), ep.GetLoadBalancingWeight().GetValue())
+		}
+	}
+}
+
+func TestEDSBuilder_MultiPorts(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	build := func(servicePorts []*model.ServicePort) map[string][]uint32 {
+		option := &resource.BuildOption{
+			RunType: resource.RunTypeSidecar,
+			Services: map[model.ServiceKey]*resource.ServiceInfo{
+				svcKey: {
+					Name:       svcKey.Name,
+					Namespace:  svcKey.Namespace,
+					ServiceKey: svcKey,
+					Ports:      servicePorts,
+					Instances: []*apiservice.Instance{
+						newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+							resource.EndpointPortsTag: "8080,9090",
+						}),
+						newTestEDSInstance("127.0.0.2", 9090, 100, nil),
+					},
+				},
+			},
+		}
+		resources := (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+		assert.Equal(t, 1, len(resources))
+		ports := map[string][]uint32{}
+		for _, ep := range resources[0].(*endpoint.ClusterLoadAssignment).GetEndpoints()[0].GetLbEndpoints() {
+			socketAddr := ep.GetEndpoint().GetAddress().GetSocketAddress()
+			ports[socketAddr.GetAddress()] = append(ports[socketAddr.GetAddress()], socketAddr.GetPortValue())
+		}
+		return ports
+	}
+
+	// 多端口的实例只下发一个 endpoint，服务没有声明端口时使用实例本身的端口
+	assert.Equal(t, map[string][]uint32{"127.0.0.1": {8080}, "127.0.0.2": {9090}}, build(nil))
+	// 实例本身的端口不是服务端口时，使用声明的额外端口中与服务端口匹配的端口
+	assert.Equal(t, map[string][]uint32{"127.0.0.1": {9090}, "127.0.0.2": {9090}},
+		build([]*model.ServicePort{{Port: 9090, Protocol: "grpc"}}))
+}
+
+func TestEDSBuilder_MaxEndpoints(t *testing.T) {
+	instances := make([]*apiservice.Instance, 0, 1010)
+	for i := 0; i < 1000; i++ {
+		instances = append(instances, newTestEDSInstance(fmt.Sprintf("10.0.%d.%d", i/256, i%256), 8080, 100, nil))
+	}
+	// 隔离的实例不参与采样
+	for i := 0; i < 10; i++ {
+		ins := newTestEDSInstance(fmt.Sprintf("10.1.0.%d", i), 8080, 100, nil)
+		ins.Isolate = utils.NewBoolValue(true)
+		instances = append(instances, ins)
+	}
+
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	generate := func(instances []*apiservice.Instance) map[string]struct{} {
+		option := &resource.BuildOption{
+			Services: map[model.ServiceKey]*resource.ServiceInfo{
+				svcKey: {ServiceKey: svcKey, Instances: instances},
+			},
+		}
+		eds := &EDSBuilder{maxEndpoints: 100}
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		ret := map[string]struct{}{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = struct{}{}
+		}
+		return ret
+	}
+
+	first := generate(instances)
+	assert.Equal(t, 100, len(first))
+	for addr := range first {
+		assert.False(t, strings.HasPrefix(addr, "10.1."))
+	}
+	// 实例顺序发生变化时，选出的实例也保持不变
+	shuffled := make([]*apiservice.Instance, len(instances))
+	copy(shuffled, instances)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	assert.Equal(t, first, generate(shuffled))
+}
+
+func TestEDSBuilder_StableOutput(t *testing.T) {
+	instances := []*apiservice.Instance{
+		newTestEDSInstance("127.0.0.3", 8080, 100, nil),
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+			resource.EndpointPortsTag: "9090",
+		}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, nil),
+	}
+	reversed := make([]*apiservice.Instance, 0, len(instances))
+	for i := len(instances) - 1; i >= 0; i-- {
+		reversed = append(reversed, instances[i])
+	}
+
+	marshal := func(cla *endpoint.ClusterLoadAssignment) []byte {
+		data, err := proto.MarshalOptions{Deterministic: true}.Marshal(cla)
+		assert.NoError(t, err)
+		return data
+	}
+	first := buildTestEDS(t, instances...)
+	second := buildTestEDS(t, reversed...)
+	// 实例顺序不同，生成的 EDS 资源也完全一致
+	assert.Equal(t, marshal(first), marshal(second))
+
+	addrs := make([]string, 0, 4)
+	for _, ep := range first.GetEndpoints()[0].GetLbEndpoints() {
+		socketAddr := ep.GetEndpoint().GetAddress().GetSocketAddress()
+		addrs = append(addrs, fmt.Sprintf("%s:%d", socketAddr.GetAddress(), socketAddr.GetPortValue()))
+	}
+	assert.Equal(t, []string{"127.0.0.1:8080", "127.0.0.2:8080", "127.0.0.3:8080"}, addrs)
+}
+
+func TestEDSBuilder_DegradedWeight(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+			resource.EndpointHealthTag: resource.EndpointHealthDegraded,
+		}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, nil),
+		newTestEDSInstance("127.0.0.3", 8080, 1, map[string]string{
+			resource.EndpointHealthTag: resource.EndpointHealthDegraded,
+		}),
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 3, len(lbEndpoints))
+
+	// 降级实例按比例降低权重，健康实例保持原始权重
+	assert.Equal(t, core.HealthStatus_DEGRADED, lbEndpoints[0].GetHealthStatus())
+	assert.Equal(t, uint32(50), lbEndpoints[0].GetLoadBalancingWeight().GetValue())
+	assert.Equal(t, core.HealthStatus_HEALTHY, lbEndpoints[1].GetHealthStatus())
+	assert.Equal(t, uint32(100), lbEndpoints[1].GetLoadBalancingWeight().GetValue())
+	// 降级实例至少保留 1 的权重
+	assert.Equal(t, uint32(1), lbEndpoints[2].GetLoadBalancingWeight().GetValue())
+}
+
+func TestEDSBuilder_MaintenanceDraining(t *testing.T) {
+	unhealthy := newTestEDSInstance("127.0.0.3", 8080, 100, map[string]string{
+		resource.EndpointMaintenanceTag: "true",
+	})
+	unhealthy.Healthy = utils.NewBoolValue(false)
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+			resource.EndpointMaintenanceTag: "true",
+			resource.EndpointHealthTag:      resource.EndpointHealthDegraded,
+		}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, map[string]string{
+			resource.EndpointMaintenanceTag: "false",
+		}),
+		unhealthy,
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	// 维护中的实例仍然下发，状态为 DRAINING，权重不做降级处理
+	assert.Equal(t, 3, len(lbEndpoints))
+	assert.Equal(t, core.HealthStatus_DRAINING, lbEndpoints[0].GetHealthStatus())
+	assert.Equal(t, uint32(100), lbEndpoints[0].GetLoadBalancingWeight().GetValue())
+	assert.Equal(t, core.HealthStatus_HEALTHY, lbEndpoints[1].GetHealthStatus())
+	// 不健康的实例保持 UNHEALTHY
+	assert.Equal(t, core.HealthStatus_UNHEALTHY, lbEndpoints[2].GetHealthStatus())
+}
+
+func TestEDSBuilder_LocalityPriority(t *testing.T) {
+	newInstance := func(host, region, zone string) *apiservice.Instance {
+		ins := newTestEDSInstance(host, 8080, 100, nil)
+		ins.Location = &apimodel.Location{
+			Region: utils.NewStringValue(region),
+			Zone:   utils.NewStringValue(zone),
+		}
+		return ins
+	}
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	build := func(client *resource.XDSClient, instances ...*apiservice.Instance) *endpoint.ClusterLoadAssignment {
+		option := &resource.BuildOption{
+			RunType: resource.RunTypeSidecar,
+			Client:  client,
+			Services: map[model.ServiceKey]*resource.ServiceInfo{
+				svcKey: {
+					Name:       svcKey.Name,
+					Namespace:  svcKey.Namespace,
+					ServiceKey: svcKey,
+					Instances:  instances,
+				},
+			},
+		}
+		return (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+	}
+	hosts := func(localityEndpoints *endpoint.LocalityLbEndpoints) []string {
+		ret := make([]string, 0, len(localityEndpoints.GetLbEndpoints()))
+		for _, ep := range localityEndpoints.GetLbEndpoints() {
+			ret = append(ret, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return ret
+	}
+	client := &resource.XDSClient{Node: &core.Node{Locality: &core.Locality{Region: "r1", Zone: "zone-a"}}}
+
+	t.Run("同 zone 的实例优先", func(t *testing.T) {
+		cla := build(client,
+			newInstance("127.0.0.1", "r1", "zone-a"),
+			newInstance("127.0.0.2", "r1", "zone-b"),
+			newInstance("127.0.0.3", "r2", "zone-c"),
+			newInstance("127.0.0.4", "r1", "zone-a"),
+		)
+		assert.Equal(t, 3, len(cla.GetEndpoints()))
+		assert.Equal(t, uint32(0), cla.GetEndpoints()[0].GetPriority())
+		assert.Equal(t, []string{"127.0.0.1", "127.0.0.4"}, hosts(cla.GetEndpoints()[0]))
+		assert.Equal(t, uint32(1), cla.GetEndpoints()[1].GetPriority())
+		assert.Equal(t, []string{"127.0.0.2"}, hosts(cla.GetEndpoints()[1]))
+		assert.Equal(t, uint32(2), cla.GetEndpoints()[2].GetPriority())
+		assert.Equal(t, []string{"127.0.0.3"}, hosts(cla.GetEndpoints()[2]))
+	})
+
+	t.Run("优先级连续编号", func(t *testing.T) {
+		cla := build(client,
+			newInstance("127.0.0.1", "r2", "zone-c"),
+			newInstance("127.0.0.2", "r1", "zone-b"),
+		)
+		assert.Equal(t, 2, len(cla.GetEndpoints()))
+		assert.Equal(t, uint32(0), cla.GetEndpoints()[0].GetPriority())
+		assert.Equal(t, []string{"127.0.0.2"}, hosts(cla.GetEndpoints()[0]))
+		assert.Equal(t, uint32(1), cla.GetEndpoints()[1].GetPriority())
+	})
+
+	t.Run("客户端未上报地域信息", func(t *testing.T) {
+		instances := []*apiservice.Instance{
+			newInstance("127.0.0.1", "r1", "zone-a"),
+			newInstance("127.0.0.2", "r2", "zone-c"),
+		}
+		for _, c := range []*resource.XDSClient{nil, {Node: &core.Node{}}} {
+			cla := build(c, instances...)
+			assert.Equal(t, 1, len(cla.GetEndpoints()))
+			assert.Equal(t, uint32(0), cla.GetEndpoints()[0].GetPriority())
+			assert.Equal(t, 2, len(cla.GetEndpoints()[0].GetLbEndpoints()))
+		}
+	})
+}
+
+func TestEDSBuilder_HeartbeatStale(t *testing.T) {
+	stale := newTestEDSInstance("127.0.0.1", 8080, 100, nil)
+	stale.EnableHealthCheck = utils.NewBoolValue(true)
+	alive := newTestEDSInstance("127.0.0.2", 8080, 100, nil)
+	alive.EnableHealthCheck = utils.NewBoolValue(true)
+	// 没有开启健康检查的实例不做心跳检查
+	noCheck := newTestEDSInstance("127.0.0.3", 8080, 100, nil)
+	heartbeats := map[string]time.Time{
+		"127.0.0.1": time.Now().Add(-time.Hour),
+		"127.0.0.2": time.Now(),
+		"127.0.0.3": time.Now().Add(-time.Hour),
+	}
+
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	generate := func(eds *EDSBuilder) []string {
+		option := &resource.BuildOption{
+			Services: map[model.ServiceKey]*resource.ServiceInfo{
+				svcKey: {ServiceKey: svcKey, Instances: []*apiservice.Instance{stale, alive, noCheck}},
+			},
+		}
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		addrs := []string{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			addrs = append(addrs, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return addrs
+	}
+	lastHeartbeat := func(ins *apiservice.Instance) (time.Time, bool) {
+		val, ok := heartbeats[ins.GetId().GetValue()]
+		return val, ok
+	}
+
+	// 默认不做心跳检查
+	assert.Equal(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, generate(&EDSBuilder{lastHeartbeat: lastHeartbeat}))
+	assert.Equal(t, []string{"127.0.0.2", "127.0.0.3"}, generate(&EDSBuilder{
+		heartbeatStaleThreshold: time.Minute,
+		lastHeartbeat:           lastHeartbeat,
+	}))
+}
+
+func TestHeartbeatSnapshot(t *testing.T) {
+	stale := newTestEDSInstance("127.0.0.1", 8080, 100, nil)
+	stale.EnableHealthCheck = utils.NewBoolValue(true)
+	alive := newTestEDSInstance("127.0.0.2", 8080, 100, nil)
+	alive.EnableHealthCheck = utils.NewBoolValue(true)
+	noCheck := newTestEDSInstance("127.0.0.3", 8080, 100, nil)
+	for _, ins := range []*apiservice.Instance{stale, alive, noCheck} {
+		ins.Namespace = utils.NewStringValue("default")
+	}
+	heartbeats := map[string]time.Time{
+		"127.0.0.1": time.Now().Add(-time.Hour),
+		"127.0.0.2": time.Now(),
+	}
+	queried := map[string]int{}
+	snapshot := newHeartbeatSnapshot(func(instanceId string) (time.Time, bool) {
+		queried[instanceId]++
+		val, ok := heartbeats[instanceId]
+		return val, ok
+	})
+
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {ServiceKey: svcKey, Instances: []*apiservice.Instance{stale, alive, noCheck}},
+		},
+	}
+	snapshot.refresh(map[string]map[model.ServiceKey]*resource.ServiceInfo{"default": option.Services})
+	eds := &EDSBuilder{heartbeatStaleThreshold: time.Minute, lastHeartbeat: snapshot.get}
+	for i := 0; i < 3; i++ {
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		assert.Len(t, cla.GetEndpoints()[0].GetLbEndpoints(), 2)
+	}
+	// 多次构建 EDS 只在刷新快照时查询一次开启了健康检查的实例
+	assert.Equal(t, map[string]int{"127.0.0.1": 1, "127.0.0.2": 1}, queried)
+
+	// 只刷新本轮推送的命名空间，其他命名空间的快照保持不变
+	snapshot.refresh(map[string]map[model.ServiceKey]*resource.ServiceInfo{"other": {}})
+	_, ok := snapshot.get(stale)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]int{"127.0.0.1": 1, "127.0.0.2": 1}, queried)
+}
+
+func TestHealthCheckedNamespaces(t *testing.T) {
+	checked := newTestEDSInstance("127.0.0.1", 8080, 100, nil)
+	checked.EnableHealthCheck = utils.NewBoolValue(true)
+	noCheck := newTestEDSInstance("127.0.0.2", 8080, 100, nil)
+	checkedKey := model.ServiceKey{Namespace: "default", Name: "checked"}
+	noCheckKey := model.ServiceKey{Namespace: "other", Name: "no-check"}
+	registryInfo := map[string]map[model.ServiceKey]*resource.ServiceInfo{
+		"default": {
+			checkedKey: {ServiceKey: checkedKey, Instances: []*apiservice.Instance{checked}},
+		},
+		"other": {
+			noCheckKey: {ServiceKey: noCheckKey, Instances: []*apiservice.Instance{noCheck}},
+		},
+		"empty": {},
+	}
+	// 只有存在开启了健康检查的实例的命名空间需要定期重新推送，推送时使用命名空间下全部的服务
+	assert.Equal(t, map[string]map[model.ServiceKey]*resource.ServiceInfo{"default": registryInfo["default"]},
+		healthCheckedNamespaces(registryInfo))
+}
+
+func TestEDSBuilder_MinHealthyPercent(t *testing.T) {
+	heartbeats := map[string]time.Time{}
+	instances := make([]*apiservice.Instance, 0, 4)
+	for i := 1; i <= 4; i++ {
+		ins := newTestEDSInstance(fmt.Sprintf("127.0.0.%d", i), 8080, 100, nil)
+		ins.EnableHealthCheck = utils.NewBoolValue(true)
+		heartbeats[ins.GetId().GetValue()] = time.Now()
+		instances = append(instances, ins)
+	}
+	lastHeartbeat := func(ins *apiservice.Instance) (time.Time, bool) {
+		val, ok := heartbeats[ins.GetId().GetValue()]
+		return val, ok
+	}
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	generate := func(eds *EDSBuilder) map[string]core.HealthStatus {
+		option := &resource.BuildOption{
+			Services: map[model.ServiceKey]*resource.ServiceInfo{
+				svcKey: {ServiceKey: svcKey, Instances: instances},
+			},
+		}
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		ret := map[string]core.HealthStatus{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = ep.GetHealthStatus()
+		}
+		return ret
+	}
+	eds := &EDSBuilder{
+		heartbeatStaleThreshold: time.Minute,
+		lastHeartbeat:           lastHeartbeat,
+		minHealthyPercent:       50,
+	}
+
+	// 健康实例占比不低于阈值时，心跳过期的实例仍然被过滤
+	heartbeats["127.0.0.1"] = time.Now().Add(-time.Hour)
+	heartbeats["127.0.0.2"] = time.Now().Add(-time.Hour)
+	assert.Equal(t, map[string]core.HealthStatus{
+		"127.0.0.3": core.HealthStatus_HEALTHY,
+		"127.0.0.4": core.HealthStatus_HEALTHY,
+	}, generate(eds))
+
+	// 低于阈值时，被过滤的实例以 UNHEALTHY 状态重新下发
+	heartbeats["127.0.0.3"] = time.Now().Add(-time.Hour)
+	assert.Equal(t, map[string]core.HealthStatus{
+		"127.0.0.1": core.HealthStatus_UNHEALTHY,
+		"127.0.0.2": core.HealthStatus_UNHEALTHY,
+		"127.0.0.3": core.HealthStatus_UNHEALTHY,
+		"127.0.0.4": core.HealthStatus_HEALTHY,
+	}, generate(eds))
+
+	// 未开启时保持原有的过滤逻辑
+	eds.minHealthyPercent = 0
+	assert.Equal(t, map[string]core.HealthStatus{
+		"127.0.0.4": core.HealthStatus_HEALTHY,
+	}, generate(eds))
+}
+
+func TestEDSBuilder_MaxConnectionsHint(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+			resource.EndpointMaxConnectionsTag: "1024",
+		}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, nil),
+		newTestEDSInstance("127.0.0.3", 8080, 100, map[string]string{
+			resource.EndpointMaxConnectionsTag: "invalid",
+		}),
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 3, len(lbEndpoints))
+
+	hint := lbEndpoints[0].GetMetadata().GetFilterMetadata()[resource.EndpointCircuitBreakersMetaKey]
+	assert.NotNil(t, hint)
+	assert.Equal(t, float64(1024), hint.GetFields()[resource.EndpointMaxConnectionsMetaField].GetNumberValue())
+	// 未声明或者声明不合法时不下发
+	for _, ep := range lbEndpoints[1:] {
+		_, ok := ep.GetMetadata().GetFilterMetadata()[resource.EndpointCircuitBreakersMetaKey]
+		assert.False(t, ok)
+	}
+}
+
+func TestEDSBuilder_IPv6Address(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("[2001:db8::1]", 8080, 100, nil),
+		newTestEDSInstance("2001:0db8:0000:0000:0000:0000:0000:0002", 8080, 100, nil),
+		newTestEDSInstance("::ffff:10.0.0.1", 8080, 100, nil),
+		newTestEDSInstance(" 10.0.0.2 ", 8080, 100, nil),
+	)
+	var addresses []string
+	for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+		socketAddress := ep.GetEndpoint().GetAddress().GetSocketAddress()
+		assert.Equal(t, core.SocketAddress_TCP, socketAddress.GetProtocol())
+		// envoy 按照 IP 字面量解析地址，不能带有中括号
+		addr, err := netip.ParseAddr(socketAddress.GetAddress())
+		assert.NoError(t, err, socketAddress.GetAddress())
+		assert.Equal(t, addr.String(), socketAddress.GetAddress())
+		addresses = append(addresses, socketAddress.GetAddress())
+	}
+	assert.ElementsMatch(t, []string{"2001:db8::1", "2001:db8::2", "10.0.0.1", "10.0.0.2"}, addresses)
+}
+
+func Test_normalizeEndpointAddress(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1":          "127.0.0.1",
+		"[::1]":              "::1",
+		"::FFFF:192.168.0.1": "192.168.0.1",
+		"fe80::1%eth0":       "fe80::1%eth0",
+		"polaris.svc.local":  "polaris.svc.local",
+	}
+	for host, expect := range tests {
+		assert.Equal(t, expect, normalizeEndpointAddress(host), host)
+	}
+}
+
+func TestEDSBuilder_CanaryLabel(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{resource.EndpointCanaryTag: "true"}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, map[string]string{resource.EndpointCanaryTag: "v2"}),
+		newTestEDSInstance("127.0.0.3", 8080, 100, map[string]string{resource.EndpointCanaryTag: "false"}),
+		newTestEDSInstance("127.0.0.4", 8080, 100, map[string]string{"env": "prod"}),
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 4, len(lbEndpoints))
+
+	for i, ep := range lbEndpoints {
+		labels := ep.GetMetadata().GetFilterMetadata()["envoy.lb"].GetFields()
+		label, ok := labels[resource.EndpointCanaryTag]
+		if i < 2 {
+			// 灰度实例的标签取值原样下发，subset 可以按照灰度版本选择实例
+			assert.True(t, ok)
+			assert.Equal(t, []string{"true", "v2"}[i], label.GetStringValue())
+			continue
+		}
+		// 非灰度实例不携带灰度标签
+		assert.False(t, ok, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+	}
+	assert.Equal(t, "prod", lbEndpoints[3].GetMetadata().GetFilterMetadata()["envoy.lb"].GetFields()["env"].GetStringValue())
+}
+
+func TestEDSBuilder_TransportSocketMatch(t *testing.T) {
+	cla := buildTestEDS(t,
+		newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{resource.TLSModeTag: "strict"}),
+		newTestEDSInstance("127.0.0.2", 8080, 100, map[string]string{resource.TLSModeTag: "Permissive"}),
+		newTestEDSInstance("127.0.0.3", 8080, 100, map[string]string{resource.TLSModeTag: "none"}),
+		newTestEDSInstance("127.0.0.4", 8080, 100, nil),
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 4, len(lbEndpoints))
+
+	for i, ep := range lbEndpoints {
+		match, ok := ep.GetMetadata().GetFilterMetadata()[resource.TransportSocketMatchMetaKey]
+		if i < 2 {
+			// 与 permissive 模式下 cluster 的 tls-mode 匹配规则一致
+			assert.True(t, ok)
+			assert.Equal(t, "true", match.GetFields()["acceptMTLS"].GetStringValue())
+			assert.True(t, proto.Equal(resource.MTLSTransportSocketMatch, match))
+			continue
+		}
+		// 没有开启 mTLS 的实例不携带匹配标签，使用明文连接
+		assert.False(t, ok, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+	}
+}
+
+func TestEDSBuilder_EndpointFilter(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		RunType: resource.RunTypeSidecar,
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {
+				Name:       svcKey.Name,
+				Namespace:  svcKey.Namespace,
+				ServiceKey: svcKey,
+				Instances: []*apiservice.Instance{
+					newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{"drain": "true"}),
+					newTestEDSInstance("127.0.0.2", 8080, 100, map[string]string{"drain": "false"}),
+					newTestEDSInstance("127.0.0.3", 8080, 100, nil),
+				},
+			},
+		},
+	}
+	build := func() []string {
+		resources := (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+		assert.Equal(t, 1, len(resources))
+		var hosts []string
+		for _, ep := range resources[0].(*endpoint.ClusterLoadAssignment).GetEndpoints()[0].GetLbEndpoints() {
+			hosts = append(hosts, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return hosts
+	}
+
+	// 默认不做过滤
+	assert.ElementsMatch(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, build())
+	assert.Nil(t, resource.MetadataEndpointFilter(nil))
+
+	option.EndpointFilter = resource.MetadataEndpointFilter(map[string]string{"drain": "true"})
+	assert.ElementsMatch(t, []string{"127.0.0.2", "127.0.0.3"}, build())
+}
+
+func TestEDSBuilder_DedupEndpoints(t *testing.T) {
+	newInstance := func(id string, host string, weight uint32, healthy bool) *apiservice.Instance {
+		ins := newTestEDSInstance(host, 8080, weight, nil)
+		ins.Id = utils.NewStringValue(id)
+		ins.Healthy = utils.NewBoolValue(healthy)
+		return ins
+	}
+	cla := buildTestEDS(t,
+		newInstance("ins-1", "127.0.0.1", 50, true),
+		newInstance("ins-2", "127.0.0.1", 200, true),
+		// 权重更高但是不健康的重复实例不会被保留
+		newInstance("ins-3", "127.0.0.1", 300, false),
+		newInstance("ins-4", "127.0.0.2", 100, true),
+	)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 2, len(lbEndpoints))
+	assert.Equal(t, "127.0.0.1", lbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+	assert.Equal(t, uint32(200), lbEndpoints[0].GetLoadBalancingWeight().GetValue())
+	assert.Equal(t, core.HealthStatus_HEALTHY, lbEndpoints[0].GetHealthStatus())
+	assert.Equal(t, "127.0.0.2", lbEndpoints[1].GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+}
+
+func TestEDSBuilder_EastWestGateway(t *testing.T) {
+	resolver, err := newEastWestGatewayResolver(&EastWestGatewayConfig{
+		LocalCluster: "cluster-a",
+		Gateways:     map[string]string{"cluster-b": "10.0.0.100:15443"},
+	})
+	assert.NoError(t, err)
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		RunType: resource.RunTypeSidecar,
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {
+				Name:       svcKey.Name,
+				Namespace:  svcKey.Namespace,
+				ServiceKey: svcKey,
+				Instances: []*apiservice.Instance{
+					newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+						resource.EndpointClusterTag: "cluster-a",
+					}),
+					newTestEDSInstance("192.168.0.1", 8080, 100, map[string]string{
+						resource.EndpointClusterTag: "cluster-b",
+					}),
+				},
+			},
+		},
+	}
+	eds := &EDSBuilder{endpointResolver: resolver}
+	resources := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+	assert.Equal(t, 1, len(resources))
+	lbEndpoints := resources[0].(*endpoint.ClusterLoadAssignment).GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, 2, len(lbEndpoints))
+
+	addrs := map[string]*endpoint.LbEndpoint{}
+	for _, ep := range lbEndpoints {
+		addrs[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = ep
+	}
+	// 本集群的实例不做改写
+	local, ok := addrs["127.0.0.1"]
+	assert.True(t, ok)
+	assert.Equal(t, uint32(8080), local.GetEndpoint().GetAddress().GetSocketAddress().GetPortValue())
+	_, ok = local.GetMetadata().GetFilterMetadata()[resource.EndpointEastWestMetaKey]
+	assert.False(t, ok)
+
+	// 远端集群的实例改写为东西向网关的地址，并保留实例原始的信息
+	remote, ok := addrs["10.0.0.100"]
+	assert.True(t, ok)
+	assert.Equal(t, uint32(15443), remote.GetEndpoint().GetAddress().GetSocketAddress().GetPortValue())
+	identity := remote.GetMetadata().GetFilterMetadata()[resource.EndpointEastWestMetaKey].GetFields()
+	assert.Equal(t, "cluster-b", identity["cluster"].GetStringValue())
+	assert.Equal(t, "192.168.0.1", identity["address"].GetStringValue())
+	assert.Equal(t, float64(8080), identity["port"].GetNumberValue())
+
+	// 网关地址不合法时返回错误
+	_, err = newEastWestGatewayResolver(&EastWestGatewayConfig{Gateways: map[string]string{"cluster-b": "10.0.0.100"}})
+	assert.Error(t, err)
+}
+
+func TestEDSBuilder_HeadlessService(t *testing.T) {
+	build := func(svc *resource.ServiceInfo) []*endpoint.LbEndpoint {
+		svc.ServiceKey = model.ServiceKey{Namespace: "default", Name: "svc"}
+		option := &resource.BuildOption{
+			RunType:  resource.RunTypeSidecar,
+			Services: map[model.ServiceKey]*resource.ServiceInfo{svc.ServiceKey: svc},
+		}
+		resources := (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)
+		assert.Equal(t, 1, len(resources))
+		return resources[0].(*endpoint.ClusterLoadAssignment).GetEndpoints()[0].GetLbEndpoints()
+	}
+
+	// headless 服务没有实例时下发 DNS endpoint
+	lbEndpoints := build(&resource.ServiceInfo{
+		Metadata: map[string]string{
+			resource.ServiceDNSFqdnTag: "svc.default.svc.cluster.local",
+			resource.ServiceDNSPortTag: "8080",
+		},
+	})
+	assert.Equal(t, 1, len(lbEndpoints))
+	addr := lbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress()
+	assert.Equal(t, "svc.default.svc.cluster.local", addr.GetAddress())
+	assert.Equal(t, uint32(8080), addr.GetPortValue())
+	assert.Equal(t, "svc.default.svc.cluster.local", lbEndpoints[0].GetEndpoint().GetHostname())
+	assert.Equal(t, core.HealthStatus_HEALTHY, lbEndpoints[0].GetHealthStatus())
+
+	// 没有声明端口时使用服务的第一个端口
+	lbEndpoints = build(&resource.ServiceInfo{
+		Metadata: map[string]string{resource.ServiceDNSFqdnTag: "svc.default.svc.cluster.local"},
+		Ports:    []*model.ServicePort{{Port: 9090, Protocol: "tcp"}},
+	})
+	assert.Equal(t, 1, len(lbEndpoints))
+	assert.Equal(t, uint32(9090), lbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetPortValue())
+
+	// 有实例的服务保持原有逻辑
+	lbEndpoints = build(&resource.ServiceInfo{
+		Metadata: map[string]string{
+			resource.ServiceDNSFqdnTag: "svc.default.svc.cluster.local",
+			resource.ServiceDNSPortTag: "8080",
+		},
+		Instances: []*apiservice.Instance{newTestEDSInstance("127.0.0.1", 8080, 100, nil)},
+	})
+	assert.Equal(t, 1, len(lbEndpoints))
+	assert.Equal(t, "127.0.0.1", lbEndpoints[0].GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+
+	// 没有声明域名或者端口的服务仍然下发空的 endpoint 列表
+	assert.Empty(t, build(&resource.ServiceInfo{}))
+	assert.Empty(t, build(&resource.ServiceInfo{
+		Metadata: map[string]string{resource.ServiceDNSFqdnTag: "svc.default.svc.cluster.local"},
+	}))
+}
+
+func TestEDSBuilder_WeightedSampling(t *testing.T) {
+	instances := make([]*apiservice.Instance, 0, 40)
+	var totalWeight float64
+	for i := 0; i < 40; i++ {
+		weight := uint32(10 * (i%5 + 1))
+		if i == 0 {
+			// 权重特别大的实例一定会被选中
+			weight = 2000
+		}
+		instances = append(instances, newTestEDSInstance(fmt.Sprintf("10.0.0.%d", i), 8080, weight, nil))
+		totalWeight += float64(weight)
+	}
+
+	const maxCount, rounds = 10, 4000
+	share := map[*apiservice.Instance]float64{}
+	for round := 0; round < rounds; round++ {
+		sampled, weights := sampleInstancesWeighted(instances, maxCount, fmt.Sprintf("node-%d", round),
+			(&EDSBuilder{}).instanceWeight)
+		assert.Equal(t, maxCount, len(sampled))
+		assert.Equal(t, maxCount, len(weights))
+		assert.Contains(t, sampled, instances[0])
+		var sum float64
+		for _, instance := range sampled {
+			sum += float64(weights[instance])
+		}
+		for _, instance := range sampled {
+			share[instance] += float64(weights[instance]) / sum / rounds
+		}
+	}
+	// 多次抽样后每个实例期望承担的流量比例与全量实例时一致
+	for _, instance := range instances {
+		expect := float64(instance.GetWeight().GetValue()) / totalWeight
+		assert.InDelta(t, expect, share[instance], 0.15*expect+0.001, instance.GetId().GetValue())
+	}
+
+	// 同一个节点每次得到相同的实例子集
+	weightOf := (&EDSBuilder{}).instanceWeight
+	first, _ := sampleInstancesWeighted(instances, maxCount, "node-1", weightOf)
+	again, _ := sampleInstancesWeighted(instances, maxCount, "node-1", weightOf)
+	assert.Equal(t, first, again)
+	// 实例数没有超过上限时不做抽样，也不覆盖权重
+	all, weights := sampleInstancesWeighted(instances[:maxCount], maxCount, "node-1", weightOf)
+	assert.Equal(t, instances[:maxCount], all)
+	assert.Nil(t, weights)
+
+	// EDS 中下发抽样后重新归一的权重
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		Namespace: "default",
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {ServiceKey: svcKey, Instances: instances},
+		},
+		Client: &resource.XDSClient{Node: &core.Node{Id: "node-1"}},
+	}
+	eds := &EDSBuilder{maxEndpoints: maxCount, sampleMode: EndpointSampleWeighted}
+	cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+	lbEndpoints := cla.GetEndpoints()[0].GetLbEndpoints()
+	assert.Equal(t, maxCount, len(lbEndpoints))
+	var sum uint32
+	for _, ep := range lbEndpoints {
+		sum += ep.GetLoadBalancingWeight().GetValue()
+	}
+	assert.InDelta(t, totalWeight, float64(sum), float64(maxCount))
+
+	// 按照命名空间共享构建的 EDS 没有节点信息，以命名空间作为种子，所有使用共享资源的节点得到同一个实例子集
+	sampled := func(option *resource.BuildOption) []string {
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		hosts := make([]string, 0, maxCount)
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			hosts = append(hosts, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return hosts
+	}
+	shared := &resource.BuildOption{Namespace: "default", Services: option.Services}
+	assert.Equal(t, "default", sampleSeed(shared))
+	assert.Equal(t, sampled(shared), sampled(&resource.BuildOption{Namespace: "default", Services: option.Services}))
+	// 按照节点构建的 EDS 以节点 ID 作为种子，不同节点得到不同的实例子集
+	assert.Equal(t, "node-1", sampleSeed(option))
+	assert.NotEqual(t, sampled(option), sampled(&resource.BuildOption{
+		Namespace: "default",
+		Services:  option.Services,
+		Client:    &resource.XDSClient{Node: &core.Node{Id: "node-2"}},
+	}))
+}
+
+func TestEDSBuilder_GatewaySNIClusterName(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	svc := &resource.ServiceInfo{
+		Name:       svcKey.Name,
+		Namespace:  svcKey.Namespace,
+		ServiceKey: svcKey,
+		Instances:  []*apiservice.Instance{newTestEDSInstance("127.0.0.1", 8080, 100, nil)},
+	}
+	build := func(runType resource.RunType, snis map[model.ServiceKey][]string) ([]string, []string) {
+		option := &resource.BuildOption{
+			RunType:     runType,
+			Services:    map[model.ServiceKey]*resource.ServiceInfo{svcKey: svc},
+			GatewaySNIs: snis,
+		}
+		var claNames, clusterNames []string
+		for _, item := range (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND) {
+			claNames = append(claNames, item.(*endpoint.ClusterLoadAssignment).GetClusterName())
+		}
+		clusters, err := (&CDSBuilder{}).GenerateByDirection(option, core.TrafficDirection_OUTBOUND)
+		assert.NoError(t, err)
+		for _, item := range clusters {
+			c := item.(*cluster.Cluster)
+			clusterNames = append(clusterNames, c.GetName())
+			assert.Equal(t, c.GetName(), c.GetEdsClusterConfig().GetServiceName())
+		}
+		return claNames, clusterNames
+	}
+	snis := map[model.ServiceKey][]string{svcKey: {"a.example.com", "b.example.com"}}
+
+	// 网关路由声明了 SNI 时，每个 SNI 单独下发 cluster，并且 CDS 与 EDS 保持一致
+	claNames, clusterNames := build(resource.RunTypeGateway, snis)
+	expect := []string{"OUTBOUND|default|svc", "OUTBOUND|default|svc|a.example.com", "OUTBOUND|default|svc|b.example.com"}
+	assert.ElementsMatch(t, expect, claNames)
+	assert.ElementsMatch(t, expect, clusterNames)
+
+	// 没有声明 SNI 的网关保持原有命名
+	claNames, _ = build(resource.RunTypeGateway, nil)
+	assert.Equal(t, []string{"OUTBOUND|default|svc"}, claNames)
+
+	// sidecar 场景不受影响
+	claNames, clusterNames = build(resource.RunTypeSidecar, snis)
+	assert.Equal(t, []string{"OUTBOUND|default|svc"}, claNames)
+	assert.Equal(t, claNames, clusterNames)
+}
+
+func TestRDSBuilder_GatewayRouteSNI(t *testing.T) {
+	gateway := model.ServiceKey{Namespace: "default", Name: "gateway"}
+	newSubRule := func(service, sni string) *traffic_manage.SubRuleRouting {
+		arguments := []*traffic_manage.SourceMatch{{
+			Type:  traffic_manage.SourceMatch_PATH,
+			Value: &apimodel.MatchString{Type: apimodel.MatchString_EXACT, Value: utils.NewStringValue("/" + service)},
+		}}
+		if sni != "" {
+			arguments = append(arguments, &traffic_manage.SourceMatch{
+				Type:  traffic_manage.SourceMatch_HEADER,
+				Key:   resource.GatewaySNIHeader,
+				Value: &apimodel.MatchString{Type: apimodel.MatchString_EXACT, Value: utils.NewStringValue(sni)},
+			})
+		}
+		return &traffic_manage.SubRuleRouting{
+			Sources: []*traffic_manage.SourceService{{
+				Service: gateway.Name, Namespace: gateway.Namespace, Arguments: arguments,
+			}},
+			Destinations: []*traffic_manage.DestinationGroup{{
+				Service: service, Namespace: gateway.Namespace, Weight: 100,
+			}},
+		}
+	}
+	rules := []*model.ExtendRouterConfig{{
+		RouterConfig: &model.RouterConfig{Policy: traffic_manage.RoutingPolicy_RulePolicy.String()},
+		RuleRouting: &traffic_manage.RuleRoutingConfig{Rules: []*traffic_manage.SubRuleRouting{
+			newSubRule("svc-a", "a.example.com"),
+			newSubRule("svc-a", "b.example.com"),
+			newSubRule("svc-b", ""),
+		}},
+	}}
+
+	// SNI 按照路由声明，同一个服务在不同域名的路由下对应不同的 cluster
+	assert.Equal(t, map[model.ServiceKey][]string{
+		{Namespace: "default", Name: "svc-a"}: {"a.example.com", "b.example.com"},
+	}, collectGatewaySNIs(rules, gateway))
+
+	option := &resource.BuildOption{RunType: resource.RunTypeGateway}
+	sni := gatewaySubRuleSNI(rules[0].RuleRouting.Rules[0], gateway.Name, gateway.Namespace)
+	assert.Equal(t, "a.example.com", sni)
+	routeOption := *option
+	routeOption.GatewaySNI = sni
+	route := resource.MakeGatewayRoute(core.TrafficDirection_OUTBOUND, nil,
+		rules[0].RuleRouting.Rules[0].GetDestinations(), &routeOption)
+	assert.Equal(t, "OUTBOUND|default|svc-a|a.example.com",
+		route.GetRoute().GetWeightedClusters().GetClusters()[0].GetName())
+	// 没有声明 SNI 的路由使用原始的 cluster
+	assert.Equal(t, "", gatewaySubRuleSNI(rules[0].RuleRouting.Rules[2], gateway.Name, gateway.Namespace))
+}
+
+func TestEDSBuilder_GatewayPassthrough(t *testing.T) {
+	passthroughKey := model.ServiceKey{Namespace: "default", Name: "egress"}
+	regularKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	services := map[model.ServiceKey]*resource.ServiceInfo{
+		passthroughKey: {
+			Name:       passthroughKey.Name,
+			Namespace:  passthroughKey.Namespace,
+			ServiceKey: passthroughKey,
+			Metadata:   map[string]string{resource.ServicePassthroughTag: "true"},
+			Instances:  []*apiservice.Instance{newTestEDSInstance("127.0.0.1", 8080, 100, nil)},
+		},
+		regularKey: {
+			Name:       regularKey.Name,
+			Namespace:  regularKey.Namespace,
+			ServiceKey: regularKey,
+			Instances:  []*apiservice.Instance{newTestEDSInstance("127.0.0.2", 8080, 100, nil)},
+		},
+	}
+	build := func(runType resource.RunType) map[string]*endpoint.ClusterLoadAssignment {
+		option := &resource.BuildOption{RunType: runType, Services: services}
+		ret := map[string]*endpoint.ClusterLoadAssignment{}
+		for _, item := range (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND) {
+			cla := item.(*endpoint.ClusterLoadAssignment)
+			ret[cla.GetClusterName()] = cla
+		}
+		return ret
+	}
+
+	// 网关场景下直接转发的服务不下发 EDS，对应的 cluster 为 ORIGINAL_DST 类型
+	option := &resource.BuildOption{RunType: resource.RunTypeGateway, Services: services}
+	clas := build(resource.RunTypeGateway)
+	assert.Equal(t, 1, len(clas))
+	_, ok := clas["OUTBOUND|default|egress"]
+	assert.False(t, ok)
+	assert.Equal(t, 1, len(clas["OUTBOUND|default|svc"].GetEndpoints()[0].GetLbEndpoints()))
+
+	c := (&CDSBuilder{}).makeCluster(services[passthroughKey], core.TrafficDirection_OUTBOUND, option)
+	assert.Equal(t, "OUTBOUND|default|egress", c.GetName())
+	assert.Equal(t, cluster.Cluster_ORIGINAL_DST, c.GetType())
+	assert.Equal(t, cluster.Cluster_CLUSTER_PROVIDED, c.GetLbPolicy())
+	assert.Nil(t, c.GetEdsClusterConfig())
+	// 普通的服务不受影响
+	c = (&CDSBuilder{}).makeCluster(services[regularKey], core.TrafficDirection_OUTBOUND, option)
+	assert.Equal(t, cluster.Cluster_EDS, c.GetType())
+
+	// sidecar 场景不受影响
+	clas = build(resource.RunTypeSidecar)
+	assert.Equal(t, 2, len(clas))
+	assert.Equal(t, 1, len(clas["OUTBOUND|default|egress"].GetEndpoints()[0].GetLbEndpoints()))
+}
+
+func TestEDSBuilder_AddressTranslator(t *testing.T) {
+	// overlay 网络下把 POD IP 转换为实例 metadata 中记录的 Node IP + NodePort
+	RegisterAddressTranslator("test-nodeport", func(ins *apiservice.Instance, host string, port uint32) (string, uint32) {
+		nodeIP, ok := ins.GetMetadata()["node-ip"]
+		if !ok {
+			return host, port
+		}
+		nodePort, err := strconv.ParseUint(ins.GetMetadata()["node-port"], 10, 32)
+		if err != nil {
+			return host, port
+		}
+		return nodeIP, uint32(nodePort)
+	})
+	translator, err := getAddressTranslator("test-nodeport")
+	assert.NoError(t, err)
+	_, err = getAddressTranslator("not-exist")
+	assert.Error(t, err)
+
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {ServiceKey: svcKey, Instances: []*apiservice.Instance{
+				newTestEDSInstance("10.0.0.1", 8080, 100, map[string]string{
+					"node-ip":   "192.168.0.1",
+					"node-port": "30080",
+				}),
+				newTestEDSInstance("10.0.0.2", 8080, 100, nil),
+			}},
+		},
+	}
+	generate := func(eds *EDSBuilder) map[string]uint32 {
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		ret := map[string]uint32{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			addr := ep.GetEndpoint().GetAddress().GetSocketAddress()
+			ret[addr.GetAddress()] = addr.GetPortValue()
+		}
+		return ret
+	}
+
+	assert.Equal(t, map[string]uint32{"192.168.0.1": 30080, "10.0.0.2": 8080},
+		generate(&EDSBuilder{addressTranslator: translator}))
+	// 默认不做转换
+	assert.Equal(t, map[string]uint32{"10.0.0.1": 8080, "10.0.0.2": 8080}, generate(&EDSBuilder{}))
+}
+
+func TestEDSBuilder_EndpointMetadataKeys(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {ServiceKey: svcKey, Instances: []*apiservice.Instance{
+				newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{
+					"version":       "v1",
+					"env":           "prod",
+					"internal-team": "infra",
+				}),
+			}},
+		},
+	}
+	generate := func(eds *EDSBuilder) []string {
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		fields := cla.GetEndpoints()[0].GetLbEndpoints()[0].GetMetadata().GetFilterMetadata()["envoy.lb"].GetFields()
+		keys := make([]string, 0, len(fields))
+		for k := range fields {
+			keys = append(keys, k)
+		}
+		return keys
+	}
+
+	// 只下发白名单中的标签
+	assert.ElementsMatch(t, []string{"version", "env"}, generate(&EDSBuilder{
+		endpointMetadataKeys: map[string]struct{}{"version": {}, "env": {}, "not-exist": {}},
+	}))
+	// 未设置白名单时下发全部标签
+	assert.ElementsMatch(t, []string{"version", "env", "internal-team"}, generate(&EDSBuilder{}))
+}
+
+func TestEDSBuilder_DeltaUpdate(t *testing.T) {
+	newInstance := func(host, zone string) *apiservice.Instance {
+		ins := newTestEDSInstance(host, 8080, 100, nil)
+		ins.Location = &apimodel.Location{Region: utils.NewStringValue("r1"), Zone: utils.NewStringValue(zone)}
+		return ins
+	}
+	svcA := model.ServiceKey{Namespace: "default", Name: "svc-a"}
+	svcB := model.ServiceKey{Namespace: "default", Name: "svc-b"}
+	services := map[model.ServiceKey]*resource.ServiceInfo{
+		svcA: {Name: svcA.Name, Namespace: svcA.Namespace, ServiceKey: svcA, Instances: []*apiservice.Instance{
+			newInstance("127.0.0.1", "zone-a"), newInstance("127.0.0.2", "zone-b"),
+		}},
+		svcB: {Name: svcB.Name, Namespace: svcB.Namespace, ServiceKey: svcB, Instances: []*apiservice.Instance{
+			newInstance("127.0.0.3", "zone-a"),
+		}},
+	}
+	option := &resource.BuildOption{
+		RunType:  resource.RunTypeSidecar,
+		Client:   &resource.XDSClient{Node: &core.Node{Locality: &core.Locality{Region: "r1", Zone: "zone-a"}}},
+		Services: services,
+	}
+	nameA := resource.MakeServiceName(svcA, core.TrafficDirection_OUTBOUND, option)
+	nameB := resource.MakeServiceName(svcB, core.TrafficDirection_OUTBOUND, option)
+	linearCache := xdscache.NewLinearCache(resourcev3.EndpointType)
+	sync := func() map[string]*endpoint.ClusterLoadAssignment {
+		linearCache.SyncResources(cachev3.IndexRawResourcesByName(
+			(&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)))
+		ret := map[string]*endpoint.ClusterLoadAssignment{}
+		for name, res := range linearCache.GetResources() {
+			ret[name] = res.(*endpoint.ClusterLoadAssignment)
+		}
+		return ret
+	}
+
+	state := stream.NewStreamState(true, map[string]string{})
+	request := &cachev3.DeltaRequest{Node: &core.Node{Id: "node-1"}, TypeUrl: resourcev3.EndpointType}
+	// watch 等待下一次变化的增量推送
+	watch := func() *cachev3.RawDeltaResponse {
+		respCh := make(chan cachev3.DeltaResponse, 1)
+		linearCache.CreateDeltaWatch(request, state, respCh)
+		select {
+		case rsp := <-respCh:
+			delta := rsp.(*cachev3.RawDeltaResponse)
+			state.SetResourceVersions(delta.NextVersionMap)
+			return delta
+		default:
+			return nil
+		}
+	}
+	names := func(resources []types.Resource) []string {
+		return cachev3.GetResourceNames(resources)
+	}
+
+	clusters := sync()
+	assert.Equal(t, 2, len(clusters))
+	assert.ElementsMatch(t, []string{nameA, nameB}, names(watch().Resources))
+	versionB, _ := linearCache.GetResourceVersion(nameB)
+
+	// 没有任何变化时不推送
+	sync()
+	assert.Nil(t, watch())
+
+	// 实例切换了 zone，只推送受影响的 cluster，并且 endpoint 按照新的 locality 重新分组
+	services[svcA].Instances = []*apiservice.Instance{newInstance("127.0.0.1", "zone-a"), newInstance("127.0.0.2", "zone-a")}
+	clusters = sync()
+	delta := watch()
+	assert.Equal(t, []string{nameA}, names(delta.Resources))
+	assert.Empty(t, delta.RemovedResources)
+	cla := delta.Resources[0].(*endpoint.ClusterLoadAssignment)
+	assert.Equal(t, nameA, cla.GetClusterName())
+	assert.Equal(t, 1, len(cla.GetEndpoints()))
+	assert.Equal(t, 2, len(cla.GetEndpoints()[0].GetLbEndpoints()))
+	assert.True(t, proto.Equal(clusters[nameA], cla))
+	// 未变化的 cluster 版本保持不变
+	version, _ := linearCache.GetResourceVersion(nameB)
+	assert.Equal(t, versionB, version)
+
+	// 服务下线后只通知删除该 cluster
+	delete(services, svcB)
+	sync()
+	delta = watch()
+	assert.Empty(t, delta.Resources)
+	assert.Equal(t, []string{nameB}, delta.RemovedResources)
+}
+
+func TestXdsResourceGenerator_UpdateEndpointWeight(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc-a"}
+	option := &resource.BuildOption{
+		RunType: resource.RunTypeSidecar,
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {Name: svcKey.Name, Namespace: svcKey.Namespace, ServiceKey: svcKey, Instances: []*apiservice.Instance{
+				newTestEDSInstance("127.0.0.1", 8080, 100, nil), newTestEDSInstance("127.0.0.2", 8080, 100, nil),
+			}},
+		},
+	}
+	cacheKey := resourcev3.EndpointType + "~" + svcKey.Namespace
+	clusterName := resource.MakeServiceName(svcKey, core.TrafficDirection_OUTBOUND, option)
+	x := &XdsResourceGenerator{cache: xdscache.NewCache(nil)}
+	assert.NoError(t, x.cache.DeltaUpdateResource(cacheKey, resourcev3.EndpointType, cachev3.IndexRawResourcesByName(
+		(&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND))))
+	val, _ := x.cache.Caches.Load(cacheKey)
+	linearCache := val.(*xdscache.LinearCache)
+	before := linearCache.GetResources()[clusterName].(*endpoint.ClusterLoadAssignment)
+	version, _ := linearCache.GetResourceVersion(clusterName)
+
+	weights := func(cla *endpoint.ClusterLoadAssignment) map[string]uint32 {
+		ret := map[string]uint32{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = ep.GetLoadBalancingWeight().GetValue()
+		}
+		return ret
+	}
+
+	assert.True(t, x.UpdateEndpointWeight(svcKey, "127.0.0.2", 8080, 20))
+	after := linearCache.GetResources()[clusterName].(*endpoint.ClusterLoadAssignment)
+	assert.Equal(t, map[string]uint32{"127.0.0.1": 100, "127.0.0.2": 20}, weights(after))
+	newVersion, _ := linearCache.GetResourceVersion(clusterName)
+	assert.Greater(t, newVersion, version)
+	// 已经推送出去的资源不能被修改
+	assert.Equal(t, map[string]uint32{"127.0.0.1": 100, "127.0.0.2": 100}, weights(before))
+
+	// 权重没有变化或者 endpoint 不存在时不更新版本
+	assert.False(t, x.UpdateEndpointWeight(svcKey, "127.0.0.2", 8080, 20))
+	assert.False(t, x.UpdateEndpointWeight(svcKey, "127.0.0.3", 8080, 20))
+	assert.False(t, x.UpdateEndpointWeight(model.ServiceKey{Namespace: "other", Name: "svc-a"}, "127.0.0.1", 8080, 20))
+	version, _ = linearCache.GetResourceVersion(clusterName)
+	assert.Equal(t, newVersion, version)
+}
+
+func TestXdsResourceGenerator_UpdateInstanceWeights(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc-a"}
+	newServices := func(weight uint32, healthy bool) map[model.ServiceKey]*resource.ServiceInfo {
+		changed := newTestEDSInstance("127.0.0.2", 8080, weight, nil)
+		changed.Healthy = utils.NewBoolValue(healthy)
+		changed.Revision = utils.NewStringValue(fmt.Sprintf("rev-%d-%t", weight, healthy))
+		return map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {Name: svcKey.Name, Namespace: svcKey.Namespace, ServiceKey: svcKey,
+				SvcInsRevision: changed.GetRevision().GetValue(), Instances: []*apiservice.Instance{
+					newTestEDSInstance("127.0.0.1", 8080, 100, nil), changed,
+				}},
+		}
+	}
+	cacheKey := resourcev3.EndpointType + "~" + svcKey.Namespace
+	option := &resource.BuildOption{RunType: resource.RunTypeSidecar, Namespace: svcKey.Namespace}
+	clusterName := resource.MakeServiceName(svcKey, core.TrafficDirection_OUTBOUND, option)
+	x := &XdsResourceGenerator{cache: xdscache.NewCache(nil)}
+	prev := newServices(100, true)
+	option.Services = prev
+	assert.NoError(t, x.cache.DeltaUpdateResource(cacheKey, resourcev3.EndpointType, cachev3.IndexRawResourcesByName(
+		x.newEDSBuilder().makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND))))
+	val, _ := x.cache.Caches.Load(cacheKey)
+	linearCache := val.(*xdscache.LinearCache)
+	version, _ := linearCache.GetResourceVersion(clusterName)
+
+	// 只调整了一个实例的权重，只更新该 endpoint 的权重，并且结果与重新构建一致
+	cur := newServices(20, true)
+	changes, ok := diffInstanceWeights(cur, prev)
+	assert.True(t, ok)
+	assert.Len(t, changes[svcKey].instances, 1)
+	assert.True(t, x.updateInstanceWeights(changes))
+	newVersion, _ := linearCache.GetResourceVersion(clusterName)
+	assert.Greater(t, newVersion, version)
+	option.Services = cur
+	rebuild := x.newEDSBuilder().makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0]
+	assert.True(t, proto.Equal(rebuild.(*endpoint.ClusterLoadAssignment), linearCache.GetResources()[clusterName]))
+
+	// 权重变为 0 时实例会被摘除，以及实例的其他信息发生变化时，都需要重新构建
+	_, ok = diffInstanceWeights(newServices(0, true), cur)
+	assert.False(t, ok)
+	_, ok = diffInstanceWeights(newServices(20, false), cur)
+	assert.False(t, ok)
+
+	// 开启了实例抽样时权重会影响抽样结果，不能只更新权重
+	x.endpointSampleMode = EndpointSampleWeighted
+	changes, ok = diffInstanceWeights(newServices(50, true), cur)
+	assert.True(t, ok)
+	assert.False(t, x.updateInstanceWeights(changes))
+}
+
+func TestEDSBuilder_DefaultWeight(t *testing.T) {
+	unset := newTestEDSInstance("127.0.0.1", 8080, 0, nil)
+	unset.Weight = nil
+	zero := newTestEDSInstance("127.0.0.2", 8080, 0, nil)
+	weighted := newTestEDSInstance("127.0.0.3", 8080, 50, nil)
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {ServiceKey: svcKey, Instances: []*apiservice.Instance{unset, zero, weighted}},
+		},
+	}
+	generate := func(eds *EDSBuilder) map[string]uint32 {
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		ret := map[string]uint32{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = ep.GetLoadBalancingWeight().GetValue()
+		}
+		return ret
+	}
+
+	// 没有设置权重的实例使用默认权重，显式设置为 0 的实例仍然不下发
+	assert.Equal(t, map[string]uint32{"127.0.0.1": defaultEndpointWeight, "127.0.0.3": 50}, generate(&EDSBuilder{}))
+	assert.Equal(t, map[string]uint32{"127.0.0.1": 10, "127.0.0.3": 50}, generate(&EDSBuilder{defaultWeight: 10}))
+}
+
+func TestEDSBuilder_LoadWeight(t *testing.T) {
+	busy := newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{resource.EndpointLoadTag: "80"})
+	idle := newTestEDSInstance("127.0.0.2", 8080, 100, map[string]string{resource.EndpointLoadTag: "20"})
+	full := newTestEDSInstance("127.0.0.3", 8080, 100, map[string]string{resource.EndpointLoadTag: "120"})
+	unknown := newTestEDSInstance("127.0.0.4", 8080, 100, map[string]string{resource.EndpointLoadTag: "high"})
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	option := &resource.BuildOption{
+		Services: map[model.ServiceKey]*resource.ServiceInfo{
+			svcKey: {ServiceKey: svcKey, Instances: []*apiservice.Instance{busy, idle, full, unknown}},
+		},
+	}
+	generate := func(eds *EDSBuilder) map[string]uint32 {
+		cla := eds.makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		ret := map[string]uint32{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret[ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress()] = ep.GetLoadBalancingWeight().GetValue()
+		}
+		return ret
+	}
+
+	// 默认使用实例配置的权重
+	assert.Equal(t, map[string]uint32{"127.0.0.1": 100, "127.0.0.2": 100, "127.0.0.3": 100, "127.0.0.4": 100},
+		generate(&EDSBuilder{}))
+	// 负载低的实例分到更高的权重，满载的实例至少保留 1 的权重，负载无法解析时保持配置的权重
+	weights := generate(&EDSBuilder{weightPolicy: EndpointWeightLoad})
+	assert.Greater(t, weights["127.0.0.2"], weights["127.0.0.1"])
+	assert.Equal(t, map[string]uint32{"127.0.0.1": 20, "127.0.0.2": 80, "127.0.0.3": 1, "127.0.0.4": 100}, weights)
+}
+
+func TestEDSBuilder_ProtocolMatch(t *testing.T) {
+	httpIns := newTestEDSInstance("127.0.0.1", 8080, 100, nil)
+	httpIns.Protocol = utils.NewStringValue("http")
+	grpcIns := newTestEDSInstance("127.0.0.2", 9090, 100, nil)
+	grpcIns.Protocol = utils.NewStringValue("grpc")
+	unknownIns := newTestEDSInstance("127.0.0.3", 8080, 100, nil)
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	svc := &resource.ServiceInfo{ServiceKey: svcKey, Instances: []*apiservice.Instance{httpIns, grpcIns, unknownIns}}
+	option := &resource.BuildOption{
+		Services: map[model.ServiceKey]*resource.ServiceInfo{svcKey: svc},
+	}
+	generate := func() []string {
+		cla := (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+		ret := []string{}
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret = append(ret, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return ret
+	}
+
+	// 服务没有声明协议时下发全部实例
+	assert.ElementsMatch(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, generate())
+	// HTTP 服务不下发只提供 gRPC 的实例，没有声明协议的实例仍然下发
+	svc.Metadata = map[string]string{resource.ServiceProtocolTag: "HTTP"}
+	assert.ElementsMatch(t, []string{"127.0.0.1", "127.0.0.3"}, generate())
+	svc.Metadata = map[string]string{resource.ServiceProtocolTag: "http, grpc"}
+	assert.ElementsMatch(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, generate())
+}
+
+func TestEDSBuilder_RequestedServices(t *testing.T) {
+	services := map[model.ServiceKey]*resource.ServiceInfo{}
+	for i := 0; i < 5; i++ {
+		svcKey := model.ServiceKey{Namespace: "default", Name: fmt.Sprintf("svc-%d", i)}
+		if i == 4 {
+			svcKey.Namespace = "other"
+		}
+		services[svcKey] = &resource.ServiceInfo{
+			Name:       svcKey.Name,
+			Namespace:  svcKey.Namespace,
+			ServiceKey: svcKey,
+			Instances:  []*apiservice.Instance{newTestEDSInstance(fmt.Sprintf("127.0.0.%d", i+1), 8080, 100, nil)},
+		}
+	}
+	node := &resource.XDSClient{
+		RunType: resource.RunTypeGateway,
+		Node:    &core.Node{Id: "gateway~default/12345~127.0.0.1"},
+		Metadata: map[string]string{
+			resource.GatewayNamespaceName: "default",
+			resource.GatewayServiceName:   "gateway",
+			// 没有指定命名空间的服务使用网关所在的命名空间
+			resource.GatewayRequestedServices: "svc-1, other/svc-4",
+		},
+	}
+	build := func(requested map[model.ServiceKey]struct{}) ([]string, []string) {
+		option := &resource.BuildOption{
+			RunType:           resource.RunTypeGateway,
+			Services:          services,
+			RequestedServices: requested,
+		}
+		var endpoints, clusters []string
+		for _, item := range (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND) {
+			endpoints = append(endpoints, item.(*endpoint.ClusterLoadAssignment).GetClusterName())
+		}
+		items, err := (&CDSBuilder{}).GenerateByDirection(option, core.TrafficDirection_OUTBOUND)
+		assert.NoError(t, err)
+		for _, item := range items {
+			clusters = append(clusters, item.(*cluster.Cluster).GetName())
+		}
+		return endpoints, clusters
+	}
+
+	endpoints, clusters := build(node.GetRequestedServices())
+	expect := []string{"OUTBOUND|default|svc-1", "OUTBOUND|other|svc-4"}
+	assert.ElementsMatch(t, expect, endpoints)
+	assert.ElementsMatch(t, expect, clusters)
+
+	// 没有声明需要的服务时下发全部服务
+	delete(node.Metadata, resource.GatewayRequestedServices)
+	assert.Nil(t, node.GetRequestedServices())
+	endpoints, clusters = build(node.GetRequestedServices())
+	assert.Equal(t, 5, len(endpoints))
+	assert.Equal(t, 5, len(clusters))
+}
+
+func TestEDSBuilder_RoutePriority(t *testing.T) {
+	newInstance := func(host, zone string, metadata map[string]string) *apiservice.Instance {
+		ins := newTestEDSInstance(host, 8080, 100, metadata)
+		ins.Location = &apimodel.Location{
+			Region: utils.NewStringValue("r1"),
+			Zone:   utils.NewStringValue(zone),
+		}
+		return ins
+	}
+	routeConfig, err := ptypes.MarshalAny(&traffic_manage.RuleRoutingConfig{
+		Rules: []*traffic_manage.SubRuleRouting{
+			{
+				Destinations: []*traffic_manage.DestinationGroup{
+					{
+						Namespace: "default",
+						Service:   "svc",
+						Labels: map[string]*apimodel.MatchString{
+							"version": {Value: utils.NewStringValue("v2")},
+							"env":     {Value: utils.NewStringValue("prod")},
+						},
+						Priority: 0,
+						Weight:   100,
+					},
+					{
+						Namespace: "default",
+						Service:   "svc",
+						Labels: map[string]*apimodel.MatchString{
+							"version": {Value: utils.NewStringValue("v1")},
+						},
+						Priority: 1,
+						Weight:   100,
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	build := func(client *resource.XDSClient) *endpoint.ClusterLoadAssignment {
+		option := &resource.BuildOption{
+			RunType: resource.RunTypeSidecar,
+			Client:  client,
+			Services: map[model.ServiceKey]*resource.ServiceInfo{
+				svcKey: {
+					Name:       svcKey.Name,
+					Namespace:  svcKey.Namespace,
+					ServiceKey: svcKey,
+					Instances: []*apiservice.Instance{
+						newInstance("127.0.0.1", "zone-a", map[string]string{"version": "v1"}),
+						newInstance("127.0.0.2", "zone-b", map[string]string{"version": "v2", "env": "prod"}),
+						newInstance("127.0.0.3", "zone-a", map[string]string{"version": "v3"}),
+						// 只命中了部分标签
+						newInstance("127.0.0.4", "zone-a", map[string]string{"version": "v2"}),
+						newInstance("127.0.0.5", "zone-a", map[string]string{"version": "v2", "env": "prod"}),
+					},
+					Routing: &traffic_manage.Routing{
+						Rules: []*traffic_manage.RouteRule{
+							{
+								RoutingPolicy: traffic_manage.RoutingPolicy_RulePolicy,
+								RoutingConfig: routeConfig,
+							},
+						},
+					},
+				},
+			},
+		}
+		return (&EDSBuilder{}).makeBoundEndpoints(option, core.TrafficDirection_OUTBOUND)[0].(*endpoint.ClusterLoadAssignment)
+	}
+	hosts := func(localityEndpoints *endpoint.LocalityLbEndpoints) []string {
+		ret := make([]string, 0, len(localityEndpoints.GetLbEndpoints()))
+		for _, ep := range localityEndpoints.GetLbEndpoints() {
+			ret = append(ret, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return ret
+	}
+
+	// 路由规则优先的分组为 priority 0，没有命中任何分组的实例优先级最低
+	cla := build(nil)
+	assert.Equal(t, 3, len(cla.GetEndpoints()))
+	assert.Equal(t, uint32(0), cla.GetEndpoints()[0].GetPriority())
+	assert.Equal(t, []string{"127.0.0.2", "127.0.0.5"}, hosts(cla.GetEndpoints()[0]))
+	assert.Equal(t, []string{"127.0.0.1"}, hosts(cla.GetEndpoints()[1]))
+	assert.Equal(t, []string{"127.0.0.3", "127.0.0.4"}, hosts(cla.GetEndpoints()[2]))
+
+	// 同一个路由分组内再按照地域排序，地域不会改变路由分组之间的顺序
+	cla = build(&resource.XDSClient{Node: &core.Node{Locality: &core.Locality{Region: "r1", Zone: "zone-a"}}})
+	assert.Equal(t, 4, len(cla.GetEndpoints()))
+	assert.Equal(t, uint32(0), cla.GetEndpoints()[0].GetPriority())
+	assert.Equal(t, []string{"127.0.0.5"}, hosts(cla.GetEndpoints()[0]))
+	assert.Equal(t, []string{"127.0.0.2"}, hosts(cla.GetEndpoints()[1]))
+	assert.Equal(t, []string{"127.0.0.1"}, hosts(cla.GetEndpoints()[2]))
+	assert.Equal(t, uint32(3), cla.GetEndpoints()[3].GetPriority())
+}
+
+func TestXdsResourceGenerator_LocalityNodeEndpoints(t *testing.T) {
+	newInstance := func(host, zone string) *apiservice.Instance {
+		ins := newTestEDSInstance(host, 8080, 100, nil)
+		ins.Location = &apimodel.Location{Region: utils.NewStringValue("r1"), Zone: utils.NewStringValue(zone)}
+		return ins
+	}
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	registry := map[string]map[model.ServiceKey]*resource.ServiceInfo{
+		"default": {
+			svcKey: {Name: svcKey.Name, Namespace: svcKey.Namespace, ServiceKey: svcKey, Instances: []*apiservice.Instance{
+				newInstance("127.0.0.1", "zone-a"), newInstance("127.0.0.2", "zone-b"),
+			}},
+		},
+	}
+	nodeMgr := resource.NewXDSNodeManager()
+	nodeMgr.AddNodeIfAbsent(1, &core.Node{
+		Id:       "default/pod-1~10.0.0.1",
+		Locality: &core.Locality{Region: "r1", Zone: "zone-b"},
+	})
+	nodeMgr.AddNodeIfAbsent(2, &core.Node{Id: "default/pod-2~10.0.0.2"})
+	x := &XdsResourceGenerator{cache: xdscache.NewCache(nil), xdsNodesMgr: nodeMgr, registry: registry}
+
+	// 本次推送中没有变化的命名空间，也使用全量的服务信息构建
+	assert.NoError(t, x.buildSidecarXDSCache(map[string]map[model.ServiceKey]*resource.ServiceInfo{"other": {}}))
+
+	outboundName := resource.MakeServiceName(svcKey, core.TrafficDirection_OUTBOUND, &resource.BuildOption{})
+	val, ok := x.cache.Caches.Load(resourcev3.EndpointType + "~default/pod-1~10.0.0.1")
+	assert.True(t, ok)
+	resources := val.(*xdscache.LinearCache).GetResources()
+	// 上报了地域信息的节点，OUTBOUND 的 endpoint 按照与节点的距离计算优先级
+	cla, ok := resources[outboundName].(*endpoint.ClusterLoadAssignment)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(cla.GetEndpoints()))
+	assert.Equal(t, "127.0.0.2", cla.GetEndpoints()[0].GetLbEndpoints()[0].GetEndpoint().GetAddress().
+		GetSocketAddress().GetAddress())
+	// INBOUND 的 endpoint 仍然在节点的缓存中
+	assert.Equal(t, 2, len(resources))
+
+	// 没有上报地域信息的节点只单独缓存 INBOUND 的 endpoint，OUTBOUND 使用命名空间共享的资源
+	val, ok = x.cache.Caches.Load(resourcev3.EndpointType + "~default/pod-2~10.0.0.2")
+	assert.True(t, ok)
+	_, ok = val.(*xdscache.LinearCache).GetResources()[outboundName]
+	assert.False(t, ok)
+}
+
+func TestXdsResourceGenerator_RequestedServicesNodeResources(t *testing.T) {
+	registry := map[string]map[model.ServiceKey]*resource.ServiceInfo{}
+	for i := 0; i < 5; i++ {
+		svcKey := model.ServiceKey{Namespace: "default", Name: fmt.Sprintf("svc-%d", i)}
+		if i == 4 {
+			svcKey.Namespace = "other"
+		}
+		if _, ok := registry[svcKey.Namespace]; !ok {
+			registry[svcKey.Namespace] = map[model.ServiceKey]*resource.ServiceInfo{}
+		}
+		registry[svcKey.Namespace][svcKey] = &resource.ServiceInfo{
+			Name:       svcKey.Name,
+			Namespace:  svcKey.Namespace,
+			ServiceKey: svcKey,
+			Instances:  []*apiservice.Instance{newTestEDSInstance(fmt.Sprintf("127.0.0.%d", i+1), 8080, 100, nil)},
+		}
+	}
+	newNode := func(id string, metadata map[string]interface{}) *core.Node {
+		meta, err := structpb.NewStruct(metadata)
+		assert.NoError(t, err)
+		return &core.Node{Id: id, Metadata: meta}
+	}
+	const (
+		sidecarId = "default/pod-1~10.0.0.1"
+		gatewayId = "gateway~default/12345~10.0.0.2"
+	)
+	nodeMgr := resource.NewXDSNodeManager()
+	nodeMgr.AddNodeIfAbsent(1, newNode(sidecarId, map[string]interface{}{
+		resource.SidecarRequestedServices: "svc-1, other/svc-4",
+	}))
+	nodeMgr.AddNodeIfAbsent(2, newNode(gatewayId, map[string]interface{}{
+		resource.GatewayNamespaceName:     "default",
+		resource.GatewayServiceName:       "gateway",
+		resource.GatewayRequestedServices: "svc-2,svc-3",
+	}))
+	x := &XdsResourceGenerator{cache: xdscache.NewCache(nil), xdsNodesMgr: nodeMgr, registry: registry}
+	// 本次推送中没有变化的命名空间，也使用全量的服务信息构建
+	x.Generate("1", map[string]map[model.ServiceKey]*resource.ServiceInfo{"default": {}})
+
+	nodeResources := func(typeUrl, nodeId string) []string {
+		val, ok := x.cache.Caches.Load(typeUrl + "~" + nodeId)
+		assert.True(t, ok)
+		var names []string
+		for name := range val.(*xdscache.LinearCache).GetResources() {
+			if strings.HasPrefix(name, "OUTBOUND|") {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+	// 节点缓存的 OUTBOUND EDS、CDS 只包含节点声明需要的服务
+	sidecarExpect := []string{"OUTBOUND|default|svc-1", "OUTBOUND|other|svc-4"}
+	assert.ElementsMatch(t, sidecarExpect, nodeResources(resourcev3.EndpointType, sidecarId))
+	assert.ElementsMatch(t, sidecarExpect, nodeResources(resourcev3.ClusterType, sidecarId))
+	gatewayExpect := []string{"OUTBOUND|default|svc-2", "OUTBOUND|default|svc-3"}
+	assert.ElementsMatch(t, gatewayExpect, nodeResources(resourcev3.EndpointType, gatewayId))
+	assert.ElementsMatch(t, gatewayExpect, nodeResources(resourcev3.ClusterType, gatewayId))
+
+	// 只变化权重时，节点单独缓存的 EDS 同样会更新
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc-2"}
+	assert.Equal(t, []string{gatewayId}, x.nodeEndpointIds(svcKey))
+	assert.True(t, x.UpdateEndpointWeight(svcKey, "127.0.0.3", 8080, 10))
+}
+
+func TestXdsResourceGenerator_NodeEndpointFilter(t *testing.T) {
+	svcKey := model.ServiceKey{Namespace: "default", Name: "svc"}
+	registry := map[string]map[model.ServiceKey]*resource.ServiceInfo{
+		"default": {
+			svcKey: {Name: svcKey.Name, Namespace: svcKey.Namespace, ServiceKey: svcKey, Instances: []*apiservice.Instance{
+				newTestEDSInstance("127.0.0.1", 8080, 100, map[string]string{"drain": "true"}),
+				newTestEDSInstance("127.0.0.2", 8080, 100, map[string]string{"region": "ap-1"}),
+				newTestEDSInstance("127.0.0.3", 8080, 100, nil),
+			}},
+		},
+	}
+	const (
+		filterId = "default/pod-1~10.0.0.1"
+		plainId  = "default/pod-2~10.0.0.2"
+	)
+	meta, err := structpb.NewStruct(map[string]interface{}{resource.SidecarEndpointExclude: "region=ap-1, invalid"})
+	assert.NoError(t, err)
+	nodeMgr := resource.NewXDSNodeManager()
+	nodeMgr.AddNodeIfAbsent(1, &core.Node{Id: filterId, Metadata: meta})
+	nodeMgr.AddNodeIfAbsent(2, &core.Node{Id: plainId})
+	x := &XdsResourceGenerator{
+		cache:          xdscache.NewCache(nil),
+		xdsNodesMgr:    nodeMgr,
+		registry:       registry,
+		endpointFilter: resource.MetadataEndpointFilter(map[string]string{"drain": "true"}),
+	}
+	x.Generate("1", registry)
+
+	hosts := func(key string) []string {
+		val, ok := x.cache.Caches.Load(resourcev3.EndpointType + "~" + key)
+		assert.True(t, ok)
+		outboundName := resource.MakeServiceName(svcKey, core.TrafficDirection_OUTBOUND, &resource.BuildOption{})
+		cla, ok := val.(*xdscache.LinearCache).GetResources()[outboundName].(*endpoint.ClusterLoadAssignment)
+		if !ok {
+			return nil
+		}
+		var ret []string
+		for _, ep := range cla.GetEndpoints()[0].GetLbEndpoints() {
+			ret = append(ret, ep.GetEndpoint().GetAddress().GetSocketAddress().GetAddress())
+		}
+		return ret
+	}
+	// 节点声明的过滤条件与全局配置同时生效，只影响该节点单独构建的 EDS
+	assert.Equal(t, map[string]string{"region": "ap-1"}, resource.ParseXDSClient(&core.Node{Id: filterId, Metadata: meta}).
+		GetEndpointExcludeMetadata())
+	assert.Equal(t, []string{"127.0.0.3"}, hosts(filterId))
+	// 其他节点使用命名空间共享的 EDS，只按照全局配置过滤
+	assert.Nil(t, hosts(plainId))
+	assert.Equal(t, []string{"127.0.0.2", "127.0.0.3"}, hosts("default"))
+}
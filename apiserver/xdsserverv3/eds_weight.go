@@ -0,0 +1,105 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"strconv"
+	"strings"
+
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// EndpointWeightStatic 直接使用实例配置的权重
+	EndpointWeightStatic = "static"
+	// EndpointWeightLoad 根据实例上报的负载调整权重，负载越高的实例分到的流量越少
+	EndpointWeightLoad = "load"
+)
+
+// maxEndpointLoad 实例上报负载的上限，即 CPU 使用率 100%
+const maxEndpointLoad = 100
+
+// parseEndpointLoad 解析实例 metadata 中上报的负载，没有上报或者格式不正确时返回 false
+func parseEndpointLoad(instance *apiservice.Instance) (float64, bool) {
+	raw, ok := instance.GetMetadata()[resource.EndpointLoadTag]
+	if !ok {
+		return 0, false
+	}
+	load, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil || load < 0 {
+		return 0, false
+	}
+	if load > maxEndpointLoad {
+		load = maxEndpointLoad
+	}
+	return load, true
+}
+
+// scaleLoadWeight 按照实例剩余的处理能力缩放权重，没有上报负载的实例保持配置的权重
+func scaleLoadWeight(ep *endpoint.LbEndpoint, instance *apiservice.Instance) {
+	load, ok := parseEndpointLoad(instance)
+	if !ok {
+		return
+	}
+	weight := uint32(float64(ep.GetLoadBalancingWeight().GetValue()) * (maxEndpointLoad - load) / maxEndpointLoad)
+	// 满载的实例仍然可以提供服务，至少保留 1 的权重，避免 envoy 认为实例不可用
+	if weight == 0 {
+		weight = 1
+	}
+	ep.LoadBalancingWeight = utils.NewUInt32Value(weight)
+}
+
+// weightChange 服务中只有权重发生变化的实例
+type weightChange struct {
+	service   *resource.ServiceInfo
+	instances []*apiservice.Instance
+}
+
+// makeWeightedEndpoint 生成实例最终下发的 endpoint，用于只更新已缓存资源中的权重；
+// 权重会影响抽样结果、重复实例的合并或者整体的权重缩放时返回 false，只能重新构建整个 ClusterLoadAssignment
+func (eds *EDSBuilder) makeWeightedEndpoint(svc *resource.ServiceInfo,
+	instance *apiservice.Instance) (*endpoint.LbEndpoint, bool) {
+	if eds.endpointResolver != nil || eds.sampleMode == EndpointSampleWeighted ||
+		(eds.maxEndpoints > 0 && len(svc.Instances) > eds.maxEndpoints) {
+		return nil, false
+	}
+	var total uint64
+	for _, item := range svc.Instances {
+		if !resource.IsNormalEndpoint(item) {
+			continue
+		}
+		if item != instance && item.GetHost().GetValue() == instance.GetHost().GetValue() {
+			// 相同地址的实例去重时按照权重取舍
+			return nil, false
+		}
+		total += uint64(eds.instanceWeight(item))
+	}
+	if total > maxLocalityWeightSum {
+		return nil, false
+	}
+	ep := eds.makeInstanceEndpoint(instance, resource.GetEndpointPort(svc, instance))
+	if eds.weightPolicy == EndpointWeightLoad {
+		scaleLoadWeight(ep, instance)
+	}
+	eds.scaleDegradedWeight(ep)
+	return ep, true
+}
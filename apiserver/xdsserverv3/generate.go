@@ -23,9 +23,11 @@ import (
 	"time"
 
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
 	cachev3 "github.com/envoyproxy/go-control-plane/pkg/cache/v3"
 	resourcev3 "github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
 
@@ -43,6 +45,32 @@ type XdsResourceGenerator struct {
 	cache        *cache.XDSCache
 	versionNum   *atomic.Uint64
 	xdsNodesMgr  *resource.XDSNodeManager
+	// maxEndpointsPerCluster 每个 cluster 最多下发的实例数，小于等于 0 时不做限制
+	maxEndpointsPerCluster int
+	// endpointSampleMode 实例数超过上限时的抽样方式
+	endpointSampleMode string
+	// degradedWeightRatio 降级实例的权重缩放比例
+	degradedWeightRatio float64
+	// heartbeatStaleThreshold 实例超过该时间没有心跳时不再下发
+	heartbeatStaleThreshold time.Duration
+	// lastHeartbeat 查询实例最近一次心跳的时间
+	lastHeartbeat LastHeartbeatFunc
+	// minHealthyPercent 健康实例占比低于该百分比时重新下发被过滤的实例
+	minHealthyPercent float64
+	// addressTranslator 实例地址的转换器
+	addressTranslator AddressTranslateFunc
+	// endpointMetadataKeys 允许下发到 endpoint metadata 中的实例标签
+	endpointMetadataKeys map[string]struct{}
+	// endpointResolver 改写 endpoint 的地址
+	endpointResolver EndpointResolveFunc
+	// defaultEndpointWeight 没有设置权重的实例下发的权重
+	defaultEndpointWeight uint32
+	// endpointWeightPolicy 实例下发权重的计算方式
+	endpointWeightPolicy string
+	// endpointFilter 全局配置的过滤不下发到 EDS 中的实例的条件，为空时不做过滤，节点还可以单独声明过滤条件
+	endpointFilter resource.EndpointFilter
+	// registry 全量的服务信息，节点单独构建 OUTBOUND EDS 时使用，为空时使用本次推送的服务信息
+	registry map[string]map[model.ServiceKey]*resource.ServiceInfo
 }
 
 func (x *XdsResourceGenerator) Generate(versionLocal string,
@@ -52,6 +80,8 @@ func (x *XdsResourceGenerator) Generate(versionLocal string,
 	if x.xdsNodesMgr.HasEnvoyNodes() {
 		// 只构建 Sidecar 特有的 XDS 数据
 		_ = x.buildSidecarXDSCache(registryInfo)
+		// 构建网关节点单独缓存的 XDS 数据
+		x.buildGatewayNodeXDSCache(registryInfo)
 	}
 
 	// CDS/EDS/VHDS 一起构建
@@ -62,6 +92,7 @@ func (x *XdsResourceGenerator) Generate(versionLocal string,
 			Services:         services,
 			TrafficDirection: corev3.TrafficDirection_OUTBOUND,
 			TLSMode:          resource.TLSModeNone,
+			EndpointFilter:   x.endpointFilter,
 		}
 		x.buildAndDeltaUpdate(resource.RDS, opt)
 		x.buildAndDeltaUpdate(resource.EDS, opt)
@@ -102,6 +133,57 @@ func (x *XdsResourceGenerator) buildAndDeltaUpdate(xdsType resource.XDSType, opt
 	}
 }
 
+// UpdateEndpointWeight 实例只有权重发生变化时（例如逐步切流），直接修改已缓存的 EDS 资源中对应 endpoint 的权重，
+// 不需要重新生成整个 ClusterLoadAssignment，weight 为最终下发的权重
+func (x *XdsResourceGenerator) UpdateEndpointWeight(svcKey model.ServiceKey, host string, port, weight uint32) bool {
+	opt := &resource.BuildOption{RunType: resource.RunTypeSidecar, Namespace: svcKey.Namespace}
+	cacheKey := resource.EDS.ResourceType() + "~" + svcKey.Namespace
+	clusterName := resource.MakeServiceName(svcKey, corev3.TrafficDirection_OUTBOUND, opt)
+	changed, err := x.cache.UpdateEndpointWeight(cacheKey, clusterName, normalizeEndpointAddress(host), port, weight)
+	if err != nil {
+		log.Error("[XDS][Sidecar] update endpoint weight fail", zap.String("cache-key", cacheKey),
+			zap.String("cluster", clusterName), zap.Error(err))
+		return false
+	}
+	// 按照节点单独构建的 OUTBOUND EDS 同样需要更新
+	for _, nodeId := range x.nodeEndpointIds(svcKey) {
+		nodeKey := resource.EDS.ResourceType() + "~" + nodeId
+		nodeChanged, err := x.cache.UpdateEndpointWeight(nodeKey, clusterName, normalizeEndpointAddress(host),
+			port, weight)
+		if err != nil {
+			log.Error("[XDS][Sidecar] update endpoint weight fail", zap.String("cache-key", nodeKey),
+				zap.String("cluster", clusterName), zap.Error(err))
+			continue
+		}
+		changed = changed || nodeChanged
+	}
+	return changed
+}
+
+// updateInstanceWeights 服务的变化只有实例权重时，按 endpoint 更新已缓存的 EDS 资源，
+// 返回 false 时表示无法只更新权重，需要重新构建整个命名空间的资源
+func (x *XdsResourceGenerator) updateInstanceWeights(changes map[model.ServiceKey]*weightChange) bool {
+	eds := x.newEDSBuilder()
+	endpoints := map[model.ServiceKey][]*endpoint.LbEndpoint{}
+	for svcKey, change := range changes {
+		for _, instance := range change.instances {
+			ep, ok := eds.makeWeightedEndpoint(change.service, instance)
+			if !ok {
+				return false
+			}
+			endpoints[svcKey] = append(endpoints[svcKey], ep)
+		}
+	}
+	for svcKey, lbEndpoints := range endpoints {
+		for _, ep := range lbEndpoints {
+			address := ep.GetEndpoint().GetAddress().GetSocketAddress()
+			x.UpdateEndpointWeight(svcKey, address.GetAddress(), address.GetPortValue(),
+				ep.GetLoadBalancingWeight().GetValue())
+		}
+	}
+	return true
+}
+
 func (x *XdsResourceGenerator) buildSidecarXDSCache(registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) error {
 
 	nodes := x.xdsNodesMgr.ListSidecarNodes()
@@ -126,6 +208,8 @@ func (x *XdsResourceGenerator) buildSidecarXDSCache(registryInfo map[string]map[
 				Namespace: xdsNode.GetSelfNamespace(),
 				Name:      xdsNode.GetSelfService(),
 			},
+			EndpointFilter:    x.nodeEndpointFilter(xdsNode),
+			RequestedServices: xdsNode.GetRequestedServices(),
 		}
 
 		opt.TrafficDirection = corev3.TrafficDirection_OUTBOUND
@@ -136,14 +220,141 @@ func (x *XdsResourceGenerator) buildSidecarXDSCache(registryInfo map[string]map[
 		opt.TrafficDirection = corev3.TrafficDirection_INBOUND
 		// 构建 INBOUND LDS 资源
 		x.buildAndDeltaUpdate(resource.LDS, opt)
-		// 构建 INBOUND EDS 资源
-		x.buildAndDeltaUpdate(resource.EDS, opt)
+		// 构建 INBOUND EDS 资源，节点上报了地域信息或者声明了只需要下发的服务时一起构建 OUTBOUND EDS 资源
+		x.buildNodeEndpoints(opt, registryInfo)
 		// 构建 INBOUND RDS 资源
 		x.buildAndDeltaUpdate(resource.RDS, opt)
+		if opt.RequestedServices != nil {
+			// 构建只包含节点需要的服务的 OUTBOUND CDS 资源
+			x.buildAndDeltaUpdate(resource.CDS, x.nodeOutboundOption(opt, registryInfo))
+		}
 	}
 	return nil
 }
 
+// buildGatewayNodeXDSCache 网关节点声明了只需要下发的服务或者不需要下发的实例标签时，OUTBOUND 的 EDS 按照节点单独构建，
+// 声明了只需要下发的服务时 CDS 同样按照节点单独构建，其余的资源仍然使用命名空间共享的资源
+func (x *XdsResourceGenerator) buildGatewayNodeXDSCache(
+	registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) {
+	for _, node := range x.xdsNodesMgr.ListGatewayNodes() {
+		requested := node.GetRequestedServices()
+		if requested == nil && node.GetEndpointExcludeMetadata() == nil {
+			continue
+		}
+		opt := x.nodeOutboundOption(&resource.BuildOption{
+			RunType:           resource.RunTypeSidecar,
+			Client:            node,
+			TLSMode:           node.TLSMode,
+			Namespace:         node.GetSelfNamespace(),
+			EndpointFilter:    x.nodeEndpointFilter(node),
+			RequestedServices: requested,
+		}, registryInfo)
+		x.buildAndDeltaUpdate(resource.EDS, opt)
+		if requested != nil {
+			x.buildAndDeltaUpdate(resource.CDS, opt)
+		}
+	}
+}
+
+// nodeEndpointFilter 节点声明了不需要下发的实例标签时，与全局配置的过滤条件同时生效
+func (x *XdsResourceGenerator) nodeEndpointFilter(node *resource.XDSClient) resource.EndpointFilter {
+	nodeFilter := resource.MetadataEndpointFilter(node.GetEndpointExcludeMetadata())
+	if nodeFilter == nil {
+		return x.endpointFilter
+	}
+	if x.endpointFilter == nil {
+		return nodeFilter
+	}
+	return func(ins *apiservice.Instance) bool {
+		return x.endpointFilter(ins) && nodeFilter(ins)
+	}
+}
+
+// nodeOutboundOption 节点单独构建 OUTBOUND 资源时的构建参数。Generate 只会传入本次变化的命名空间，
+// 因此优先使用全量的服务信息，节点声明了只需要下发的服务时，只使用这些服务，允许跨命名空间
+func (x *XdsResourceGenerator) nodeOutboundOption(opt *resource.BuildOption,
+	registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) *resource.BuildOption {
+	registry := x.registry
+	if registry == nil {
+		registry = registryInfo
+	}
+	outboundOpt := *opt
+	outboundOpt.TrafficDirection = corev3.TrafficDirection_OUTBOUND
+	if opt.RequestedServices == nil {
+		outboundOpt.Services = registry[opt.Namespace]
+		return &outboundOpt
+	}
+	services := make(map[model.ServiceKey]*resource.ServiceInfo, len(opt.RequestedServices))
+	for svcKey := range opt.RequestedServices {
+		if svc, ok := registry[svcKey.Namespace][svcKey]; ok {
+			services[svcKey] = svc
+		}
+	}
+	outboundOpt.Services = services
+	return &outboundOpt
+}
+
+// buildNodeEndpoints 构建节点单独缓存的 EDS 资源。OUTBOUND 的 EDS 默认按照命名空间构建，所有节点共享，
+// 节点上报了地域信息时，需要按照实例与该节点的距离计算优先级，节点声明了只需要下发的服务或者不需要下发的实例标签时，
+// 需要过滤掉其余的服务以及实例，这些情况下和 INBOUND 的 endpoint 一起按照节点单独构建
+func (x *XdsResourceGenerator) buildNodeEndpoints(opt *resource.BuildOption,
+	registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) {
+	if !opt.Client.HasNodeEndpoints() {
+		x.buildAndDeltaUpdate(resource.EDS, opt)
+		return
+	}
+	inbound, err := x.generateXDSResource(resource.EDS, opt)
+	if err != nil {
+		log.Error("[XDS][Sidecar] build node inbound endpoints fail", zap.String("node", opt.Client.GetNodeID()),
+			zap.Error(err))
+		return
+	}
+	outbound, err := x.generateXDSResource(resource.EDS, x.nodeOutboundOption(opt, registryInfo))
+	if err != nil {
+		log.Error("[XDS][Sidecar] build node outbound endpoints fail", zap.String("node", opt.Client.GetNodeID()),
+			zap.Error(err))
+		return
+	}
+	typeUrl := resource.EDS.ResourceType()
+	cacheKey := typeUrl + "~" + opt.Client.Node.Id
+	if err := x.cache.DeltaUpdateResource(cacheKey, typeUrl,
+		cachev3.IndexRawResourcesByName(append(inbound, outbound...))); err != nil {
+		log.Error("[XDS][Sidecar] delta update fail", zap.String("cache-key", cacheKey),
+			zap.String("type", resource.EDS.String()), zap.Error(err))
+	}
+}
+
+// nodeEndpointIds 单独缓存了服务 OUTBOUND EDS 的节点
+func (x *XdsResourceGenerator) nodeEndpointIds(svcKey model.ServiceKey) []string {
+	if x.xdsNodesMgr == nil {
+		return nil
+	}
+	var ids []string
+	for _, node := range x.xdsNodesMgr.ListSidecarNodes() {
+		if requested := node.GetRequestedServices(); requested != nil {
+			if _, ok := requested[svcKey]; ok {
+				ids = append(ids, node.Node.GetId())
+			}
+			continue
+		}
+		if node.HasNodeEndpoints() && node.GetSelfNamespace() == svcKey.Namespace {
+			ids = append(ids, node.Node.GetId())
+		}
+	}
+	for _, node := range x.xdsNodesMgr.ListGatewayNodes() {
+		if requested := node.GetRequestedServices(); requested != nil {
+			if _, ok := requested[svcKey]; ok {
+				ids = append(ids, node.Node.GetId())
+			}
+			continue
+		}
+		if node.GetEndpointExcludeMetadata() != nil && node.GetSelfNamespace() == svcKey.Namespace {
+			ids = append(ids, node.Node.GetId())
+		}
+	}
+	return ids
+}
+
 // buildGatewayXDSCache 网关场景是允许跨命名空间直接进行访问
 func (x *XdsResourceGenerator) buildGatewayXDSCache(versionLocal string,
 	registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) error {
@@ -178,6 +389,11 @@ func (x *XdsResourceGenerator) makeGatewaySnapshot(xdsNode *resource.XDSClient,
 
 	opt := &resource.BuildOption{
 		TLSMode: tlsMode,
+		// 网关路由中声明的 SNI，每个 SNI 单独下发 cluster
+		GatewaySNIs: x.gatewayRouteSNIs(xdsNode),
+		// 网关节点的资源单独缓存，可以只下发节点声明需要的服务
+		RequestedServices: xdsNode.GetRequestedServices(),
+		EndpointFilter:    x.nodeEndpointFilter(xdsNode),
 	}
 	var (
 		allEndpoints []types.Resource
@@ -221,8 +437,10 @@ func (x *XdsResourceGenerator) makeGatewaySnapshot(xdsNode *resource.XDSClient,
 	cacheKey := (resource.PolarisNodeHash{}).ID(xdsNode.Node)
 
 	for typeUrl, resources := range resources {
-		if err := x.cache.DeltaUpdateResource(xdsNode.Node.Id, typeUrl, cachev3.IndexRawResourcesByName(resources)); err != nil {
-			// TODO: need log
+		// 与 buildAndDeltaUpdate 保持一致，网关节点的资源按照 typeUrl~nodeId 缓存
+		nodeKey := typeUrl + "~" + xdsNode.Node.Id
+		if err := x.cache.DeltaUpdateResource(nodeKey, typeUrl, cachev3.IndexRawResourcesByName(resources)); err != nil {
+			log.Error("[XDS][Gateway] delta update fail", zap.String("cache-key", nodeKey), zap.Error(err))
 		}
 	}
 	// 为每个 nodeId 刷写 cache ，推送 xds 更新
@@ -230,6 +448,32 @@ func (x *XdsResourceGenerator) makeGatewaySnapshot(xdsNode *resource.XDSClient,
 	return nil
 }
 
+// gatewayRouteSNIs 网关节点所属服务的路由规则中声明的 SNI
+func (x *XdsResourceGenerator) gatewayRouteSNIs(xdsNode *resource.XDSClient) map[model.ServiceKey][]string {
+	if x.namingServer == nil {
+		return nil
+	}
+	selfService := model.ServiceKey{Namespace: xdsNode.GetSelfNamespace(), Name: xdsNode.GetSelfService()}
+	rules := x.namingServer.Cache().RoutingConfig().ListRouterRule(selfService.Name, selfService.Namespace)
+	return collectGatewaySNIs(rules, selfService)
+}
+
+func (x *XdsResourceGenerator) newEDSBuilder() *EDSBuilder {
+	return &EDSBuilder{
+		maxEndpoints:            x.maxEndpointsPerCluster,
+		sampleMode:              x.endpointSampleMode,
+		degradedWeightRatio:     x.degradedWeightRatio,
+		heartbeatStaleThreshold: x.heartbeatStaleThreshold,
+		lastHeartbeat:           x.lastHeartbeat,
+		minHealthyPercent:       x.minHealthyPercent,
+		addressTranslator:       x.addressTranslator,
+		endpointMetadataKeys:    x.endpointMetadataKeys,
+		defaultWeight:           x.defaultEndpointWeight,
+		weightPolicy:            x.endpointWeightPolicy,
+		endpointResolver:        x.endpointResolver,
+	}
+}
+
 func (x *XdsResourceGenerator) generateXDSResource(xdsType resource.XDSType,
 	opt *resource.BuildOption) ([]types.Resource, error) {
 
@@ -256,7 +500,7 @@ func (x *XdsResourceGenerator) generateXDSResource(xdsType resource.XDSType,
 	case resource.CDS:
 		xdsBuilder = &CDSBuilder{}
 	case resource.EDS:
-		xdsBuilder = &EDSBuilder{}
+		xdsBuilder = x.newEDSBuilder()
 	case resource.LDS:
 		xdsBuilder = &LDSBuilder{}
 	case resource.RDS:
@@ -0,0 +1,106 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"sync"
+	"time"
+
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+	"github.com/polarismesh/polaris/common/model"
+)
+
+// heartbeatSnapshot 每一轮推送开始时对本轮涉及的实例统一查询一次最近一次心跳的时间，
+// 同一轮推送中不同命名空间、不同节点的多次 EDS 构建直接读取快照，不再逐个实例查询健康检查模块
+type heartbeatSnapshot struct {
+	lock sync.RWMutex
+	// namespace -> instanceId -> 最近一次心跳的时间
+	heartbeats map[string]map[string]time.Time
+	query      func(instanceId string) (time.Time, bool)
+}
+
+func newHeartbeatSnapshot(query func(instanceId string) (time.Time, bool)) *heartbeatSnapshot {
+	return &heartbeatSnapshot{
+		heartbeats: map[string]map[string]time.Time{},
+		query:      query,
+	}
+}
+
+// refresh 重新查询本轮需要推送的命名空间下开启了健康检查的实例的心跳时间，其他命名空间的快照保持不变
+func (h *heartbeatSnapshot) refresh(registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) {
+	snapshot := make(map[string]map[string]time.Time, len(registryInfo))
+	for namespace, services := range registryInfo {
+		heartbeats := map[string]time.Time{}
+		for _, svc := range services {
+			for _, ins := range svc.Instances {
+				if !ins.GetEnableHealthCheck().GetValue() {
+					continue
+				}
+				id := ins.GetId().GetValue()
+				if _, ok := heartbeats[id]; ok {
+					continue
+				}
+				if lastHeartbeat, ok := h.query(id); ok {
+					heartbeats[id] = lastHeartbeat
+				}
+			}
+		}
+		snapshot[namespace] = heartbeats
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for namespace, heartbeats := range snapshot {
+		h.heartbeats[namespace] = heartbeats
+	}
+}
+
+// get 从快照中读取实例最近一次心跳的时间，快照中没有记录时返回 false
+func (h *heartbeatSnapshot) get(ins *apiservice.Instance) (time.Time, bool) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	lastHeartbeat, ok := h.heartbeats[ins.GetNamespace().GetValue()][ins.GetId().GetValue()]
+	return lastHeartbeat, ok
+}
+
+// healthCheckedNamespaces 存在开启了健康检查的实例的命名空间。实例停止心跳时服务的版本不会发生变化，
+// 这些命名空间需要定期重新推送，心跳过期的实例才会从 EDS 中移除
+func healthCheckedNamespaces(
+	registryInfo map[string]map[model.ServiceKey]*resource.ServiceInfo) map[string]map[model.ServiceKey]*resource.ServiceInfo {
+	ret := map[string]map[model.ServiceKey]*resource.ServiceInfo{}
+	for namespace, services := range registryInfo {
+		for _, svc := range services {
+			if hasHealthCheckedInstance(svc) {
+				ret[namespace] = services
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func hasHealthCheckedInstance(svc *resource.ServiceInfo) bool {
+	for _, ins := range svc.Instances {
+		if ins.GetEnableHealthCheck().GetValue() {
+			return true
+		}
+	}
+	return false
+}
@@ -18,6 +18,8 @@
 package xdsserverv3
 
 import (
+	"slices"
+
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	on_demandv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/on_demand/v3"
@@ -286,8 +288,15 @@ func (rds *RDSBuilder) makeGatewayRoutes(option *resource.BuildOption) ([]*route
 				continue
 			}
 
+			// 路由声明了 SNI 时，使用带有 SNI 的 cluster，不同域名下的路由转发到不同的 cluster
+			routeOption := option
+			if sni := gatewaySubRuleSNI(subRule, callerService, callerNamespace); sni != "" {
+				sniOption := *option
+				sniOption.GatewaySNI = sni
+				routeOption = &sniOption
+			}
 			gatewayRoute := resource.MakeGatewayRoute(corev3.TrafficDirection_OUTBOUND, routeMatch,
-				subRule.GetDestinations(), option)
+				subRule.GetDestinations(), routeOption)
 			pathInfo := gatewayRoute.GetMatch().GetPath()
 			if pathInfo == "" {
 				pathInfo = gatewayRoute.GetMatch().GetSafeRegex().GetRegex()
@@ -340,6 +349,45 @@ func buildGatewayRouteMatch(routeMatch *route.RouteMatch, source *traffic_manage
 	resource.BuildCommonRouteMatch(routeMatch, source)
 }
 
+// gatewaySubRuleSNI 网关路由声明的 SNI，取第一个匹配网关的来源中声明的 SNI
+func gatewaySubRuleSNI(subRule *traffic_manage.SubRuleRouting, svcName, svcNamespace string) string {
+	for _, source := range subRule.GetSources() {
+		if !isMatchGatewaySource(source, svcName, svcNamespace) {
+			continue
+		}
+		if sni := resource.GetGatewayRouteSNI(source); sni != "" {
+			return sni
+		}
+	}
+	return ""
+}
+
+// collectGatewaySNIs 收集网关路由中每个目标服务声明的 SNI，用于 EDS、CDS 下发带有 SNI 的 cluster
+func collectGatewaySNIs(rules []*model.ExtendRouterConfig, selfService model.ServiceKey) map[model.ServiceKey][]string {
+	ret := map[model.ServiceKey][]string{}
+	for _, rule := range rules {
+		if rule.GetRoutingPolicy() != traffic_manage.RoutingPolicy_RulePolicy {
+			continue
+		}
+		for _, subRule := range rule.RuleRouting.GetRules() {
+			sni := gatewaySubRuleSNI(subRule, selfService.Name, selfService.Namespace)
+			if sni == "" {
+				continue
+			}
+			for _, dest := range subRule.GetDestinations() {
+				if dest.GetService() == utils.MatchAll {
+					continue
+				}
+				svcKey := model.ServiceKey{Namespace: dest.GetNamespace(), Name: dest.GetService()}
+				if !slices.Contains(ret[svcKey], sni) {
+					ret[svcKey] = append(ret[svcKey], sni)
+				}
+			}
+		}
+	}
+	return ret
+}
+
 func isMatchGatewaySource(source *traffic_manage.SourceService, svcName, svcNamespace string) bool {
 	var (
 		existPathLabel bool
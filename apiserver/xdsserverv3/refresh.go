@@ -0,0 +1,108 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/polarismesh/polaris/cache"
+	"github.com/polarismesh/polaris/common/model"
+)
+
+var (
+	// defaultHealthRefreshDelay 实例健康状态变化后，等待缓存刷新并合并抖动的时间窗口
+	defaultHealthRefreshDelay = 2 * cache.UpdateCacheInterval
+)
+
+// healthRefresher 监听实例健康状态的变化，在一个时间窗口内合并多次变化后，触发一次 XDS 资源的重新生成
+type healthRefresher struct {
+	lock    sync.Mutex
+	delay   time.Duration
+	timer   *time.Timer
+	pending map[model.ServiceKey]struct{}
+	refresh func(services []model.ServiceKey)
+}
+
+func newHealthRefresher(delay time.Duration, refresh func(services []model.ServiceKey)) *healthRefresher {
+	return &healthRefresher{
+		delay:   delay,
+		pending: map[model.ServiceKey]struct{}{},
+		refresh: refresh,
+	}
+}
+
+// PreProcess do preprocess logic for event
+func (h *healthRefresher) PreProcess(_ context.Context, e any) any {
+	return e
+}
+
+// OnEvent event process logic
+func (h *healthRefresher) OnEvent(_ context.Context, arg any) error {
+	event, ok := arg.(model.InstanceEvent)
+	if !ok {
+		return nil
+	}
+	if event.EType != model.EventInstanceTurnHealth && event.EType != model.EventInstanceTurnUnHealth {
+		return nil
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pending[model.ServiceKey{Namespace: event.Namespace, Name: event.Service}] = struct{}{}
+	if h.timer == nil {
+		h.timer = time.AfterFunc(h.delay, h.flush)
+	}
+	return nil
+}
+
+func (h *healthRefresher) flush() {
+	h.lock.Lock()
+	services := make([]model.ServiceKey, 0, len(h.pending))
+	for svcKey := range h.pending {
+		services = append(services, svcKey)
+	}
+	h.pending = map[model.ServiceKey]struct{}{}
+	h.timer = nil
+	h.lock.Unlock()
+
+	if len(services) == 0 {
+		return
+	}
+	log.Info("[XDS][Health] instance health changed, refresh xds resource", zap.Int("services", len(services)))
+	h.refresh(services)
+}
+
+func (h *healthRefresher) stop() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+}
+
+// notifyRefresh 通知同步任务立即对比一次服务数据，如果已经有待处理的通知则直接合并
+func (x *XDSServer) notifyRefresh(_ []model.ServiceKey) {
+	select {
+	case x.refreshCh <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,76 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package xdsserverv3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/atomic"
+
+	"github.com/polarismesh/polaris/apiserver/xdsserverv3/resource"
+	"github.com/polarismesh/polaris/common/model"
+)
+
+func TestHealthRefresher_Debounce(t *testing.T) {
+	x := &XDSServer{
+		refreshCh:    make(chan struct{}, 1),
+		versionNum:   atomic.NewUint64(0),
+		activeFinish: func() {},
+		resourceGenerator: &XdsResourceGenerator{
+			xdsNodesMgr: resource.NewXDSNodeManager(),
+		},
+	}
+	refreshed := atomic.NewInt32(0)
+	refresher := newHealthRefresher(100*time.Millisecond, func(services []model.ServiceKey) {
+		refreshed.Inc()
+		assert.Equal(t, 1, len(services))
+		x.notifyRefresh(services)
+	})
+	defer refresher.stop()
+
+	// 心跳事件不会触发刷新
+	_ = refresher.OnEvent(context.Background(), model.InstanceEvent{
+		Namespace: "default",
+		Service:   "svc",
+		EType:     model.EventInstanceSendHeartbeat,
+	})
+	// 短时间内多次健康状态抖动只会触发一次刷新
+	for i := 0; i < 10; i++ {
+		etype := model.EventInstanceTurnUnHealth
+		if i%2 == 1 {
+			etype = model.EventInstanceTurnHealth
+		}
+		_ = refresher.OnEvent(context.Background(), model.InstanceEvent{
+			Namespace: "default",
+			Service:   "svc",
+			EType:     etype,
+		})
+	}
+
+	select {
+	case <-x.refreshCh:
+		x.Generate(map[string]map[model.ServiceKey]*resource.ServiceInfo{})
+	case <-time.After(time.Second):
+		t.Fatal("health change not trigger xds refresh")
+	}
+	assert.Equal(t, int32(1), refreshed.Load())
+	assert.Equal(t, uint64(1), x.versionNum.Load())
+}
@@ -0,0 +1,130 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package resource
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// XDSAckStatus envoy 节点对某一类 XDS 资源最近一次推送的确认结果
+type XDSAckStatus struct {
+	// TypeUrl 资源类型
+	TypeUrl string `json:"type_url"`
+	// Version envoy 确认的版本，NACK 时为 envoy 仍在使用的上一个版本
+	Version string `json:"version"`
+	// Nonce 被确认的推送的 nonce
+	Nonce string `json:"nonce"`
+	// Acked 最近一次推送是否被 envoy 接受
+	Acked bool `json:"acked"`
+	// ErrorCode NACK 时 envoy 返回的错误码
+	ErrorCode int32 `json:"error_code,omitempty"`
+	// ErrorDetail NACK 时 envoy 返回的拒绝原因
+	ErrorDetail string `json:"error_detail,omitempty"`
+	// NackCount 累计被拒绝的次数
+	NackCount uint64 `json:"nack_count"`
+	// UpdateTime 最近一次收到确认的时间
+	UpdateTime time.Time `json:"update_time"`
+}
+
+// XDSAckTracker 记录每个 envoy 节点对每一类 XDS 资源的 ACK/NACK 结果
+type XDSAckTracker struct {
+	lock sync.RWMutex
+	// nodes node id -> type url -> 确认结果
+	nodes map[string]map[string]*XDSAckStatus
+}
+
+func NewXDSAckTracker() *XDSAckTracker {
+	return &XDSAckTracker{
+		nodes: map[string]map[string]*XDSAckStatus{},
+	}
+}
+
+// Record 记录一次确认结果，返回记录之后的状态
+func (t *XDSAckTracker) Record(nodeId string, status XDSAckStatus) XDSAckStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	types, ok := t.nodes[nodeId]
+	if !ok {
+		types = map[string]*XDSAckStatus{}
+		t.nodes[nodeId] = types
+	}
+	saved, ok := types[status.TypeUrl]
+	if !ok {
+		saved = &XDSAckStatus{TypeUrl: status.TypeUrl}
+		types[status.TypeUrl] = saved
+	}
+	saved.Version = status.Version
+	saved.Nonce = status.Nonce
+	saved.Acked = status.Acked
+	saved.ErrorCode = status.ErrorCode
+	saved.ErrorDetail = status.ErrorDetail
+	if !status.Acked {
+		saved.NackCount++
+	}
+	saved.UpdateTime = status.UpdateTime
+	if saved.UpdateTime.IsZero() {
+		saved.UpdateTime = time.Now()
+	}
+	return *saved
+}
+
+// DelNode 节点断开之后删除其确认结果
+func (t *XDSAckTracker) DelNode(nodeId string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.nodes, nodeId)
+}
+
+// GetNodeStatus 获取节点对每一类资源的确认结果，按照资源类型排序
+func (t *XDSAckTracker) GetNodeStatus(nodeId string) []XDSAckStatus {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return sortedAckStatus(t.nodes[nodeId])
+}
+
+// ListNackedNodes 获取最近一次推送被拒绝的节点，以及被拒绝的资源类型
+func (t *XDSAckTracker) ListNackedNodes() map[string][]XDSAckStatus {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	ret := map[string][]XDSAckStatus{}
+	for nodeId, types := range t.nodes {
+		for _, item := range sortedAckStatus(types) {
+			if !item.Acked {
+				ret[nodeId] = append(ret[nodeId], item)
+			}
+		}
+	}
+	return ret
+}
+
+func sortedAckStatus(types map[string]*XDSAckStatus) []XDSAckStatus {
+	ret := make([]XDSAckStatus, 0, len(types))
+	for _, item := range types {
+		ret = append(ret, *item)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].TypeUrl < ret[j].TypeUrl
+	})
+	return ret
+}
@@ -19,6 +19,7 @@ package resource
 
 import (
 	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
 
 	"github.com/polarismesh/polaris/common/model"
 	"github.com/polarismesh/polaris/service"
@@ -43,12 +44,40 @@ type BuildOption struct {
 	// 不是比带，只有在 EDS 生成，并且是处理 INBOUND 的时候才会设置
 	Client           *XDSClient
 	TrafficDirection corev3.TrafficDirection
+	// GatewaySNI 网关路由声明的 TLS SNI，不为空时会作为该路由使用的 cluster 名称的后缀
+	GatewaySNI string
+	// GatewaySNIs 网关路由中每个目标服务声明的 SNI，EDS、CDS 需要为每个 SNI 单独下发 cluster
+	GatewaySNIs map[model.ServiceKey][]string
+	// RequestedServices 节点声明只需要下发的服务，为空时下发全部服务
+	RequestedServices map[model.ServiceKey]struct{}
+	// EndpointFilter 过滤不下发到 EDS 中的实例，为空时不做过滤
+	EndpointFilter EndpointFilter
+}
+
+// EndpointFilter 返回 false 的实例不会下发到 EDS 中
+type EndpointFilter func(ins *apiservice.Instance) bool
+
+// AcceptEndpoint 实例是否需要下发到 EDS 中
+func (opt *BuildOption) AcceptEndpoint(ins *apiservice.Instance) bool {
+	return opt.EndpointFilter == nil || opt.EndpointFilter(ins)
+}
+
+// IsRequestedService 节点没有声明需要的服务列表时，所有的服务都需要下发
+func (opt *BuildOption) IsRequestedService(svcKey model.ServiceKey) bool {
+	if len(opt.RequestedServices) == 0 {
+		return true
+	}
+	_, ok := opt.RequestedServices[svcKey]
+	return ok
 }
 
 func (opt *BuildOption) Clone() *BuildOption {
 	return &BuildOption{
-		Namespace: opt.Namespace,
-		TLSMode:   opt.TLSMode,
-		Services:  opt.Services,
+		Namespace:      opt.Namespace,
+		TLSMode:        opt.TLSMode,
+		Services:       opt.Services,
+		GatewaySNI:     opt.GatewaySNI,
+		GatewaySNIs:    opt.GatewaySNIs,
+		EndpointFilter: opt.EndpointFilter,
 	}
 }
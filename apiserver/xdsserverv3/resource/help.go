@@ -95,6 +95,53 @@ func FilterInboundRouterRule(svc *ServiceInfo) []*traffic_manage.SubRuleRouting
 	return ret
 }
 
+const (
+	// RouteUnmatchedPriority 路由规则中的优先级范围为 [0, 9]，没有命中任何目标分组的实例排在所有分组之后
+	RouteUnmatchedPriority = 10
+)
+
+// FilterRouteDestinations 返回路由规则中指向该服务的目标分组，与 BuildWeightClustersV2 一致跳过权重为 0 的分组，
+// 被隔离的分组不会有流量，同样跳过
+func FilterRouteDestinations(svc *ServiceInfo) []*traffic_manage.DestinationGroup {
+	var ret []*traffic_manage.DestinationGroup
+	for _, rule := range FilterInboundRouterRule(svc) {
+		for _, dest := range rule.GetDestinations() {
+			if !svc.MatchService(dest.GetNamespace(), dest.GetService()) {
+				continue
+			}
+			if dest.GetWeight() == 0 || dest.GetIsolate() {
+				continue
+			}
+			ret = append(ret, dest)
+		}
+	}
+	return ret
+}
+
+// RouteDestinationPriority 计算实例命中的目标分组的优先级，命中多个分组时取最高的优先级。
+// 与 CDS 的 subset 以及 RDS 的 metadataMatch 一致，标签按照精确值匹配
+func RouteDestinationPriority(destinations []*traffic_manage.DestinationGroup, ins *apiservice.Instance) uint32 {
+	priority := uint32(RouteUnmatchedPriority)
+	for _, dest := range destinations {
+		if dest.GetPriority() < priority && matchDestinationLabels(dest, ins) {
+			priority = dest.GetPriority()
+		}
+	}
+	return priority
+}
+
+func matchDestinationLabels(dest *traffic_manage.DestinationGroup, ins *apiservice.Instance) bool {
+	for k, v := range dest.GetLabels() {
+		if k == utils.MatchAll && v.GetValue().GetValue() == utils.MatchAll {
+			return true
+		}
+		if val, ok := ins.GetMetadata()[k]; !ok || val != v.GetValue().GetValue() {
+			return false
+		}
+	}
+	return true
+}
+
 func BuildSidecarRouteMatch(routeMatch *route.RouteMatch, source *traffic_manage.SourceService) {
 	for i := range source.GetArguments() {
 		argument := source.GetArguments()[i]
@@ -111,6 +158,22 @@ func BuildSidecarRouteMatch(routeMatch *route.RouteMatch, source *traffic_manage
 	BuildCommonRouteMatch(routeMatch, source)
 }
 
+// GatewaySNIHeader 网关路由通过精确匹配该请求头声明路由的 TLS SNI
+const GatewaySNIHeader = ":authority"
+
+// GetGatewayRouteSNI 获取网关路由来源中精确匹配的 :authority 请求头，作为该路由的 SNI，没有声明时返回空
+func GetGatewayRouteSNI(source *traffic_manage.SourceService) string {
+	for _, argument := range source.GetArguments() {
+		if argument.GetType() != traffic_manage.SourceMatch_HEADER || argument.GetKey() != GatewaySNIHeader {
+			continue
+		}
+		if argument.GetValue().GetType() == apimodel.MatchString_EXACT {
+			return argument.GetValue().GetValue().GetValue()
+		}
+	}
+	return ""
+}
+
 func BuildCommonRouteMatch(routeMatch *route.RouteMatch, source *traffic_manage.SourceService) {
 	for i := range source.GetArguments() {
 		argument := source.GetArguments()[i]
@@ -634,12 +697,33 @@ func MakeInBoundRouteConfigName(svcKey model.ServiceKey) string {
 	return InBoundRouteConfigName + "/" + svcKey.Domain()
 }
 
+// MakeGatewaySNIServiceNames 网关路由中声明了 SNI 的服务，每个 SNI 对应的 cluster 名称
+func MakeGatewaySNIServiceNames(svcKey model.ServiceKey, trafficDirection corev3.TrafficDirection,
+	opt *BuildOption) []string {
+	if opt.RunType != RunTypeGateway || opt.GatewaySNI != "" {
+		return nil
+	}
+	snis := opt.GatewaySNIs[svcKey]
+	names := make([]string, 0, len(snis))
+	for _, sni := range snis {
+		sniOpt := *opt
+		sniOpt.GatewaySNI = sni
+		names = append(names, MakeServiceName(svcKey, trafficDirection, &sniOpt))
+	}
+	return names
+}
+
 // MakeServiceName .
 func MakeServiceName(svcKey model.ServiceKey, trafficDirection corev3.TrafficDirection,
 	opt *BuildOption) string {
 	if trafficDirection == core.TrafficDirection_INBOUND || !opt.OpenOnDemand {
-		return fmt.Sprintf("%s|%s|%s", corev3.TrafficDirection_name[int32(trafficDirection)],
+		name := fmt.Sprintf("%s|%s|%s", corev3.TrafficDirection_name[int32(trafficDirection)],
 			svcKey.Namespace, svcKey.Name)
+		// 网关场景下同一个服务可能通过不同的 SNI 暴露，需要使用不同的 cluster 进行区分
+		if opt.RunType == RunTypeGateway && opt.GatewaySNI != "" {
+			name += "|" + opt.GatewaySNI
+		}
+		return name
 	}
 	return svcKey.Name + "." + svcKey.Namespace
 }
@@ -939,18 +1023,60 @@ func MakeOutlierDetection(serviceInfo *ServiceInfo) *cluster.OutlierDetection {
 	return outlierDetection
 }
 
-// Translate the FaultDetector configuration of Polaris into HealthCheck
+const (
+	// defaultHealthCheckInterval 探测规则没有设置探测间隔时使用的默认值
+	defaultHealthCheckInterval = 30 * time.Second
+	// defaultHealthCheckTimeout 探测规则没有设置超时时间时使用的默认值
+	defaultHealthCheckTimeout = time.Second
+	// healthCheckUnhealthyThreshold 连续探测失败多少次后认为实例不健康
+	healthCheckUnhealthyThreshold = 3
+	// healthCheckHealthyThreshold 连续探测成功多少次后认为实例恢复健康
+	healthCheckHealthyThreshold = 1
+)
+
+// filterFaultDetectRules 获取作用于当前服务的主动探测规则
+func filterFaultDetectRules(serviceInfo *ServiceInfo) []*apifault.FaultDetectRule {
+	if serviceInfo.FaultDetect == nil {
+		return nil
+	}
+	var rules []*apifault.FaultDetectRule
+	for _, rule := range serviceInfo.FaultDetect.GetRules() {
+		target := rule.GetTargetService()
+		if target != nil {
+			if !matchFaultDetectTarget(target.GetNamespace(), serviceInfo.Namespace) ||
+				!matchFaultDetectTarget(target.GetService(), serviceInfo.Name) {
+				continue
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func matchFaultDetectTarget(expect, actual string) bool {
+	return expect == "" || expect == utils.MatchAll || expect == actual
+}
+
+func makeHealthCheckDuration(seconds uint32, defaultValue time.Duration) *durationpb.Duration {
+	if seconds == 0 {
+		return durationpb.New(defaultValue)
+	}
+	return durationpb.New(time.Duration(seconds) * time.Second)
+}
+
+// MakeHealthCheck Translate the FaultDetector configuration of Polaris into HealthCheck
 func MakeHealthCheck(serviceInfo *ServiceInfo) []*core.HealthCheck {
-	if serviceInfo.FaultDetect == nil || len(serviceInfo.FaultDetect.Rules) == 0 {
+	rules := filterFaultDetectRules(serviceInfo)
+	if len(rules) == 0 {
 		return nil
 	}
 	var healthChecks []*core.HealthCheck
-	for _, rule := range serviceInfo.FaultDetect.Rules {
+	for _, rule := range rules {
 		healthCheck := &core.HealthCheck{
-			Timeout:            durationpb.New(time.Duration(rule.GetTimeout()) * time.Second),
-			Interval:           durationpb.New(time.Duration(rule.GetInterval()) * time.Second),
-			UnhealthyThreshold: &wrappers.UInt32Value{Value: 3},
-			HealthyThreshold:   &wrappers.UInt32Value{Value: 1},
+			Timeout:            makeHealthCheckDuration(rule.GetTimeout(), defaultHealthCheckTimeout),
+			Interval:           makeHealthCheckDuration(rule.GetInterval(), defaultHealthCheckInterval),
+			UnhealthyThreshold: &wrappers.UInt32Value{Value: healthCheckUnhealthyThreshold},
+			HealthyThreshold:   &wrappers.UInt32Value{Value: healthCheckHealthyThreshold},
 		}
 		if rule.GetProtocol() == apifault.FaultDetectRule_HTTP {
 			config := rule.GetHttpConfig()
@@ -968,11 +1094,23 @@ func MakeHealthCheck(serviceInfo *ServiceInfo) []*core.HealthCheck {
 				headers = append(headers, &header)
 			}
 
+			path := config.GetUrl()
+			if path == "" {
+				path = "/"
+			}
+			method := core.RequestMethod(core.RequestMethod_value[strings.ToUpper(config.GetMethod())])
 			httpHealthCheck := &core.HealthCheck_HttpHealthCheck{
-				Path:                config.Url,
-				Method:              core.RequestMethod(core.RequestMethod_value[config.Method]),
+				Path:                path,
+				Method:              method,
 				RequestHeadersToAdd: headers,
 			}
+			// envoy 只允许可以携带 body 的请求方法设置探测内容
+			if config.GetBody() != "" && method != core.RequestMethod_METHOD_UNSPECIFIED &&
+				method != core.RequestMethod_GET && method != core.RequestMethod_HEAD {
+				httpHealthCheck.Send = &core.HealthCheck_Payload{
+					Payload: &core.HealthCheck_Payload_Text{Text: hex.EncodeToString([]byte(config.GetBody()))},
+				}
+			}
 			healthCheck.HealthChecker = &core.HealthCheck_HttpHealthCheck_{HttpHealthCheck: httpHealthCheck}
 			healthChecks = append(healthChecks, healthCheck)
 		} else if rule.GetProtocol() == apifault.FaultDetectRule_TCP {
@@ -987,11 +1125,14 @@ func MakeHealthCheck(serviceInfo *ServiceInfo) []*core.HealthCheck {
 				})
 			}
 			tcpHealthCheck := &core.HealthCheck_TcpHealthCheck{
-				Send: &core.HealthCheck_Payload{
-					Payload: &core.HealthCheck_Payload_Text{Text: hex.EncodeToString([]byte(config.Send))},
-				},
 				Receive: receives,
 			}
+			// 没有设置发送内容时只探测端口是否可以连通
+			if config.GetSend() != "" {
+				tcpHealthCheck.Send = &core.HealthCheck_Payload{
+					Payload: &core.HealthCheck_Payload_Text{Text: hex.EncodeToString([]byte(config.GetSend()))},
+				}
+			}
 			healthCheck.HealthChecker = &core.HealthCheck_TcpHealthCheck_{TcpHealthCheck: tcpHealthCheck}
 			healthChecks = append(healthChecks, healthCheck)
 		}
@@ -999,6 +1140,20 @@ func MakeHealthCheck(serviceInfo *ServiceInfo) []*core.HealthCheck {
 	return healthChecks
 }
 
+// GetHealthCheckPort 探测规则指定了探测端口时返回该端口，envoy 只支持为每个 endpoint 设置一个探测端口，
+// 因此以第一个指定了端口的规则为准
+func GetHealthCheckPort(serviceInfo *ServiceInfo) uint32 {
+	for _, rule := range filterFaultDetectRules(serviceInfo) {
+		if rule.GetProtocol() != apifault.FaultDetectRule_HTTP && rule.GetProtocol() != apifault.FaultDetectRule_TCP {
+			continue
+		}
+		if rule.GetPort() != 0 {
+			return rule.GetPort()
+		}
+	}
+	return 0
+}
+
 func MakeLbSubsetConfig(serviceInfo *ServiceInfo) *cluster.Cluster_LbSubsetConfig {
 	rules := FilterInboundRouterRule(serviceInfo)
 	if len(rules) == 0 {
@@ -1027,10 +1182,17 @@ func MakeLbSubsetConfig(serviceInfo *ServiceInfo) *cluster.Cluster_LbSubsetConfi
 	return lbSubsetConfig
 }
 
-func GenEndpointMetaFromPolarisIns(ins *apiservice.Instance) *core.Metadata {
+// GenEndpointMetaFromPolarisIns 将实例的 metadata 转换为 endpoint 的 metadata，allowKeys 为空时下发实例全部的标签
+func GenEndpointMetaFromPolarisIns(ins *apiservice.Instance, allowKeys map[string]struct{}) *core.Metadata {
 	meta := &core.Metadata{}
 	fields := make(map[string]*_struct.Value)
 	for k, v := range ins.Metadata {
+		// 设置了白名单时只下发白名单中的标签，避免内部使用的标签泄漏给 envoy
+		if len(allowKeys) != 0 {
+			if _, ok := allowKeys[k]; !ok {
+				continue
+			}
+		}
 		fields[k] = &_struct.Value{
 			Kind: &_struct.Value_StringValue{
 				StringValue: v,
@@ -1038,29 +1200,190 @@ func GenEndpointMetaFromPolarisIns(ins *apiservice.Instance) *core.Metadata {
 		}
 	}
 
+	// 灰度实例总是携带灰度标签，取值保持不变；非灰度实例不携带该标签，避免被灰度的 subset 选中
+	delete(fields, EndpointCanaryTag)
+	if IsCanaryEndpoint(ins) {
+		fields[EndpointCanaryTag] = &_struct.Value{
+			Kind: &_struct.Value_StringValue{
+				StringValue: ins.GetMetadata()[EndpointCanaryTag],
+			},
+		}
+	}
+
 	meta.FilterMetadata = make(map[string]*_struct.Struct)
 	meta.FilterMetadata["envoy.lb"] = &_struct.Struct{
 		Fields: fields,
 	}
-	if ins.Metadata != nil && ins.Metadata[TLSModeTag] != "" {
-		meta.FilterMetadata["envoy.transport_socket_match"] = MTLSTransportSocketMatch
+	// 开启了 mTLS 的实例携带 transport socket match 标签，permissive 模式的 cluster 据此选择 TLS 或者明文连接
+	if IsTLSEndpoint(ins) {
+		meta.FilterMetadata[TransportSocketMatchMetaKey] = MTLSTransportSocketMatch
+	}
+	if maxConnections, ok := GetEndpointMaxConnections(ins); ok {
+		meta.FilterMetadata[EndpointCircuitBreakersMetaKey] = &_struct.Struct{
+			Fields: map[string]*_struct.Value{
+				EndpointMaxConnectionsMetaField: {
+					Kind: &_struct.Value_NumberValue{
+						NumberValue: float64(maxConnections),
+					},
+				},
+			},
+		}
 	}
 	return meta
 }
 
+// IsTLSEndpoint 实例是否开启了 mTLS，只有 TLS 模式为 strict 或者 permissive 时才认为实例可以接收 TLS 连接
+func IsTLSEndpoint(ins *apiservice.Instance) bool {
+	val := TLSMode(strings.ToLower(strings.TrimSpace(ins.GetMetadata()[TLSModeTag])))
+	return val == TLSModeStrict || val == TLSModePermissive
+}
+
+// IsCanaryEndpoint 实例是否被标记为灰度实例，标签取值为空或者 false 时不是灰度实例
+func IsCanaryEndpoint(ins *apiservice.Instance) bool {
+	val := strings.TrimSpace(ins.GetMetadata()[EndpointCanaryTag])
+	return val != "" && !strings.EqualFold(val, "false")
+}
+
+// GetEndpointMaxConnections 获取实例声明的最大连接数，未声明或者不合法时返回 false
+func GetEndpointMaxConnections(ins *apiservice.Instance) (uint32, bool) {
+	val, ok := ins.GetMetadata()[EndpointMaxConnectionsTag]
+	if !ok {
+		return 0, false
+	}
+	ret, err := strconv.ParseUint(strings.TrimSpace(val), 10, 32)
+	if err != nil || ret == 0 {
+		return 0, false
+	}
+	return uint32(ret), true
+}
+
+// GetEndpointHostname 获取实例需要下发给 envoy 的 hostname，未设置时为空
+func GetEndpointHostname(ins *apiservice.Instance) string {
+	return ins.GetMetadata()[EndpointHostnameTag]
+}
+
+// GetEndpointPort 获取实例需要下发的端口。实例本身的端口与服务端口不匹配时，使用 metadata 中声明的额外端口里
+// 第一个与服务端口匹配的端口，服务没有声明端口或者都不匹配时使用实例本身的端口
+func GetEndpointPort(svc *ServiceInfo, ins *apiservice.Instance) uint32 {
+	port := ins.GetPort().GetValue()
+	if len(svc.Ports) == 0 || matchServicePort(svc, port) {
+		return port
+	}
+	extraPorts, ok := ins.GetMetadata()[EndpointPortsTag]
+	if !ok {
+		return port
+	}
+	for _, extraPort := range ParsePorts(extraPorts) {
+		if matchServicePort(svc, extraPort) {
+			return extraPort
+		}
+	}
+	return port
+}
+
+func matchServicePort(svc *ServiceInfo, port uint32) bool {
+	for _, item := range svc.Ports {
+		if item.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// GetServiceDNSEndpoint 获取 headless 服务在 metadata 中声明的 DNS 域名以及端口，没有声明或者端口不合法时返回 false
+func GetServiceDNSEndpoint(svc *ServiceInfo) (string, uint32, bool) {
+	fqdn := strings.TrimSpace(svc.Metadata[ServiceDNSFqdnTag])
+	if fqdn == "" {
+		return "", 0, false
+	}
+	if val, ok := svc.Metadata[ServiceDNSPortTag]; ok {
+		ports := ParsePorts(val)
+		if len(ports) == 0 {
+			return "", 0, false
+		}
+		return fqdn, ports[0], true
+	}
+	for _, port := range svc.Ports {
+		if port.Port > 0 && port.Port <= 65535 {
+			return fqdn, port.Port, true
+		}
+	}
+	return "", 0, false
+}
+
+// IsPassthroughService 网关场景下服务是否声明了直接转发到原始目的地址
+func IsPassthroughService(svc *ServiceInfo, opt *BuildOption) bool {
+	if opt.RunType != RunTypeGateway {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(svc.Metadata[ServicePassthroughTag]), "true")
+}
+
+// ParsePorts 解析逗号分隔的端口列表，忽略非法的端口
+func ParsePorts(val string) []uint32 {
+	var ports []uint32
+	for _, item := range strings.Split(val, ",") {
+		ret, err := strconv.ParseUint(strings.TrimSpace(item), 10, 64)
+		if err != nil || ret == 0 || ret > 65535 {
+			continue
+		}
+		ports = append(ports, uint32(ret))
+	}
+	return ports
+}
+
 func IsNormalEndpoint(ins *apiservice.Instance) bool {
 	if ins.GetIsolate().GetValue() {
 		return false
 	}
-	if ins.GetWeight().GetValue() == 0 {
+	// 没有设置权重的实例使用默认权重下发，只有显式设置为 0 的实例才不下发
+	if ins.GetWeight() != nil && ins.GetWeight().GetValue() == 0 {
 		return false
 	}
 	return true
 }
 
+// MetadataEndpointFilter 实例标签命中 excludes 中任意一个键值对时不下发，例如 drain=true，excludes 为空时不做过滤
+func MetadataEndpointFilter(excludes map[string]string) EndpointFilter {
+	if len(excludes) == 0 {
+		return nil
+	}
+	return func(ins *apiservice.Instance) bool {
+		metadata := ins.GetMetadata()
+		for key, value := range excludes {
+			if val, ok := metadata[key]; ok && val == value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchServiceProtocol 服务声明了端口协议时，实例注册的协议需要与之匹配，服务或者实例没有声明协议时都视为匹配
+func MatchServiceProtocol(svc *ServiceInfo, ins *apiservice.Instance) bool {
+	declared := strings.TrimSpace(svc.Metadata[ServiceProtocolTag])
+	protocol := strings.TrimSpace(ins.GetProtocol().GetValue())
+	if declared == "" || protocol == "" {
+		return true
+	}
+	for _, item := range strings.Split(declared, ",") {
+		if strings.EqualFold(strings.TrimSpace(item), protocol) {
+			return true
+		}
+	}
+	return false
+}
+
 func FormatEndpointHealth(ins *apiservice.Instance) core.HealthStatus {
-	if ins.GetHealthy().GetValue() {
-		return core.HealthStatus_HEALTHY
+	if !ins.GetHealthy().GetValue() {
+		return core.HealthStatus_UNHEALTHY
+	}
+	// 维护中的实例没有被隔离，仍然会下发给 envoy，只是不再接收新的连接
+	if strings.EqualFold(ins.GetMetadata()[EndpointMaintenanceTag], "true") {
+		return core.HealthStatus_DRAINING
+	}
+	if ins.GetMetadata()[EndpointHealthTag] == EndpointHealthDegraded {
+		return core.HealthStatus_DEGRADED
 	}
-	return core.HealthStatus_UNHEALTHY
+	return core.HealthStatus_HEALTHY
 }
@@ -142,6 +142,48 @@ const (
 	TLSModePermissive TLSMode = "permissive"
 )
 
+const (
+	// EndpointHostnameTag 实例 metadata 中指定 envoy endpoint hostname 的标签，用于集群发起 TLS 时设置 SNI
+	EndpointHostnameTag = "polarismesh.cn/endpoint-hostname"
+	// EndpointPortsTag 实例 metadata 中声明实例额外暴露的端口，多个端口使用逗号分隔，实例本身的端口不是服务端口时，
+	// 使用其中与服务端口匹配的端口下发
+	EndpointPortsTag = "polarismesh.cn/endpoint-ports"
+	// EndpointHealthTag 实例 metadata 中声明实例的健康状态，目前只支持 degraded
+	EndpointHealthTag = "polarismesh.cn/endpoint-health"
+	// EndpointHealthDegraded 实例处于降级状态，仍然可以提供服务但需要逐步减少流量
+	EndpointHealthDegraded = "degraded"
+	// EndpointLoadTag 实例 metadata 中上报的实例负载，取值为 [0, 100] 的 CPU 使用率百分比
+	EndpointLoadTag = "polarismesh.cn/endpoint-load"
+	// EndpointMaintenanceTag 实例 metadata 中标记实例处于维护状态，取值为 true 时 envoy 不再建立新的连接，已有连接不受影响
+	EndpointMaintenanceTag = "maintenance"
+	// EndpointMaxConnectionsTag 实例 metadata 中声明实例允许的最大连接数
+	EndpointMaxConnectionsTag = "polarismesh.cn/endpoint-max-connections"
+	// EndpointCircuitBreakersMetaKey endpoint filter metadata 中存放实例级别熔断限制的命名空间，
+	// 供配套的 CDS cluster 设置 per-endpoint 的熔断阈值
+	EndpointCircuitBreakersMetaKey = "polarismesh.cn/circuit_breakers"
+	// EndpointMaxConnectionsMetaField 实例级别熔断限制中的最大连接数
+	EndpointMaxConnectionsMetaField = "max_connections"
+	// EndpointCanaryTag 实例 metadata 中标记灰度实例的标签，取值为 true 或者灰度版本。只有灰度实例才会在
+	// endpoint envoy.lb metadata 中携带该标签，取值与实例保持一致，用于 envoy subset 路由将部分流量导入灰度实例
+	EndpointCanaryTag = "canary"
+	// EndpointClusterTag 实例 metadata 中声明实例所属的集群，多集群网格中远端集群的实例经过东西向网关访问
+	EndpointClusterTag = "polarismesh.cn/cluster"
+	// EndpointEastWestMetaKey endpoint filter metadata 中存放经过东西向网关访问的实例原始信息的命名空间
+	EndpointEastWestMetaKey = "polarismesh.cn/east_west"
+)
+
+const (
+	// ServiceDNSFqdnTag 服务 metadata 中声明 headless 服务可以通过 DNS 解析的域名，服务没有实例时使用该域名作为 endpoint
+	ServiceDNSFqdnTag = "polarismesh.cn/dns-fqdn"
+	// ServiceDNSPortTag 服务 metadata 中声明 DNS endpoint 的端口，不声明时使用服务的第一个端口
+	ServiceDNSPortTag = "polarismesh.cn/dns-port"
+	// ServicePassthroughTag 服务 metadata 中声明网关直接转发到请求的原始目的地址，取值为 true 时不下发 EDS，
+	// 对应的 cluster 使用 ORIGINAL_DST 类型
+	ServicePassthroughTag = "polarismesh.cn/passthrough"
+	// ServiceProtocolTag 服务 metadata 中声明服务端口的协议，多个协议使用逗号分隔，声明之后只下发协议匹配的实例
+	ServiceProtocolTag = "polarismesh.cn/protocol"
+)
+
 const (
 	// 这个是特殊指定的 prefix
 	MatchString_Prefix = apimodel.MatchString_MatchStringType(-1)
@@ -159,6 +201,7 @@ type ServiceInfo struct {
 	Routing                *traffic_manage.Routing
 	SvcRoutingRevision     string
 	Ports                  []*model.ServicePort
+	Metadata               map[string]string
 	RateLimit              *traffic_manage.RateLimit
 	SvcRateLimitRevision   string
 	CircuitBreaker         *fault_tolerance.CircuitBreaker
@@ -47,6 +47,10 @@ var DefaultSdsConfig = &core.ConfigSource{
 	ResourceApiVersion:  core.ApiVersion_V3,
 }
 
+// TransportSocketMatchMetaKey endpoint filter metadata 中用于匹配 cluster transport_socket_matches 的命名空间
+const TransportSocketMatchMetaKey = "envoy.transport_socket_match"
+
+// MTLSTransportSocketMatch 开启了 mTLS 的 endpoint 携带的匹配标签，与 permissive 模式下 cluster 的 tls-mode 匹配规则一致
 var MTLSTransportSocketMatch = &structpb.Struct{
 	Fields: map[string]*structpb.Value{
 		"acceptMTLS": {Kind: &structpb.Value_StringValue{StringValue: "true"}},
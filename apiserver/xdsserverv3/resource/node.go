@@ -30,6 +30,8 @@ import (
 	_struct "github.com/golang/protobuf/ptypes/struct"
 	structpb "github.com/golang/protobuf/ptypes/struct"
 	"go.uber.org/zap"
+
+	"github.com/polarismesh/polaris/common/model"
 )
 
 type RunType string
@@ -47,6 +49,9 @@ const (
 	GatewayNamespaceName = "gateway.polarismesh.cn/serviceNamespace"
 	// GatewayNamespaceName xds metadata key when node is run in gateway mode
 	GatewayServiceName = "gateway.polarismesh.cn/serviceName"
+	// GatewayRequestedServices xds metadata key when node is run in gateway mode, 网关只需要下发的服务列表，
+	// value example: default/productpage,reviews 没有指定命名空间时使用网关所在的命名空间
+	GatewayRequestedServices = "gateway.polarismesh.cn/requestedServices"
 	// OldGatewayNamespaceName xds metadata key when node is run in gateway mode
 	OldGatewayNamespaceName = "gateway_namespace"
 	// OldGatewayServiceName xds metadata key when node is run in gateway mode
@@ -66,6 +71,14 @@ const (
 	SidecarTLSModeTag = "sidecar.polarismesh.cn/tlsMode"
 	// SidecarConnectServerEndpoint report xds server the envoy xds on-demand cds server endpoint info
 	SidecarODCDSServerEndpoint = "sidecar.polarismesh.cn/odcdsServerEndpoint"
+	// SidecarRequestedServices xds metadata key when node is run in sidecar mode, sidecar 只需要下发的服务列表，
+	// 格式与 GatewayRequestedServices 一致
+	SidecarRequestedServices = "sidecar.polarismesh.cn/requestedServices"
+	// SidecarEndpointExclude xds metadata key when node is run in sidecar mode, 节点不需要下发的实例标签，
+	// value example: drain=true,region=ap-guangzhou 命中任意一个键值对的实例不会下发到该节点的 EDS 中
+	SidecarEndpointExclude = "sidecar.polarismesh.cn/endpointExcludeMetadata"
+	// GatewayEndpointExclude xds metadata key when node is run in gateway mode, 格式与 SidecarEndpointExclude 一致
+	GatewayEndpointExclude = "gateway.polarismesh.cn/endpointExcludeMetadata"
 )
 
 func NewXDSNodeManager() *XDSNodeManager {
@@ -318,6 +331,74 @@ func (n *XDSClient) GetRegisterServices() []*RegisterService {
 	return ret
 }
 
+// GetRequestedServices 获取节点声明只需要下发的服务，没有声明时返回 nil，表示下发全部服务
+func (n *XDSClient) GetRequestedServices() map[model.ServiceKey]struct{} {
+	key := SidecarRequestedServices
+	if n.IsGateway() {
+		key = GatewayRequestedServices
+	}
+	val := n.Metadata[key]
+	if val == "" {
+		return nil
+	}
+	ret := map[model.ServiceKey]struct{}{}
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		namespace, service, ok := strings.Cut(item, "/")
+		if !ok {
+			namespace, service = n.GetSelfNamespace(), item
+		}
+		ret[model.ServiceKey{Namespace: namespace, Name: service}] = struct{}{}
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
+
+// GetEndpointExcludeMetadata 获取节点声明的不需要下发的实例标签，没有声明时返回 nil
+func (n *XDSClient) GetEndpointExcludeMetadata() map[string]string {
+	key := SidecarEndpointExclude
+	if n.IsGateway() {
+		key = GatewayEndpointExclude
+	}
+	val := n.Metadata[key]
+	if val == "" {
+		return nil
+	}
+	ret := map[string]string{}
+	for _, item := range strings.Split(val, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(item), "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			continue
+		}
+		ret[k] = strings.TrimSpace(v)
+	}
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret
+}
+
+// HasNodeEndpoints 节点的 OUTBOUND EDS 是否按照节点单独构建，节点上报了地域信息、声明了只需要下发的服务
+// 或者不需要下发的实例标签时，EDS 的内容与同一命名空间下的其他节点不同
+func (n *XDSClient) HasNodeEndpoints() bool {
+	return n.GetLocality() != nil || n.GetRequestedServices() != nil || n.GetEndpointExcludeMetadata() != nil
+}
+
+// GetLocality 获取 envoy 上报的自身地域信息，没有上报 zone 时返回 nil
+func (n *XDSClient) GetLocality() *core.Locality {
+	locality := n.Node.GetLocality()
+	if locality.GetZone() == "" {
+		return nil
+	}
+	return locality
+}
+
 // GetSelfService 获取 envoy 对应的 service 信息
 func (n *XDSClient) GetSelfService() string {
 	if n.IsGateway() {
@@ -20,7 +20,9 @@ package xdsserverv3
 import (
 	"context"
 	"fmt"
+	"math"
 	"net"
+	"reflect"
 	"strconv"
 	"time"
 
@@ -33,6 +35,7 @@ import (
 	runtimeservice "github.com/envoyproxy/go-control-plane/envoy/service/runtime/v3"
 	secretservice "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
 	serverv3 "github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"github.com/golang/protobuf/proto"
 	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
@@ -45,6 +48,7 @@ import (
 	"github.com/polarismesh/polaris/cache"
 	api "github.com/polarismesh/polaris/common/api/v1"
 	connlimit "github.com/polarismesh/polaris/common/conn/limit"
+	"github.com/polarismesh/polaris/common/eventhub"
 	commonlog "github.com/polarismesh/polaris/common/log"
 	"github.com/polarismesh/polaris/common/model"
 	"github.com/polarismesh/polaris/common/utils"
@@ -70,8 +74,11 @@ type XDSServer struct {
 	versionNum      *atomic.Uint64
 	server          *grpc.Server
 	connLimitConfig *connlimit.Config
+	// compression 下发 xDS 响应使用的压缩算法，为空时不压缩
+	compression string
 
 	nodeMgr           *resource.XDSNodeManager
+	ackTracker        *resource.XDSAckTracker
 	registryInfo      map[string]map[model.ServiceKey]*resource.ServiceInfo
 	resourceGenerator *XdsResourceGenerator
 
@@ -80,6 +87,12 @@ type XDSServer struct {
 	singleFlight   singleflight.Group
 	activeNotifier context.Context
 	activeFinish   context.CancelFunc
+
+	refreshCh       chan struct{}
+	healthRefresher *healthRefresher
+	subCtx          *eventhub.SubscribtionContext
+	// heartbeats 开启了心跳过期过滤时，每一轮推送前批量查询实例的心跳时间
+	heartbeats *heartbeatSnapshot
 }
 
 // Initialize 初始化
@@ -89,11 +102,13 @@ func (x *XDSServer) Initialize(ctx context.Context, option map[string]interface{
 	x.listenPort = uint32(option["listenPort"].(int))
 	x.listenIP = option["listenIP"].(string)
 	x.nodeMgr = resource.NewXDSNodeManager()
+	x.ackTracker = resource.NewXDSAckTracker()
 	x.cache = xdscache.NewCache(x)
 	x.active = atomic.NewBool(false)
 	x.versionNum = atomic.NewUint64(0)
 	x.ctx = ctx
 	x.activeNotifier, x.activeFinish = context.WithCancel(context.Background())
+	x.refreshCh = make(chan struct{}, 1)
 	var err error
 
 	x.namingServer, err = service.GetOriginServer()
@@ -114,11 +129,115 @@ func (x *XDSServer) Initialize(ctx context.Context, option map[string]interface{
 		}
 		x.connLimitConfig = connConfig
 	}
+	x.compression, _ = option["compression"].(string)
+	if err := checkCompressor(x.compression); err != nil {
+		return err
+	}
+	maxEndpoints, _ := option["maxEndpointsPerCluster"].(int)
+	sampleMode, _ := option["endpointSampleMode"].(string)
+	if sampleMode != "" && sampleMode != EndpointSampleTruncate && sampleMode != EndpointSampleWeighted {
+		return fmt.Errorf("[XDSV3] unsupported endpointSampleMode %s", sampleMode)
+	}
+	var degradedWeightRatio float64
+	switch val := option["degradedWeightRatio"].(type) {
+	case float64:
+		degradedWeightRatio = val
+	case int:
+		degradedWeightRatio = float64(val)
+	}
+	var heartbeatStaleThreshold time.Duration
+	if raw, _ := option["heartbeatStaleThreshold"].(string); raw != "" {
+		heartbeatStaleThreshold, err = time.ParseDuration(raw)
+		if err != nil {
+			log.Errorf("[XDSV3] parse heartbeatStaleThreshold %s fail: %v", raw, err)
+			return err
+		}
+	}
+	var minHealthyPercent float64
+	switch val := option["minHealthyPercent"].(type) {
+	case float64:
+		minHealthyPercent = val
+	case int:
+		minHealthyPercent = float64(val)
+	}
+	if minHealthyPercent < 0 || minHealthyPercent > 100 {
+		return fmt.Errorf("[XDSV3] minHealthyPercent must be in [0, 100], but got %v", minHealthyPercent)
+	}
+	translatorName, _ := option["addressTranslator"].(string)
+	addressTranslator, err := getAddressTranslator(translatorName)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+	var endpointMetadataKeys map[string]struct{}
+	if raw, _ := option["endpointMetadataKeys"].([]interface{}); len(raw) != 0 {
+		endpointMetadataKeys = make(map[string]struct{}, len(raw))
+		for _, item := range raw {
+			key, _ := item.(string)
+			if key == "" {
+				return fmt.Errorf("[XDSV3] endpointMetadataKeys contains invalid key %v", item)
+			}
+			endpointMetadataKeys[key] = struct{}{}
+		}
+	}
+	defaultEndpointWeight, _ := option["defaultEndpointWeight"].(int)
+	if defaultEndpointWeight < 0 || int64(defaultEndpointWeight) > math.MaxUint32 {
+		return fmt.Errorf("[XDSV3] invalid defaultEndpointWeight %d", defaultEndpointWeight)
+	}
+	weightPolicy, _ := option["endpointWeightPolicy"].(string)
+	if weightPolicy != "" && weightPolicy != EndpointWeightStatic && weightPolicy != EndpointWeightLoad {
+		return fmt.Errorf("[XDSV3] unsupported endpointWeightPolicy %s", weightPolicy)
+	}
+	var endpointExcludeMetadata map[string]string
+	if raw, _ := option["endpointExcludeMetadata"].(map[interface{}]interface{}); len(raw) != 0 {
+		endpointExcludeMetadata = make(map[string]string, len(raw))
+		for k, v := range raw {
+			key, _ := k.(string)
+			if key == "" {
+				return fmt.Errorf("[XDSV3] endpointExcludeMetadata contains invalid key %v", k)
+			}
+			endpointExcludeMetadata[key] = fmt.Sprint(v)
+		}
+	}
+	eastWestGatewayRaw, _ := option["eastWestGateway"].(map[interface{}]interface{})
+	eastWestGatewayCfg, err := parseEastWestGatewayConfig(eastWestGatewayRaw)
+	if err != nil {
+		return err
+	}
+	endpointResolver, err := newEastWestGatewayResolver(eastWestGatewayCfg)
+	if err != nil {
+		return err
+	}
+	var lastHeartbeat LastHeartbeatFunc
+	if heartbeatStaleThreshold > 0 && x.healthSvr != nil {
+		x.heartbeats = newHeartbeatSnapshot(x.queryLastHeartbeat)
+		lastHeartbeat = x.heartbeats.get
+	}
 	x.resourceGenerator = &XdsResourceGenerator{
-		namingServer: x.namingServer,
-		cache:        x.cache,
-		versionNum:   x.versionNum,
-		xdsNodesMgr:  x.nodeMgr,
+		namingServer:            x.namingServer,
+		cache:                   x.cache,
+		versionNum:              x.versionNum,
+		xdsNodesMgr:             x.nodeMgr,
+		maxEndpointsPerCluster:  maxEndpoints,
+		endpointSampleMode:      sampleMode,
+		degradedWeightRatio:     degradedWeightRatio,
+		heartbeatStaleThreshold: heartbeatStaleThreshold,
+		lastHeartbeat:           lastHeartbeat,
+		minHealthyPercent:       minHealthyPercent,
+		addressTranslator:       addressTranslator,
+		endpointMetadataKeys:    endpointMetadataKeys,
+		defaultEndpointWeight:   uint32(defaultEndpointWeight),
+		endpointWeightPolicy:    weightPolicy,
+		endpointFilter:          resource.MetadataEndpointFilter(endpointExcludeMetadata),
+		endpointResolver:        endpointResolver,
+		registry:                x.registryInfo,
+	}
+	// 实例健康状态变化时主动触发一次 XDS 资源的对比与推送
+	x.healthRefresher = newHealthRefresher(defaultHealthRefreshDelay, x.notifyRefresh)
+	x.subCtx, err = eventhub.Subscribe(eventhub.InstanceEventTopic, x.healthRefresher)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
 	}
 	resource.Init()
 	return nil
@@ -128,10 +247,13 @@ func (x *XDSServer) Initialize(ctx context.Context, option map[string]interface{
 func (x *XDSServer) Run(errCh chan error) {
 	// 启动 grpc server
 	ctx := context.Background()
-	cb := xdscache.NewCallback(commonlog.GetScopeOrDefaultByName(commonlog.XDSLoggerName), x.nodeMgr)
+	cb := xdscache.NewCallback(commonlog.GetScopeOrDefaultByName(commonlog.XDSLoggerName), x.nodeMgr, x.ackTracker)
 	srv := serverv3.NewServer(ctx, x.cache, cb)
 	var grpcOptions []grpc.ServerOption
 	grpcOptions = append(grpcOptions, grpc.MaxConcurrentStreams(1000))
+	if x.compression != "" {
+		grpcOptions = append(grpcOptions, grpc.StreamInterceptor(compressionStreamInterceptor(x.compression)))
+	}
 	grpcServer := grpc.NewServer(grpcOptions...)
 	x.server = grpcServer
 	address := fmt.Sprintf("%v:%v", x.listenIP, x.listenPort)
@@ -179,6 +301,12 @@ func registerServer(grpcServer *grpc.Server, server serverv3.Server, x *XDSServe
 // Stop 停止服务
 func (x *XDSServer) Stop() {
 	connlimit.RemoveLimitListener(x.GetProtocol())
+	if x.subCtx != nil {
+		x.subCtx.Cancel()
+	}
+	if x.healthRefresher != nil {
+		x.healthRefresher.stop()
+	}
 	if x.server != nil {
 		x.server.Stop()
 	}
@@ -274,11 +402,26 @@ func (x *XDSServer) startSynTask(ctx context.Context) {
 			// todo 不考虑命名空间删除的情况
 			// 判断当前这个空间，是否需要更新配置
 			if x.checkUpdate(infos, cacheServiceInfos) {
+				// 只有实例权重发生变化时（例如逐步切流），直接更新已缓存的 endpoint 权重
+				if changes, ok := diffInstanceWeights(infos, cacheServiceInfos); ok &&
+					x.resourceGenerator.updateInstanceWeights(changes) {
+					x.registryInfo[ns] = infos
+					continue
+				}
 				needPush[ns] = infos
 				x.registryInfo[ns] = infos
 			}
 		}
 
+		// 开启了心跳过期过滤时，心跳过期不会引起服务版本变化，定期重新评估存在开启了健康检查的实例的命名空间
+		if x.heartbeats != nil {
+			for ns, infos := range healthCheckedNamespaces(x.registryInfo) {
+				if _, ok := needPush[ns]; !ok {
+					needPush[ns] = infos
+				}
+			}
+		}
+
 		if len(needPush) > 0 {
 			log.Info("start update xds resource snapshot ticker task", zap.Int("need-push", len(needPush)))
 			x.Generate(needPush)
@@ -290,6 +433,8 @@ func (x *XDSServer) startSynTask(ctx context.Context) {
 		select {
 		case <-ticker.C:
 			synXdsConfFunc()
+		case <-x.refreshCh:
+			synXdsConfFunc()
 		case <-ctx.Done():
 			ticker.Stop()
 			log.Info("stop update xds resource snapshot ticker task")
@@ -329,6 +474,7 @@ func (x *XDSServer) getRegistryInfoWithCache(ctx context.Context,
 			ServiceKey: svcKey,
 			Instances:  []*apiservice.Instance{},
 			Ports:      value.ServicePorts,
+			Metadata:   value.Meta,
 		}
 		registryInfo[value.Namespace][svcKey] = info
 		return true, nil
@@ -418,6 +564,9 @@ func (x *XDSServer) getRegistryInfoWithCache(ctx context.Context,
 func (x *XDSServer) Generate(needPush map[string]map[model.ServiceKey]*resource.ServiceInfo) {
 	defer x.activeFinish()
 	versionLocal := time.Now().Format(time.RFC3339) + "/" + strconv.FormatUint(x.versionNum.Inc(), 10)
+	if x.heartbeats != nil {
+		x.heartbeats.refresh(needPush)
+	}
 	x.resourceGenerator.Generate(versionLocal, needPush)
 }
 
@@ -445,6 +594,12 @@ func (x *XDSServer) checkUpdate(curServiceInfo, cacheServiceInfo map[model.Servi
 				if info.FaultDetectRevision != serviceInfo.FaultDetectRevision {
 					return true
 				}
+				// headless 服务的 DNS 声明以及直接转发的声明不影响实例版本号，需要单独判断
+				if info.Metadata[resource.ServiceDNSFqdnTag] != serviceInfo.Metadata[resource.ServiceDNSFqdnTag] ||
+					info.Metadata[resource.ServiceDNSPortTag] != serviceInfo.Metadata[resource.ServiceDNSPortTag] ||
+					info.Metadata[resource.ServicePassthroughTag] != serviceInfo.Metadata[resource.ServicePassthroughTag] {
+					return true
+				}
 				find = true
 			}
 		}
@@ -455,6 +610,67 @@ func (x *XDSServer) checkUpdate(curServiceInfo, cacheServiceInfo map[model.Servi
 	return false
 }
 
+// diffInstanceWeights 对比命名空间下的服务，只有实例的权重发生变化时返回权重变化的实例，其他任何变化都返回 false
+func diffInstanceWeights(curServiceInfo,
+	cacheServiceInfo map[model.ServiceKey]*resource.ServiceInfo) (map[model.ServiceKey]*weightChange, bool) {
+	if len(curServiceInfo) != len(cacheServiceInfo) {
+		return nil, false
+	}
+	changes := map[model.ServiceKey]*weightChange{}
+	for svcKey, info := range curServiceInfo {
+		cacheInfo, ok := cacheServiceInfo[svcKey]
+		if !ok {
+			return nil, false
+		}
+		if info.SvcInsRevision == cacheInfo.SvcInsRevision {
+			continue
+		}
+		if info.SvcRoutingRevision != cacheInfo.SvcRoutingRevision ||
+			info.SvcRateLimitRevision != cacheInfo.SvcRateLimitRevision ||
+			info.CircuitBreakerRevision != cacheInfo.CircuitBreakerRevision ||
+			info.FaultDetectRevision != cacheInfo.FaultDetectRevision ||
+			!reflect.DeepEqual(info.Metadata, cacheInfo.Metadata) ||
+			len(info.Instances) != len(cacheInfo.Instances) {
+			return nil, false
+		}
+		cacheInstances := make(map[string]*apiservice.Instance, len(cacheInfo.Instances))
+		for _, ins := range cacheInfo.Instances {
+			cacheInstances[ins.GetId().GetValue()] = ins
+		}
+		for _, ins := range info.Instances {
+			cacheIns, ok := cacheInstances[ins.GetId().GetValue()]
+			if !ok || !onlyWeightChanged(ins, cacheIns) {
+				return nil, false
+			}
+			if ins.GetWeight().GetValue() == cacheIns.GetWeight().GetValue() {
+				continue
+			}
+			change, ok := changes[svcKey]
+			if !ok {
+				change = &weightChange{service: info}
+				changes[svcKey] = change
+			}
+			change.instances = append(change.instances, ins)
+		}
+	}
+	return changes, true
+}
+
+// onlyWeightChanged 实例除了权重以及版本信息之外没有其他变化，权重变为 0 或者从 0 恢复时实例会被摘除或者重新下发，不属于只变更权重
+func onlyWeightChanged(cur, prev *apiservice.Instance) bool {
+	if !resource.IsNormalEndpoint(cur) || !resource.IsNormalEndpoint(prev) {
+		return proto.Equal(cur, prev)
+	}
+	cur = proto.Clone(cur).(*apiservice.Instance)
+	prev = proto.Clone(prev).(*apiservice.Instance)
+	for _, ins := range []*apiservice.Instance{cur, prev} {
+		ins.Weight = nil
+		ins.Revision = nil
+		ins.Mtime = nil
+	}
+	return proto.Equal(cur, prev)
+}
+
 func (x *XDSServer) DebugHandlers() []apiserver.DebugHandler {
 	return []apiserver.DebugHandler{
 		{
@@ -465,5 +681,33 @@ func (x *XDSServer) DebugHandlers() []apiserver.DebugHandler {
 			Path:    "/debug/apiserver/xds/resources",
 			Handler: x.listXDSResources,
 		},
+		{
+			Path:    "/debug/apiserver/xds/ack_status",
+			Handler: x.listXDSAckStatus,
+		},
+	}
+}
+
+// GetNodeAckStatus 查询 envoy 节点对每一类资源最近一次推送的确认结果
+func (x *XDSServer) GetNodeAckStatus(nodeId string) []resource.XDSAckStatus {
+	if x.ackTracker == nil {
+		return nil
+	}
+	return x.ackTracker.GetNodeStatus(nodeId)
+}
+
+// queryLastHeartbeat 从健康检查模块查询实例最近一次心跳的时间
+func (x *XDSServer) queryLastHeartbeat(instanceId string) (time.Time, bool) {
+	if x.healthSvr == nil {
+		return time.Time{}, false
+	}
+	resp := x.healthSvr.GetLastHeartbeat(&apiservice.Instance{Id: utils.NewStringValue(instanceId)})
+	if resp.GetCode().GetValue() != api.ExecuteSuccess {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(resp.GetInstance().GetMetadata()["last-heartbeat-timestamp"], 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}, false
 	}
+	return time.Unix(sec, 0), true
 }
@@ -38,6 +38,8 @@ type AuthChecker interface {
 	CheckClientPermission(preCtx *model.AcquireContext) (bool, error)
 	// CheckConsolePermission 执行检查控制台动作判断是否有权限，并且对 RequestContext 注入操作者数据
 	CheckConsolePermission(preCtx *model.AcquireContext) (bool, error)
+	// CheckResourcePermission 不区分读写操作，检查客户端是否拥有访问目标资源的权限
+	CheckResourcePermission(preCtx *model.AcquireContext) (bool, error)
 	// IsOpenConsoleAuth 返回是否开启了操作鉴权，可以用于前端查询
 	IsOpenConsoleAuth() bool
 	// IsOpenClientAuth
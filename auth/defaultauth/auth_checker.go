@@ -133,9 +133,130 @@ func (d *DefaultAuthChecker) CheckClientPermission(preCtx *model.AcquireContext)
 	if !d.IsOpenClientAuth() {
 		return true, nil
 	}
+	if token, _ := preCtx.GetAttachment(model.DelegationTokenKey).(string); token != "" {
+		return d.checkDelegatedPermission(preCtx, token)
+	}
 	return d.CheckPermission(preCtx)
 }
 
+// checkDelegatedPermission 机器客户端代理用户访问资源时的权限检查
+//
+//	step 1. 机器客户端自身的凭据必须合法，不允许降级为匿名用户后再进行代理
+//	step 2. 被代理的必须是和机器客户端属于同一个主账户下、并且 token 没有被禁用的用户
+//	step 3. 机器客户端以及被代理用户都需要拥有目标资源的权限，代理访问的权限不会超过任意一方
+func (d *DefaultAuthChecker) checkDelegatedPermission(authCtx *model.AcquireContext, token string) (bool, error) {
+	if err := d.VerifyCredential(authCtx); err != nil {
+		return false, err
+	}
+	operator, _ := authCtx.GetAttachment(model.TokenDetailInfoKey).(OperatorInfo)
+	delegator, err := d.verifyDelegator(operator, token)
+	if err != nil {
+		log.Warn("[Auth][Checker] verify delegation token fail", utils.RequestID(authCtx.GetRequestContext()),
+			zap.String("operator", operator.OperatorID), zap.Error(err))
+		return false, err
+	}
+	authCtx.SetAttachment(model.DelegatorIDKey, delegator.OperatorID)
+
+	if authCtx.GetOperation() == model.Read {
+		return true, nil
+	}
+	if operator.Disable {
+		return false, model.ErrorTokenDisabled
+	}
+
+	delegatorPrincipal := model.Principal{
+		PrincipalID:   delegator.OperatorID,
+		PrincipalRole: model.PrincipalUser,
+	}
+	check := func() (bool, error) {
+		if ok, err := d.doCheckPermission(authCtx); !ok {
+			return ok, err
+		}
+		return d.checkPrincipalPermission(authCtx, delegatorPrincipal)
+	}
+	if ok, _ := check(); ok {
+		return true, nil
+	}
+	// 强制同步一次db中strategy数据到cache
+	if err := d.cacheMgn.AuthStrategy().ForceSync(); err != nil {
+		log.Error("[Auth][Checker] force sync strategy to cache failed",
+			utils.RequestID(authCtx.GetRequestContext()), zap.Error(err))
+		return false, err
+	}
+	return check()
+}
+
+// verifyDelegator 校验代理链，任何一个环节不合法都返回 model.ErrorDelegationInvalid
+func (d *DefaultAuthChecker) verifyDelegator(operator OperatorInfo, token string) (OperatorInfo, error) {
+	if IsEmptyOperator(operator) {
+		return OperatorInfo{}, errors.Wrap(model.ErrorDelegationInvalid, "anonymous operator can't delegate")
+	}
+	delegator, err := d.decodeToken(token)
+	if err != nil {
+		return OperatorInfo{}, errors.Wrap(model.ErrorDelegationInvalid, "decode delegation token")
+	}
+	if !delegator.IsUserToken {
+		return OperatorInfo{}, errors.Wrap(model.ErrorDelegationInvalid, "delegator must be user")
+	}
+	ownerId, _, err := d.checkToken(&delegator)
+	if err != nil {
+		return OperatorInfo{}, errors.Wrap(model.ErrorDelegationInvalid, err.Error())
+	}
+	if delegator.Disable {
+		return OperatorInfo{}, errors.Wrap(model.ErrorDelegationInvalid, "delegator token already disabled")
+	}
+	if ownerId != operator.OwnerID {
+		return OperatorInfo{}, errors.Wrap(model.ErrorDelegationInvalid, "delegator belongs to other owner")
+	}
+	delegator.OwnerID = ownerId
+	return delegator, nil
+}
+
+// CheckResourcePermission 客户端已经拿到的资源在推送前重新鉴权，读操作同样需要检查操作者（以及被代理的用户）的资源权限
+func (d *DefaultAuthChecker) CheckResourcePermission(preCtx *model.AcquireContext) (bool, error) {
+	preCtx.SetFromClient()
+	if !d.IsOpenClientAuth() {
+		return true, nil
+	}
+	if err := d.VerifyCredential(preCtx); err != nil {
+		return false, err
+	}
+	principleID, _ := preCtx.GetAttachment(model.OperatorIDKey).(string)
+	principleType, _ := preCtx.GetAttachment(model.OperatorPrincipalType).(model.PrincipalType)
+	principals := []model.Principal{{PrincipalID: principleID, PrincipalRole: principleType}}
+	if token, _ := preCtx.GetAttachment(model.DelegationTokenKey).(string); token != "" {
+		operator, _ := preCtx.GetAttachment(model.TokenDetailInfoKey).(OperatorInfo)
+		delegator, err := d.verifyDelegator(operator, token)
+		if err != nil {
+			return false, err
+		}
+		preCtx.SetAttachment(model.DelegatorIDKey, delegator.OperatorID)
+		principals = append(principals, model.Principal{
+			PrincipalID:   delegator.OperatorID,
+			PrincipalRole: model.PrincipalUser,
+		})
+	}
+
+	check := func() (bool, error) {
+		for _, p := range principals {
+			if ok, err := d.checkPrincipalPermission(preCtx, p); !ok {
+				return ok, err
+			}
+		}
+		return true, nil
+	}
+	if ok, _ := check(); ok {
+		return true, nil
+	}
+	// 强制同步一次db中strategy数据到cache
+	if err := d.cacheMgn.AuthStrategy().ForceSync(); err != nil {
+		log.Error("[Auth][Checker] force sync strategy to cache failed",
+			utils.RequestID(preCtx.GetRequestContext()), zap.Error(err))
+		return false, err
+	}
+	return check()
+}
+
 // CheckConsolePermission 执行检查控制台动作判断是否有权限，并且对 RequestContext 注入操作者数据
 func (d *DefaultAuthChecker) CheckConsolePermission(preCtx *model.AcquireContext) (bool, error) {
 	preCtx.SetFromConsole()
@@ -385,45 +506,72 @@ func (d *DefaultAuthChecker) checkToken(tokenInfo *OperatorInfo) (string, bool,
 	return group.Owner, false, nil
 }
 
-func (d *DefaultAuthChecker) isResourceEditable(
+// deniedResources 一次性检查所有资源，返回没有权限的资源列表
+func (d *DefaultAuthChecker) deniedResources(
 	principal model.Principal,
 	resourceType apisecurity.ResourceType,
-	resEntries []model.ResourceEntry) bool {
+	resEntries []model.ResourceEntry) []model.ResourceEntry {
+	if len(resEntries) == 0 {
+		return nil
+	}
+	var denied []model.ResourceEntry
+	resIds := make([]string, 0, len(resEntries))
 	for _, entry := range resEntries {
-		if !d.cacheMgn.AuthStrategy().IsResourceEditable(principal, resourceType, entry.ID) {
-			return false
+		// 通配的资源表示跨命名空间访问，只有被授权了全部资源的策略才能访问，没有任何策略关联全部资源时不能直接放行
+		if entry.ID == utils.MatchAll && !d.cacheMgn.AuthStrategy().IsResourceLinkStrategy(resourceType, entry.ID) {
+			denied = append(denied, entry)
+			continue
 		}
+		resIds = append(resIds, entry.ID)
 	}
-	return true
+	deniedIds := d.cacheMgn.AuthStrategy().DeniedResources(principal, resourceType, resIds)
+	if len(deniedIds) == 0 {
+		return denied
+	}
+	deniedSet := make(map[string]struct{}, len(deniedIds))
+	for _, id := range deniedIds {
+		deniedSet[id] = struct{}{}
+	}
+	for _, entry := range resEntries {
+		if _, ok := deniedSet[entry.ID]; ok {
+			denied = append(denied, entry)
+		}
+	}
+	return denied
 }
 
-// doCheckPermission 执行权限检查
+// doCheckPermission 执行权限检查，鉴权失败时通过 model.ResourcePermissionError 返回所有没有权限的资源
 func (d *DefaultAuthChecker) doCheckPermission(authCtx *model.AcquireContext) (bool, error) {
-
-	var checkNamespace, checkSvc, checkCfgGroup bool
-
-	reqRes := authCtx.GetAccessResources()
-	nsResEntries := reqRes[apisecurity.ResourceType_Namespaces]
-	svcResEntries := reqRes[apisecurity.ResourceType_Services]
-	cfgResEntries := reqRes[apisecurity.ResourceType_ConfigGroups]
-
 	principleID, _ := authCtx.GetAttachment(model.OperatorIDKey).(string)
 	principleType, _ := authCtx.GetAttachment(model.OperatorPrincipalType).(model.PrincipalType)
-	p := model.Principal{
+	return d.checkPrincipalPermission(authCtx, model.Principal{
 		PrincipalID:   principleID,
 		PrincipalRole: principleType,
-	}
-	checkNamespace = d.isResourceEditable(p, apisecurity.ResourceType_Namespaces, nsResEntries)
-	checkSvc = d.isResourceEditable(p, apisecurity.ResourceType_Services, svcResEntries)
-	checkCfgGroup = d.isResourceEditable(p, apisecurity.ResourceType_ConfigGroups, cfgResEntries)
+	})
+}
 
-	checkAllResEntries := checkNamespace && checkSvc && checkCfgGroup
+// checkPrincipalPermission 检查指定的操作者是否拥有本次访问的所有资源的权限
+func (d *DefaultAuthChecker) checkPrincipalPermission(authCtx *model.AcquireContext,
+	p model.Principal) (bool, error) {
+	reqRes := authCtx.GetAccessResources()
+	deniedRes := map[apisecurity.ResourceType][]model.ResourceEntry{}
+	for _, resType := range []apisecurity.ResourceType{
+		apisecurity.ResourceType_Namespaces,
+		apisecurity.ResourceType_Services,
+		apisecurity.ResourceType_ConfigGroups,
+	} {
+		if denied := d.deniedResources(p, resType, reqRes[resType]); len(denied) != 0 {
+			deniedRes[resType] = denied
+		}
+	}
 
-	var err error
-	if !checkAllResEntries {
-		err = ErrorNotPermission
+	if len(deniedRes) != 0 {
+		return false, &model.ResourcePermissionError{
+			Err:             ErrorNotPermission,
+			DeniedResources: deniedRes,
+		}
 	}
-	return checkAllResEntries, err
+	return true, nil
 }
 
 // checkAction 检查操作是否和策略匹配
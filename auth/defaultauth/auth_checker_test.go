@@ -639,6 +639,308 @@ func Test_DefaultAuthChecker_CheckPermission_Write_Strict(t *testing.T) {
 	})
 }
 
+func Test_DefaultAuthChecker_CheckClientPermission_Delegation(t *testing.T) {
+	reset(true)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := createMockUser(10)
+	groups := createMockUserGroup(users)
+	// 另外一个主账户下的用户
+	otherUsers := createMockUser(2, "other")
+
+	namespaces := createMockNamespace(len(users)+len(groups)+10, users[0].ID)
+	services := createMockService(namespaces)
+	serviceMap := convertServiceSliceToMap(services)
+	strategies, _ := createMockStrategy(users, groups, services[:len(users)+len(groups)])
+
+	cfg, storage := initCache(ctrl)
+
+	storage.EXPECT().GetUsersForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(append(users, otherUsers...), nil)
+	storage.EXPECT().GetGroupsForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(groups, nil)
+	storage.EXPECT().GetStrategyDetailsForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(strategies, nil)
+	storage.EXPECT().GetMoreNamespaces(gomock.Any()).AnyTimes().Return(namespaces, nil)
+	storage.EXPECT().GetMoreServices(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().Return(serviceMap, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cacheMgn, err := cache.TestCacheInitialize(ctx, cfg, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cacheMgn.OpenResourceCache([]cache.ConfigEntry{
+		{
+			Name: "users",
+		},
+		{
+			Name: "strategyRule",
+		},
+		{
+			Name: "namespace",
+		},
+	}...); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheMgn.TestUpdate(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+		cacheMgn.Close()
+	})
+
+	time.Sleep(time.Second)
+
+	checker := &defaultauth.DefaultAuthChecker{}
+	checker.SetCacheMgr(cacheMgn)
+
+	freeIndex := len(users) + len(groups) + 1
+
+	// users[1] 作为机器客户端，users[i] 只拥有 services[i] 的权限，services[freeIndex] 没有关联任何策略
+	check := func(delegation string, op model.ResourceOperation, svc *model.Service) (*model.AcquireContext, error) {
+		return checkDelegation(checker, users[1].Token, delegation, op, svc)
+	}
+
+	t.Run("代理合法-双方都有资源权限", func(t *testing.T) {
+		authCtx, err := check(users[2].Token, model.Modify, services[freeIndex])
+		assert.NoError(t, err)
+		assert.Equal(t, users[2].ID, authCtx.GetAttachment(model.DelegatorIDKey))
+	})
+
+	t.Run("代理合法-被代理用户没有资源权限", func(t *testing.T) {
+		_, err := check(users[2].Token, model.Modify, services[1])
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, model.ErrorDelegationInvalid))
+	})
+
+	t.Run("代理合法-不能借助被代理用户提升机器客户端的权限", func(t *testing.T) {
+		_, err := check(users[2].Token, model.Modify, services[2])
+		assert.Error(t, err)
+		_, err = check(users[0].Token, model.Modify, services[0])
+		assert.Error(t, err)
+	})
+
+	t.Run("代理非法-伪造的token", func(t *testing.T) {
+		_, err := check("forged-delegation-token", model.Read, services[freeIndex])
+		assert.True(t, errors.Is(err, model.ErrorDelegationInvalid), err)
+
+		// 使用合法的格式重新签发的 token 与用户当前的 token 不一致
+		forged, _ := defaultauth.TestCreateToken(users[2].ID, "")
+		_, err = check(forged, model.Modify, services[freeIndex])
+		assert.True(t, errors.Is(err, model.ErrorDelegationInvalid), err)
+
+		// 不存在的用户
+		forged, _ = defaultauth.TestCreateToken("fake-delegator", "")
+		_, err = check(forged, model.Read, services[freeIndex])
+		assert.True(t, errors.Is(err, model.ErrorDelegationInvalid), err)
+	})
+
+	t.Run("代理非法-被代理者不是用户", func(t *testing.T) {
+		_, err := check(groups[2].Token, model.Modify, services[freeIndex])
+		assert.True(t, errors.Is(err, model.ErrorDelegationInvalid), err)
+	})
+
+	t.Run("代理非法-被代理用户属于其他主账户", func(t *testing.T) {
+		_, err := check(otherUsers[1].Token, model.Read, services[freeIndex])
+		assert.True(t, errors.Is(err, model.ErrorDelegationInvalid), err)
+	})
+
+	t.Run("代理非法-匿名用户不能进行代理", func(t *testing.T) {
+		defaultauth.AuthOption.ClientStrict = false
+		defer reset(true)
+		_, err := checkDelegation(checker, "", users[2].Token, model.Read, services[freeIndex])
+		assert.True(t, errors.Is(err, model.ErrorDelegationInvalid), err)
+	})
+}
+
+func Test_DefaultAuthChecker_CheckClientPermission_WildcardNamespace(t *testing.T) {
+	reset(true)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := createMockUser(4)
+	// 两个命名空间下各有一个配置分组，分别只授权给 users[2] 和 users[3]
+	groupStrategy := func(userIndex int, groupID string) *model.StrategyDetail {
+		id := utils.NewUUID()
+		return &model.StrategyDetail{
+			ID:         id,
+			Name:       "strategy_config_group_" + groupID,
+			Action:     apisecurity.AuthAction_READ_WRITE.String(),
+			Principals: []model.Principal{{PrincipalID: users[userIndex].ID, PrincipalRole: model.PrincipalUser}},
+			Owner:      users[0].ID,
+			Resources: []model.StrategyResource{
+				{StrategyID: id, ResType: int32(apisecurity.ResourceType_ConfigGroups), ResID: groupID},
+			},
+			Valid:    true,
+			Revision: utils.NewUUID(),
+		}
+	}
+	newChecker := func(strategies []*model.StrategyDetail) *defaultauth.DefaultAuthChecker {
+		cfg, storage := initCache(ctrl)
+		storage.EXPECT().GetUsersForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(users, nil)
+		storage.EXPECT().GetGroupsForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+		storage.EXPECT().GetStrategyDetailsForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(strategies, nil)
+		storage.EXPECT().GetMoreNamespaces(gomock.Any()).AnyTimes().Return(nil, nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cacheMgn, err := cache.TestCacheInitialize(ctx, cfg, storage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := cacheMgn.OpenResourceCache([]cache.ConfigEntry{
+			{Name: "users"}, {Name: "strategyRule"}, {Name: "namespace"},
+		}...); err != nil {
+			t.Fatal(err)
+		}
+		if err := cacheMgn.TestUpdate(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			cancel()
+			cacheMgn.Close()
+		})
+		checker := &defaultauth.DefaultAuthChecker{}
+		checker.SetCacheMgr(cacheMgn)
+		return checker
+	}
+	check := func(checker *defaultauth.DefaultAuthChecker, token string, groupIDs ...string) error {
+		entries := make([]model.ResourceEntry, 0, len(groupIDs))
+		for _, id := range groupIDs {
+			entries = append(entries, model.ResourceEntry{ID: id})
+		}
+		authCtx := model.NewAcquireContext(
+			model.WithRequestContext(context.WithValue(context.Background(), utils.ContextAuthTokenKey, token)),
+			model.WithMethod("Test_DefaultAuthChecker_CheckClientPermission_WildcardNamespace"),
+			// 读操作不做资源鉴权，这里使用发布配置等写操作
+			model.WithOperation(model.Modify),
+			model.WithModule(model.ConfigModule),
+			model.WithAccessResources(map[apisecurity.ResourceType][]model.ResourceEntry{
+				apisecurity.ResourceType_ConfigGroups: entries,
+			}),
+		)
+		_, err := checker.CheckClientPermission(authCtx)
+		return err
+	}
+
+	t.Run("授权了全部配置分组", func(t *testing.T) {
+		wildcard := groupStrategy(1, utils.MatchAll)
+		checker := newChecker([]*model.StrategyDetail{wildcard, groupStrategy(2, "1"), groupStrategy(3, "2")})
+
+		// 一个策略即可访问两个命名空间下的配置分组，以及跨命名空间的通配资源
+		assert.NoError(t, check(checker, users[1].Token, "1", "2"))
+		assert.NoError(t, check(checker, users[1].Token, utils.MatchAll))
+		// 普通客户端的权限不受影响，不能访问其他命名空间的分组，也不能跨命名空间访问
+		assert.NoError(t, check(checker, users[2].Token, "1"))
+		assert.Error(t, check(checker, users[2].Token, "2"))
+		assert.Error(t, check(checker, users[2].Token, utils.MatchAll))
+	})
+
+	t.Run("没有策略授权全部配置分组", func(t *testing.T) {
+		checker := newChecker([]*model.StrategyDetail{groupStrategy(2, "1")})
+		// 通配资源没有关联任何策略时不能直接放行
+		assert.Error(t, check(checker, users[2].Token, utils.MatchAll))
+		assert.NoError(t, check(checker, users[2].Token, "1"))
+	})
+}
+
+func Test_DefaultAuthChecker_CheckResourcePermission(t *testing.T) {
+	reset(true)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	users := createMockUser(4)
+	id := utils.NewUUID()
+	// 配置分组只授权给 users[2]
+	strategy := &model.StrategyDetail{
+		ID:         id,
+		Name:       "strategy_config_group_1",
+		Action:     apisecurity.AuthAction_READ_WRITE.String(),
+		Principals: []model.Principal{{PrincipalID: users[2].ID, PrincipalRole: model.PrincipalUser}},
+		Owner:      users[0].ID,
+		Resources: []model.StrategyResource{
+			{StrategyID: id, ResType: int32(apisecurity.ResourceType_ConfigGroups), ResID: "1"},
+		},
+		Valid:    true,
+		Revision: utils.NewUUID(),
+	}
+	cfg, storage := initCache(ctrl)
+	storage.EXPECT().GetUsersForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(users, nil)
+	storage.EXPECT().GetGroupsForCache(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+	storage.EXPECT().GetStrategyDetailsForCache(gomock.Any(), gomock.Any()).AnyTimes().
+		Return([]*model.StrategyDetail{strategy}, nil)
+	storage.EXPECT().GetMoreNamespaces(gomock.Any()).AnyTimes().Return(nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cacheMgn, err := cache.TestCacheInitialize(ctx, cfg, storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cacheMgn.Close()
+	if err := cacheMgn.OpenResourceCache([]cache.ConfigEntry{
+		{Name: "users"}, {Name: "strategyRule"}, {Name: "namespace"},
+	}...); err != nil {
+		t.Fatal(err)
+	}
+	if err := cacheMgn.TestUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	checker := &defaultauth.DefaultAuthChecker{}
+	checker.SetCacheMgr(cacheMgn)
+
+	newAuthCtx := func(token, delegation string) *model.AcquireContext {
+		return model.NewAcquireContext(
+			model.WithRequestContext(context.WithValue(context.Background(), utils.ContextAuthTokenKey, token)),
+			model.WithMethod("Test_DefaultAuthChecker_CheckResourcePermission"),
+			model.WithOperation(model.Read),
+			model.WithModule(model.ConfigModule),
+			model.WithDelegationToken(delegation),
+			model.WithAccessResources(map[apisecurity.ResourceType][]model.ResourceEntry{
+				apisecurity.ResourceType_ConfigGroups: {{ID: "1"}},
+			}),
+		)
+	}
+
+	// 读操作在 CheckClientPermission 中只要凭据合法就放行
+	_, err = checker.CheckClientPermission(newAuthCtx(users[3].Token, ""))
+	assert.NoError(t, err)
+
+	// CheckResourcePermission 需要真正拥有配置分组的权限
+	_, err = checker.CheckResourcePermission(newAuthCtx(users[2].Token, ""))
+	assert.NoError(t, err)
+	_, err = checker.CheckResourcePermission(newAuthCtx(users[3].Token, ""))
+	var permErr *model.ResourcePermissionError
+	assert.True(t, errors.As(err, &permErr), err)
+	assert.True(t, permErr.IsDenied(apisecurity.ResourceType_ConfigGroups, "1"))
+
+	// 代理访问时被代理的用户同样需要拥有权限
+	_, err = checker.CheckResourcePermission(newAuthCtx(users[2].Token, users[3].Token))
+	assert.Error(t, err)
+}
+
+func checkDelegation(checker *defaultauth.DefaultAuthChecker, token, delegation string,
+	op model.ResourceOperation, svc *model.Service) (*model.AcquireContext, error) {
+	ctx := context.WithValue(context.Background(), utils.ContextAuthTokenKey, token)
+	authCtx := model.NewAcquireContext(
+		model.WithRequestContext(ctx),
+		model.WithMethod("Test_DefaultAuthChecker_CheckClientPermission_Delegation"),
+		model.WithOperation(op),
+		model.WithModule(model.ConfigModule),
+		model.WithDelegationToken(delegation),
+		model.WithAccessResources(map[apisecurity.ResourceType][]model.ResourceEntry{
+			apisecurity.ResourceType_Services: {
+				{
+					ID:    svc.ID,
+					Owner: svc.Owner,
+				},
+			},
+		}),
+	)
+	_, err := checker.CheckClientPermission(authCtx)
+	return authCtx, err
+}
+
 func Test_DefaultAuthChecker_CheckPermission_Read_NoStrict(t *testing.T) {
 	reset(false)
 	ctrl := gomock.NewController(t)
@@ -457,6 +457,21 @@ func (mr *MockAuthCheckerMockRecorder) CheckClientPermission(preCtx interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckClientPermission", reflect.TypeOf((*MockAuthChecker)(nil).CheckClientPermission), preCtx)
 }
 
+// CheckResourcePermission mocks base method.
+func (m *MockAuthChecker) CheckResourcePermission(preCtx *model.AcquireContext) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckResourcePermission", preCtx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckResourcePermission indicates an expected call of CheckResourcePermission.
+func (mr *MockAuthCheckerMockRecorder) CheckResourcePermission(preCtx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckResourcePermission", reflect.TypeOf((*MockAuthChecker)(nil).CheckResourcePermission), preCtx)
+}
+
 // CheckConsolePermission mocks base method.
 func (m *MockAuthChecker) CheckConsolePermission(preCtx *model.AcquireContext) (bool, error) {
 	m.ctrl.T.Helper()
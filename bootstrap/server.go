@@ -212,6 +212,12 @@ func StartComponents(ctx context.Context, cfg *boot_config.Config) error {
 	if err := cache.Run(cacheMgn, ctx); err != nil {
 		return err
 	}
+	// 等待缓存预热完成后再对外提供客户端接口，超时后不阻断启动
+	originSvr, err := service.GetOriginServer()
+	if err != nil {
+		return err
+	}
+	_ = originSvr.WaitCacheWarmUp(ctx)
 	return nil
 }
 
@@ -265,6 +271,7 @@ func StartDiscoverComponents(ctx context.Context, cfg *boot_config.Config, s sto
 	opts := []service.InitOption{
 		service.WithBatchController(bc),
 		service.WithStorage(s),
+		service.WithCacheWarmUp(cfg.Naming.CacheWarmUpTimeout),
 		service.WithCacheManager(&cfg.Cache, cacheMgn),
 		service.WithHealthCheckSvr(healthCheckServer),
 		service.WithNamespaceSvr(namespaceSvr),
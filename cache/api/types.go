@@ -446,6 +446,10 @@ type (
 		GetRelease(key model.ConfigFileReleaseKey) *model.ConfigFileRelease
 		// QueryReleases
 		QueryReleases(args *ConfigReleaseArgs) (uint32, []*model.SimpleConfigFileRelease, error)
+		// GetReleaseContentByVersion 获取配置文件某个版本发布的内容
+		GetReleaseContentByVersion(namespace, group, fileName string, version uint64) (string, bool)
+		// GetMaxReleaseVersion 获取配置文件所有发布中最大的版本号
+		GetMaxReleaseVersion(namespace, group, fileName string) uint64
 	}
 )
 
@@ -480,6 +484,8 @@ type (
 		IsResourceLinkStrategy(resType apisecurity.ResourceType, resId string) bool
 		// IsResourceEditable 判断该资源是否可以操作
 		IsResourceEditable(principal model.Principal, resType apisecurity.ResourceType, resId string) bool
+		// DeniedResources 批量判断资源是否可以操作，返回不可以操作的资源ID
+		DeniedResources(principal model.Principal, resType apisecurity.ResourceType, resIds []string) []string
 		// ForceSync 强制同步鉴权策略到cache (串行)
 		ForceSync() error
 	}
@@ -586,6 +592,8 @@ func (bc *BaseCache) OriginLastFetchTime() time.Time {
 }
 
 func (bc *BaseCache) IsFirstUpdate() bool {
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
 	return bc.firstUpdate
 }
 
@@ -336,25 +336,25 @@ func (sc *strategyCache) checkResourceEditable(strategIds *utils.SyncSet[string]
 // 这里需要考虑两种情况，一种是 “ * ” 策略，另一种是明确指出了具体的资源ID的策略
 func (sc *strategyCache) IsResourceEditable(
 	principal model.Principal, resType apisecurity.ResourceType, resId string) bool {
-	var (
-		valAll, val *utils.SyncSet[string]
-		ok          bool
-	)
+	return len(sc.DeniedResources(principal, resType, []string{resId})) == 0
+}
+
+// DeniedResources 批量判断资源是否可以操作，返回不可以操作的资源ID，操作者关联的用户组只解析一次
+func (sc *strategyCache) DeniedResources(
+	principal model.Principal, resType apisecurity.ResourceType, resIds []string) []string {
+	var index *utils.SyncMap[string, *utils.SyncSet[string]]
 	switch resType {
 	case apisecurity.ResourceType_Namespaces:
-		val, ok = sc.namespace2Strategy.Load(resId)
-		valAll, _ = sc.namespace2Strategy.Load("*")
+		index = sc.namespace2Strategy
 	case apisecurity.ResourceType_Services:
-		val, ok = sc.service2Strategy.Load(resId)
-		valAll, _ = sc.service2Strategy.Load("*")
+		index = sc.service2Strategy
 	case apisecurity.ResourceType_ConfigGroups:
-		val, ok = sc.configGroup2Strategy.Load(resId)
-		valAll, _ = sc.configGroup2Strategy.Load("*")
+		index = sc.configGroup2Strategy
+	default:
+		return nil
 	}
-
-	// 代表该资源没有关联到任何策略，任何人都可以编辑
-	if !ok {
-		return true
+	if len(resIds) == 0 {
+		return nil
 	}
 
 	principals := make([]model.Principal, 0, 4)
@@ -369,18 +369,33 @@ func (sc *strategyCache) IsResourceEditable(
 		}
 	}
 
-	for i := range principals {
-		item := principals[i]
-		if valAll != nil && sc.checkResourceEditable(valAll, item, true) {
-			return true
+	// 拥有全部资源的权限时不需要再逐个检查资源
+	if valAll, ok := index.Load("*"); ok {
+		for i := range principals {
+			if sc.checkResourceEditable(valAll, principals[i], true) {
+				return nil
+			}
 		}
-
-		if sc.checkResourceEditable(val, item, false) {
-			return true
+	}
+	var denied []string
+	for _, resId := range resIds {
+		val, ok := index.Load(resId)
+		// 代表该资源没有关联到任何策略，任何人都可以编辑
+		if !ok {
+			continue
+		}
+		editable := false
+		for i := range principals {
+			if sc.checkResourceEditable(val, principals[i], false) {
+				editable = true
+				break
+			}
+		}
+		if !editable {
+			denied = append(denied, resId)
 		}
 	}
-
-	return false
+	return denied
 }
 
 func (sc *strategyCache) GetStrategyDetailsByUID(uid string) []*model.StrategyDetail {
@@ -387,6 +387,40 @@ func Test_strategyCache_IsResourceEditable_1(t *testing.T) {
 	})
 }
 
+func Test_strategyCache_DeniedResources(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockCacheMgr := cachemock.NewMockCacheManager(ctrl)
+	mockStore := mock.NewMockStore(ctrl)
+
+	userCache := NewUserCache(mockStore, mockCacheMgr)
+	strategyCache := NewStrategyCache(mockStore, mockCacheMgr).(*strategyCache)
+	mockCacheMgr.EXPECT().GetCacher(types.CacheUser).Return(userCache).AnyTimes()
+	userCache.Initialize(map[string]interface{}{})
+	strategyCache.Initialize(map[string]interface{}{})
+	strategyCache.setStrategys(buildStrategies(3))
+
+	principal := model.Principal{PrincipalID: "user-1", PrincipalRole: model.PrincipalUser}
+	// 一次检查多个资源，只返回没有权限的资源，没有关联策略的资源任何人都可以操作
+	denied := strategyCache.DeniedResources(principal, apisecurity.ResourceType_Namespaces,
+		[]string{"namespace-1", "namespace-2", "namespace-3", "namespace-free"})
+	assert.Equal(t, []string{"namespace-2", "namespace-3"}, denied)
+	assert.Empty(t, strategyCache.DeniedResources(principal, apisecurity.ResourceType_Namespaces, nil))
+
+	// 拥有全部资源权限的操作者不需要逐个检查
+	strategyCache.setStrategys([]*model.StrategyDetail{
+		{
+			ID:         "rule-all",
+			Name:       "rule-all",
+			Principals: []model.Principal{principal},
+			Valid:      true,
+			Resources:  []model.StrategyResource{{StrategyID: "rule-all", ResType: 0, ResID: "*"}},
+		},
+	})
+	assert.Empty(t, strategyCache.DeniedResources(principal, apisecurity.ResourceType_Namespaces,
+		[]string{"namespace-1", "namespace-2", "namespace-3"}))
+}
+
 func buildStrategies(num int) []*model.StrategyDetail {
 
 	ret := make([]*model.StrategyDetail, 0, num)
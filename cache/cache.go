@@ -35,8 +35,15 @@ var (
 const (
 	// UpdateCacheInterval 缓存更新时间间隔
 	UpdateCacheInterval = 1 * time.Second
+	// waitFirstUpdateInterval 等待缓存首次加载时的检查间隔
+	waitFirstUpdateInterval = 50 * time.Millisecond
 )
 
+// firstUpdater 可以感知是否完成首次加载的缓存
+type firstUpdater interface {
+	IsFirstUpdate() bool
+}
+
 // CacheManager 名字服务缓存
 type CacheManager struct {
 	storage  store.Store
@@ -147,6 +154,42 @@ func (nc *CacheManager) Start(ctx context.Context) error {
 	return nil
 }
 
+// WaitFirstUpdate 等待指定的缓存完成首次加载，ctx 结束时返回仍未完成首次加载的缓存名称
+func (nc *CacheManager) WaitFirstUpdate(ctx context.Context, names ...string) []string {
+	ticker := time.NewTicker(waitFirstUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		lagging := nc.laggingCaches(names)
+		if len(lagging) == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return lagging
+		}
+	}
+}
+
+// laggingCaches 获取已开启但是还未完成首次加载的缓存
+func (nc *CacheManager) laggingCaches(names []string) []string {
+	lagging := make([]string, 0, len(names))
+	for _, name := range names {
+		if !nc.needLoad.Contains(name) {
+			continue
+		}
+		index, exist := cacheSet[name]
+		if !exist {
+			continue
+		}
+		if c, ok := nc.caches[index].(firstUpdater); ok && c.IsFirstUpdate() {
+			lagging = append(lagging, name)
+		}
+	}
+	return lagging
+}
+
 // Clear 主动清除缓存数据
 func (nc *CacheManager) Clear() error {
 	return nc.clear()
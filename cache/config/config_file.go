@@ -168,6 +168,8 @@ func (fc *fileCache) setReleases(releases []*model.ConfigFileRelease) (map[strin
 			lastMtime = modifyUnix
 		}
 		oldVal, _ := fc.releases.Get(item.Id)
+		rotated := isDataKeyRotated(oldVal, item)
+		rollback := isRollback(fc.getActiveSimpleRelease(item.Namespace, item.Group, item.FileName), item)
 		if !item.Valid {
 			del++
 			if err := fc.handleDeleteRelease(oldVal, item); err != nil {
@@ -184,16 +186,40 @@ func (fc *fileCache) setReleases(releases []*model.ConfigFileRelease) (map[strin
 			configLog.Info("[Config][Release][Cache] notify config release change",
 				zap.String("namespace", item.Namespace), zap.String("group", item.Group),
 				zap.String("file", item.FileName), zap.Uint64("version", item.Version), zap.Bool("valid", item.Valid))
-			fc.sendEvent(item)
+			fc.sendEvent(item, rotated, rollback)
 		}
 	}
 	fc.postProcessUpdatedRelease(affect)
 	return map[string]time.Time{fc.Name(): time.Unix(lastMtime, 0)}, update, del, nil
 }
 
-func (fc *fileCache) sendEvent(item *model.ConfigFileRelease) {
+// isDataKeyRotated 加密配置的数据密钥发生了变化但是版本没有变化，客户端需要重新拉取配置解密
+func isDataKeyRotated(oldVal *model.SimpleConfigFileRelease, item *model.ConfigFileRelease) bool {
+	if oldVal == nil || !item.Valid || !item.IsEncrypted() {
+		return false
+	}
+	return oldVal.Version == item.Version && oldVal.GetEncryptDataKey() != item.GetEncryptDataKey()
+}
+
+// isRollback 当前生效的发布被切换为另一个版本更旧的发布，客户端持有的版本比新生效的版本更新
+func isRollback(prevActive *model.SimpleConfigFileRelease, item *model.ConfigFileRelease) bool {
+	if prevActive == nil || !item.Valid || !item.Active {
+		return false
+	}
+	return prevActive.Name != item.Name && item.Version < prevActive.Version
+}
+
+func (fc *fileCache) sendEvent(item *model.ConfigFileRelease, rotated, rollback bool) {
+	message := item.SimpleConfigFileRelease
+	if rotated || rollback {
+		// 缓存中的数据是共享的，轮转以及回滚标记只能打在副本上
+		copyMessage := *message
+		copyMessage.DataKeyRotated = rotated
+		copyMessage.Rollback = rollback
+		message = &copyMessage
+	}
 	err := eventhub.Publish(eventhub.ConfigFilePublishTopic, &eventhub.PublishConfigFileEvent{
-		Message: item.SimpleConfigFileRelease,
+		Message: message,
 	})
 	if err != nil {
 		configLog.Error("[Config][Release][Cache] notify config release change",
@@ -223,6 +249,19 @@ func (fc *fileCache) handleUpdateRelease(oldVal *model.SimpleConfigFileRelease,
 		files.Store(item.Name, item.SimpleConfigFileRelease)
 	}()
 
+	// 保存每一次发布的内容，用于计算客户端持有的历史版本与当前版本之间的差异
+	if item.Content != "" {
+		if err := fc.valueCache.Update(func(tx *bbolt.Tx) error {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(item.OwnerKey()))
+			if err != nil {
+				return err
+			}
+			return bucket.Put([]byte(item.ReleaseKey()), []byte(item.Content))
+		}); err != nil {
+			return errors.Join(err, errors.New("persistent config_file release content fail"))
+		}
+	}
+
 	if !item.Active {
 		return nil
 	}
@@ -276,6 +315,16 @@ func (fc *fileCache) handleDeleteRelease(oldVal *model.SimpleConfigFileRelease,
 		}
 	}()
 
+	if err := fc.valueCache.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(item.OwnerKey()))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(item.ReleaseKey()))
+	}); err != nil {
+		return errors.Join(err, errors.New("remove config_file release content fail"))
+	}
+
 	if oldVal == nil {
 		return nil
 	}
@@ -397,6 +446,19 @@ func (fc *fileCache) GetGroupActiveReleases(namespace, group string) ([]*model.C
 
 // GetActiveRelease
 func (fc *fileCache) GetActiveRelease(namespace, group, fileName string) *model.ConfigFileRelease {
+	simple := fc.getActiveSimpleRelease(namespace, group, fileName)
+	if simple == nil {
+		return nil
+	}
+	ret := &model.ConfigFileRelease{
+		SimpleConfigFileRelease: simple,
+	}
+	fc.loadValueCache(ret)
+	return ret
+}
+
+// getActiveSimpleRelease 获取配置文件当前生效的发布，不加载配置内容
+func (fc *fileCache) getActiveSimpleRelease(namespace, group, fileName string) *model.SimpleConfigFileRelease {
 	nsBucket, ok := fc.activeReleases.Load(namespace)
 	if !ok {
 		return nil
@@ -410,15 +472,8 @@ func (fc *fileCache) GetActiveRelease(namespace, group, fileName string) *model.
 		Group:     group,
 		FileName:  fileName,
 	}
-	simple, ok := groupBucket.Load(searchKey.ActiveKey())
-	if !ok {
-		return nil
-	}
-	ret := &model.ConfigFileRelease{
-		SimpleConfigFileRelease: simple,
-	}
-	fc.loadValueCache(ret)
-	return ret
+	simple, _ := groupBucket.Load(searchKey.ActiveKey())
+	return simple
 }
 
 // GetRelease
@@ -453,6 +508,69 @@ func (fc *fileCache) GetRelease(key model.ConfigFileReleaseKey) *model.ConfigFil
 	return ret
 }
 
+// GetReleaseContentByVersion 获取配置文件某个版本发布的内容，该版本的发布已经被清理时返回 false
+func (fc *fileCache) GetReleaseContentByVersion(namespace, group, fileName string, version uint64) (string, bool) {
+	nsB, ok := fc.name2release.Load(namespace)
+	if !ok {
+		return "", false
+	}
+	groupB, ok := nsB.Load(group)
+	if !ok {
+		return "", false
+	}
+	fileB, ok := groupB.Load(fileName)
+	if !ok {
+		return "", false
+	}
+	var release *model.SimpleConfigFileRelease
+	fileB.ReadRange(func(_ string, item *model.SimpleConfigFileRelease) {
+		if item.Version == version {
+			release = item
+		}
+	})
+	if release == nil {
+		return "", false
+	}
+	var (
+		content string
+		found   bool
+	)
+	_ = fc.valueCache.View(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(release.OwnerKey()))
+		if bucket == nil {
+			return nil
+		}
+		if val := bucket.Get([]byte(release.ReleaseKey())); val != nil {
+			content, found = string(val), true
+		}
+		return nil
+	})
+	return content, found
+}
+
+// GetMaxReleaseVersion 获取配置文件所有发布中最大的版本号，当前生效的发布版本比它小时说明发生过回滚
+func (fc *fileCache) GetMaxReleaseVersion(namespace, group, fileName string) uint64 {
+	nsB, ok := fc.name2release.Load(namespace)
+	if !ok {
+		return 0
+	}
+	groupB, ok := nsB.Load(group)
+	if !ok {
+		return 0
+	}
+	fileB, ok := groupB.Load(fileName)
+	if !ok {
+		return 0
+	}
+	var version uint64
+	fileB.ReadRange(func(_ string, item *model.SimpleConfigFileRelease) {
+		if item.Version > version {
+			version = item.Version
+		}
+	})
+	return version
+}
+
 func (fc *fileCache) QueryReleases(args *types.ConfigReleaseArgs) (uint32, []*model.SimpleConfigFileRelease, error) {
 	if err := fc.Update(); err != nil {
 		return 0, nil, err
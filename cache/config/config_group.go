@@ -26,6 +26,7 @@ import (
 	"golang.org/x/sync/singleflight"
 
 	types "github.com/polarismesh/polaris/cache/api"
+	"github.com/polarismesh/polaris/common/eventhub"
 	"github.com/polarismesh/polaris/common/model"
 	"github.com/polarismesh/polaris/common/utils"
 	"github.com/polarismesh/polaris/store"
@@ -103,8 +104,11 @@ func (fc *configGroupCache) setConfigGroups(groups []*model.ConfigFileGroup) (ma
 	for i := range groups {
 		item := groups[i]
 		affect[item.Namespace] = struct{}{}
+		oldItem, hasOldVal := fc.groups.Load(item.Id)
 
+		eventType := eventhub.EventCreated
 		if !item.Valid {
+			eventType = eventhub.EventDeleted
 			del++
 			fc.groups.Delete(item.Id)
 			nsBucket, ok := fc.name2groups.Load(item.Namespace)
@@ -119,7 +123,15 @@ func (fc *configGroupCache) setConfigGroups(groups []*model.ConfigFileGroup) (ma
 			}
 			nsBucket, _ := fc.name2groups.Load(item.Namespace)
 			nsBucket.Store(item.Name, item)
+			if hasOldVal {
+				eventType = eventhub.EventUpdated
+			}
 		}
+		_ = eventhub.Publish(eventhub.CacheConfigGroupEventTopic, &eventhub.CacheConfigGroupEvent{
+			OldItem:   oldItem,
+			Item:      item,
+			EventType: eventType,
+		})
 
 		modifyUnix := item.ModifyTime.Unix()
 		if modifyUnix > lastMtime {
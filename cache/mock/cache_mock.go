@@ -1806,6 +1806,20 @@ func (mr *MockConfigFileCacheMockRecorder) GetGroupActiveReleases(namespace, gro
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGroupActiveReleases", reflect.TypeOf((*MockConfigFileCache)(nil).GetGroupActiveReleases), namespace, group)
 }
 
+// GetMaxReleaseVersion mocks base method.
+func (m *MockConfigFileCache) GetMaxReleaseVersion(namespace, group, fileName string) uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMaxReleaseVersion", namespace, group, fileName)
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// GetMaxReleaseVersion indicates an expected call of GetMaxReleaseVersion.
+func (mr *MockConfigFileCacheMockRecorder) GetMaxReleaseVersion(namespace, group, fileName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMaxReleaseVersion", reflect.TypeOf((*MockConfigFileCache)(nil).GetMaxReleaseVersion), namespace, group, fileName)
+}
+
 // GetRelease mocks base method.
 func (m *MockConfigFileCache) GetRelease(key model.ConfigFileReleaseKey) *model.ConfigFileRelease {
 	m.ctrl.T.Helper()
@@ -1820,6 +1834,21 @@ func (mr *MockConfigFileCacheMockRecorder) GetRelease(key interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRelease", reflect.TypeOf((*MockConfigFileCache)(nil).GetRelease), key)
 }
 
+// GetReleaseContentByVersion mocks base method.
+func (m *MockConfigFileCache) GetReleaseContentByVersion(namespace, group, fileName string, version uint64) (string, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReleaseContentByVersion", namespace, group, fileName, version)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// GetReleaseContentByVersion indicates an expected call of GetReleaseContentByVersion.
+func (mr *MockConfigFileCacheMockRecorder) GetReleaseContentByVersion(namespace, group, fileName, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReleaseContentByVersion", reflect.TypeOf((*MockConfigFileCache)(nil).GetReleaseContentByVersion), namespace, group, fileName, version)
+}
+
 // Initialize mocks base method.
 func (m *MockConfigFileCache) Initialize(c map[string]interface{}) error {
 	m.ctrl.T.Helper()
@@ -2120,6 +2149,20 @@ func (mr *MockStrategyCacheMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockStrategyCache)(nil).Close))
 }
 
+// DeniedResources mocks base method.
+func (m *MockStrategyCache) DeniedResources(principal model.Principal, resType security.ResourceType, resIds []string) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeniedResources", principal, resType, resIds)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// DeniedResources indicates an expected call of DeniedResources.
+func (mr *MockStrategyCacheMockRecorder) DeniedResources(principal, resType, resIds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeniedResources", reflect.TypeOf((*MockStrategyCache)(nil).DeniedResources), principal, resType, resIds)
+}
+
 // ForceSync mocks base method.
 func (m *MockStrategyCache) ForceSync() error {
 	m.ctrl.T.Helper()
@@ -42,3 +42,8 @@ var (
 func (nc *CacheManager) TestUpdate() error {
 	return nc.update()
 }
+
+// TestIsResourceCacheOpen only for test
+func (nc *CacheManager) TestIsResourceCacheOpen(name string) bool {
+	return nc.needLoad.Contains(name)
+}
@@ -360,8 +360,32 @@ var code2info = map[uint32]string{
 	InvalidRoutingName:   "invalid routing name",
 
 	NamespaceExistedConfigGroups: "some config group existed in namespace",
+
+	ReleasePropagateTimeout: "config file released, but wait release to propagate timeout",
+	ServiceTooBusy:          "server is too busy, please retry later",
+
+	InvalidConfigFileSchema: "config file content does not match the registered schema",
+
+	ConfigFileContentTooLarge: "config file content exceeds the max message size, " +
+		"please download it in chunks via grpc /v1.PolarisConfigStreamGRPC/DownloadConfigFile",
+	WatchInterestRemoved: "config file subscription has been removed",
 }
 
+// specification 中没有定义的错误码，仅在服务端内部扩展使用
+const (
+	// ReleasePropagateTimeout 配置已经发布成功，但是在等待时间内监听中心还没有处理该发布事件
+	ReleasePropagateTimeout = uint32(200100)
+	// ServiceTooBusy 服务端负载过高拒绝了本次请求，客户端需要退避后重试
+	ServiceTooBusy = uint32(429100)
+	// InvalidConfigFileSchema 配置内容没有通过该配置文件注册的 schema 校验
+	InvalidConfigFileSchema = uint32(400890)
+	// ConfigFileContentTooLarge 配置内容超过了单个消息的最大大小，客户端需要通过 gRPC 流式接口
+	// v1.PolarisConfigStreamGRPC/DownloadConfigFile 分片获取配置
+	ConfigFileContentTooLarge = uint32(400891)
+	// WatchInterestRemoved 流式订阅的客户端对某个配置文件的订阅已经被服务端移除
+	WatchInterestRemoved = uint32(400892)
+)
+
 // code to info
 func Code2Info(code uint32) string {
 	info, ok := code2info[code]
@@ -33,6 +33,8 @@ const (
 	CacheClientEventTopic = "cache_client_event"
 	// CacheNamespaceEventTopic record cache occur namespace add/update/del event
 	CacheNamespaceEventTopic = "cache_namespace_event"
+	// CacheConfigGroupEventTopic record cache occur config group add/update/del event
+	CacheConfigGroupEventTopic = "cache_config_group_event"
 )
 
 // PublishConfigFileEvent 事件对象，包含类型和事件消息
@@ -67,3 +69,9 @@ type CacheNamespaceEvent struct {
 	Item      *model.Namespace
 	EventType EventType
 }
+
+type CacheConfigGroupEvent struct {
+	OldItem   *model.ConfigFileGroup
+	Item      *model.ConfigFileGroup
+	EventType EventType
+}
@@ -0,0 +1,41 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package log
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// TestCapture only for test, 将 scope 的日志输出替换为 core，返回恢复原有输出的函数
+func (s *Scope) TestCapture(core zapcore.Core) func() {
+	old := s.getPathTable()
+	s.pt = &patchTable{
+		write: func(ent zapcore.Entry, fields []zapcore.Field) error {
+			if !core.Enabled(ent.Level) {
+				return nil
+			}
+			return core.Write(ent, fields)
+		},
+		sync:        core.Sync,
+		exitProcess: old.exitProcess,
+		errorSink:   old.errorSink,
+	}
+	return func() {
+		s.pt = old
+	}
+}
@@ -48,9 +48,29 @@ func registerConfigFileMetrics() {
 		},
 	}, []string{LabelNamespace, LabelGroup})
 
+	// configClientAuthTotal 配置中心客户端接口的鉴权结果统计
+	configClientAuthTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "config_client_auth_total",
+		Help: "total number of config client requests allowed or denied by auth, labeled by api",
+		ConstLabels: map[string]string{
+			LabelServerNode: utils.LocalHost,
+		},
+	}, []string{LabelApi, labelAuthResult})
+
+	// configWatcherCleanupFailureTotal 配置订阅者清理失败的次数
+	configWatcherCleanupFailureTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "config_watcher_cleanup_failure_total",
+		Help: "total number of config watch contexts failed to close when removing watchers",
+		ConstLabels: map[string]string{
+			LabelServerNode: utils.LocalHost,
+		},
+	})
+
 	_ = GetRegistry().Register(configGroupTotal)
 	_ = GetRegistry().Register(configFileTotal)
 	_ = GetRegistry().Register(releaseConfigFileTotal)
+	_ = GetRegistry().Register(configClientAuthTotal)
+	_ = GetRegistry().Register(configWatcherCleanupFailureTotal)
 }
 
 func GetConfigGroupTotal() *prometheus.GaugeVec {
@@ -64,3 +84,31 @@ func GetConfigFileTotal() *prometheus.GaugeVec {
 func GetReleaseConfigFileTotal() *prometheus.GaugeVec {
 	return releaseConfigFileTotal
 }
+
+func GetConfigClientAuthTotal() *prometheus.CounterVec {
+	return configClientAuthTotal
+}
+
+func GetConfigWatcherCleanupFailureTotal() prometheus.Counter {
+	return configWatcherCleanupFailureTotal
+}
+
+// ReportConfigClientAuth 记录配置中心客户端接口的一次鉴权结果，metrics 没有初始化时不做处理
+func ReportConfigClientAuth(api string, allowed bool) {
+	if configClientAuthTotal == nil {
+		return
+	}
+	result := AuthResultDeny
+	if allowed {
+		result = AuthResultAllow
+	}
+	configClientAuthTotal.WithLabelValues(api, result).Inc()
+}
+
+// ReportConfigWatcherCleanupFailure 记录一次配置订阅者清理失败，metrics 没有初始化时不做处理
+func ReportConfigWatcherCleanupFailure() {
+	if configWatcherCleanupFailureTotal == nil {
+		return
+	}
+	configWatcherCleanupFailureTotal.Inc()
+}
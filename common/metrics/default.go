@@ -61,4 +61,5 @@ func InitMetrics() {
 	registerClientMetrics()
 	registerConfigFileMetrics()
 	registerDiscoveryMetrics()
+	registerXDSMetrics()
 }
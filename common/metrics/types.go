@@ -37,6 +37,14 @@ const (
 	labelCacheType        = "cache_type"
 	labelCacheUpdateCount = "cache_update_count"
 	labelBatchJobLabel    = "batch_label"
+	labelAuthResult       = "auth_result"
+)
+
+const (
+	// AuthResultAllow 鉴权通过
+	AuthResultAllow = "allow"
+	// AuthResultDeny 鉴权拒绝
+	AuthResultDeny = "deny"
 )
 
 // CallMetricType .
@@ -142,9 +150,11 @@ var (
 )
 
 var (
-	configGroupTotal       *prometheus.GaugeVec
-	configFileTotal        *prometheus.GaugeVec
-	releaseConfigFileTotal *prometheus.GaugeVec
+	configGroupTotal                 *prometheus.GaugeVec
+	configFileTotal                  *prometheus.GaugeVec
+	releaseConfigFileTotal           *prometheus.GaugeVec
+	configClientAuthTotal            *prometheus.CounterVec
+	configWatcherCleanupFailureTotal prometheus.Counter
 )
 
 // instance astbc registry metrics
@@ -0,0 +1,69 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	labelXDSTypeUrl   = "type_url"
+	labelXDSAckResult = "ack_result"
+)
+
+const (
+	// XDSAckResultAck envoy 接受了推送的资源
+	XDSAckResultAck = "ack"
+	// XDSAckResultNack envoy 拒绝了推送的资源
+	XDSAckResultNack = "nack"
+)
+
+var (
+	// xdsAckTotal envoy 对 XDS 资源推送的确认结果统计
+	xdsAckTotal *prometheus.CounterVec
+)
+
+func registerXDSMetrics() {
+	xdsAckTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "xds_ack_total",
+		Help: "total number of xds pushes acked or nacked by envoy, labeled by resource type",
+		ConstLabels: map[string]string{
+			LabelServerNode: utils.LocalHost,
+		},
+	}, []string{labelXDSTypeUrl, labelXDSAckResult})
+
+	_ = GetRegistry().Register(xdsAckTotal)
+}
+
+func GetXDSAckTotal() *prometheus.CounterVec {
+	return xdsAckTotal
+}
+
+// ReportXDSAck 记录 envoy 对一次 XDS 资源推送的确认结果，metrics 没有初始化时不做处理
+func ReportXDSAck(typeUrl string, acked bool) {
+	if xdsAckTotal == nil {
+		return
+	}
+	result := XDSAckResultNack
+	if acked {
+		result = XDSAckResultAck
+	}
+	xdsAckTotal.WithLabelValues(typeUrl, result).Inc()
+}
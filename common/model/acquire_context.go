@@ -132,6 +132,18 @@ func WithAttachment(attachment map[string]interface{}) acquireContextOption {
 	}
 }
 
+// WithDelegationToken 设置机器客户端代理的用户 token，token 为空时不做任何处理
+//
+//	@param token
+//	@return acquireContextOption
+func WithDelegationToken(token string) acquireContextOption {
+	return func(authCtx *AcquireContext) {
+		if token != "" {
+			authCtx.attachment[DelegationTokenKey] = token
+		}
+	}
+}
+
 // WithFromConsole 设置本次请求来自控制台
 func WithFromConsole() acquireContextOption {
 	return func(authCtx *AcquireContext) {
@@ -20,8 +20,12 @@ package model
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	apisecurity "github.com/polarismesh/specification/source/go/api/v1/security"
 )
 
 var (
@@ -48,6 +52,9 @@ var (
 
 	// ErrorTokenDisabled token 已经被禁用
 	ErrorTokenDisabled error = errors.New("token already disabled")
+
+	// ErrorDelegationInvalid 非法的代理 token
+	ErrorDelegationInvalid error = errors.New("invalid delegation token")
 )
 
 const (
@@ -66,6 +73,11 @@ const (
 	TokenForUserGroup  string = "groupid"
 
 	ResourceAttachmentKey string = "resource_attachment"
+
+	// DelegationTokenKey 机器客户端代理的用户 token
+	DelegationTokenKey string = "delegation_token"
+	// DelegatorIDKey 代理链校验通过后，被代理用户的 ID
+	DelegatorIDKey string = "delegator_id"
 )
 
 func _() {
@@ -188,6 +200,53 @@ type ResourceEntry struct {
 	Owner string
 }
 
+// ResourcePermissionError 鉴权未通过时的错误，携带被拒绝访问的资源，便于调用方定位具体是哪个资源没有权限
+type ResourcePermissionError struct {
+	// Err 原始的鉴权错误
+	Err error
+	// DeniedResources 被拒绝访问的资源
+	DeniedResources map[apisecurity.ResourceType][]ResourceEntry
+}
+
+// Error .
+func (e *ResourcePermissionError) Error() string {
+	resTypes := make([]apisecurity.ResourceType, 0, len(e.DeniedResources))
+	for resType := range e.DeniedResources {
+		resTypes = append(resTypes, resType)
+	}
+	sort.Slice(resTypes, func(i, j int) bool {
+		return resTypes[i] < resTypes[j]
+	})
+	denied := make([]string, 0, len(resTypes))
+	for _, resType := range resTypes {
+		entries := e.DeniedResources[resType]
+		ids := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		denied = append(denied, fmt.Sprintf("%s[%s]", resType.String(), strings.Join(ids, ",")))
+	}
+	if len(denied) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s, denied resources: %s", e.Err.Error(), strings.Join(denied, " "))
+}
+
+// Unwrap .
+func (e *ResourcePermissionError) Unwrap() error {
+	return e.Err
+}
+
+// IsDenied 判断某个资源是否被拒绝访问
+func (e *ResourcePermissionError) IsDenied(resType apisecurity.ResourceType, id string) bool {
+	for _, entry := range e.DeniedResources[resType] {
+		if entry.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
 // User 用户
 type User struct {
 	ID          string
@@ -172,6 +172,10 @@ type SimpleConfigFileRelease struct {
 	ModifyTime         time.Time
 	ModifyBy           string
 	ReleaseDescription string
+	// DataKeyRotated 配置的数据密钥发生了轮转但是版本没有变化，只在发布事件中使用，不做持久化
+	DataKeyRotated bool
+	// Rollback 生效的发布回退到了一个版本更旧的发布，只在发布事件以及发布过期回退时使用，不做持久化
+	Rollback bool
 }
 
 func (s *SimpleConfigFileRelease) GetEncryptDataKey() string {
@@ -186,6 +190,25 @@ func (s *SimpleConfigFileRelease) IsEncrypted() bool {
 	return s.GetEncryptDataKey() != ""
 }
 
+// ExpireTime 配置发布的过期时间，没有设置或者格式不正确时返回 false
+func (s *SimpleConfigFileRelease) ExpireTime() (time.Time, bool) {
+	val, ok := s.Metadata[utils.ConfigFileTagKeyReleaseExpireTime]
+	if !ok {
+		return time.Time{}, false
+	}
+	expireTime, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return expireTime, true
+}
+
+// IsExpired 配置发布在 now 时是否已经过期
+func (s *SimpleConfigFileRelease) IsExpired(now time.Time) bool {
+	expireTime, ok := s.ExpireTime()
+	return ok && !now.Before(expireTime)
+}
+
 func (s *SimpleConfigFileRelease) ToSpecNotifyClientRequest() *config_manage.ClientConfigFileInfo {
 	return &config_manage.ClientConfigFileInfo{
 		Namespace: utils.NewStringValue(s.Namespace),
@@ -261,6 +284,14 @@ type ConfigFileTemplate struct {
 	ModifyBy   string
 }
 
+// ConfigWatchSubscription 客户端订阅配置文件的持久化对象，用于服务端重启后恢复客户端的订阅关系
+type ConfigWatchSubscription struct {
+	ClientId string
+	// Files 客户端订阅的配置文件以及已经通知过的版本，JSON 格式
+	Files      string
+	ModifyTime time.Time
+}
+
 func ToConfigFileStore(file *config_manage.ConfigFile) *ConfigFile {
 	var comment string
 	if file.Comment != nil {
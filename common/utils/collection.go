@@ -280,6 +280,19 @@ func (s *SyncMap[K, V]) Load(key K) (V, bool) {
 	return empty, false
 }
 
+// LoadBatch 在同一把读锁内查询多个 key，返回值与 keys 一一对应，key 不存在时对应的 found 为 false
+func (s *SyncMap[K, V]) LoadBatch(keys []K) ([]V, []bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	values := make([]V, len(keys))
+	founds := make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], founds[i] = s.m[key]
+	}
+	return values, founds
+}
+
 // Store
 func (s *SyncMap[K, V]) Store(key K, val V) {
 	s.lock.Lock()
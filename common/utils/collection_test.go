@@ -93,3 +93,17 @@ func Test_SyncSegmentMap(t *testing.T) {
 		}
 	}
 }
+
+func Test_SyncMapLoadBatch(t *testing.T) {
+	m := NewSyncMap[string, int]()
+	m.Store("a", 1)
+	m.Store("b", 2)
+
+	values, founds := m.LoadBatch([]string{"b", "c", "a"})
+	assert.Equal(t, []int{2, 0, 1}, values)
+	assert.Equal(t, []bool{true, false, true}, founds)
+
+	values, founds = m.LoadBatch(nil)
+	assert.Empty(t, values)
+	assert.Empty(t, founds)
+}
@@ -33,6 +33,7 @@ import (
 	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
 	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	api "github.com/polarismesh/polaris/common/api/v1"
@@ -339,6 +340,23 @@ func ParseAuthToken(ctx context.Context) string {
 	return token
 }
 
+// ParseDelegationToken 从ctx中获取机器客户端代理的用户 token，兼容 HTTP 请求头以及 gRPC metadata
+func ParseDelegationToken(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	token, _ := ctx.Value(ContextDelegationTokenKey).(string)
+	if token == "" {
+		if md, ok := ctx.Value(ContextGrpcHeader).(metadata.MD); ok {
+			if vals := md.Get(HeaderDelegationTokenKey); len(vals) > 0 {
+				token = vals[0]
+			}
+		}
+	}
+	return token
+}
+
 // ParseIsOwner 从ctx中获取token
 func ParseIsOwner(ctx context.Context) bool {
 	if ctx == nil {
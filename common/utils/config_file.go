@@ -62,6 +62,36 @@ const (
 	ConfigFileTagKeyDataKey = "internal-datakey"
 	// ConfigFileTagKeyEncryptAlgo 加密算法 tag key
 	ConfigFileTagKeyEncryptAlgo = "internal-encryptalgo"
+	// ConfigFileTagKeyPreviousVersion 变更通知中客户端变更前持有的配置版本 tag key
+	ConfigFileTagKeyPreviousVersion = "internal-previous-version"
+	// ConfigFileTagKeyClientSelector 配置发布只通知匹配的客户端，格式为 k1=v1,k2=v2，客户端标签需要全部匹配
+	ConfigFileTagKeyClientSelector = "internal-client-selector"
+	// ConfigFileTagKeyWatchPriority 客户端订阅配置文件时声明的通知优先级，value 为整数，数值越大越优先通知
+	ConfigFileTagKeyWatchPriority = "internal-watch-priority"
+	// ConfigFileTagKeyWatchAction 流式订阅时客户端对该配置文件的订阅动作，不设置时为新增订阅
+	ConfigFileTagKeyWatchAction = "internal-watch-action"
+	// ConfigFileWatchActionRemove 流式订阅时取消对该配置文件的订阅
+	ConfigFileWatchActionRemove = "remove"
+	// ConfigFileTagKeyChunkOffset 分片下载配置时分片在完整内容中的偏移量，客户端断点续传时携带已经接收的偏移量
+	ConfigFileTagKeyChunkOffset = "internal-chunk-offset"
+	// ConfigFileTagKeyChunkMd5 分片下载配置时单个分片内容的 md5
+	ConfigFileTagKeyChunkMd5 = "internal-chunk-md5"
+	// ConfigFileTagKeyContentSize 分片下载配置时完整内容的字节数
+	ConfigFileTagKeyContentSize = "internal-content-size"
+	// ConfigFileTagKeyContentMd5 分片下载配置时完整内容的 md5，用于客户端拼接所有分片之后校验
+	ConfigFileTagKeyContentMd5 = "internal-content-md5"
+	// ConfigFileTagKeyAcceptDiff 客户端订阅时携带该 tag 并且 value 为 true，表示变更通知可以只携带与持有版本之间的差异
+	ConfigFileTagKeyAcceptDiff = "internal-accept-diff"
+	// ConfigFileTagKeyDiffBaseVersion 变更通知的内容为相对于该版本的差异，而不是完整的配置内容
+	ConfigFileTagKeyDiffBaseVersion = "internal-diff-base-version"
+	// ConfigFileTagKeyGroupFileName 配置分组变更通知中分组下当前的配置文件名称，每个配置文件一个 tag
+	ConfigFileTagKeyGroupFileName = "internal-group-file-name"
+	// ConfigFileTagKeyWatchSessionId 变更通知中订阅的会话 ID，用于关联客户端重连前后的订阅
+	ConfigFileTagKeyWatchSessionId = "internal-watch-session-id"
+	// ConfigFileTagKeyReleaseExpireTime 配置发布的过期时间，格式为 RFC3339，过期后客户端回退到之前的发布
+	ConfigFileTagKeyReleaseExpireTime = "internal-release-expire-time"
+	// ConfigFileTagKeyInternalPrefix 系统内部使用的 tag key 前缀
+	ConfigFileTagKeyInternalPrefix = "internal-"
 )
 
 // GenFileId 生成文件 Id
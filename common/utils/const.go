@@ -46,6 +46,20 @@ const (
 	HeaderOwnerIDKey string = "X-Owner-ID"
 	// HeaderUserRoleKey user role key
 	HeaderUserRoleKey string = "X-Polaris-User-Role"
+	// HeaderWatchProtocolKey config watch protocols supported by client
+	HeaderWatchProtocolKey string = "X-Polaris-Watch-Protocol"
+	// HeaderClientIdKey config watch client id declared by client
+	HeaderClientIdKey string = "X-Polaris-Client-Id"
+	// HeaderClientVersionKey sdk version declared by client
+	HeaderClientVersionKey string = "X-Polaris-Client-Version"
+	// HeaderIdempotencyKey idempotency key of config publish request
+	HeaderIdempotencyKey string = "X-Polaris-Idempotency-Key"
+	// HeaderDryRunKey marks the config write request only do check
+	HeaderDryRunKey string = "X-Polaris-Dry-Run"
+	// HeaderPublishWaitKey max time of waiting config release propagated, such as 3s or 3000(ms)
+	HeaderPublishWaitKey string = "X-Polaris-Publish-Wait"
+	// HeaderDelegationTokenKey token of the user on whose behalf the machine client acts
+	HeaderDelegationTokenKey string = "X-Polaris-Delegation-Token"
 
 	// ContextAuthTokenKey auth token key
 	ContextAuthTokenKey = StringContext(HeaderAuthTokenKey)
@@ -73,6 +87,16 @@ const (
 	ContextIsFromSystem = StringContext("from-system")
 	// ContextOperator operator info
 	ContextOperator = StringContext("operator")
+	// ContextWatchProtocolKey config watch protocols supported by client
+	ContextWatchProtocolKey = StringContext(HeaderWatchProtocolKey)
+	// ContextClientIdKey config watch client id declared by client
+	ContextClientIdKey = StringContext(HeaderClientIdKey)
+	// ContextClientVersionKey sdk version declared by client
+	ContextClientVersionKey = StringContext(HeaderClientVersionKey)
+	// ContextIdempotencyKey idempotency key of config publish request
+	ContextIdempotencyKey = StringContext(HeaderIdempotencyKey)
+	// ContextDelegationTokenKey token of the user on whose behalf the machine client acts
+	ContextDelegationTokenKey = StringContext(HeaderDelegationTokenKey)
 )
 
 const (
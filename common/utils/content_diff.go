@@ -0,0 +1,109 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var (
+	// ErrInvalidContentDiff 差异内容格式错误或者与基准内容不匹配
+	ErrInvalidContentDiff = errors.New("invalid config file content diff")
+)
+
+// DiffContent 按行计算 newContent 相对于 oldContent 的差异。差异由若干个块组成，每个块的格式为
+// "@@ <基准内容中的起始行>,<删除的行数>,<新增的行数>\n" 加上新增的各行原文，行号从 0 开始，
+// 行保留各自的换行符，因此应用差异后可以得到与 newContent 完全一致的内容
+func DiffContent(oldContent, newContent string) string {
+	oldLines, newLines := splitContentLines(oldContent), splitContentLines(newContent)
+	matcher := difflib.NewMatcherWithJunk(oldLines, newLines, false, nil)
+	var builder strings.Builder
+	for _, op := range matcher.GetOpCodes() {
+		if op.Tag == 'e' {
+			continue
+		}
+		fmt.Fprintf(&builder, "@@ %d,%d,%d\n", op.I1, op.I2-op.I1, op.J2-op.J1)
+		for _, line := range newLines[op.J1:op.J2] {
+			builder.WriteString(line)
+		}
+	}
+	return builder.String()
+}
+
+// PatchContent 将 DiffContent 计算出的差异应用到基准内容上
+func PatchContent(oldContent, diff string) (string, error) {
+	oldLines := splitContentLines(oldContent)
+	var builder strings.Builder
+	cursor := 0
+	for diff != "" {
+		header, rest, ok := strings.Cut(diff, "\n")
+		if !ok || !strings.HasPrefix(header, "@@ ") {
+			return "", ErrInvalidContentDiff
+		}
+		items := strings.Split(strings.TrimPrefix(header, "@@ "), ",")
+		if len(items) != 3 {
+			return "", ErrInvalidContentDiff
+		}
+		nums := make([]int, 0, len(items))
+		for _, item := range items {
+			num, err := strconv.Atoi(item)
+			if err != nil || num < 0 {
+				return "", ErrInvalidContentDiff
+			}
+			nums = append(nums, num)
+		}
+		start, deleted, inserted := nums[0], nums[1], nums[2]
+		if start < cursor || start+deleted > len(oldLines) {
+			return "", ErrInvalidContentDiff
+		}
+		for _, line := range oldLines[cursor:start] {
+			builder.WriteString(line)
+		}
+		cursor = start + deleted
+		diff = rest
+		for i := 0; i < inserted; i++ {
+			if diff == "" {
+				return "", ErrInvalidContentDiff
+			}
+			line, rest, ok := strings.Cut(diff, "\n")
+			builder.WriteString(line)
+			if ok {
+				builder.WriteString("\n")
+			}
+			diff = rest
+		}
+	}
+	for _, line := range oldLines[cursor:] {
+		builder.WriteString(line)
+	}
+	return builder.String(), nil
+}
+
+func splitContentLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	// 以换行符结尾时最后会多出一个空行
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
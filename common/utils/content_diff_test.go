@@ -0,0 +1,53 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffContent(t *testing.T) {
+	cases := [][2]string{
+		{"", ""},
+		{"", "a: 1\nb: 2\n"},
+		{"a: 1\nb: 2\n", ""},
+		{"a: 1\nb: 2\nc: 3\n", "a: 1\nb: 20\nc: 3\n"},
+		{"a: 1\nb: 2\nc: 3\n", "x: 0\na: 1\nc: 3\nd: 4"},
+		// 只有末尾的换行符不同
+		{"a: 1\nb: 2", "a: 1\nb: 2\n"},
+		{"a: 1\n\n\nb: 2\n", "a: 1\n\nb: 2\n\n"},
+	}
+	for _, item := range cases {
+		diff := DiffContent(item[0], item[1])
+		ret, err := PatchContent(item[0], diff)
+		assert.NoError(t, err)
+		assert.Equal(t, item[1], ret, "diff %q", diff)
+	}
+	assert.Equal(t, "", DiffContent("a: 1\n", "a: 1\n"))
+	assert.Equal(t, "@@ 1,1,1\nb: 20\n", DiffContent("a: 1\nb: 2\nc: 3\n", "a: 1\nb: 20\nc: 3\n"))
+
+	// 差异与基准内容不匹配
+	_, err := PatchContent("a: 1\n", "@@ 1,3,0\n")
+	assert.ErrorIs(t, err, ErrInvalidContentDiff)
+	_, err = PatchContent("a: 1\n", "@@ 0,1,2\nb: 1\n")
+	assert.ErrorIs(t, err, ErrInvalidContentDiff)
+	_, err = PatchContent("a: 1\n", "b: 1\n")
+	assert.ErrorIs(t, err, ErrInvalidContentDiff)
+}
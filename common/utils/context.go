@@ -17,7 +17,12 @@
 
 package utils
 
-import "context"
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type (
 	// StringContext is a context key that carries a string.
@@ -28,8 +33,85 @@ type (
 	ContextAPIServerSlot struct{}
 	// WatchTimeoutCtx .
 	WatchTimeoutCtx struct{}
+	// dryRunCtx is a context key that marks the request only do check.
+	dryRunCtx struct{}
+	// publishWaitCtx is a context key that carries the timeout of waiting release propagated.
+	publishWaitCtx struct{}
 )
 
+// WithCancelFrom 返回一个在 src 结束时也会被取消的 ctx，用于将底层连接的生命周期传递给重新构造的请求上下文
+func WithCancelFrom(ctx context.Context, src context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(src, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// WithDryRun 标记请求只做鉴权以及参数校验，不做实际的写入
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunCtx{}, true)
+}
+
+// WithDryRunHeader 客户端通过请求头或者 gRPC metadata 携带 X-Polaris-Dry-Run: true 时，标记请求为 dry-run
+func WithDryRunHeader(ctx context.Context, val string) context.Context {
+	if dryRun, err := strconv.ParseBool(strings.TrimSpace(val)); err == nil && dryRun {
+		return WithDryRun(ctx)
+	}
+	return ctx
+}
+
+// IsDryRun 判断请求是否为 dry-run
+func IsDryRun(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	value, _ := ctx.Value(dryRunCtx{}).(bool)
+	return value
+}
+
+// WithPublishWait 标记配置发布请求需要等待发布事件被监听中心处理后才返回，最多等待 timeout
+func WithPublishWait(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, publishWaitCtx{}, timeout)
+}
+
+// MaxPublishWait 客户端通过请求头声明的发布等待时间上限，避免请求被长时间挂起
+const MaxPublishWait = 30 * time.Second
+
+// WithPublishWaitHeader 客户端通过请求头或者 gRPC metadata 携带 X-Polaris-Publish-Wait 时，标记发布请求需要等待，
+// 取值为 time.Duration 格式或者毫秒数，超过 MaxPublishWait 时按照 MaxPublishWait 等待
+func WithPublishWaitHeader(ctx context.Context, val string) context.Context {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return ctx
+	}
+	timeout, err := time.ParseDuration(val)
+	if err != nil {
+		millis, perr := strconv.ParseInt(val, 10, 64)
+		if perr != nil {
+			return ctx
+		}
+		timeout = time.Duration(millis) * time.Millisecond
+	}
+	if timeout <= 0 {
+		return ctx
+	}
+	if timeout > MaxPublishWait {
+		timeout = MaxPublishWait
+	}
+	return WithPublishWait(ctx, timeout)
+}
+
+// GetPublishWait 获取配置发布请求等待发布事件被处理的超时时间
+func GetPublishWait(ctx context.Context) (time.Duration, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	timeout, ok := ctx.Value(publishWaitCtx{}).(time.Duration)
+	return timeout, ok && timeout > 0
+}
+
 // WithLocalhost 存储localhost
 func WithLocalhost(ctx context.Context, localhost string) context.Context {
 	return context.WithValue(ctx, localhostCtx{}, localhost)
@@ -245,6 +245,12 @@ func ConvertGRPCContext(ctx context.Context) context.Context {
 	ctx = context.WithValue(ctx, StringContext("client-ip"), clientIP)
 	ctx = context.WithValue(ctx, ContextClientAddress, address)
 	ctx = context.WithValue(ctx, StringContext("user-agent"), userAgent)
+	if vals := meta.Get(HeaderDryRunKey); len(vals) > 0 {
+		ctx = WithDryRunHeader(ctx, vals[0])
+	}
+	if vals := meta.Get(HeaderPublishWaitKey); len(vals) > 0 {
+		ctx = WithPublishWaitHeader(ctx, vals[0])
+	}
 
 	return ctx
 }
@@ -18,9 +18,12 @@
 package utils
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestNewUUID(t *testing.T) {
@@ -33,3 +36,28 @@ func TestStringSliceDeDuplication(t *testing.T) {
 	s2 := StringSliceDeDuplication(s)
 	assert.Equal(t, s2, []string{"1", "2", "", "_invalid", "23"})
 }
+
+func TestConvertGRPCContext_DryRun(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(HeaderDryRunKey, "true"))
+	assert.True(t, IsDryRun(ConvertGRPCContext(ctx)))
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(HeaderDryRunKey, "false"))
+	assert.False(t, IsDryRun(ConvertGRPCContext(ctx)))
+	assert.False(t, IsDryRun(ConvertGRPCContext(context.Background())))
+}
+
+func TestConvertGRPCContext_PublishWait(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(HeaderPublishWaitKey, "2s"))
+	timeout, ok := GetPublishWait(ConvertGRPCContext(ctx))
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Second, timeout)
+
+	// 超过上限时按照上限等待
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(HeaderPublishWaitKey, "1h"))
+	timeout, ok = GetPublishWait(ConvertGRPCContext(ctx))
+	assert.True(t, ok)
+	assert.Equal(t, MaxPublishWait, timeout)
+
+	_, ok = GetPublishWait(ConvertGRPCContext(context.Background()))
+	assert.False(t, ok)
+}
@@ -21,6 +21,8 @@ import (
 	"context"
 
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+
+	"github.com/polarismesh/polaris/plugin"
 )
 
 type (
@@ -87,6 +89,8 @@ type ConfigFileReleaseOperate interface {
 	GetConfigFileReleaseHistories(ctx context.Context, filter map[string]string) *apiconfig.ConfigBatchQueryResponse
 	// UpsertAndReleaseConfigFile 创建/更新配置文件并发布
 	UpsertAndReleaseConfigFile(ctx context.Context, req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse
+	// ForceNotifyConfigFile 强制通知所有订阅该配置文件的客户端重新拉取当前生效的配置
+	ForceNotifyConfigFile(ctx context.Context, req *apiconfig.ConfigFileRelease) *apiconfig.ConfigResponse
 }
 
 // ConfigFileClientOperate 给客户端提供服务接口，不同的上层协议抽象的公共服务逻辑
@@ -105,6 +109,13 @@ type ConfigFileClientOperate interface {
 	UpsertAndReleaseConfigFileFromClient(ctx context.Context, req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse
 	// LongPullWatchFile 客户端监听配置文件
 	LongPullWatchFile(ctx context.Context, req *apiconfig.ClientWatchConfigFileRequest) (WatchCallback, error)
+	// StreamWatchFile 客户端通过双向流持续监听配置文件
+	StreamWatchFile(ctx context.Context, stream WatchFileStream) error
+	// DownloadConfigFile 通过流分片下载超过单个消息大小限制的配置文件
+	DownloadConfigFile(ctx context.Context, req *apiconfig.ClientConfigFileInfo, stream ConfigFileChunkStream) error
+	// GetConfigFileReleaseHistoryForClient 获取客户端已经订阅的配置文件最近的发布记录
+	GetConfigFileReleaseHistoryForClient(ctx context.Context,
+		req *apiconfig.ClientConfigFileInfo) *apiconfig.ConfigClientListResponse
 	// GetConfigFileNamesWithCache 获取某个配置分组下的配置文件
 	GetConfigFileNamesWithCache(ctx context.Context,
 		req *apiconfig.ConfigFileGroupRequest) *apiconfig.ConfigClientListResponse
@@ -127,4 +138,6 @@ type ConfigCenterServer interface {
 	ConfigFileReleaseOperate
 	ConfigFileClientOperate
 	ConfigFileTemplateOperate
+	// DebugHandlers 配置中心的排查接口
+	DebugHandlers() []plugin.DebugHandler
 }
@@ -20,6 +20,9 @@ package config
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"math/rand"
+	"sort"
 	"time"
 
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
@@ -50,13 +53,18 @@ func (s *Server) GetConfigFileForClient(ctx context.Context,
 			apimodel.Code_BadRequest, "namespace & group & fileName can not be empty")
 	}
 	// 从缓存中获取配置内容
-	release := s.fileCache.GetActiveRelease(namespace, group, fileName)
+	release := effectiveRelease(s.fileCache, s.watchCenter.priorReleaseCache(), namespace, group, fileName,
+		time.Now())
 	if release == nil {
 		return api.NewConfigClientResponse(apimodel.Code_NotFoundResource, nil)
 	}
 
-	// 客户端版本号大于服务端版本号，服务端不返回变更
-	if clientVersion > release.Version {
+	// 客户端版本号大于服务端版本号，服务端不返回变更；发布过期回退时客户端持有的是过期发布的更新版本，需要返回回退后的配置
+	if clientVersion > release.Version && !release.Rollback {
+		return api.NewConfigClientResponse(apimodel.Code_DataNoChange, nil)
+	}
+	// 客户端持有的配置和当前生效的配置一致，不需要再返回配置内容
+	if isConfigFileNotModified(client, release) {
 		return api.NewConfigClientResponse(apimodel.Code_DataNoChange, nil)
 	}
 	configFile, err := toClientInfo(client, release)
@@ -64,12 +72,49 @@ func (s *Server) GetConfigFileForClient(ctx context.Context,
 		log.Error("[Config][Service] get config file to client info", utils.RequestID(ctx), zap.Error(err))
 		return api.NewConfigClientResponseWithInfo(apimodel.Code_ExecuteException, err.Error())
 	}
+	// 超过消息大小限制时客户端会收到难以理解的传输层错误，这里直接返回明确的错误码，只携带配置的元数据，
+	// 客户端需要通过 gRPC 流式接口 v1.PolarisConfigStreamGRPC/DownloadConfigFile 分片获取
+	if size := estimateClientInfoSize(configFile); size > s.clientMessageMaxSize() {
+		log.Warn("[Config][Service] config file content exceeds the max message size.", utils.RequestID(ctx),
+			zap.String("namespace", namespace), zap.String("group", group), zap.String("fileName", fileName),
+			zap.Int("size", size), zap.Int("max", s.clientMessageMaxSize()))
+		return api.NewConfigClientResponse(apimodel.Code(api.ConfigFileContentTooLarge),
+			release.SimpleConfigFileRelease.ToSpecNotifyClientRequest())
+	}
 	return api.NewConfigClientResponse(apimodel.Code_ExecuteSuccess, configFile)
 }
 
+// clientMessageMaxSize 返回给客户端的单个配置响应的最大字节数
+func (s *Server) clientMessageMaxSize() int {
+	if s.cfg != nil && s.cfg.ClientMessageMaxSize > 0 {
+		return s.cfg.ClientMessageMaxSize
+	}
+	return defaultClientMessageMaxSize
+}
+
+// clientInfoReservedSize 估算响应大小时为配置内容以及标签之外的字段预留的字节数
+const clientInfoReservedSize = 1024
+
+// estimateClientInfoSize 估算返回给客户端的配置响应大小，proto.Size 会在消息中缓存计算结果，这里只累加主要字段的长度
+func estimateClientInfoSize(info *apiconfig.ClientConfigFileInfo) int {
+	size := clientInfoReservedSize + len(info.GetContent().GetValue())
+	for _, tag := range info.GetTags() {
+		size += len(tag.GetKey().GetValue()) + len(tag.GetValue().GetValue())
+	}
+	return size
+}
+
 // UpsertAndReleaseConfigFile 创建/更新配置文件并发布
 func (s *Server) UpsertAndReleaseConfigFileFromClient(ctx context.Context,
 	req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse {
+	// 写入配置前先做 schema 校验，没有注册校验器的配置格式不受影响
+	if errResp := s.validateConfigFileContent(ctx, toUpsertConfigFile(ctx, req)); errResp != nil {
+		return errResp
+	}
+	// 客户端通过 X-Polaris-Dry-Run 开启 dry-run，只做鉴权以及参数校验，不会写入配置也不会通知客户端
+	if utils.IsDryRun(ctx) {
+		return s.dryRunUpsertAndReleaseConfigFile(ctx, req)
+	}
 	return s.UpsertAndReleaseConfigFile(ctx, req)
 }
 
@@ -92,17 +137,37 @@ func (s *Server) UpdateConfigFileFromClient(ctx context.Context,
 	return api.NewConfigClientResponseFromConfigResponse(configResponse)
 }
 
-// PublishConfigFileFromClient 调用config_file_release接口发布配置文件
+// PublishConfigFileFromClient 调用config_file_release接口发布配置文件，
+// 客户端可以通过 X-Polaris-Idempotency-Key 携带幂等键，去重窗口内同一个操作人对同一个配置文件的重复请求返回第一次发布的结果
 func (s *Server) PublishConfigFileFromClient(ctx context.Context,
 	client *apiconfig.ConfigFileRelease) *apiconfig.ConfigClientResponse {
-	configResponse := s.PublishConfigFile(ctx, client)
-	return api.NewConfigClientResponseFromConfigResponse(configResponse)
+	// 携带幂等键的重试请求直接返回第一次发布的结果，避免重复发布
+	return s.publishDedup.do(publishDedupKey(ctx, client), client, func() *apiconfig.ConfigClientResponse {
+		return api.NewConfigClientResponseFromConfigResponse(s.PublishConfigFile(ctx, client))
+	})
 }
 
 // LongPullWatchFile .
 func (s *Server) LongPullWatchFile(ctx context.Context,
 	req *apiconfig.ClientWatchConfigFileRequest) (WatchCallback, error) {
-	watchFiles := req.GetWatchFiles()
+	clientId := s.WatchCenter().ParseClientId(ctx)
+	// 开启订阅关系持久化时记录客户端的订阅以及已经通知过的版本
+	persistId := s.WatchCenter().declaredClientId(ctx)
+	s.WatchCenter().persistence.observe(persistId, req.GetWatchFiles())
+	callback, err := s.longPullWatchFile(ctx, clientId, req.GetWatchFiles())
+	if err != nil {
+		return nil, err
+	}
+	// 按照客户端声明的版本转换响应码，兼容不同版本的 SDK
+	return func() *apiconfig.ConfigClientResponse {
+		rsp := callback()
+		s.WatchCenter().persistence.delivered(persistId, rsp)
+		return s.WatchCenter().mapResponseCode(ctx, rsp)
+	}, nil
+}
+
+func (s *Server) longPullWatchFile(ctx context.Context, clientId string,
+	watchFiles []*apiconfig.ClientConfigFileInfo) (WatchCallback, error) {
 
 	tmpWatchCtx := BuildTimeoutWatchCtx(0)("")
 	for _, file := range watchFiles {
@@ -115,25 +180,63 @@ func (s *Server) LongPullWatchFile(ctx context.Context,
 		}, nil
 	}
 
-	watchTimeOut := defaultLongPollingTimeout
-	if timeoutVal, ok := ctx.Value(utils.WatchTimeoutCtx{}).(time.Duration); ok {
-		watchTimeOut = timeoutVal
-	}
-
 	// 3. 监听配置变更，hold 请求 30s，30s 内如果有配置发布，则响应请求
-	clientId := utils.ParseClientAddress(ctx) + "@" + utils.NewUUID()[0:8]
-	watchCtx := s.WatchCenter().AddWatcher(clientId, watchFiles, BuildTimeoutWatchCtx(watchTimeOut))
+	watchCtx, err := replaceWatcher[*LongPollWatchContext](s.WatchCenter(), clientId, watchFiles,
+		s.WatchCenter().SelectWatchContextFactory(ctx, WatchProtocolLongPoll))
+	if errors.Is(err, ErrTooManyWatchers) {
+		// 订阅者数量已经超过上限，立即响应客户端而不是继续排队
+		return func() *apiconfig.ConfigClientResponse {
+			return tooBusyResponse
+		}, nil
+	}
+	if err != nil {
+		code := apimodel.Code_BadRequest
+		if errors.Is(err, ErrInvalidWatchFile) {
+			code = apimodel.Code_InvalidWatchConfigFileFormat
+		}
+		return func() *apiconfig.ConfigClientResponse {
+			return api.NewConfigClientResponseWithInfo(code, err.Error())
+		}, nil
+	}
+	// 首次检查到注册订阅之间可能错过了配置发布，注册完成后再对比一次
+	if quickResp := s.WatchCenter().reconcileWatchContext(watchCtx); quickResp != nil {
+		return func() *apiconfig.ConfigClientResponse {
+			return quickResp
+		}, nil
+	}
 	return func() *apiconfig.ConfigClientResponse {
-		return (watchCtx.(*LongPollWatchContext)).GetNotifieResult()
+		// 即使超时清理任务没有按时响应，最多也只会等待到长轮询超时之后的一小段时间，避免调用方一直阻塞
+		waitCtx, cancel := context.WithDeadline(ctx, watchCtx.ExpireTime().Add(maxWatchCallbackDelay))
+		defer cancel()
+		ret, err := watchCtx.GetNotifieResultWithContext(waitCtx)
+		if err != nil {
+			// 客户端已经断开或者等待超时，不需要再等待配置变更
+			s.WatchCenter().removeWatchContext(watchCtx)
+			return notModifiedResponse
+		}
+		return ret
 	}, nil
 }
 
 func BuildTimeoutWatchCtx(watchTimeOut time.Duration) WatchContextFactory {
+	return BuildJitterTimeoutWatchCtx(watchTimeOut, 0)
+}
+
+// BuildJitterTimeoutWatchCtx 在超时时间的基础上随机提前 [0, jitter) 结束长轮询，
+// 避免同一时刻建立的大量长轮询同时超时，客户端集中重连
+func BuildJitterTimeoutWatchCtx(watchTimeOut, jitter time.Duration) WatchContextFactory {
 	return func(clientId string) WatchContext {
+		finishTime := time.Now().Add(watchTimeOut)
+		if jitter > 0 {
+			finishTime = finishTime.Add(-time.Duration(rand.Int63n(int64(jitter))))
+		}
 		watchCtx := &LongPollWatchContext{
-			clientId:         clientId,
-			finishTime:       time.Now().Add(watchTimeOut),
-			finishChan:       make(chan *apiconfig.ConfigClientResponse),
+			watchActivity: newWatchActivity(),
+			clientId:      clientId,
+			finishTime:    finishTime,
+			// 带缓冲，等待方已经返回时 Reply 也不会被阻塞
+			finishChan:       make(chan *apiconfig.ConfigClientResponse, 1),
+			closeCh:          make(chan struct{}),
 			watchConfigFiles: map[string]*apiconfig.ClientConfigFileInfo{},
 		}
 		return watchCtx
@@ -182,7 +285,61 @@ func (s *Server) GetConfigFileNamesWithCache(ctx context.Context,
 	}
 }
 
+// GetConfigFileReleaseHistoryForClient 获取客户端已经订阅的配置文件最近的发布记录，按照版本号从新到旧排序，
+// 客户端需要声明 ID 才能找到对应的订阅上下文
+func (s *Server) GetConfigFileReleaseHistoryForClient(ctx context.Context,
+	req *apiconfig.ClientConfigFileInfo) *apiconfig.ConfigClientListResponse {
+	namespace := req.GetNamespace().GetValue()
+	group := req.GetGroup().GetValue()
+	fileName := req.GetFileName().GetValue()
+
+	out := api.NewConfigClientListResponse(apimodel.Code_ExecuteSuccess)
+	if namespace == "" || group == "" || fileName == "" {
+		out.Code = utils.NewUInt32Value(uint32(apimodel.Code_BadRequest))
+		out.Info = utils.NewStringValue("namespace & group & fileName can not be empty")
+		return out
+	}
+	clientId := s.watchCenter.declaredClientId(ctx)
+	if clientId == "" {
+		out.Code = utils.NewUInt32Value(uint32(apimodel.Code_BadRequest))
+		out.Info = utils.NewStringValue("client id can not be empty")
+		return out
+	}
+
+	releases, err := s.watchCenter.GetActiveReleaseHistory(clientId, req, 0)
+	if errors.Is(err, ErrNotWatchedFile) {
+		out.Code = utils.NewUInt32Value(uint32(apimodel.Code_NotFoundResource))
+		out.Info = utils.NewStringValue(err.Error())
+		return out
+	}
+	if err != nil {
+		log.Error("[Config][Service] get config file release history for client", utils.RequestID(ctx),
+			utils.ZapNamespace(namespace), utils.ZapGroup(group), utils.ZapFileName(fileName), zap.Error(err))
+		out.Code = utils.NewUInt32Value(uint32(apimodel.Code_ExecuteException))
+		out.Info = utils.NewStringValue(err.Error())
+		return out
+	}
+	out.Namespace = namespace
+	out.Group = group
+	out.ConfigFileInfos = make([]*apiconfig.ClientConfigFileInfo, 0, len(releases))
+	for i := range releases {
+		out.ConfigFileInfos = append(out.ConfigFileInfos, &apiconfig.ClientConfigFileInfo{
+			Namespace:   utils.NewStringValue(releases[i].Namespace),
+			Group:       utils.NewStringValue(releases[i].Group),
+			FileName:    utils.NewStringValue(releases[i].FileName),
+			Name:        utils.NewStringValue(releases[i].Name),
+			Version:     utils.NewUInt64Value(releases[i].Version),
+			Md5:         utils.NewStringValue(releases[i].Md5),
+			ReleaseTime: utils.NewStringValue(commontime.Time2String(releases[i].ModifyTime)),
+		})
+	}
+	return out
+}
+
 func CompareByVersion(clientInfo *apiconfig.ClientConfigFileInfo, file *model.ConfigFileRelease) bool {
+	if file.Rollback {
+		return clientInfo.GetVersion().GetValue() != file.Version
+	}
 	return clientInfo.GetVersion().GetValue() < file.Version
 }
 
@@ -205,7 +362,8 @@ func (s *Server) checkClientConfigFile(ctx context.Context, files []*apiconfig.C
 				"namespace & group & fileName can not be empty"), false
 		}
 		// 从缓存中获取最新的配置文件信息
-		release := s.fileCache.GetActiveRelease(namespace, group, fileName)
+		release := effectiveRelease(s.fileCache, s.watchCenter.priorReleaseCache(), namespace, group, fileName,
+			time.Now())
 		if release != nil && compartor(configFile, release) {
 			ret := &apiconfig.ClientConfigFileInfo{
 				Namespace: utils.NewStringValue(namespace),
@@ -220,6 +378,18 @@ func (s *Server) checkClientConfigFile(ctx context.Context, files []*apiconfig.C
 	return api.NewConfigClientResponse(apimodel.Code_DataNoChange, nil), true
 }
 
+// isConfigFileNotModified 类似 HTTP 的 If-None-Match，客户端携带了当前持有配置的 md5 并且和生效的配置一致时，
+// 认为配置没有变化；客户端同时携带了版本号时版本号也需要一致。加密的配置在数据密钥轮转时版本以及 md5 都不会变化，
+// 因此总是返回完整的配置
+func isConfigFileNotModified(client *apiconfig.ClientConfigFileInfo, release *model.ConfigFileRelease) bool {
+	clientMd5 := client.GetMd5().GetValue()
+	if clientMd5 == "" || clientMd5 != release.Md5 || release.IsEncrypted() {
+		return false
+	}
+	clientVersion := client.GetVersion().GetValue()
+	return clientVersion == 0 || clientVersion == release.Version
+}
+
 func toClientInfo(client *apiconfig.ClientConfigFileInfo,
 	release *model.ConfigFileRelease) (*apiconfig.ClientConfigFileInfo, error) {
 
@@ -247,6 +417,10 @@ func toClientInfo(client *apiconfig.ClientConfigFileInfo,
 		Encrypted: utils.NewBoolValue(release.IsEncrypted()),
 		Tags:      model.FromTagMap(copyMetadata),
 	}
+	// 客户端会根据配置标签在本地做过滤，保证标签的顺序稳定
+	sort.Slice(configFile.Tags, func(i, j int) bool {
+		return configFile.Tags[i].GetKey().GetValue() < configFile.Tags[j].GetKey().GetValue()
+	})
 
 	dataKey := release.GetEncryptDataKey()
 	encryptAlgo := release.GetEncryptAlgo()
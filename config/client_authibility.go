@@ -21,6 +21,8 @@ import (
 	"context"
 
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
 
 	api "github.com/polarismesh/polaris/common/api/v1"
 	"github.com/polarismesh/polaris/common/model"
@@ -32,10 +34,15 @@ func (s *serverAuthability) UpsertAndReleaseConfigFileFromClient(ctx context.Con
 	req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse {
 	authCtx := s.collectConfigFilePublishAuthContext(ctx, []*apiconfig.ConfigFilePublishInfo{req},
 		model.Modify, "UpsertAndReleaseConfigFileFromClient")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		return api.NewConfigFileResponse(convertToErrCode(err), nil)
 	}
 
+	if namespace := req.GetNamespace().GetValue(); !s.publishLimiter.allow(namespace) {
+		log.Warn("[Config][Release] client publish request is limited", utils.ZapNamespace(namespace))
+		return api.NewConfigResponse(apimodel.Code_APIRateLimit)
+	}
+
 	ctx = authCtx.GetRequestContext()
 	ctx = context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
 
@@ -51,7 +58,7 @@ func (s *serverAuthability) CreateConfigFileFromClient(ctx context.Context,
 			Name:      fileInfo.Name,
 			Group:     fileInfo.Group},
 		}, model.Create, "CreateConfigFileFromClient")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		return api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error())
 	}
 
@@ -66,7 +73,7 @@ func (s *serverAuthability) UpdateConfigFileFromClient(ctx context.Context,
 	fileInfo *apiconfig.ConfigFile) *apiconfig.ConfigClientResponse {
 	authCtx := s.collectClientConfigFileAuthContext(ctx,
 		[]*apiconfig.ConfigFile{fileInfo}, model.Modify, "UpdateConfigFileFromClient")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		return api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error())
 	}
 
@@ -82,7 +89,7 @@ func (s *serverAuthability) DeleteConfigFileFromClient(ctx context.Context,
 
 	authCtx := s.collectConfigFileAuthContext(ctx,
 		[]*apiconfig.ConfigFile{req}, model.Delete, "DeleteConfigFileFromClient")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		return api.NewConfigResponseWithInfo(convertToErrCode(err), err.Error())
 	}
 
@@ -101,10 +108,15 @@ func (s *serverAuthability) PublishConfigFileFromClient(ctx context.Context,
 			Name:      fileInfo.FileName,
 			Group:     fileInfo.Group},
 		}, model.Create, "PublishConfigFileFromClient")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		return api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error())
 	}
 
+	if namespace := fileInfo.GetNamespace().GetValue(); !s.publishLimiter.allow(namespace) {
+		log.Warn("[Config][Release] client publish request is limited", utils.ZapNamespace(namespace))
+		return api.NewConfigClientResponse0(apimodel.Code_APIRateLimit)
+	}
+
 	ctx = authCtx.GetRequestContext()
 	ctx = context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
 
@@ -120,7 +132,7 @@ func (s *serverAuthability) GetConfigFileForClient(ctx context.Context,
 			Name:      fileInfo.FileName,
 			Group:     fileInfo.Group},
 		}, model.Read, "GetConfigFileForClient")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		return api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error())
 	}
 
@@ -129,11 +141,37 @@ func (s *serverAuthability) GetConfigFileForClient(ctx context.Context,
 	return s.targetServer.GetConfigFileForClient(ctx, fileInfo)
 }
 
+// DownloadConfigFile 分片下载配置文件，和获取配置文件使用相同的鉴权
+func (s *serverAuthability) DownloadConfigFile(ctx context.Context, fileInfo *apiconfig.ClientConfigFileInfo,
+	stream ConfigFileChunkStream) error {
+	authCtx := s.collectClientConfigFileAuthContext(ctx,
+		[]*apiconfig.ConfigFile{{
+			Namespace: fileInfo.Namespace,
+			Name:      fileInfo.FileName,
+			Group:     fileInfo.Group},
+		}, model.Read, "DownloadConfigFile")
+	if err := s.checkClientPermission(authCtx); err != nil {
+		return stream.Send(api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error()))
+	}
+
+	ctx = authCtx.GetRequestContext()
+	ctx = context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
+	return s.targetServer.DownloadConfigFile(ctx, fileInfo, stream)
+}
+
 // WatchConfigFiles 监听配置文件变化
 func (s *serverAuthability) LongPullWatchFile(ctx context.Context,
 	request *apiconfig.ClientWatchConfigFileRequest) (WatchCallback, error) {
+	// 先做限流，避免客户端重连风暴压垮鉴权以及订阅中心
+	if clientId := parseWatchClientId(ctx, request); !s.watchLimiter.allow(clientId) {
+		log.Warn("[Config][Watcher] client watch request is limited", zap.String("client", clientId))
+		return func() *apiconfig.ConfigClientResponse {
+			return api.NewConfigClientResponse0(apimodel.Code_IPRateLimit)
+		}, nil
+	}
 	authCtx := s.collectClientWatchConfigFiles(ctx, request, model.Read, "LongPullWatchFile")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
+		err = s.wrapWatchPermissionError(request, err)
 		return func() *apiconfig.ConfigClientResponse {
 			return api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error())
 		}, nil
@@ -145,7 +183,72 @@ func (s *serverAuthability) LongPullWatchFile(ctx context.Context,
 	return s.targetServer.LongPullWatchFile(ctx, request)
 }
 
+// StreamWatchFile 流式监听配置文件变化，每次变更订阅列表时都需要鉴权
+func (s *serverAuthability) StreamWatchFile(ctx context.Context, stream WatchFileStream) error {
+	// 建立连接时先校验凭据，客户端声明的 ID 以及持久化的订阅关系都按照校验后的操作者区分
+	if s.strategyMgn.GetAuthChecker().IsOpenClientAuth() {
+		authCtx := s.collectClientWatchConfigFiles(ctx, &apiconfig.ClientWatchConfigFileRequest{}, model.Read,
+			"StreamWatchFile")
+		if err := s.strategyMgn.GetAuthChecker().VerifyCredential(authCtx); err != nil {
+			return err
+		}
+		ctx = context.WithValue(authCtx.GetRequestContext(), utils.ContextAuthContextKey, authCtx)
+	}
+	return s.targetServer.StreamWatchFile(ctx, &authWatchFileStream{
+		WatchFileStream: stream,
+		ctx:             ctx,
+		svr:             s,
+	})
+}
+
+// authWatchFileStream 对客户端每一次新增的订阅做鉴权，鉴权失败时直接结束整个流
+type authWatchFileStream struct {
+	WatchFileStream
+	ctx context.Context
+	svr *serverAuthability
+}
+
+// Recv .
+func (a *authWatchFileStream) Recv() (*apiconfig.ClientWatchConfigFileRequest, error) {
+	req, err := a.WatchFileStream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	// 取消订阅不需要鉴权，只校验新增的订阅
+	added, _ := splitStreamWatchFiles(req.GetWatchFiles())
+	if len(added) == 0 {
+		return req, nil
+	}
+	authReq := &apiconfig.ClientWatchConfigFileRequest{
+		ClientIp:    req.GetClientIp(),
+		ServiceName: req.GetServiceName(),
+		WatchFiles:  added,
+	}
+	authCtx := a.svr.collectClientWatchConfigFiles(a.ctx, authReq, model.Read, "StreamWatchFile")
+	if err := a.svr.checkClientPermission(authCtx); err != nil {
+		return nil, a.svr.wrapWatchPermissionError(authReq, err)
+	}
+	return req, nil
+}
+
 // GetConfigFileNamesWithCache 获取某个配置分组下的配置文件
+func (s *serverAuthability) GetConfigFileReleaseHistoryForClient(ctx context.Context,
+	fileInfo *apiconfig.ClientConfigFileInfo) *apiconfig.ConfigClientListResponse {
+	authCtx := s.collectClientConfigFileAuthContext(ctx,
+		[]*apiconfig.ConfigFile{{
+			Namespace: fileInfo.Namespace,
+			Name:      fileInfo.FileName,
+			Group:     fileInfo.Group},
+		}, model.Read, "GetConfigFileReleaseHistoryForClient")
+	if err := s.checkClientPermission(authCtx); err != nil {
+		return api.NewConfigClientListResponse(convertToErrCode(err))
+	}
+
+	ctx = authCtx.GetRequestContext()
+	ctx = context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
+	return s.targetServer.GetConfigFileReleaseHistoryForClient(ctx, fileInfo)
+}
+
 func (s *serverAuthability) GetConfigFileNamesWithCache(ctx context.Context,
 	req *apiconfig.ConfigFileGroupRequest) *apiconfig.ConfigClientListResponse {
 
@@ -155,7 +258,7 @@ func (s *serverAuthability) GetConfigFileNamesWithCache(ctx context.Context,
 			Group:     req.GetConfigFileGroup().GetName(),
 		},
 	}, model.Read, "GetConfigFileNamesWithCache")
-	if _, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx); err != nil {
+	if err := s.checkClientPermission(authCtx); err != nil {
 		out := api.NewConfigClientListResponse(convertToErrCode(err))
 		return out
 	}
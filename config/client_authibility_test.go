@@ -0,0 +1,183 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	apisecurity "github.com/polarismesh/specification/source/go/api/v1/security"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	authmock "github.com/polarismesh/polaris/auth/mock"
+	"github.com/polarismesh/polaris/cache/mock"
+	"github.com/polarismesh/polaris/common/metrics"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_serverAuthability_LongPullWatchFilePermission(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "allow", "file-1", 10),
+	}).AnyTimes()
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName("default", "allow").Return(&model.ConfigFileGroup{Id: 1}).AnyTimes()
+	groupCache.EXPECT().GetGroupByName("default", "deny").Return(&model.ConfigFileGroup{Id: 2}).AnyTimes()
+	groupCache.EXPECT().GetGroupByName("default", "unknown").Return(nil).AnyTimes()
+
+	var denied bool
+	checker := authmock.NewMockAuthChecker(ctrl)
+	// 不论订阅多少个文件，都只做一次合并后的鉴权
+	checker.EXPECT().CheckClientPermission(gomock.Any()).DoAndReturn(func(authCtx *model.AcquireContext) (bool, error) {
+		entries := authCtx.GetAccessResources()[apisecurity.ResourceType_ConfigGroups]
+		assert.Len(t, entries, 2)
+		if !denied {
+			return true, nil
+		}
+		return false, &model.ResourcePermissionError{
+			Err: model.ErrorTokenInvalid,
+			DeniedResources: map[apisecurity.ResourceType][]model.ResourceEntry{
+				apisecurity.ResourceType_ConfigGroups: {{ID: "2"}},
+			},
+		}
+	}).Times(2)
+
+	proxy := &serverAuthability{
+		targetServer: &Server{watchCenter: wc, fileCache: fileCache, groupCache: groupCache},
+		strategyMgn:  &testStrategyServer{checker: checker},
+	}
+	req := &apiconfig.ClientWatchConfigFileRequest{
+		ClientIp: utils.NewStringValue("127.0.0.1"),
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{
+			newTestWatchFile("default", "allow", "file-1", 0),
+			newTestWatchFile("default", "allow", "file-2", 0),
+			newTestWatchFile("default", "deny", "file-3", 0),
+			newTestWatchFile("default", "deny", "file-4", 0),
+			newTestWatchFile("default", "unknown", "file-5", 0),
+		},
+	}
+
+	callback, err := proxy.LongPullWatchFile(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), callback().GetCode().GetValue())
+
+	denied = true
+	callback, err = proxy.LongPullWatchFile(context.Background(), req)
+	assert.NoError(t, err)
+	rsp := callback()
+	assert.Equal(t, uint32(apimodel.Code_NotAllowedAccess), rsp.GetCode().GetValue())
+	info := rsp.GetInfo().GetValue()
+	assert.Contains(t, info, "default/deny/file-3")
+	assert.Contains(t, info, "default/deny/file-4")
+	// 缓存中不存在的配置分组无法确认权限，同样作为没有权限的配置文件返回
+	assert.Contains(t, info, "default/unknown/file-5")
+	assert.NotContains(t, info, "default/allow")
+}
+
+func Test_serverAuthability_DelegationToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName(gomock.Any(), gomock.Any()).Return(&model.ConfigFileGroup{Id: 1}).AnyTimes()
+	checker := authmock.NewMockAuthChecker(ctrl)
+	// 代理 token 需要透传给鉴权插件，由鉴权插件校验代理链
+	checker.EXPECT().CheckClientPermission(gomock.Any()).DoAndReturn(func(authCtx *model.AcquireContext) (bool, error) {
+		assert.Equal(t, "forged-token", authCtx.GetAttachment(model.DelegationTokenKey))
+		return false, model.ErrorDelegationInvalid
+	}).Times(2)
+
+	proxy := &serverAuthability{
+		targetServer: &Server{groupCache: groupCache},
+		strategyMgn:  &testStrategyServer{checker: checker},
+	}
+
+	// HTTP 请求头
+	ctx := context.WithValue(context.Background(), utils.ContextDelegationTokenKey, "forged-token")
+	rsp := proxy.GetConfigFileForClient(ctx, newTestWatchFile("default", "group", "file-1", 0))
+	assert.Equal(t, uint32(apimodel.Code_NotAllowedAccess), rsp.GetCode().GetValue())
+
+	// gRPC metadata
+	ctx = context.WithValue(context.Background(), utils.ContextGrpcHeader,
+		metadata.Pairs(utils.HeaderDelegationTokenKey, "forged-token"))
+	callback, err := proxy.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(apimodel.Code_NotAllowedAccess), callback().GetCode().GetValue())
+}
+
+func Test_serverAuthability_WildcardNamespaceResource(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName("default", "group").Return(&model.ConfigFileGroup{Id: 1}).AnyTimes()
+	groupCache.EXPECT().GetGroupByName(utils.MatchAll, gomock.Any()).Return(nil).AnyTimes()
+	proxy := &serverAuthability{targetServer: &Server{groupCache: groupCache}}
+
+	// 下游不会展开通配的命名空间，鉴权时也不作为通配资源处理，避免通过通配资源放行
+	ret := proxy.queryConfigFilePublishResource(context.Background(), []*apiconfig.ConfigFilePublishInfo{{
+		Namespace: utils.NewStringValue(utils.MatchAll),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue("file-1"),
+	}})
+	assert.Empty(t, ret[apisecurity.ResourceType_ConfigGroups])
+
+	ret = proxy.queryWatchConfigFilesResource(context.Background(), &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{
+			newTestWatchFile(utils.MatchAll, "group", "file-1", 0),
+			newTestWatchFile("default", "group", "file-1", 0),
+		},
+	})
+	assert.Equal(t, []model.ResourceEntry{{ID: "1"}}, ret[apisecurity.ResourceType_ConfigGroups])
+}
+
+func Test_serverAuthability_AuthMetrics(t *testing.T) {
+	metrics.InitMetrics()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName(gomock.Any(), gomock.Any()).Return(&model.ConfigFileGroup{Id: 1}).AnyTimes()
+	checker := authmock.NewMockAuthChecker(ctrl)
+	checker.EXPECT().CheckClientPermission(gomock.Any()).Return(false, model.ErrorTokenInvalid).AnyTimes()
+	proxy := &serverAuthability{
+		targetServer: &Server{groupCache: groupCache},
+		strategyMgn:  &testStrategyServer{checker: checker},
+	}
+
+	counter := func(result string) float64 {
+		return testutil.ToFloat64(metrics.GetConfigClientAuthTotal().WithLabelValues("GetConfigFileForClient", result))
+	}
+	allowed, denied := counter(metrics.AuthResultAllow), counter(metrics.AuthResultDeny)
+	rsp := proxy.GetConfigFileForClient(context.Background(), newTestWatchFile("default", "group", "file-1", 0))
+	assert.Equal(t, uint32(apimodel.Code_NotAllowedAccess), rsp.GetCode().GetValue())
+	// 鉴权拒绝时只增加拒绝的次数
+	assert.Equal(t, denied+1, counter(metrics.AuthResultDeny))
+	assert.Equal(t, allowed, counter(metrics.AuthResultAllow))
+}
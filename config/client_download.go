@@ -0,0 +1,181 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// defaultDownloadChunkSize 分片下载配置内容时默认的分片大小
+	defaultDownloadChunkSize = 1024 * 1024
+	// minDownloadChunkSize 分片需要按照 UTF-8 字符切分，分片大小不能小于单个字符的最大长度
+	minDownloadChunkSize = utf8.UTFMax
+)
+
+var (
+	// errInvalidDownloadOffset 客户端断点续传时携带的偏移量不合法
+	errInvalidDownloadOffset = errors.New("invalid config file download offset")
+)
+
+// ConfigFileChunkStream 分片下载配置内容的服务端流，和具体的传输协议无关
+type ConfigFileChunkStream interface {
+	// Context .
+	Context() context.Context
+	// Send 推送一个配置内容分片
+	Send(*apiconfig.ConfigClientResponse) error
+}
+
+// DownloadConfigFile 将配置内容按照分片通过流返回给客户端，用于获取超过单个消息大小限制的配置。
+// 每个分片通过 tag 携带分片的偏移量、分片的 md5、完整内容的大小以及 md5，客户端拼接之后校验完整内容；
+// 客户端通过 tag 携带已经接收的偏移量以及版本号断点续传，配置在此期间重新发布时返回 DataConflict，需要从头下载
+func (s *Server) DownloadConfigFile(ctx context.Context, req *apiconfig.ClientConfigFileInfo,
+	stream ConfigFileChunkStream) error {
+	namespace := req.GetNamespace().GetValue()
+	group := req.GetGroup().GetValue()
+	fileName := req.GetFileName().GetValue()
+	if namespace == "" || group == "" || fileName == "" {
+		return stream.Send(api.NewConfigClientResponseWithInfo(
+			apimodel.Code_BadRequest, "namespace & group & fileName can not be empty"))
+	}
+	release := effectiveRelease(s.fileCache, s.watchCenter.priorReleaseCache(), namespace, group, fileName,
+		time.Now())
+	if release == nil {
+		return stream.Send(api.NewConfigClientResponse(apimodel.Code_NotFoundResource, nil))
+	}
+	offset, err := parseDownloadOffset(req)
+	if err != nil {
+		return stream.Send(api.NewConfigClientResponseWithInfo(apimodel.Code_BadRequest, err.Error()))
+	}
+	// 断点续传时配置已经重新发布，已经接收的分片不再有效
+	if offset > 0 && req.GetVersion().GetValue() != release.Version {
+		return stream.Send(api.NewConfigClientResponse(apimodel.Code_DataConflict,
+			release.SimpleConfigFileRelease.ToSpecNotifyClientRequest()))
+	}
+
+	// 客户端需要获取完整的配置，不能使用 md5 判断配置是否变化
+	fileInfo := &apiconfig.ClientConfigFileInfo{
+		Namespace: req.Namespace,
+		Group:     req.Group,
+		FileName:  req.FileName,
+		PublicKey: req.PublicKey,
+	}
+	configFile, err := toClientInfo(fileInfo, release)
+	if err != nil {
+		log.Error("[Config][Service] download config file to client info", utils.RequestID(ctx), zap.Error(err))
+		return stream.Send(api.NewConfigClientResponseWithInfo(apimodel.Code_ExecuteException, err.Error()))
+	}
+	content := configFile.GetContent().GetValue()
+	if offset > len(content) {
+		return stream.Send(api.NewConfigClientResponseWithInfo(apimodel.Code_BadRequest,
+			"download offset exceeds the config file content length"))
+	}
+
+	contentTags := append(configFile.Tags,
+		&apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyContentSize),
+			Value: utils.NewStringValue(strconv.Itoa(len(content))),
+		},
+		&apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyContentMd5),
+			Value: utils.NewStringValue(CalMd5(content)),
+		})
+	chunkSize := s.downloadChunkSize()
+	for {
+		end := nextChunkEnd(content, offset, chunkSize)
+		chunk := content[offset:end]
+		tags := make([]*apiconfig.ConfigFileTag, 0, len(contentTags)+2)
+		tags = append(tags, contentTags...)
+		tags = append(tags, &apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyChunkOffset),
+			Value: utils.NewStringValue(strconv.Itoa(offset)),
+		}, &apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyChunkMd5),
+			Value: utils.NewStringValue(CalMd5(chunk)),
+		})
+		chunkInfo := &apiconfig.ClientConfigFileInfo{
+			Namespace: configFile.Namespace,
+			Group:     configFile.Group,
+			FileName:  configFile.FileName,
+			Content:   utils.NewStringValue(chunk),
+			Version:   configFile.Version,
+			Md5:       configFile.Md5,
+			Encrypted: configFile.Encrypted,
+			Tags:      tags,
+		}
+		if err := stream.Send(api.NewConfigClientResponse(apimodel.Code_ExecuteSuccess, chunkInfo)); err != nil {
+			log.Warn("[Config][Service] send config file chunk fail.", utils.RequestID(ctx),
+				zap.String("namespace", namespace), zap.String("group", group), zap.String("fileName", fileName),
+				zap.Int("offset", offset), zap.Error(err))
+			return err
+		}
+		offset = end
+		if offset >= len(content) {
+			return nil
+		}
+		if err := stream.Context().Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseDownloadOffset 解析客户端断点续传时携带的偏移量
+func parseDownloadOffset(req *apiconfig.ClientConfigFileInfo) (int, error) {
+	for _, tag := range req.GetTags() {
+		if tag.GetKey().GetValue() != utils.ConfigFileTagKeyChunkOffset {
+			continue
+		}
+		offset, err := strconv.Atoi(tag.GetValue().GetValue())
+		if err != nil || offset < 0 {
+			return 0, errInvalidDownloadOffset
+		}
+		return offset, nil
+	}
+	return 0, nil
+}
+
+// nextChunkEnd 计算分片的结束位置，分片不能把一个 UTF-8 字符切分到两个分片中
+func nextChunkEnd(content string, offset, chunkSize int) int {
+	end := offset + chunkSize
+	if end >= len(content) {
+		return len(content)
+	}
+	for end > offset && !utf8.RuneStart(content[end]) {
+		end--
+	}
+	return end
+}
+
+// downloadChunkSize 分片下载配置内容时的分片大小
+func (s *Server) downloadChunkSize() int {
+	if s.cfg != nil && s.cfg.DownloadChunkSize >= minDownloadChunkSize {
+		return s.cfg.DownloadChunkSize
+	}
+	return defaultDownloadChunkSize
+}
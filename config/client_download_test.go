@@ -0,0 +1,135 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/cache/mock"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+type testChunkStream struct {
+	ctx  context.Context
+	rsps []*apiconfig.ConfigClientResponse
+}
+
+func (s *testChunkStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testChunkStream) Send(rsp *apiconfig.ConfigClientResponse) error {
+	s.rsps = append(s.rsps, rsp)
+	return nil
+}
+
+func newTestDownloadServer(t *testing.T, content string, chunkSize int) *Server {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+
+	release := newTestRelease("default", "group", "file-1", 3)
+	release.Md5 = CalMd5(content)
+	fileCache := mock.NewMockConfigFileCache(ctrl)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: release,
+		Content:                 content,
+	}).AnyTimes()
+	return &Server{cfg: &Config{DownloadChunkSize: chunkSize}, fileCache: fileCache}
+}
+
+func newTestDownloadRequest(version uint64, offset int) *apiconfig.ClientConfigFileInfo {
+	req := newTestWatchFile("default", "group", "file-1", version)
+	if offset > 0 {
+		req.Tags = []*apiconfig.ConfigFileTag{{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyChunkOffset),
+			Value: utils.NewStringValue(strconv.Itoa(offset)),
+		}}
+	}
+	return req
+}
+
+// assembleChunks 按照客户端的逻辑校验每一个分片并拼接完整的配置内容
+func assembleChunks(t *testing.T, rsps []*apiconfig.ConfigClientResponse, offset int) string {
+	var builder strings.Builder
+	for _, rsp := range rsps {
+		assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+		tags := model.ToTagMap(rsp.GetConfigFile().GetTags())
+		chunk := rsp.GetConfigFile().GetContent().GetValue()
+		assert.Equal(t, strconv.Itoa(offset), tags[utils.ConfigFileTagKeyChunkOffset])
+		assert.Equal(t, CalMd5(chunk), tags[utils.ConfigFileTagKeyChunkMd5])
+		builder.WriteString(chunk)
+		offset += len(chunk)
+	}
+	return builder.String()
+}
+
+func Test_DownloadConfigFile_MultiChunk(t *testing.T) {
+	// 包含多字节字符，分片不能切分单个字符
+	content := strings.Repeat("key: 配置值\n", 10)
+	svr := newTestDownloadServer(t, content, 16)
+
+	stream := &testChunkStream{ctx: context.Background()}
+	assert.NoError(t, svr.DownloadConfigFile(context.Background(), newTestDownloadRequest(0, 0), stream))
+	assert.Greater(t, len(stream.rsps), 1)
+	for _, rsp := range stream.rsps {
+		chunk := rsp.GetConfigFile().GetContent().GetValue()
+		assert.LessOrEqual(t, len(chunk), 16)
+		assert.True(t, strings.ToValidUTF8(chunk, "") == chunk)
+		assert.Equal(t, uint64(3), rsp.GetConfigFile().GetVersion().GetValue())
+	}
+
+	ret := assembleChunks(t, stream.rsps, 0)
+	assert.Equal(t, content, ret)
+	tags := model.ToTagMap(stream.rsps[0].GetConfigFile().GetTags())
+	assert.Equal(t, strconv.Itoa(len(content)), tags[utils.ConfigFileTagKeyContentSize])
+	assert.Equal(t, CalMd5(ret), tags[utils.ConfigFileTagKeyContentMd5])
+}
+
+func Test_DownloadConfigFile_Resume(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+	svr := newTestDownloadServer(t, content, 32)
+
+	// 从已经接收的偏移量继续下载
+	stream := &testChunkStream{ctx: context.Background()}
+	assert.NoError(t, svr.DownloadConfigFile(context.Background(), newTestDownloadRequest(3, 40), stream))
+	assert.Equal(t, 2, len(stream.rsps))
+	assert.Equal(t, content[40:], assembleChunks(t, stream.rsps, 40))
+	tags := model.ToTagMap(stream.rsps[0].GetConfigFile().GetTags())
+	assert.Equal(t, CalMd5(content), tags[utils.ConfigFileTagKeyContentMd5])
+
+	// 配置已经重新发布，需要从头下载
+	stream = &testChunkStream{ctx: context.Background()}
+	assert.NoError(t, svr.DownloadConfigFile(context.Background(), newTestDownloadRequest(2, 40), stream))
+	assert.Equal(t, 1, len(stream.rsps))
+	assert.Equal(t, uint32(apimodel.Code_DataConflict), stream.rsps[0].GetCode().GetValue())
+	assert.Equal(t, uint64(3), stream.rsps[0].GetConfigFile().GetVersion().GetValue())
+
+	// 偏移量超过配置内容长度
+	stream = &testChunkStream{ctx: context.Background()}
+	assert.NoError(t, svr.DownloadConfigFile(context.Background(), newTestDownloadRequest(3, 101), stream))
+	assert.Equal(t, uint32(apimodel.Code_BadRequest), stream.rsps[0].GetCode().GetValue())
+}
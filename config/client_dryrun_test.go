@@ -0,0 +1,76 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+type testPublishEventCollector struct {
+	events chan *eventhub.PublishConfigFileEvent
+}
+
+func (c *testPublishEventCollector) PreProcess(_ context.Context, e any) any {
+	return e
+}
+
+func (c *testPublishEventCollector) OnEvent(_ context.Context, e any) error {
+	if event, ok := e.(*eventhub.PublishConfigFileEvent); ok {
+		c.events <- event
+	}
+	return nil
+}
+
+func Test_UpsertAndReleaseConfigFileFromClient_DryRun(t *testing.T) {
+	eventhub.InitEventHub()
+	collector := &testPublishEventCollector{events: make(chan *eventhub.PublishConfigFileEvent, 1)}
+	subCtx, err := eventhub.Subscribe(eventhub.ConfigFilePublishTopic, collector)
+	assert.NoError(t, err)
+	defer subCtx.Cancel()
+
+	// storage 为空，dry-run 如果发生任何写入都会直接 panic
+	svr := &Server{cfg: &Config{ContentMaxLength: fileContentMaxLength}}
+	req := &apiconfig.ConfigFilePublishInfo{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue("file.yaml"),
+		Content:   utils.NewStringValue("key: value"),
+	}
+	rsp := svr.UpsertAndReleaseConfigFileFromClient(utils.WithDryRun(context.Background()), req)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue(), rsp.GetInfo().GetValue())
+
+	// 校验失败时需要返回真实请求会得到的错误码
+	req.FileName = utils.NewStringValue("file&yaml")
+	rsp = svr.UpsertAndReleaseConfigFileFromClient(utils.WithDryRun(context.Background()), req)
+	assert.Equal(t, uint32(apimodel.Code_BadRequest), rsp.GetCode().GetValue())
+
+	select {
+	case event := <-collector.events:
+		t.Fatalf("dry-run should not publish event %+v", event)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
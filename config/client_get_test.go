@@ -0,0 +1,142 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/cache/mock"
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_GetConfigFileForClient_Tags(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := newTestRelease("default", "group", "file-1", 1)
+	release.Metadata = map[string]string{
+		"env":    "prod",
+		"region": "ap-guangzhou",
+		"app":    "demo",
+	}
+	fileCache := mock.NewMockConfigFileCache(ctrl)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: release,
+		Content:                 "key: value",
+	}).AnyTimes()
+	svr := &Server{fileCache: fileCache}
+
+	rsp := svr.GetConfigFileForClient(context.Background(), newTestWatchFile("default", "group", "file-1", 0))
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, "key: value", rsp.GetConfigFile().GetContent().GetValue())
+	// 配置发布的标签原样返回给客户端，并且按照 key 排序
+	assert.Equal(t, []*apiconfig.ConfigFileTag{
+		{Key: utils.NewStringValue("app"), Value: utils.NewStringValue("demo")},
+		{Key: utils.NewStringValue("env"), Value: utils.NewStringValue("prod")},
+		{Key: utils.NewStringValue("region"), Value: utils.NewStringValue("ap-guangzhou")},
+	}, rsp.GetConfigFile().GetTags())
+	assert.Equal(t, release.Metadata, model.ToTagMap(rsp.GetConfigFile().GetTags()))
+}
+
+func Test_GetConfigFileForClient_Conditional(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := newTestRelease("default", "group", "file-1", 2)
+	release.Md5 = CalMd5("key: value")
+	fileCache := mock.NewMockConfigFileCache(ctrl)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: release,
+		Content:                 "key: value",
+	}).AnyTimes()
+	svr := &Server{fileCache: fileCache}
+
+	get := func(md5 string, version uint64) *apiconfig.ConfigClientResponse {
+		fileInfo := newTestWatchFile("default", "group", "file-1", version)
+		fileInfo.Md5 = utils.NewStringValue(md5)
+		return svr.GetConfigFileForClient(context.Background(), fileInfo)
+	}
+
+	// 配置没有变化时不返回配置内容
+	for _, version := range []uint64{0, 2} {
+		rsp := get(release.Md5, version)
+		assert.Equal(t, uint32(apimodel.Code_DataNoChange), rsp.GetCode().GetValue())
+		assert.Nil(t, rsp.GetConfigFile())
+	}
+
+	// 配置已经变化或者客户端没有携带 md5 时返回完整的配置
+	for _, args := range []struct {
+		md5     string
+		version uint64
+	}{
+		{md5: CalMd5("key: old"), version: 1},
+		{md5: release.Md5, version: 1},
+		{md5: "", version: 0},
+	} {
+		rsp := get(args.md5, args.version)
+		assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+		assert.Equal(t, "key: value", rsp.GetConfigFile().GetContent().GetValue())
+		assert.Equal(t, release.Md5, rsp.GetConfigFile().GetMd5().GetValue())
+	}
+}
+
+func Test_GetConfigFileForClient_Oversized(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := newTestRelease("default", "group", "file-1", 3)
+	release.Md5 = "md5"
+	fileCache := mock.NewMockConfigFileCache(ctrl)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: release,
+		Content:                 strings.Repeat("a", 2048),
+	}).AnyTimes()
+	svr := &Server{cfg: &Config{ClientMessageMaxSize: 1024}, fileCache: fileCache}
+
+	// 超过消息大小限制时返回明确的错误码，只携带配置的元数据
+	rsp := svr.GetConfigFileForClient(context.Background(), newTestWatchFile("default", "group", "file-1", 0))
+	assert.Equal(t, api.ConfigFileContentTooLarge, rsp.GetCode().GetValue())
+	assert.Equal(t, api.Code2Info(api.ConfigFileContentTooLarge), rsp.GetInfo().GetValue())
+	assert.Equal(t, uint64(3), rsp.GetConfigFile().GetVersion().GetValue())
+	assert.Equal(t, "md5", rsp.GetConfigFile().GetMd5().GetValue())
+	assert.Nil(t, rsp.GetConfigFile().GetContent())
+
+	// 变更通知中也不能携带超过大小限制的配置内容
+	eventhub.InitEventHub()
+	wc, err := NewWatchCenter(fileCache)
+	assert.NoError(t, err)
+	defer wc.Close()
+	wc.inlineContentMaxLength = 4096
+	wc.messageMaxSize = 1024
+	assert.Nil(t, wc.buildNotifyFileInfo(release).GetContent())
+
+	svr.cfg.ClientMessageMaxSize = 0
+	rsp = svr.GetConfigFileForClient(context.Background(), newTestWatchFile("default", "group", "file-1", 0))
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, 2048, len(rsp.GetConfigFile().GetContent().GetValue()))
+}
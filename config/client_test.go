@@ -396,8 +396,9 @@ func TestWatchConfigFileAtFirstPublish(t *testing.T) {
 			testSuit.OriginConfigServer().WatchCenter().RemoveWatcher(clientId, watchConfigFiles)
 		}()
 
-		watchCtx := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
+		watchCtx, err := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
 			config.BuildTimeoutWatchCtx(30*time.Second))
+		assert.NoError(t, err)
 		assert.NotNil(t, watchCtx)
 
 		rsp := testSuit.ConfigServer().CreateConfigFile(testSuit.DefaultCtx, configFile)
@@ -431,8 +432,9 @@ func TestWatchConfigFileAtFirstPublish(t *testing.T) {
 
 		clientId := "TestWatchConfigFileAtFirstPublish-second"
 
-		watchCtx := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
+		watchCtx, err := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
 			config.BuildTimeoutWatchCtx(30*time.Second))
+		assert.NoError(t, err)
 		assert.NotNil(t, watchCtx)
 
 		rsp3 := testSuit.ConfigServer().PublishConfigFile(testSuit.DefaultCtx, assembleConfigFileRelease(configFile))
@@ -474,8 +476,9 @@ func TestManyClientWatchConfigFile(t *testing.T) {
 		clientId := fmt.Sprintf("Test10000ClientWatchConfigFile-client-id=%d", i)
 		received.Store(clientId, false)
 		receivedVersion.Store(clientId, uint64(0))
-		watchCtx := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
+		watchCtx, err := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
 			config.BuildTimeoutWatchCtx(30*time.Second))
+		assert.NoError(t, err)
 		assert.NotNil(t, watchCtx)
 		go func() {
 			notifyRsp := (watchCtx.(*config.LongPollWatchContext)).GetNotifieResult()
@@ -552,8 +555,9 @@ func TestDeleteConfigFile(t *testing.T) {
 
 	t.Log("add config watcher")
 
-	watchCtx := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
+	watchCtx, err := testSuit.OriginConfigServer().WatchCenter().AddWatcher(clientId, watchConfigFiles,
 		config.BuildTimeoutWatchCtx(30*time.Second))
+	assert.NoError(t, err)
 	assert.NotNil(t, watchCtx)
 
 	// 删除配置文件
@@ -567,7 +571,7 @@ func TestDeleteConfigFile(t *testing.T) {
 
 	// 客户端收到推送通知
 	t.Log("wait receive config change msg")
-	_, err := (watchCtx.(*config.LongPollWatchContext)).GetNotifieResultWithTime(10 * time.Second)
+	_, err = (watchCtx.(*config.LongPollWatchContext)).GetNotifieResultWithTime(10 * time.Second)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -19,10 +19,14 @@ package config
 
 import (
 	"errors"
+	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
 
 	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
 )
 
 func (s *Server) checkNamespaceExisted(namespaceName string) bool {
@@ -33,6 +37,71 @@ func (s *Server) checkNamespaceExisted(namespaceName string) bool {
 	return namespace != nil
 }
 
+// isConfigFileExisted 判断配置文件是否存在，配置分组以缓存为准；配置文件没有缓存，查询存储的结果
+// 短时间缓存，客户端长轮询还没有发布的配置文件时不会每次都查询存储，查询存储失败时当作存在处理，避免误导客户端
+func (s *Server) isConfigFileExisted(namespace, group, fileName string) bool {
+	if s.groupCache.GetGroupByName(namespace, group) == nil {
+		return false
+	}
+	return s.fileExistCache.check(utils.GenFileId(namespace, group, fileName), func() (bool, bool) {
+		file, err := s.storage.GetConfigFile(namespace, group, fileName)
+		if err != nil {
+			log.Error("[Config][File] check config file existed.", utils.ZapNamespace(namespace),
+				utils.ZapGroup(group), utils.ZapFileName(fileName), zap.Error(err))
+			return true, false
+		}
+		return file != nil, true
+	})
+}
+
+const (
+	// defaultFileExistCacheTTL 配置文件是否存在的判断结果的缓存时间，新创建的配置文件最多延迟这么久才能被感知
+	defaultFileExistCacheTTL = 5 * time.Second
+	// defaultFileExistCacheMaxKeys 最多缓存多少个配置文件的判断结果
+	defaultFileExistCacheMaxKeys = 100000
+)
+
+type fileExistEntry struct {
+	existed  bool
+	expireAt time.Time
+}
+
+// fileExistCache 短时间缓存配置文件是否存在的判断结果
+type fileExistCache struct {
+	ttl     time.Duration
+	entries *lru.Cache
+}
+
+func newFileExistCache(ttl time.Duration) *fileExistCache {
+	if ttl <= 0 {
+		ttl = defaultFileExistCacheTTL
+	}
+	entries, _ := lru.New(defaultFileExistCacheMaxKeys)
+	return &fileExistCache{
+		ttl:     ttl,
+		entries: entries,
+	}
+}
+
+// check 缓存中存在没有过期的结果时直接返回，否则执行一次判断，load 返回判断结果以及该结果是否可以缓存
+func (c *fileExistCache) check(key string, load func() (bool, bool)) bool {
+	if c == nil {
+		existed, _ := load()
+		return existed
+	}
+	if val, ok := c.entries.Get(key); ok {
+		entry := val.(*fileExistEntry)
+		if time.Now().Before(entry.expireAt) {
+			return entry.existed
+		}
+	}
+	existed, cacheable := load()
+	if cacheable {
+		c.entries.Add(key, &fileExistEntry{existed: existed, expireAt: time.Now().Add(c.ttl)})
+	}
+	return existed
+}
+
 func convertToErrCode(err error) apimodel.Code {
 	if errors.Is(err, model.ErrorTokenNotExist) {
 		return apimodel.Code_TokenNotExisted
@@ -77,6 +77,10 @@ func (s *Server) handleCreateConfigFile(ctx context.Context, tx store.Tx,
 		return api.NewConfigResponse(apimodel.Code_ExistedResource)
 	}
 
+	// 加密等处理之前先按照配置格式校验明文内容
+	if errResp := s.validateConfigFileContent(ctx, req); errResp != nil {
+		return errResp
+	}
 	savaData := model.ToConfigFileStore(req)
 	if errResp := s.chains.BeforeCreateFile(ctx, savaData); errResp != nil {
 		return errResp
@@ -134,6 +138,9 @@ func (s *Server) handleUpdateConfigFile(ctx context.Context, tx store.Tx,
 	if saveData == nil {
 		return api.NewConfigResponse(apimodel.Code_NotFoundResource)
 	}
+	if errResp := s.validateConfigFileContent(ctx, req); errResp != nil {
+		return errResp
+	}
 	updateData, needUpdate := s.updateConfigFileAttribute(saveData, model.ToConfigFileStore(req))
 	if !needUpdate {
 		return api.NewConfigResponse(apimodel.Code_NoNeedUpdate)
@@ -73,6 +73,11 @@ func (s *Server) PublishConfigFile(ctx context.Context, req *apiconfig.ConfigFil
 		return resp
 	}
 
+	// 客户端通过 X-Polaris-Publish-Wait 声明需要等待发布事件被监听中心处理后再返回
+	timeout, needWait := utils.GetPublishWait(ctx)
+	if needWait {
+		s.watchCenter.releaseWaiter.forget(data.Namespace, data.Group, data.FileName, data.Name)
+	}
 	if err := tx.Commit(); err != nil {
 		s.recordReleaseFail(ctx, utils.ReleaseTypeNormal, data, err)
 		log.Error("[Config][Release] publish config file commit tx.", utils.RequestID(ctx), zap.Error(err))
@@ -80,6 +85,9 @@ func (s *Server) PublishConfigFile(ctx context.Context, req *apiconfig.ConfigFil
 	}
 	s.recordReleaseSuccess(ctx, utils.ReleaseTypeNormal, data)
 	resp.ConfigFileRelease = req
+	if needWait {
+		return s.waitReleasePropagated(ctx, timeout, resp)
+	}
 	return resp
 }
 
@@ -106,6 +114,12 @@ func (s *Server) handlePublishConfigFile(ctx context.Context, tx store.Tx,
 	if toPublishFile == nil {
 		return nil, api.NewConfigResponse(apimodel.Code_NotFoundResource)
 	}
+	// 加密的配置文件存储的是密文，只有在写入时才能校验明文内容
+	if !toPublishFile.IsEncrypted() {
+		if errResp := s.validateConfigFileContent(ctx, model.ToConfigFileAPI(toPublishFile)); errResp != nil {
+			return nil, errResp
+		}
+	}
 	if releaseName := req.GetName().GetValue(); releaseName == "" {
 		// 这里要保证每一次发布都有唯一的 release_name 名称
 		req.Name = utils.NewStringValue(fmt.Sprintf("%s-%d-%d", fileName, time.Now().Unix(), s.nextSequence()))
@@ -208,6 +222,22 @@ func (s *Server) GetConfigFileRelease(ctx context.Context, req *apiconfig.Config
 	return api.NewConfigFileReleaseResponse(apimodel.Code_ExecuteSuccess, release)
 }
 
+// ForceNotifyConfigFile 使用当前生效的发布强制通知所有订阅该配置文件的客户端
+func (s *Server) ForceNotifyConfigFile(ctx context.Context, req *apiconfig.ConfigFileRelease) *apiconfig.ConfigResponse {
+	if errCode, errMsg := checkBaseReleaseParam(req, false); errCode != apimodel.Code_ExecuteSuccess {
+		return api.NewConfigResponseWithInfo(errCode, errMsg)
+	}
+	namespace := req.GetNamespace().GetValue()
+	group := req.GetGroup().GetValue()
+	fileName := req.GetFileName().GetValue()
+	if !s.watchCenter.ForceNotify(namespace, group, fileName) {
+		return api.NewConfigResponse(apimodel.Code_NotFoundResource)
+	}
+	log.Info("[Config][Release] force notify config file watchers.", utils.RequestID(ctx),
+		utils.ZapNamespace(namespace), utils.ZapGroup(group), utils.ZapFileName(fileName))
+	return api.NewConfigResponse(apimodel.Code_ExecuteSuccess)
+}
+
 // DeleteConfigFileRelease 删除某个配置文件的发布 release
 func (s *Server) DeleteConfigFileReleases(ctx context.Context,
 	reqs []*apiconfig.ConfigFileRelease) *apiconfig.ConfigBatchWriteResponse {
@@ -511,28 +541,11 @@ func (s *Server) handleRollbackConfigFileRelease(ctx context.Context, tx store.T
 func (s *Server) UpsertAndReleaseConfigFile(ctx context.Context,
 	req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse {
 
-	if err := utils.CheckResourceName(req.GetNamespace()); err != nil {
-		return api.NewConfigResponseWithInfo(apimodel.Code_BadRequest, "invalid config namespace")
-	}
-	if err := utils.CheckResourceName(req.GetGroup()); err != nil {
-		return api.NewConfigResponseWithInfo(apimodel.Code_BadRequest, "invalid config group")
-	}
-	if err := CheckFileName(req.GetFileName()); err != nil {
-		return api.NewConfigResponseWithInfo(apimodel.Code_BadRequest, "invalid config file_name")
+	if errResp := checkConfigFilePublishInfo(req); errResp != nil {
+		return errResp
 	}
 
-	upsertFileReq := &apiconfig.ConfigFile{
-		Name:        req.GetFileName(),
-		Namespace:   req.GetNamespace(),
-		Group:       req.GetGroup(),
-		Content:     req.GetContent(),
-		Format:      req.GetFormat(),
-		Comment:     req.GetComment(),
-		Tags:        req.GetTags(),
-		CreateBy:    utils.NewStringValue(utils.ParseUserName(ctx)),
-		ModifyBy:    utils.NewStringValue(utils.ParseUserName(ctx)),
-		ReleaseTime: utils.NewStringValue(req.GetReleaseDescription().GetValue()),
-	}
+	upsertFileReq := toUpsertConfigFile(ctx, req)
 	if rsp := s.prepareCreateConfigFile(ctx, upsertFileReq); rsp.Code.Value != api.ExecuteSuccess {
 		return rsp
 	}
@@ -580,6 +593,46 @@ func (s *Server) UpsertAndReleaseConfigFile(ctx context.Context,
 	return releaseResp
 }
 
+// dryRunUpsertAndReleaseConfigFile 只做参数校验，不做任何写入以及发布通知
+func (s *Server) dryRunUpsertAndReleaseConfigFile(ctx context.Context,
+	req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse {
+	if errResp := checkConfigFilePublishInfo(req); errResp != nil {
+		return errResp
+	}
+	if errResp := s.checkConfigFileParams(toUpsertConfigFile(ctx, req)); errResp != nil {
+		return errResp
+	}
+	return api.NewConfigResponse(apimodel.Code_ExecuteSuccess)
+}
+
+func checkConfigFilePublishInfo(req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigResponse {
+	if err := utils.CheckResourceName(req.GetNamespace()); err != nil {
+		return api.NewConfigResponseWithInfo(apimodel.Code_BadRequest, "invalid config namespace")
+	}
+	if err := utils.CheckResourceName(req.GetGroup()); err != nil {
+		return api.NewConfigResponseWithInfo(apimodel.Code_BadRequest, "invalid config group")
+	}
+	if err := CheckFileName(req.GetFileName()); err != nil {
+		return api.NewConfigResponseWithInfo(apimodel.Code_BadRequest, "invalid config file_name")
+	}
+	return nil
+}
+
+func toUpsertConfigFile(ctx context.Context, req *apiconfig.ConfigFilePublishInfo) *apiconfig.ConfigFile {
+	return &apiconfig.ConfigFile{
+		Name:        req.GetFileName(),
+		Namespace:   req.GetNamespace(),
+		Group:       req.GetGroup(),
+		Content:     req.GetContent(),
+		Format:      req.GetFormat(),
+		Comment:     req.GetComment(),
+		Tags:        req.GetTags(),
+		CreateBy:    utils.NewStringValue(utils.ParseUserName(ctx)),
+		ModifyBy:    utils.NewStringValue(utils.ParseUserName(ctx)),
+		ReleaseTime: utils.NewStringValue(req.GetReleaseDescription().GetValue()),
+	}
+}
+
 func (s *Server) cleanConfigFileReleases(ctx context.Context, tx store.Tx,
 	file *model.ConfigFile) *apiconfig.ConfigResponse {
 
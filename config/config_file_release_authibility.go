@@ -101,6 +101,21 @@ func (s *serverAuthability) GetConfigFileReleases(ctx context.Context,
 	return s.targetServer.GetConfigFileReleases(ctx, filters)
 }
 
+// ForceNotifyConfigFile implements ConfigCenterServer.
+func (s *serverAuthability) ForceNotifyConfigFile(ctx context.Context,
+	req *apiconfig.ConfigFileRelease) *apiconfig.ConfigResponse {
+
+	authCtx := s.collectConfigFileReleaseAuthContext(ctx, []*apiconfig.ConfigFileRelease{req},
+		model.Modify, "ForceNotifyConfigFile")
+
+	if _, err := s.strategyMgn.GetAuthChecker().CheckConsolePermission(authCtx); err != nil {
+		return api.NewConfigResponseWithInfo(convertToErrCode(err), err.Error())
+	}
+	ctx = authCtx.GetRequestContext()
+	ctx = context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
+	return s.targetServer.ForceNotifyConfigFile(ctx, req)
+}
+
 // RollbackConfigFileReleases implements ConfigCenterServer.
 func (s *serverAuthability) RollbackConfigFileReleases(ctx context.Context,
 	reqs []*apiconfig.ConfigFileRelease) *apiconfig.ConfigBatchWriteResponse {
@@ -0,0 +1,147 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+type (
+	// ContentValidator 写入以及发布配置前对配置内容做 schema 校验，按照配置文件格式注册
+	ContentValidator interface {
+		// Validate 校验配置内容，没有为该配置文件注册 schema 时直接返回 nil，
+		// 校验不通过时返回 *ContentValidationError 以说明具体是哪个字段不符合 schema
+		Validate(ctx context.Context, file *apiconfig.ConfigFile) error
+	}
+
+	// ContentValidationError 配置内容没有通过 schema 校验
+	ContentValidationError struct {
+		// Path 不符合 schema 的字段路径
+		Path string
+		// Message 不符合 schema 的原因
+		Message string
+	}
+)
+
+// Error .
+func (e *ContentValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// validators 返回配置格式到内容校验器的映射，首次访问时初始化，可以被并发调用
+func (s *Server) validators() *utils.SyncMap[string, ContentValidator] {
+	s.contentValidatorsOnce.Do(func() {
+		s.contentValidators = utils.NewSyncMap[string, ContentValidator]()
+	})
+	return s.contentValidators
+}
+
+// registerBuiltinContentValidators 为 json、yaml、xml 格式的配置文件注册默认的语法校验器
+func (s *Server) registerBuiltinContentValidators() {
+	s.RegisterContentValidator(utils.FileFormatJson, jsonContentValidator{})
+	s.RegisterContentValidator(utils.FileFormatYaml, yamlContentValidator{})
+	s.RegisterContentValidator(utils.FileFormatXml, xmlContentValidator{})
+}
+
+// RegisterContentValidator 注册某种格式配置文件的内容校验器，同一种格式重复注册时覆盖之前的校验器，传入 nil 时取消注册
+func (s *Server) RegisterContentValidator(format string, validator ContentValidator) {
+	format = strings.ToLower(format)
+	if validator == nil {
+		s.validators().Delete(format)
+		return
+	}
+	s.validators().Store(format, validator)
+}
+
+// validateConfigFileContent 使用配置格式对应的校验器校验配置内容，没有注册校验器的格式直接放行
+func (s *Server) validateConfigFileContent(ctx context.Context, file *apiconfig.ConfigFile) *apiconfig.ConfigResponse {
+	validator, ok := s.validators().Load(strings.ToLower(file.GetFormat().GetValue()))
+	if !ok {
+		return nil
+	}
+	err := validator.Validate(ctx, file)
+	if err == nil {
+		return nil
+	}
+	log.Info("[Config][File] config file content validate fail.", utils.RequestID(ctx),
+		utils.ZapNamespace(file.GetNamespace().GetValue()), utils.ZapGroup(file.GetGroup().GetValue()),
+		utils.ZapFileName(file.GetName().GetValue()), zap.Error(err))
+	var validationErr *ContentValidationError
+	if errors.As(err, &validationErr) {
+		return api.NewConfigResponseWithInfo(apimodel.Code(api.InvalidConfigFileSchema), validationErr.Error())
+	}
+	return api.NewConfigResponseWithInfo(apimodel.Code(api.InvalidConfigFileSchema), err.Error())
+}
+
+// jsonContentValidator 校验配置内容是合法的 json，空内容直接放行
+type jsonContentValidator struct{}
+
+func (jsonContentValidator) Validate(_ context.Context, file *apiconfig.ConfigFile) error {
+	content := file.GetContent().GetValue()
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(content), &data); err != nil {
+		return &ContentValidationError{Path: "$", Message: "invalid json: " + err.Error()}
+	}
+	return nil
+}
+
+// yamlContentValidator 校验配置内容是合法的 yaml
+type yamlContentValidator struct{}
+
+func (yamlContentValidator) Validate(_ context.Context, file *apiconfig.ConfigFile) error {
+	var data interface{}
+	if err := yaml.Unmarshal([]byte(file.GetContent().GetValue()), &data); err != nil {
+		return &ContentValidationError{Path: "$", Message: "invalid yaml: " + err.Error()}
+	}
+	return nil
+}
+
+// xmlContentValidator 校验配置内容是合法的 xml，空内容直接放行
+type xmlContentValidator struct{}
+
+func (xmlContentValidator) Validate(_ context.Context, file *apiconfig.ConfigFile) error {
+	decoder := xml.NewDecoder(strings.NewReader(file.GetContent().GetValue()))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return &ContentValidationError{Path: "$", Message: "invalid xml: " + err.Error()}
+		}
+	}
+}
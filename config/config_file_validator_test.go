@@ -0,0 +1,187 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+	storemock "github.com/polarismesh/polaris/store/mock"
+)
+
+// testJSONValidator 只为 app.json 注册了 schema：port 字段必须是数字
+type testJSONValidator struct{}
+
+func (v *testJSONValidator) Validate(_ context.Context, file *apiconfig.ConfigFile) error {
+	if file.GetName().GetValue() != "app.json" {
+		return nil
+	}
+	content := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(file.GetContent().GetValue()), &content); err != nil {
+		return &ContentValidationError{Path: "$", Message: "invalid json"}
+	}
+	if _, ok := content["port"].(float64); !ok {
+		return &ContentValidationError{Path: "$.port", Message: "must be a number"}
+	}
+	return nil
+}
+
+func Test_UpsertAndReleaseConfigFileFromClient_ContentValidator(t *testing.T) {
+	// storage 为空，校验不通过的请求如果发生任何写入都会直接 panic
+	svr := &Server{cfg: &Config{ContentMaxLength: fileContentMaxLength}}
+	svr.RegisterContentValidator("JSON", &testJSONValidator{})
+
+	publish := func(ctx context.Context, fileName, format, content string) *apiconfig.ConfigResponse {
+		return svr.UpsertAndReleaseConfigFileFromClient(ctx, &apiconfig.ConfigFilePublishInfo{
+			Namespace: utils.NewStringValue("default"),
+			Group:     utils.NewStringValue("group"),
+			FileName:  utils.NewStringValue(fileName),
+			Format:    utils.NewStringValue(format),
+			Content:   utils.NewStringValue(content),
+		})
+	}
+
+	// 符合 schema 的配置
+	rsp := publish(utils.WithDryRun(context.Background()), "app.json", "json", `{"port": 8080}`)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue(), rsp.GetInfo().GetValue())
+
+	// 不符合 schema 的配置在写入前被拒绝，并且说明具体的字段
+	rsp = publish(context.Background(), "app.json", "json", `{"port": "8080"}`)
+	assert.Equal(t, api.InvalidConfigFileSchema, rsp.GetCode().GetValue())
+	assert.Equal(t, "$.port: must be a number", rsp.GetInfo().GetValue())
+	rsp = publish(utils.WithDryRun(context.Background()), "app.json", "json", `{"port":`)
+	assert.Equal(t, api.InvalidConfigFileSchema, rsp.GetCode().GetValue())
+
+	// 没有注册 schema 的配置文件以及配置格式不受影响
+	rsp = publish(utils.WithDryRun(context.Background()), "other.json", "json", `{"port": "8080"}`)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue(), rsp.GetInfo().GetValue())
+	rsp = publish(utils.WithDryRun(context.Background()), "app.json", "yaml", `port: "8080"`)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue(), rsp.GetInfo().GetValue())
+
+	// 取消注册后不再校验
+	svr.RegisterContentValidator("json", nil)
+	rsp = publish(utils.WithDryRun(context.Background()), "app.json", "json", `{"port": "8080"}`)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue(), rsp.GetInfo().GetValue())
+}
+
+func Test_BuiltinContentValidators(t *testing.T) {
+	svr := &Server{cfg: &Config{ContentMaxLength: fileContentMaxLength}}
+	svr.registerBuiltinContentValidators()
+
+	validate := func(format, content string) *apiconfig.ConfigResponse {
+		return svr.validateConfigFileContent(context.Background(), &apiconfig.ConfigFile{
+			Name:    utils.NewStringValue("app"),
+			Format:  utils.NewStringValue(format),
+			Content: utils.NewStringValue(content),
+		})
+	}
+
+	assert.Nil(t, validate(utils.FileFormatJson, `{"port": 8080}`))
+	assert.Nil(t, validate(utils.FileFormatJson, ""))
+	assert.Equal(t, api.InvalidConfigFileSchema, validate("JSON", `{"port":`).GetCode().GetValue())
+	assert.Nil(t, validate(utils.FileFormatYaml, "port: 8080\nhost: 127.0.0.1"))
+	assert.Equal(t, api.InvalidConfigFileSchema, validate(utils.FileFormatYaml, "port: [8080").GetCode().GetValue())
+	assert.Nil(t, validate(utils.FileFormatXml, `<app><port>8080</port></app>`))
+	assert.Equal(t, api.InvalidConfigFileSchema, validate(utils.FileFormatXml, `<app><port>8080</app>`).GetCode().GetValue())
+	// 没有注册校验器的格式不受影响
+	assert.Nil(t, validate(utils.FileFormatProperties, `{"port":`))
+	assert.Nil(t, validate(utils.FileFormatText, `{"port":`))
+}
+
+func Test_RegisterContentValidator_Concurrent(t *testing.T) {
+	svr := &Server{cfg: &Config{ContentMaxLength: fileContentMaxLength}}
+	wait := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			svr.RegisterContentValidator("json", &testJSONValidator{})
+			_ = svr.validateConfigFileContent(context.Background(), &apiconfig.ConfigFile{
+				Name:    utils.NewStringValue("app.json"),
+				Format:  utils.NewStringValue("json"),
+				Content: utils.NewStringValue(`{"port": 8080}`),
+			})
+		}()
+	}
+	wait.Wait()
+	_, ok := svr.validators().Load("json")
+	assert.True(t, ok)
+}
+
+func Test_ConsoleWriteContentValidator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// 校验不通过时不会写入配置文件以及发布记录，mock store 上没有声明任何写入方法
+	storage := storemock.NewMockStore(ctrl)
+	svr := &Server{cfg: &Config{ContentMaxLength: fileContentMaxLength}, storage: storage}
+	svr.registerBuiltinContentValidators()
+
+	req := &apiconfig.ConfigFile{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		Name:      utils.NewStringValue("app.json"),
+		Format:    utils.NewStringValue(utils.FileFormatJson),
+		Content:   utils.NewStringValue(`{"port":`),
+	}
+
+	t.Run("create", func(t *testing.T) {
+		storage.EXPECT().GetConfigFileTx(gomock.Any(), "default", "group", "app.json").Return(nil, nil)
+		rsp := svr.handleCreateConfigFile(context.Background(), nil, req)
+		assert.Equal(t, api.InvalidConfigFileSchema, rsp.GetCode().GetValue())
+	})
+
+	t.Run("update", func(t *testing.T) {
+		storage.EXPECT().GetConfigFileTx(gomock.Any(), "default", "group", "app.json").Return(&model.ConfigFile{
+			Namespace: "default",
+			Group:     "group",
+			Name:      "app.json",
+			Format:    utils.FileFormatJson,
+			Content:   `{"port": 8080}`,
+		}, nil)
+		rsp := svr.handleUpdateConfigFile(context.Background(), nil, req)
+		assert.Equal(t, api.InvalidConfigFileSchema, rsp.GetCode().GetValue())
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		storage.EXPECT().GetConfigFileTx(gomock.Any(), "default", "group", "app.json").Return(&model.ConfigFile{
+			Namespace: "default",
+			Group:     "group",
+			Name:      "app.json",
+			Format:    utils.FileFormatJson,
+			Content:   `{"port":`,
+		}, nil)
+		data, rsp := svr.handlePublishConfigFile(context.Background(), nil, &apiconfig.ConfigFileRelease{
+			Namespace: utils.NewStringValue("default"),
+			Group:     utils.NewStringValue("group"),
+			FileName:  utils.NewStringValue("app.json"),
+		})
+		assert.Nil(t, data)
+		assert.Equal(t, api.InvalidConfigFileSchema, rsp.GetCode().GetValue())
+	})
+}
@@ -0,0 +1,61 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/polarismesh/polaris/plugin"
+)
+
+// DebugHandlers 配置中心的排查接口
+func (s *Server) DebugHandlers() []plugin.DebugHandler {
+	return []plugin.DebugHandler{
+		{
+			Path:    "/debug/config/watch_contexts",
+			Handler: handleDescribeWatchContexts(s),
+		},
+	}
+}
+
+// handleDescribeWatchContexts 查询当前所有客户端的订阅上下文，支持通过 client_id 参数只查询某个客户端
+func handleDescribeWatchContexts(s *Server) func(http.ResponseWriter, *http.Request) {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if s.watchCenter == nil {
+			resp.WriteHeader(http.StatusTooEarly)
+			_, _ = resp.Write([]byte("config server not initialize"))
+			return
+		}
+
+		snapshots := s.watchCenter.Snapshot()
+		if clientId := req.URL.Query().Get("client_id"); clientId != "" {
+			ret := make([]WatchContextSnapshot, 0, 1)
+			for i := range snapshots {
+				if snapshots[i].ClientID == clientId {
+					ret = append(ret, snapshots[i])
+				}
+			}
+			snapshots = ret
+		}
+
+		data, _ := json.Marshal(snapshots)
+		resp.WriteHeader(http.StatusOK)
+		_, _ = resp.Write(data)
+	}
+}
@@ -0,0 +1,143 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	lru "github.com/hashicorp/golang-lru"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"google.golang.org/grpc/metadata"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// defaultPublishDedupWindow 默认的发布请求去重时间窗口
+	defaultPublishDedupWindow = 5 * time.Minute
+	// defaultPublishDedupMaxKeys 最多记录多少个幂等键，超过后淘汰最久未访问的幂等键
+	defaultPublishDedupMaxKeys = 10000
+)
+
+// publishDedupEntry 一个幂等键对应的发布请求及其结果
+type publishDedupEntry struct {
+	fingerprint string
+	expireAt    time.Time
+	// done 发布完成时关闭，并发的重试请求等待第一个请求的结果
+	done chan struct{}
+	rsp  *apiconfig.ConfigClientResponse
+}
+
+// publishDeduper 根据客户端携带的幂等键对发布请求去重，避免网络重试导致重复发布
+type publishDeduper struct {
+	lock    sync.Mutex
+	window  time.Duration
+	entries *lru.Cache
+}
+
+func newPublishDeduper(window time.Duration) *publishDeduper {
+	if window <= 0 {
+		window = defaultPublishDedupWindow
+	}
+	entries, _ := lru.New(defaultPublishDedupMaxKeys)
+	return &publishDeduper{
+		window:  window,
+		entries: entries,
+	}
+}
+
+// do 同一个幂等键在去重窗口内只会真正执行一次发布，重复的请求直接返回第一次发布的结果；
+// 同一个幂等键对应的请求内容不一致时返回冲突，发布失败的结果不会被记录，客户端可以继续重试
+func (d *publishDeduper) do(key string, req *apiconfig.ConfigFileRelease,
+	publish func() *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+	if d == nil || key == "" {
+		return publish()
+	}
+	fingerprint := publishFingerprint(req)
+
+	d.lock.Lock()
+	if val, ok := d.entries.Get(key); ok {
+		entry := val.(*publishDedupEntry)
+		if time.Now().Before(entry.expireAt) {
+			d.lock.Unlock()
+			if entry.fingerprint != fingerprint {
+				return api.NewConfigClientResponseWithInfo(apimodel.Code_DataConflict,
+					"idempotency key is already used by another publish request")
+			}
+			<-entry.done
+			return entry.rsp
+		}
+	}
+	entry := &publishDedupEntry{
+		fingerprint: fingerprint,
+		expireAt:    time.Now().Add(d.window),
+		done:        make(chan struct{}),
+	}
+	d.entries.Add(key, entry)
+	d.lock.Unlock()
+
+	entry.rsp = publish()
+	close(entry.done)
+	if entry.rsp.GetCode().GetValue() != uint32(apimodel.Code_ExecuteSuccess) {
+		d.lock.Lock()
+		if val, ok := d.entries.Peek(key); ok && val == entry {
+			d.entries.Remove(key)
+		}
+		d.lock.Unlock()
+	}
+	return entry.rsp
+}
+
+// publishFingerprint 计算发布请求的指纹，用于判断同一个幂等键对应的请求内容是否一致
+func publishFingerprint(req *apiconfig.ConfigFileRelease) string {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return req.String()
+	}
+	return CalMd5(string(data))
+}
+
+// publishDedupKey 幂等键只在同一个操作人对同一个配置文件的发布请求之间生效，
+// 避免不同的操作人或者不同的配置文件使用了相同的幂等键时相互冲突，没有携带幂等键时返回空
+func publishDedupKey(ctx context.Context, req *apiconfig.ConfigFileRelease) string {
+	key := parseIdempotencyKey(ctx)
+	if key == "" {
+		return ""
+	}
+	return strings.Join([]string{parseAuthPrincipal(ctx), req.GetNamespace().GetValue(),
+		req.GetGroup().GetValue(), req.GetFileName().GetValue(), key}, "|")
+}
+
+// parseIdempotencyKey 从 HTTP 请求头或者 gRPC metadata 中获取客户端携带的幂等键
+func parseIdempotencyKey(ctx context.Context) string {
+	val, _ := ctx.Value(utils.ContextIdempotencyKey).(string)
+	if val == "" {
+		if md, ok := ctx.Value(utils.ContextGrpcHeader).(metadata.MD); ok {
+			if vals := md.Get(utils.HeaderIdempotencyKey); len(vals) > 0 {
+				val = vals[0]
+			}
+		}
+	}
+	return strings.TrimSpace(val)
+}
@@ -0,0 +1,144 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func newTestPublishRequest(fileName, releaseName string) *apiconfig.ConfigFileRelease {
+	return &apiconfig.ConfigFileRelease{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue(fileName),
+		Name:      utils.NewStringValue(releaseName),
+	}
+}
+
+func Test_publishDeduper_DuplicateSame(t *testing.T) {
+	deduper := newPublishDeduper(time.Minute)
+	published := 0
+	publish := func() *apiconfig.ConfigClientResponse {
+		published++
+		return api.NewConfigClientResponseWithInfo(apimodel.Code_ExecuteSuccess, "release-1")
+	}
+
+	first := deduper.do("key-1", newTestPublishRequest("file-1", "release-1"), publish)
+	// 网络重试携带同样的幂等键以及同样的请求内容，直接返回第一次发布的结果
+	retry := deduper.do("key-1", newTestPublishRequest("file-1", "release-1"), publish)
+	assert.Equal(t, 1, published)
+	assert.Same(t, first, retry)
+
+	// 不同的幂等键以及不携带幂等键的请求正常发布
+	deduper.do("key-2", newTestPublishRequest("file-1", "release-1"), publish)
+	deduper.do("", newTestPublishRequest("file-1", "release-1"), publish)
+	assert.Equal(t, 3, published)
+}
+
+func Test_publishDeduper_DuplicateDifferent(t *testing.T) {
+	deduper := newPublishDeduper(time.Minute)
+	published := 0
+	publish := func() *apiconfig.ConfigClientResponse {
+		published++
+		return api.NewConfigClientResponse0(apimodel.Code_ExecuteSuccess)
+	}
+
+	deduper.do("key-1", newTestPublishRequest("file-1", "release-1"), publish)
+	// 同一个幂等键对应的请求内容不一致，不能当作重复请求
+	rsp := deduper.do("key-1", newTestPublishRequest("file-1", "release-2"), publish)
+	assert.Equal(t, uint32(apimodel.Code_DataConflict), rsp.GetCode().GetValue())
+	rsp = deduper.do("key-1", newTestPublishRequest("file-2", "release-1"), publish)
+	assert.Equal(t, uint32(apimodel.Code_DataConflict), rsp.GetCode().GetValue())
+	assert.Equal(t, 1, published)
+}
+
+func Test_publishDeduper_FailedAndExpired(t *testing.T) {
+	deduper := newPublishDeduper(50 * time.Millisecond)
+	code := apimodel.Code_StoreLayerException
+	published := 0
+	publish := func() *apiconfig.ConfigClientResponse {
+		published++
+		return api.NewConfigClientResponse0(code)
+	}
+	req := newTestPublishRequest("file-1", "release-1")
+
+	// 发布失败的结果不会被记录，客户端重试时会重新发布
+	assert.Equal(t, uint32(code), deduper.do("key-1", req, publish).GetCode().GetValue())
+	code = apimodel.Code_ExecuteSuccess
+	assert.Equal(t, uint32(code), deduper.do("key-1", req, publish).GetCode().GetValue())
+	deduper.do("key-1", req, publish)
+	assert.Equal(t, 2, published)
+
+	// 超过去重窗口后重新发布
+	time.Sleep(100 * time.Millisecond)
+	deduper.do("key-1", req, publish)
+	assert.Equal(t, 3, published)
+}
+
+func Test_parseIdempotencyKey(t *testing.T) {
+	assert.Equal(t, "", parseIdempotencyKey(context.Background()))
+	ctx := context.WithValue(context.Background(), utils.ContextIdempotencyKey, " key-1 ")
+	assert.Equal(t, "key-1", parseIdempotencyKey(ctx))
+	ctx = context.WithValue(context.Background(), utils.ContextGrpcHeader,
+		metadata.Pairs(utils.HeaderIdempotencyKey, "key-2"))
+	assert.Equal(t, "key-2", parseIdempotencyKey(ctx))
+}
+
+func Test_publishDedupKey(t *testing.T) {
+	withOperator := func(operator string) context.Context {
+		authCtx := model.NewAcquireContext()
+		authCtx.SetAttachment(model.OperatorIDKey, operator)
+		ctx := context.WithValue(context.Background(), utils.ContextIdempotencyKey, "key-1")
+		return context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
+	}
+	req := newTestPublishRequest("file-1", "release-1")
+
+	// 没有携带幂等键时不做去重
+	assert.Equal(t, "", publishDedupKey(context.Background(), req))
+
+	// 同一个操作人对同一个配置文件的请求使用同一个去重键
+	assert.Equal(t, publishDedupKey(withOperator("user-1"), req),
+		publishDedupKey(withOperator("user-1"), newTestPublishRequest("file-1", "release-2")))
+
+	// 不同的操作人以及不同的配置文件使用相同的幂等键时互不影响
+	deduper := newPublishDeduper(time.Minute)
+	published := 0
+	publish := func() *apiconfig.ConfigClientResponse {
+		published++
+		return api.NewConfigClientResponse0(apimodel.Code_ExecuteSuccess)
+	}
+	deduper.do(publishDedupKey(withOperator("user-1"), req), req, publish)
+	deduper.do(publishDedupKey(withOperator("user-2"), req), req, publish)
+	other := newTestPublishRequest("file-2", "release-1")
+	rsp := deduper.do(publishDedupKey(withOperator("user-1"), other), other, publish)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, 3, published)
+	deduper.do(publishDedupKey(withOperator("user-1"), req), req, publish)
+	assert.Equal(t, 3, published)
+}
@@ -0,0 +1,86 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"golang.org/x/time/rate"
+
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// PublishQuota 客户端发布配置的限流配置
+type PublishQuota struct {
+	// Rate 每秒允许发布的次数，小于等于 0 时不做限制
+	Rate float64 `yaml:"rate"`
+	// Burst 允许的突发发布次数
+	Burst int `yaml:"burst"`
+}
+
+// PublishQuotaConfig 按照命名空间维度的客户端发布配置限流配置
+type PublishQuotaConfig struct {
+	// Default 没有单独配置的命名空间使用的限流配置
+	Default *PublishQuota `yaml:"default"`
+	// Namespaces 单独配置的命名空间限流配置
+	Namespaces map[string]*PublishQuota `yaml:"namespaces"`
+}
+
+// DefaultPublishQuota 正常的业务不会频繁地发布配置，只有异常的客户端循环发布时才会触发限流
+var DefaultPublishQuota = PublishQuota{
+	Rate:  10,
+	Burst: 50,
+}
+
+// publishRateLimiter 按照命名空间维度的令牌桶限流器，避免单个租户频繁发布配置
+type publishRateLimiter struct {
+	cfg      PublishQuotaConfig
+	limiters *utils.SyncMap[string, *rate.Limiter]
+}
+
+func newPublishRateLimiter(cfg PublishQuotaConfig) *publishRateLimiter {
+	if cfg.Default == nil {
+		quota := DefaultPublishQuota
+		cfg.Default = &quota
+	}
+	return &publishRateLimiter{
+		cfg:      cfg,
+		limiters: utils.NewSyncMap[string, *rate.Limiter](),
+	}
+}
+
+// allow 判断命名空间本次发布请求是否被允许
+func (l *publishRateLimiter) allow(namespace string) bool {
+	if l == nil {
+		return true
+	}
+	quota, ok := l.cfg.Namespaces[namespace]
+	if !ok || quota == nil {
+		quota = l.cfg.Default
+	}
+	if quota.Rate <= 0 {
+		return true
+	}
+	limiter, _ := l.limiters.ComputeIfAbsent(namespace, func(_ string) *rate.Limiter {
+		burst := quota.Burst
+		// burst 为 0 时令牌桶永远不会放行请求，至少允许发布一次
+		if burst <= 0 {
+			burst = 1
+		}
+		return rate.NewLimiter(rate.Limit(quota.Rate), burst)
+	})
+	return limiter.Allow()
+}
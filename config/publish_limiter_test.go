@@ -0,0 +1,112 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	authmock "github.com/polarismesh/polaris/auth/mock"
+	"github.com/polarismesh/polaris/cache/mock"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_serverAuthability_PublishQuota(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 10),
+	}).AnyTimes()
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	checker := authmock.NewMockAuthChecker(ctrl)
+	checker.EXPECT().CheckClientPermission(gomock.Any()).Return(true, nil).AnyTimes()
+
+	proxy := &serverAuthability{
+		targetServer: &Server{
+			cfg:         &Config{ContentMaxLength: fileContentMaxLength},
+			watchCenter: wc,
+			fileCache:   fileCache,
+			groupCache:  groupCache,
+		},
+		strategyMgn:  &testStrategyServer{checker: checker},
+		watchLimiter: newWatchRateLimiter(DefaultWatchRateLimitConfig),
+		publishLimiter: newPublishRateLimiter(PublishQuotaConfig{
+			Default: &PublishQuota{Rate: 0.1, Burst: 5},
+			Namespaces: map[string]*PublishQuota{
+				"vip": {Rate: 0.1, Burst: 20},
+			},
+		}),
+	}
+
+	// dry-run 不会写入存储，只用来驱动发布请求经过鉴权以及限流
+	publish := func(namespace string) uint32 {
+		rsp := proxy.UpsertAndReleaseConfigFileFromClient(utils.WithDryRun(context.Background()),
+			&apiconfig.ConfigFilePublishInfo{
+				Namespace: utils.NewStringValue(namespace),
+				Group:     utils.NewStringValue("group"),
+				FileName:  utils.NewStringValue("file-1"),
+				Content:   utils.NewStringValue("key: value"),
+			})
+		return rsp.GetCode().GetValue()
+	}
+	count := func(namespace string, times int) (int, int) {
+		allowed, limited := 0, 0
+		for i := 0; i < times; i++ {
+			switch publish(namespace) {
+			case uint32(apimodel.Code_ExecuteSuccess):
+				allowed++
+			case uint32(apimodel.Code_APIRateLimit):
+				limited++
+			}
+		}
+		return allowed, limited
+	}
+
+	allowed, limited := count("default", 50)
+	assert.Equal(t, 5, allowed)
+	assert.Equal(t, 45, limited)
+
+	// 单独配置了配额的命名空间使用自己的配额，并且不受其他命名空间影响
+	allowed, limited = count("vip", 50)
+	assert.Equal(t, 20, allowed)
+	assert.Equal(t, 30, limited)
+
+	// 发布被限流不影响已经发布的配置文件的订阅
+	callback, err := proxy.LongPullWatchFile(context.Background(), &apiconfig.ClientWatchConfigFileRequest{
+		ClientIp:   utils.NewStringValue("127.0.0.1"),
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), callback().GetCode().GetValue())
+}
+
+func Test_publishRateLimiter_Unlimited(t *testing.T) {
+	limiter := newPublishRateLimiter(PublishQuotaConfig{Default: &PublishQuota{Rate: 0}})
+	for i := 0; i < 100; i++ {
+		assert.True(t, limiter.allow("default"))
+	}
+}
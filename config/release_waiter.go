@@ -0,0 +1,155 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// releaseWaiter 记录监听中心已经处理过的配置发布事件，用于发布配置时同步等待发布事件被监听中心处理
+type releaseWaiter struct {
+	lock sync.Mutex
+	// fileId -> 最近一次处理的发布名称
+	observed map[string]string
+	// fileId -> releaseName -> waiters
+	waiters map[string]map[string][]chan struct{}
+}
+
+func newReleaseWaiter() *releaseWaiter {
+	return &releaseWaiter{
+		observed: map[string]string{},
+		waiters:  map[string]map[string][]chan struct{}{},
+	}
+}
+
+// observe 监听中心处理完一次配置发布事件，唤醒等待该次发布的请求
+func (w *releaseWaiter) observe(release *model.SimpleConfigFileRelease) {
+	fileId := utils.GenFileId(release.Namespace, release.Group, release.FileName)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.observed[fileId] = release.Name
+	fileWaiters, ok := w.waiters[fileId]
+	if !ok {
+		return
+	}
+	for _, ch := range fileWaiters[release.Name] {
+		close(ch)
+	}
+	delete(fileWaiters, release.Name)
+	if len(fileWaiters) == 0 {
+		delete(w.waiters, fileId)
+	}
+}
+
+// forget 同名的发布重新激活之前清理已经处理过的记录，避免等待请求被上一次同名发布的处理记录直接唤醒，
+// 需要在发布事务提交之前调用，保证本次发布的事件一定在清理之后才会被处理
+func (w *releaseWaiter) forget(namespace, group, fileName, releaseName string) {
+	fileId := utils.GenFileId(namespace, group, fileName)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.observed[fileId] == releaseName {
+		delete(w.observed, fileId)
+	}
+}
+
+// wait 等待指定的配置发布被监听中心处理，超时或者 ctx 结束时返回对应的错误
+func (w *releaseWaiter) wait(ctx context.Context, namespace, group, fileName, releaseName string,
+	timeout time.Duration) error {
+	fileId := utils.GenFileId(namespace, group, fileName)
+
+	w.lock.Lock()
+	// 发布事件可能在开始等待之前就已经被处理了
+	if w.observed[fileId] == releaseName {
+		w.lock.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	if _, ok := w.waiters[fileId]; !ok {
+		w.waiters[fileId] = map[string][]chan struct{}{}
+	}
+	w.waiters[fileId][releaseName] = append(w.waiters[fileId][releaseName], ch)
+	w.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-ch:
+		return nil
+	case <-timer.C:
+		err = context.DeadlineExceeded
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	w.cancel(fileId, releaseName, ch)
+	return err
+}
+
+func (w *releaseWaiter) cancel(fileId, releaseName string, ch chan struct{}) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	fileWaiters, ok := w.waiters[fileId]
+	if !ok {
+		return
+	}
+	chs := fileWaiters[releaseName]
+	for i := range chs {
+		if chs[i] == ch {
+			chs = append(chs[:i], chs[i+1:]...)
+			break
+		}
+	}
+	if len(chs) > 0 {
+		fileWaiters[releaseName] = chs
+		return
+	}
+	delete(fileWaiters, releaseName)
+	if len(fileWaiters) == 0 {
+		delete(w.waiters, fileId)
+	}
+}
+
+// waitReleasePropagated 等待本次配置发布被监听中心处理，超时时返回部分成功的错误码，配置本身已经发布成功
+func (s *Server) waitReleasePropagated(ctx context.Context, timeout time.Duration,
+	rsp *apiconfig.ConfigResponse) *apiconfig.ConfigResponse {
+	release := rsp.GetConfigFileRelease()
+	err := s.watchCenter.releaseWaiter.wait(ctx, release.GetNamespace().GetValue(), release.GetGroup().GetValue(),
+		release.GetFileName().GetValue(), release.GetName().GetValue(), timeout)
+	if err != nil {
+		log.Warn("[Config][Release] wait config file release propagate timeout", utils.RequestID(ctx),
+			utils.ZapNamespace(release.GetNamespace().GetValue()), utils.ZapGroup(release.GetGroup().GetValue()),
+			utils.ZapFileName(release.GetFileName().GetValue()), zap.Error(err))
+		timeoutRsp := api.NewConfigResponseWithInfo(apimodel.Code(api.ReleasePropagateTimeout),
+			api.Code2Info(api.ReleasePropagateTimeout))
+		timeoutRsp.ConfigFileRelease = rsp.GetConfigFileRelease()
+		return timeoutRsp
+	}
+	return rsp
+}
@@ -0,0 +1,87 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_Server_WaitReleasePropagated(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	svr := &Server{watchCenter: wc}
+
+	release := newTestRelease("default", "group", "file-1", 1)
+	release.Name = "release-1"
+	rsp := api.NewConfigResponse(apimodel.Code_ExecuteSuccess)
+	rsp.ConfigFileRelease = &apiconfig.ConfigFileRelease{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue("file-1"),
+		Name:      utils.NewStringValue("release-1"),
+	}
+
+	var observed atomic.Bool
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		// 其他配置的发布事件不会唤醒等待的请求
+		other := newTestRelease("default", "group", "file-2", 1)
+		other.Name = "release-1"
+		_ = wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{Message: other})
+		time.Sleep(100 * time.Millisecond)
+		observed.Store(true)
+		_ = wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{Message: release})
+	}()
+	ret := svr.waitReleasePropagated(context.Background(), 5*time.Second, rsp)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), ret.GetCode().GetValue())
+	// 只有在监听中心处理完本次发布事件之后才返回
+	assert.True(t, observed.Load())
+
+	// 发布事件已经处理过，不需要再等待
+	ret = svr.waitReleasePropagated(context.Background(), time.Second, rsp)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), ret.GetCode().GetValue())
+
+	// 同名的发布重新激活时，上一次同名发布的处理记录不能唤醒本次等待
+	wc.releaseWaiter.forget("default", "group", "file-1", "release-1")
+	ret = svr.waitReleasePropagated(context.Background(), 100*time.Millisecond, rsp)
+	assert.Equal(t, api.ReleasePropagateTimeout, ret.GetCode().GetValue())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{Message: release})
+	}()
+	ret = svr.waitReleasePropagated(context.Background(), 5*time.Second, rsp)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), ret.GetCode().GetValue())
+
+	// 等待超时返回部分成功的错误码，不会一直阻塞
+	rsp.ConfigFileRelease.Name = utils.NewStringValue("release-2")
+	start := time.Now()
+	ret = svr.waitReleasePropagated(context.Background(), 100*time.Millisecond, rsp)
+	assert.Equal(t, api.ReleasePropagateTimeout, ret.GetCode().GetValue())
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Empty(t, wc.releaseWaiter.waiters)
+}
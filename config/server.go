@@ -20,6 +20,8 @@ package config
 import (
 	"context"
 	"errors"
+	"sync"
+	"time"
 
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
 
@@ -98,6 +100,38 @@ var (
 type Config struct {
 	Open             bool  `yaml:"open"`
 	ContentMaxLength int64 `yaml:"contentMaxLength"`
+	// WatchExpireJitterRatio 长轮询超时时间的随机抖动比例，取值 (0, 1)，不设置时默认为 0.1
+	WatchExpireJitterRatio float64 `yaml:"watchExpireJitterRatio"`
+	// MaxWatchers 最多同时存在的订阅客户端数量，不设置时默认为 100000
+	MaxWatchers int `yaml:"maxWatchers"`
+	// NotifyWorkers 异步通知订阅客户端的协程数量，不设置时在事件消费协程中同步通知
+	NotifyWorkers int `yaml:"notifyWorkers"`
+	// NotifyQueueSize 每个通知协程的队列长度，队列满时阻塞事件消费，不设置时默认为 1024
+	NotifyQueueSize int `yaml:"notifyQueueSize"`
+	// NotifyContentMaxLength 配置内容不超过该长度时在变更通知中直接携带配置内容，不设置时只通知元数据
+	NotifyContentMaxLength int `yaml:"notifyContentMaxLength"`
+	// NotifyFanOutConcurrency 单个配置文件的订阅者较多时并发通知的协程数量，不设置时逐个通知
+	NotifyFanOutConcurrency int `yaml:"notifyFanOutConcurrency"`
+	// ClientMessageMaxSize 返回给客户端的单个配置响应的最大字节数，不设置时默认为 4MB，与 gRPC 客户端默认的最大接收消息大小一致
+	ClientMessageMaxSize int `yaml:"clientMessageMaxSize"`
+	// DownloadChunkSize 分片下载配置内容时的分片大小，不设置时默认为 1MB
+	DownloadChunkSize int `yaml:"downloadChunkSize"`
+	// PublishDedupWindow 客户端携带幂等键发布配置时的去重时间窗口，不设置时默认为 5m
+	PublishDedupWindow time.Duration `yaml:"publishDedupWindow"`
+	// PublishQuota 客户端发布配置的命名空间级别限流配置
+	PublishQuota PublishQuotaConfig `yaml:"publishQuota"`
+	// WatchExpiry 订阅协议 -> 订阅上下文的过期策略，不设置时长轮询到达超时时间后过期，流式订阅不过期
+	WatchExpiry map[string]ExpiryPolicyConfig `yaml:"watchExpiry"`
+	// WatchMemorySoftLimit 订阅上下文估算占用内存的软上限，单位为字节，超过后淘汰最久没有活跃的订阅，不设置时不做限制
+	WatchMemorySoftLimit int64 `yaml:"watchMemorySoftLimit"`
+	// NotifyLogSampling 配置变更通知日志的采样配置，不设置时每一次通知都输出日志
+	NotifyLogSampling NotifyLogSamplingConfig `yaml:"notifyLogSampling"`
+	// NotifyCodeMappings 按照客户端声明的版本转换订阅响应码，不设置时原样返回
+	NotifyCodeMappings []NotifyCodeMapping `yaml:"notifyCodeMappings"`
+	// WatchPersistence 客户端订阅关系的持久化配置，不设置时服务端重启后丢失订阅关系
+	WatchPersistence WatchPersistenceConfig `yaml:"watchPersistence"`
+	// WatchSessionResumeWindow 客户端断开后在该时间内使用相同的 clientId 重新订阅时沿用之前的会话 ID，不设置时每次订阅都生成新的会话 ID
+	WatchSessionResumeWindow time.Duration `yaml:"watchSessionResumeWindow"`
 }
 
 // Server 配置中心核心服务
@@ -109,12 +143,17 @@ type Server struct {
 	groupCache        cachetypes.ConfigGroupCache
 	caches            *cache.CacheManager
 	watchCenter       *watchCenter
+	publishDedup      *publishDeduper
+	fileExistCache    *fileExistCache
 	namespaceOperator namespace.NamespaceOperateServer
 	initialized       bool
 
 	history       plugin.History
 	cryptoManager plugin.CryptoManager
 	hooks         []ResourceHook
+	// contentValidators 配置文件格式 -> 写入以及发布配置时的内容校验器
+	contentValidators     *utils.SyncMap[string, ContentValidator]
+	contentValidatorsOnce sync.Once
 
 	// chains
 	chains *ConfigChains
@@ -153,6 +192,7 @@ func (s *Server) initialize(ctx context.Context, config Config, ss store.Store,
 		s.cfg.ContentMaxLength = fileContentMaxLength
 	}
 	s.storage = ss
+	s.registerBuiltinContentValidators()
 	s.namespaceOperator = namespaceOperator
 	s.fileCache = cacheMgn.ConfigFile()
 	s.groupCache = cacheMgn.ConfigGroup()
@@ -161,6 +201,38 @@ func (s *Server) initialize(ctx context.Context, config Config, ss store.Store,
 	if err != nil {
 		return err
 	}
+	if ratio := s.cfg.WatchExpireJitterRatio; ratio > 0 && ratio < 1 {
+		s.watchCenter.expireJitterRatio = ratio
+	}
+	if s.cfg.MaxWatchers > 0 {
+		s.watchCenter.maxWatchers = s.cfg.MaxWatchers
+	}
+	s.fileExistCache = newFileExistCache(defaultFileExistCacheTTL)
+	s.watchCenter.fileExisted = s.isConfigFileExisted
+	s.watchCenter.inlineContentMaxLength = s.cfg.NotifyContentMaxLength
+	s.watchCenter.notifyFanOutConcurrency = s.cfg.NotifyFanOutConcurrency
+	s.watchCenter.messageMaxSize = s.clientMessageMaxSize()
+	s.watchCenter.watchMemorySoftLimit.Store(s.cfg.WatchMemorySoftLimit)
+	s.watchCenter.notifyLogSampler = newNotifyLogSampler(s.cfg.NotifyLogSampling)
+	s.watchCenter.codeMappings = s.cfg.NotifyCodeMappings
+	for protocol, expiryCfg := range s.cfg.WatchExpiry {
+		policy, err := NewExpiryPolicy(expiryCfg)
+		if err != nil {
+			return err
+		}
+		if err := s.watchCenter.SetExpiryPolicy(protocol, policy); err != nil {
+			return err
+		}
+	}
+	s.publishDedup = newPublishDeduper(s.cfg.PublishDedupWindow)
+	s.watchCenter.startNotifyPool(s.cfg.NotifyWorkers, s.cfg.NotifyQueueSize)
+	s.watchCenter.sessionResumeWindow = s.cfg.WatchSessionResumeWindow
+	if s.cfg.WatchPersistence.Open {
+		persistence := newWatchPersistence(ss, s.cfg.WatchPersistence)
+		if err := s.watchCenter.startWatchPersistence(persistence); err != nil {
+			return err
+		}
+	}
 
 	// 获取History插件，注意：插件的配置在bootstrap已经设置好
 	s.history = plugin.GetHistory()
@@ -19,15 +19,20 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
 	apisecurity "github.com/polarismesh/specification/source/go/api/v1/security"
 	"go.uber.org/zap"
 
 	"github.com/polarismesh/polaris/auth"
+	"github.com/polarismesh/polaris/common/metrics"
 	"github.com/polarismesh/polaris/common/model"
 	"github.com/polarismesh/polaris/common/utils"
+	"github.com/polarismesh/polaris/plugin"
 )
 
 var _ ConfigCenterServer = (*serverAuthability)(nil)
@@ -37,19 +42,47 @@ type serverAuthability struct {
 	targetServer *Server
 	userMgn      auth.UserServer
 	strategyMgn  auth.StrategyServer
+	// watchLimiter 客户端订阅配置的限流器
+	watchLimiter *watchRateLimiter
+	// publishLimiter 客户端发布配置的命名空间级别限流器
+	publishLimiter *publishRateLimiter
+	// notifyAuthCache 通知客户端时重新鉴权的结果缓存
+	notifyAuthCache *notifyAuthCache
 }
 
 func newServerAuthAbility(targetServer *Server,
 	userMgn auth.UserServer, strategyMgn auth.StrategyServer) ConfigCenterServer {
+	var publishQuota PublishQuotaConfig
+	if targetServer.cfg != nil {
+		publishQuota = targetServer.cfg.PublishQuota
+	}
 	proxy := &serverAuthability{
-		targetServer: targetServer,
-		userMgn:      userMgn,
-		strategyMgn:  strategyMgn,
+		targetServer:    targetServer,
+		userMgn:         userMgn,
+		strategyMgn:     strategyMgn,
+		watchLimiter:    newWatchRateLimiter(DefaultWatchRateLimitConfig),
+		publishLimiter:  newPublishRateLimiter(publishQuota),
+		notifyAuthCache: newNotifyAuthCache(defaultNotifyAuthCacheTTL),
 	}
 	targetServer.SetResourceHooks(proxy)
+	if targetServer.watchCenter != nil {
+		targetServer.watchCenter.SetNotifyAuthorizer(proxy.authorizeNotify)
+	}
 	return proxy
 }
 
+// DebugHandlers 排查接口和其他模块的 debug 接口一样不经过鉴权
+func (s *serverAuthability) DebugHandlers() []plugin.DebugHandler {
+	return s.targetServer.DebugHandlers()
+}
+
+// checkClientPermission 客户端接口鉴权，并按照接口记录鉴权通过以及拒绝的次数
+func (s *serverAuthability) checkClientPermission(authCtx *model.AcquireContext) error {
+	_, err := s.strategyMgn.GetAuthChecker().CheckClientPermission(authCtx)
+	metrics.ReportConfigClientAuth(authCtx.GetMethod(), err == nil)
+	return err
+}
+
 func (s *serverAuthability) collectConfigFileAuthContext(ctx context.Context, req []*apiconfig.ConfigFile,
 	op model.ResourceOperation, methodName string) *model.AcquireContext {
 	return model.NewAcquireContext(
@@ -58,6 +91,7 @@ func (s *serverAuthability) collectConfigFileAuthContext(ctx context.Context, re
 		model.WithOperation(op),
 		model.WithMethod(methodName),
 		model.WithAccessResources(s.queryConfigFileResource(ctx, req)),
+		model.WithDelegationToken(utils.ParseDelegationToken(ctx)),
 	)
 }
 
@@ -70,6 +104,7 @@ func (s *serverAuthability) collectClientConfigFileAuthContext(ctx context.Conte
 		model.WithMethod(methodName),
 		model.WithFromClient(),
 		model.WithAccessResources(s.queryConfigFileResource(ctx, req)),
+		model.WithDelegationToken(utils.ParseDelegationToken(ctx)),
 	)
 }
 
@@ -82,6 +117,7 @@ func (s *serverAuthability) collectClientWatchConfigFiles(ctx context.Context,
 		model.WithMethod(methodName),
 		model.WithFromClient(),
 		model.WithAccessResources(s.queryWatchConfigFilesResource(ctx, req)),
+		model.WithDelegationToken(utils.ParseDelegationToken(ctx)),
 	)
 }
 
@@ -104,6 +140,7 @@ func (s *serverAuthability) collectConfigFilePublishAuthContext(ctx context.Cont
 		model.WithOperation(op),
 		model.WithMethod(methodName),
 		model.WithAccessResources(s.queryConfigFilePublishResource(ctx, req)),
+		model.WithDelegationToken(utils.ParseDelegationToken(ctx)),
 	)
 }
 
@@ -116,6 +153,7 @@ func (s *serverAuthability) collectClientConfigFileReleaseAuthContext(ctx contex
 		model.WithMethod(methodName),
 		model.WithFromClient(),
 		model.WithAccessResources(s.queryConfigFileReleaseResource(ctx, req)),
+		model.WithDelegationToken(utils.ParseDelegationToken(ctx)),
 	)
 }
 
@@ -305,6 +343,28 @@ func (s *serverAuthability) queryConfigGroupRsEntryByNames(ctx context.Context,
 	return entries, nil
 }
 
+// wrapWatchPermissionError 鉴权失败时找出请求中没有权限订阅的配置文件，方便客户端定位问题
+func (s *serverAuthability) wrapWatchPermissionError(req *apiconfig.ClientWatchConfigFileRequest, err error) error {
+	var permErr *model.ResourcePermissionError
+	if !errors.As(err, &permErr) {
+		return err
+	}
+	deniedFiles := make([]string, 0, len(req.GetWatchFiles()))
+	for _, file := range req.GetWatchFiles() {
+		namespace := file.GetNamespace().GetValue()
+		groupName := file.GetGroup().GetValue()
+		data := s.targetServer.groupCache.GetGroupByName(namespace, groupName)
+		// 缓存中还没有的配置分组无法确认是否有权限，同样认为没有权限
+		if data == nil || permErr.IsDenied(apisecurity.ResourceType_ConfigGroups, strconv.FormatUint(data.Id, 10)) {
+			deniedFiles = append(deniedFiles, namespace+"/"+groupName+"/"+file.GetFileName().GetValue())
+		}
+	}
+	if len(deniedFiles) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w, no permission to watch config files: %s", err, strings.Join(deniedFiles, ","))
+}
+
 func (s *serverAuthability) queryWatchConfigFilesResource(ctx context.Context,
 	req *apiconfig.ClientWatchConfigFileRequest) map[apisecurity.ResourceType][]model.ResourceEntry {
 	files := req.GetWatchFiles()
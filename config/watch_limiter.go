@@ -0,0 +1,90 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+
+	lru "github.com/hashicorp/golang-lru"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"golang.org/x/time/rate"
+
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// WatchRateLimitConfig 客户端长轮询订阅的限流配置
+type WatchRateLimitConfig struct {
+	// Rate 每个客户端每秒允许发起的订阅请求数
+	Rate float64
+	// Burst 每个客户端允许的突发请求数
+	Burst int
+	// MaxClients 最多记录多少个客户端的限流器，超过后淘汰最久未访问的客户端
+	MaxClients int
+}
+
+// DefaultWatchRateLimitConfig 正常的客户端每 30s 发起一次长轮询，配置变更时会立即重新发起，
+// 因此只有客户端陷入重连风暴时才会触发限流
+var DefaultWatchRateLimitConfig = WatchRateLimitConfig{
+	Rate:       1,
+	Burst:      10,
+	MaxClients: 100000,
+}
+
+// watchRateLimiter 按照客户端维度的令牌桶限流器
+type watchRateLimiter struct {
+	cfg      WatchRateLimitConfig
+	limiters *lru.Cache
+}
+
+func newWatchRateLimiter(cfg WatchRateLimitConfig) *watchRateLimiter {
+	if cfg.MaxClients <= 0 {
+		cfg.MaxClients = DefaultWatchRateLimitConfig.MaxClients
+	}
+	limiters, _ := lru.New(cfg.MaxClients)
+	return &watchRateLimiter{
+		cfg:      cfg,
+		limiters: limiters,
+	}
+}
+
+// allow 判断客户端本次订阅请求是否被允许
+func (l *watchRateLimiter) allow(clientId string) bool {
+	if l == nil || clientId == "" {
+		return true
+	}
+	l.limiters.ContainsOrAdd(clientId, rate.NewLimiter(rate.Limit(l.cfg.Rate), l.cfg.Burst))
+	if value, ok := l.limiters.Get(clientId); ok {
+		return value.(*rate.Limiter).Allow()
+	}
+	return true
+}
+
+// parseWatchClientId 获取限流时用于标识客户端的 ID
+func parseWatchClientId(ctx context.Context, req *apiconfig.ClientWatchConfigFileRequest) string {
+	clientIP := req.GetClientIp().GetValue()
+	if clientIP == "" {
+		clientIP, _ = ctx.Value(utils.StringContext("client-ip")).(string)
+	}
+	if clientIP == "" {
+		clientIP = utils.ParseClientAddress(ctx)
+	}
+	if clientIP == "" {
+		return ""
+	}
+	return clientIP + "|" + req.GetServiceName().GetValue()
+}
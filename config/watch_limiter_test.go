@@ -0,0 +1,99 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/auth"
+	authmock "github.com/polarismesh/polaris/auth/mock"
+	"github.com/polarismesh/polaris/cache/mock"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+type testStrategyServer struct {
+	auth.StrategyServer
+	checker auth.AuthChecker
+}
+
+func (s *testStrategyServer) GetAuthChecker() auth.AuthChecker {
+	return s.checker
+}
+
+func Test_serverAuthability_LongPullWatchFileRateLimit(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// 配置已经有新版本，订阅请求会被立即响应
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 10),
+	}).AnyTimes()
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	checker := authmock.NewMockAuthChecker(ctrl)
+	checker.EXPECT().CheckClientPermission(gomock.Any()).Return(true, nil).AnyTimes()
+	strategyMgn := &testStrategyServer{checker: checker}
+
+	proxy := &serverAuthability{
+		targetServer: &Server{watchCenter: wc, fileCache: fileCache, groupCache: groupCache},
+		strategyMgn:  strategyMgn,
+		watchLimiter: newWatchRateLimiter(WatchRateLimitConfig{Rate: 0.1, Burst: 5}),
+	}
+
+	watch := func(clientIP string) uint32 {
+		callback, err := proxy.LongPullWatchFile(context.Background(), &apiconfig.ClientWatchConfigFileRequest{
+			ClientIp:   utils.NewStringValue(clientIP),
+			WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+		})
+		assert.NoError(t, err)
+		return callback().GetCode().GetValue()
+	}
+
+	allowed, limited := 0, 0
+	for i := 0; i < 50; i++ {
+		switch watch("127.0.0.1") {
+		case uint32(apimodel.Code_ExecuteSuccess):
+			allowed++
+		case uint32(apimodel.Code_IPRateLimit):
+			limited++
+		}
+	}
+	assert.Equal(t, 5, allowed)
+	assert.Equal(t, 45, limited)
+
+	// 其他客户端不受影响
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), watch("127.0.0.2"))
+}
+
+func Test_watchRateLimiter_NormalPolling(t *testing.T) {
+	limiter := newWatchRateLimiter(DefaultWatchRateLimitConfig)
+	// 配置变更后客户端会立即重新发起长轮询，这种少量的连续请求不能被限流
+	for i := 0; i < DefaultWatchRateLimitConfig.Burst; i++ {
+		assert.True(t, limiter.allow("127.0.0.1|svc"))
+	}
+	assert.False(t, limiter.allow("127.0.0.1|svc"))
+	assert.True(t, limiter.allow(""))
+}
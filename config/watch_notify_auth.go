@@ -0,0 +1,115 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+
+	"github.com/polarismesh/polaris/common/metrics"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// defaultNotifyAuthCacheTTL 通知时鉴权结果的缓存时间，权限被回收后最多延迟这么久才会生效
+	defaultNotifyAuthCacheTTL = 5 * time.Second
+	// defaultNotifyAuthCacheMaxKeys 最多缓存多少个客户端分组的鉴权结果
+	defaultNotifyAuthCacheMaxKeys = 100000
+)
+
+// notifyAuthEntry 一次鉴权的结果
+type notifyAuthEntry struct {
+	err      error
+	expireAt time.Time
+}
+
+// notifyAuthCache 短时间缓存通知时的鉴权结果，热点配置频繁发布时避免每次通知都走一遍完整的鉴权
+type notifyAuthCache struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	entries *lru.Cache
+}
+
+func newNotifyAuthCache(ttl time.Duration) *notifyAuthCache {
+	if ttl <= 0 {
+		ttl = defaultNotifyAuthCacheTTL
+	}
+	entries, _ := lru.New(defaultNotifyAuthCacheMaxKeys)
+	return &notifyAuthCache{
+		ttl:     ttl,
+		entries: entries,
+	}
+}
+
+// check 缓存中存在没有过期的鉴权结果时直接返回，否则执行一次鉴权并缓存结果
+func (c *notifyAuthCache) check(key string, authorize func() error) error {
+	c.lock.Lock()
+	if val, ok := c.entries.Get(key); ok {
+		entry := val.(*notifyAuthEntry)
+		if time.Now().Before(entry.expireAt) {
+			c.lock.Unlock()
+			return entry.err
+		}
+	}
+	c.lock.Unlock()
+
+	err := authorize()
+	c.lock.Lock()
+	c.entries.Add(key, &notifyAuthEntry{err: err, expireAt: time.Now().Add(c.ttl)})
+	c.lock.Unlock()
+	return err
+}
+
+// authorizeNotify 通知客户端之前重新校验客户端是否仍然有权限读取配置文件，使用订阅时的请求上下文进行鉴权
+//
+//	读操作在 CheckClientPermission 中凭据合法即放行，这里需要真正检查配置分组的资源权限；
+//	鉴权结果按照校验过凭据的操作者缓存，不能使用客户端自己声明的 ClientID
+func (s *serverAuthability) authorizeNotify(watchCtx WatchContext, event *model.SimpleConfigFileRelease) error {
+	checker := s.strategyMgn.GetAuthChecker()
+	if !checker.IsOpenClientAuth() {
+		return nil
+	}
+	req := &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{
+			{
+				Namespace: utils.NewStringValue(event.Namespace),
+				Group:     utils.NewStringValue(event.Group),
+				FileName:  utils.NewStringValue(event.FileName),
+			},
+		},
+	}
+	authCtx := s.collectClientWatchConfigFiles(watchRequestContext(watchCtx), req, model.Read, "NotifyWatchFile")
+	if err := checker.VerifyCredential(authCtx); err != nil {
+		return err
+	}
+	operator, _ := authCtx.GetAttachment(model.OperatorIDKey).(string)
+	delegation, _ := authCtx.GetAttachment(model.DelegationTokenKey).(string)
+	key := operator + "|" + delegation + "|" + event.Namespace + "|" + event.Group
+	return s.notifyAuthCache.check(key, func() error {
+		_, err := checker.CheckResourcePermission(authCtx)
+		metrics.ReportConfigClientAuth(authCtx.GetMethod(), err == nil)
+		if err != nil {
+			return s.wrapWatchPermissionError(req, err)
+		}
+		return nil
+	})
+}
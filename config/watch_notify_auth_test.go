@@ -0,0 +1,164 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	authmock "github.com/polarismesh/polaris/auth/mock"
+	"github.com/polarismesh/polaris/cache/mock"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_serverAuthability_NotifyPermissionRevoked(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	wc.inlineContentMaxLength = 1024
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	version := uint64(1)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(namespace, group, fileName string) *model.ConfigFileRelease {
+			return &model.ConfigFileRelease{
+				SimpleConfigFileRelease: newTestRelease(namespace, group, fileName, version),
+				Content:                 "secret",
+			}
+		}).AnyTimes()
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	revoked := false
+	checks := 0
+	checker := authmock.NewMockAuthChecker(ctrl)
+	checker.EXPECT().IsOpenClientAuth().Return(true).AnyTimes()
+	checker.EXPECT().VerifyCredential(gomock.Any()).DoAndReturn(func(authCtx *model.AcquireContext) error {
+		authCtx.SetAttachment(model.OperatorIDKey, utils.ParseAuthToken(authCtx.GetRequestContext()))
+		return nil
+	}).AnyTimes()
+	checker.EXPECT().CheckResourcePermission(gomock.Any()).DoAndReturn(func(authCtx *model.AcquireContext) (bool, error) {
+		checks++
+		assert.Equal(t, "NotifyWatchFile", authCtx.GetMethod())
+		if revoked {
+			return false, errors.New("no permission")
+		}
+		return true, nil
+	}).AnyTimes()
+
+	proxy := &serverAuthability{
+		targetServer:    &Server{watchCenter: wc, fileCache: fileCache, groupCache: groupCache},
+		strategyMgn:     &testStrategyServer{checker: checker},
+		notifyAuthCache: newNotifyAuthCache(50 * time.Millisecond),
+	}
+	wc.SetNotifyAuthorizer(proxy.authorizeNotify)
+
+	ctx := context.WithValue(context.Background(), utils.ContextAuthTokenKey, "token-1")
+	factory := wc.SelectWatchContextFactory(ctx, WatchProtocolStream)
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+	}, factory).(*StreamWatchContext)
+
+	// 有权限时正常下发配置内容，短时间内的多次通知只鉴权一次
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", version))
+	rsp := <-watchCtx.sendCh
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, "secret", rsp.GetConfigFile().GetContent().GetValue())
+	version = 2
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", version))
+	<-watchCtx.sendCh
+	assert.Equal(t, 1, checks)
+
+	// 权限被回收，缓存过期后的通知只告知鉴权失败，并且不再继续订阅该配置文件
+	revoked = true
+	time.Sleep(100 * time.Millisecond)
+	version = 3
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", version))
+	rsp = <-watchCtx.sendCh
+	assert.Equal(t, uint32(apimodel.Code_NotAllowedAccess), rsp.GetCode().GetValue())
+	assert.Equal(t, "file-1", rsp.GetConfigFile().GetFileName().GetValue())
+	assert.Empty(t, rsp.GetConfigFile().GetContent().GetValue())
+	assert.Empty(t, watchCtx.ListWatchFiles())
+
+	version = 4
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", version))
+	select {
+	case rsp := <-watchCtx.sendCh:
+		t.Fatalf("unexpected notify after permission revoked: %+v", rsp)
+	default:
+	}
+}
+
+func Test_serverAuthability_NotifyAuthCacheKeyByOperator(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(namespace, group, fileName string) *model.ConfigFileRelease {
+			return &model.ConfigFileRelease{SimpleConfigFileRelease: newTestRelease(namespace, group, fileName, 1)}
+		}).AnyTimes()
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	checker := authmock.NewMockAuthChecker(ctrl)
+	checker.EXPECT().IsOpenClientAuth().Return(true).AnyTimes()
+	checker.EXPECT().VerifyCredential(gomock.Any()).DoAndReturn(func(authCtx *model.AcquireContext) error {
+		authCtx.SetAttachment(model.OperatorIDKey, utils.ParseAuthToken(authCtx.GetRequestContext()))
+		return nil
+	}).AnyTimes()
+	// 只有 user-1 拥有配置分组的权限
+	checker.EXPECT().CheckResourcePermission(gomock.Any()).DoAndReturn(func(authCtx *model.AcquireContext) (bool, error) {
+		if authCtx.GetAttachment(model.OperatorIDKey) != "user-1" {
+			return false, errors.New("no permission")
+		}
+		return true, nil
+	}).AnyTimes()
+
+	proxy := &serverAuthability{
+		targetServer:    &Server{watchCenter: wc, fileCache: fileCache, groupCache: groupCache},
+		strategyMgn:     &testStrategyServer{checker: checker},
+		notifyAuthCache: newNotifyAuthCache(time.Minute),
+	}
+	wc.SetNotifyAuthorizer(proxy.authorizeNotify)
+
+	addWatcher := func(token string) *StreamWatchContext {
+		ctx := context.WithValue(context.Background(), utils.ContextAuthTokenKey, token)
+		factory := wc.SelectWatchContextFactory(ctx, WatchProtocolStream)
+		// 客户端声明相同的 ClientID，不能复用其他操作者的鉴权结果
+		return mustAddWatcher(t, wc, "same-client", []*apiconfig.ClientConfigFileInfo{
+			newTestWatchFile("default", "group", "file-1", 0),
+		}, factory).(*StreamWatchContext)
+	}
+
+	allowed := addWatcher("user-1")
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	rsp := <-allowed.sendCh
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	wc.RemoveWatcher("same-client", nil)
+
+	denied := addWatcher("user-2")
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	rsp = <-denied.sendCh
+	assert.Equal(t, uint32(apimodel.Code_NotAllowedAccess), rsp.GetCode().GetValue())
+}
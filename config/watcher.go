@@ -19,16 +19,26 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
 	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
 
 	cachetypes "github.com/polarismesh/polaris/cache/api"
 	api "github.com/polarismesh/polaris/common/api/v1"
 	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/metrics"
 	"github.com/polarismesh/polaris/common/model"
 	"github.com/polarismesh/polaris/common/utils"
 )
@@ -36,13 +46,44 @@ import (
 const (
 	defaultLongPollingTimeout = 30000 * time.Millisecond
 	QueueSize                 = 10240
+	// defaultCompactInterval 清理 watchers 索引中已经没有任何订阅者的文件的周期
+	defaultCompactInterval = time.Minute
+	// defaultExpireJitterRatio 长轮询超时时间默认的随机抖动比例
+	defaultExpireJitterRatio = 0.1
+	// defaultMaxWatchers 默认最多同时 hold 住的订阅客户端数量
+	defaultMaxWatchers = 100000
+	// defaultReleaseHistoryLimit 默认返回的最近发布记录数量
+	defaultReleaseHistoryLimit = 10
+	// maxWatchCallbackDelay 长轮询超时之后 WatchCallback 最多再等待的时间，超时清理任务每秒执行一次
+	maxWatchCallbackDelay = 2 * time.Second
+	// defaultNotifyFastPathMax 配置文件的订阅者不超过该数量时，一次性获取所有订阅上下文后再通知
+	defaultNotifyFastPathMax = 16
+	// defaultClientMessageMaxSize 默认返回给客户端的单个响应的最大字节数，与 gRPC 客户端默认的最大接收消息大小一致
+	defaultClientMessageMaxSize = 4 * 1024 * 1024
+)
+
+const (
+	// WatchProtocolLongPoll 长轮询订阅，未声明订阅能力的老版本客户端默认使用
+	WatchProtocolLongPoll = "long-poll"
+	// WatchProtocolStream 双向流订阅
+	WatchProtocolStream = "stream"
 )
 
 var (
+	// ErrTooManyWatchers 订阅客户端数量超过上限
+	ErrTooManyWatchers = errors.New("too many config watchers")
+	// ErrWatchProtocolMismatch 同一个客户端同时使用了不同的订阅协议
+	ErrWatchProtocolMismatch = errors.New("client watch protocol mismatch")
+	// ErrNotWatchedFile 客户端没有订阅该配置文件
+	ErrNotWatchedFile = errors.New("config file is not watched by client")
+	// ErrInvalidWatchFile 订阅的配置文件缺少命名空间或者分组
+	ErrInvalidWatchFile = errors.New("invalid watch config file")
+
 	notModifiedResponse = &apiconfig.ConfigClientResponse{
 		Code:       utils.NewUInt32Value(uint32(apimodel.Code_DataNoChange)),
 		ConfigFile: nil,
 	}
+	tooBusyResponse = api.NewConfigClientResponse0(apimodel.Code(api.ServiceTooBusy))
 )
 
 type (
@@ -50,6 +91,12 @@ type (
 
 	WatchContextFactory func(clientId string) WatchContext
 
+	// NotifyTransform 在通知客户端之前对响应做加工，入参是响应的副本，可以直接修改；返回 nil 时使用原始响应
+	NotifyTransform func(watchCtx WatchContext, rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse
+
+	// ClientIdExtractor 从请求中解析订阅者的客户端 ID，无法解析时返回空字符串
+	ClientIdExtractor func(ctx context.Context) string
+
 	WatchContext interface {
 		// ClientID .
 		ClientID() string
@@ -73,11 +120,32 @@ type (
 )
 
 type LongPollWatchContext struct {
+	watchActivity
 	clientId         string
 	once             sync.Once
 	finishTime       time.Time
 	finishChan       chan *apiconfig.ConfigClientResponse
 	watchConfigFiles map[string]*apiconfig.ClientConfigFileInfo
+	// closeCh 订阅上下文被废弃时关闭，释放还在等待通知的请求
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	// interestRemovedHook 取消对某个配置文件的订阅后的回调，由 watchCenter 设置用于同步清理 watchers 索引
+	interestRemovedHook func(item *apiconfig.ClientConfigFileInfo)
+}
+
+// interestRemovedNotifier 取消订阅时需要通知 watchCenter 的订阅上下文
+type interestRemovedNotifier interface {
+	setInterestRemovedHook(hook func(item *apiconfig.ClientConfigFileInfo))
+}
+
+// interestRevoker 服务端主动取消订阅时需要显式告知客户端的订阅上下文
+type interestRevoker interface {
+	revokeInterest(item *apiconfig.ClientConfigFileInfo)
+}
+
+// labeledWatchContext 携带了客户端标签的订阅上下文，用于按照标签定向通知
+type labeledWatchContext interface {
+	ClientLabels() map[string]string
 }
 
 // IsOnce
@@ -89,6 +157,18 @@ func (c *LongPollWatchContext) GetNotifieResult() *apiconfig.ConfigClientRespons
 	return <-c.finishChan
 }
 
+// GetNotifieResultWithContext 等待通知结果，ctx 结束时立即返回 ctx.Err()
+func (c *LongPollWatchContext) GetNotifieResultWithContext(ctx context.Context) (*apiconfig.ConfigClientResponse, error) {
+	select {
+	case ret := <-c.finishChan:
+		return ret, nil
+	case <-c.closeCh:
+		return notModifiedResponse, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (c *LongPollWatchContext) GetNotifieResultWithTime(timeout time.Duration) (*apiconfig.ConfigClientResponse, error) {
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
@@ -101,7 +181,12 @@ func (c *LongPollWatchContext) GetNotifieResultWithTime(timeout time.Duration) (
 }
 
 func (c *LongPollWatchContext) ShouldExpire(now time.Time) bool {
-	return true
+	return now.After(c.finishTime)
+}
+
+// ExpireTime .
+func (c *LongPollWatchContext) ExpireTime() time.Time {
+	return c.finishTime
 }
 
 // ClientID .
@@ -115,9 +200,26 @@ func (c *LongPollWatchContext) ShouldNotify(event *model.SimpleConfigFileRelease
 	if !ok {
 		return false
 	}
+	// 删除操作，直接通知
+	if !event.Valid {
+		return true
+	}
+	// 数据密钥轮转时版本不变，客户端持有的版本不比轮转的版本新就需要重新拉取
+	if event.DataKeyRotated {
+		return watchFile.GetVersion().GetValue() <= event.Version
+	}
+	// 回滚时版本变小，只要客户端持有的不是回滚后的版本就需要通知
+	if event.Rollback {
+		return watchFile.GetVersion().GetValue() != event.Version
+	}
 	return watchFile.GetVersion().GetValue() < event.Version
 }
 
+// ClientLabels .
+func (c *LongPollWatchContext) ClientLabels() map[string]string {
+	return collectClientLabels(c.ListWatchFiles())
+}
+
 func (c *LongPollWatchContext) ListWatchFiles() []*apiconfig.ClientConfigFileInfo {
 	ret := make([]*apiconfig.ClientConfigFileInfo, 0, len(c.watchConfigFiles))
 	for _, v := range c.watchConfigFiles {
@@ -130,30 +232,64 @@ func (c *LongPollWatchContext) ListWatchFiles() []*apiconfig.ClientConfigFileInf
 func (c *LongPollWatchContext) AppendInterest(item *apiconfig.ClientConfigFileInfo) {
 	key := model.BuildKeyForClientConfigFileInfo(item)
 	c.watchConfigFiles[key] = item
+	c.touch()
 }
 
 // RemoveInterest .
 func (c *LongPollWatchContext) RemoveInterest(item *apiconfig.ClientConfigFileInfo) {
 	key := model.BuildKeyForClientConfigFileInfo(item)
 	delete(c.watchConfigFiles, key)
+	if c.interestRemovedHook != nil {
+		c.interestRemovedHook(item)
+	}
+}
+
+func (c *LongPollWatchContext) setInterestRemovedHook(hook func(item *apiconfig.ClientConfigFileInfo)) {
+	c.interestRemovedHook = hook
 }
 
 // Close .
 func (c *LongPollWatchContext) Close() error {
+	c.closeOnce.Do(func() {
+		if c.closeCh != nil {
+			close(c.closeCh)
+		}
+	})
 	return nil
 }
 
 func (c *LongPollWatchContext) Reply(rsp *apiconfig.ConfigClientResponse) {
+	c.touch()
 	c.once.Do(func() {
+		// 发送结果时发生 panic 也要保证 finishChan 被关闭，否则等待通知的请求只能等到超时
+		defer func() {
+			if err := recover(); err != nil {
+				log.Error("[Config][Watcher] long poll watch context reply panic",
+					zap.String("client", c.clientId), zap.Any("error", err))
+			}
+			c.closeFinishChan()
+		}()
 		c.finishChan <- rsp
-		close(c.finishChan)
 	})
 }
 
+// closeFinishChan 关闭 finishChan，channel 已经被关闭或者没有初始化时只记录日志
+func (c *LongPollWatchContext) closeFinishChan() {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error("[Config][Watcher] long poll watch context close finish chan panic",
+				zap.String("client", c.clientId), zap.Any("error", err))
+		}
+	}()
+	close(c.finishChan)
+}
+
 // watchCenter 处理客户端订阅配置请求，监听配置文件发布事件通知客户端
 type watchCenter struct {
-	subCtx *eventhub.SubscribtionContext
-	lock   sync.Mutex
+	// subCtxs 订阅 watchTopics 中每个主题的上下文
+	subCtxs []*eventhub.SubscribtionContext
+	// lock 保护 watchers 索引的清理，AddWatcher 持有读锁，清理空索引时持有写锁
+	lock sync.RWMutex
 	// clientId -> watchContext
 	clients *utils.SyncMap[string, WatchContext]
 	// fileId -> []clientId
@@ -161,6 +297,58 @@ type watchCenter struct {
 	// fileCache
 	fileCache cachetypes.ConfigFileCache
 	cancel    context.CancelFunc
+	// compactInterval 清理空 watchers 索引的周期
+	compactInterval time.Duration
+	// transform 通知客户端前对响应的加工逻辑
+	transform atomic.Value
+	// notifyAuthorizer 通知客户端前重新鉴权的逻辑
+	notifyAuthorizer atomic.Value
+	// clientIdExtractor 解析订阅者客户端 ID 的逻辑
+	clientIdExtractor atomic.Value
+	// expireJitterRatio 长轮询超时时间的随机抖动比例，抖动上限为超时时间乘以该比例
+	expireJitterRatio float64
+	// releaseWaiter 等待配置发布事件被处理的请求
+	releaseWaiter *releaseWaiter
+	// maxWatchers 最多同时存在的订阅客户端数量，小于等于 0 时不做限制
+	maxWatchers int
+	// groupId -> 分组下已经发布的配置文件名称，只记录有客户端订阅的分组
+	groupFiles *utils.SyncMap[string, *utils.SyncSet[string]]
+	// fileExisted 判断没有发布的配置文件是否存在，为空时不做判断
+	fileExisted func(namespace, group, fileName string) bool
+	// notifyPool 异步通知客户端的协程池，为空时在 eventhub 的消费协程中同步通知
+	notifyPool *notifyPool
+	// inlineContentMaxLength 配置内容不超过该长度时直接在变更通知中携带配置内容，小于等于 0 时只通知元数据
+	inlineContentMaxLength int
+	// messageMaxSize 携带配置内容的变更通知超过该大小时只通知元数据
+	messageMaxSize int
+	// notifiedLock 保护 notifiedVersions
+	notifiedLock sync.Mutex
+	// fileId -> 最近一次通知的版本，版本更旧的发布事件不再通知，避免客户端最终停留在旧版本
+	notifiedVersions map[string]uint64
+	// notifyFastPathMax 订阅者不超过该数量时走批量获取订阅上下文的通知逻辑，小于等于 0 时不开启
+	notifyFastPathMax int
+	// notifyFanOutConcurrency 单个配置文件的订阅者较多时并发通知的协程数量，小于等于 1 时逐个通知
+	notifyFanOutConcurrency int
+	// expiryPolicies 订阅协议 -> 订阅上下文的过期策略，没有设置时使用订阅上下文自身的过期判断
+	expiryPolicies *utils.SyncMap[string, ExpiryPolicy]
+	// watchMemorySoftLimit 订阅上下文估算占用内存的软上限，超过后淘汰最久没有活跃的订阅，小于等于 0 时不做限制
+	watchMemorySoftLimit atomic.Int64
+	// notifyLogSampler 配置变更通知日志的采样器，为空时每一次通知都输出日志
+	notifyLogSampler *notifyLogSampler
+	// fileId -> 最近一次收到配置发布事件的时间，用于发现卡住的发布链路
+	lastPublishTimes *utils.SyncMap[string, time.Time]
+	// codeMappings 按照客户端版本转换订阅响应码的映射，为空时不做转换
+	codeMappings []NotifyCodeMapping
+	// persistence 客户端订阅关系的持久化，为空时不做持久化
+	persistence *watchPersistence
+	// sessionResumeWindow 客户端在该时间内重新订阅时沿用之前的会话 ID，小于等于 0 时每次订阅都使用新的会话 ID
+	sessionResumeWindow time.Duration
+	// clientId -> 客户端最近一次订阅的会话
+	sessions *utils.SyncMap[string, watchSessionRecord]
+	// fileId -> 设置了过期时间并且还没有过期的发布
+	releaseExpiries *utils.SyncMap[string, *model.SimpleConfigFileRelease]
+	// priorReleases 已经过期的发布需要回退到的发布
+	priorReleases *priorReleaseCache
 }
 
 // NewWatchCenter 创建一个客户端监听配置发布的处理中心
@@ -168,16 +356,32 @@ func NewWatchCenter(fileCache cachetypes.ConfigFileCache) (*watchCenter, error)
 	ctx, cancel := context.WithCancel(context.Background())
 
 	wc := &watchCenter{
-		clients:   utils.NewSyncMap[string, WatchContext](),
-		watchers:  utils.NewSyncMap[string, *utils.SyncSet[string]](),
-		fileCache: fileCache,
-		cancel:    cancel,
+		clients:           utils.NewSyncMap[string, WatchContext](),
+		watchers:          utils.NewSyncMap[string, *utils.SyncSet[string]](),
+		fileCache:         fileCache,
+		cancel:            cancel,
+		compactInterval:   defaultCompactInterval,
+		expireJitterRatio: defaultExpireJitterRatio,
+		releaseWaiter:     newReleaseWaiter(),
+		maxWatchers:       defaultMaxWatchers,
+		groupFiles:        utils.NewSyncMap[string, *utils.SyncSet[string]](),
+		notifiedVersions:  map[string]uint64{},
+		notifyFastPathMax: defaultNotifyFastPathMax,
+		messageMaxSize:    defaultClientMessageMaxSize,
+		expiryPolicies:    utils.NewSyncMap[string, ExpiryPolicy](),
+		lastPublishTimes:  utils.NewSyncMap[string, time.Time](),
+		sessions:          utils.NewSyncMap[string, watchSessionRecord](),
+		releaseExpiries:   utils.NewSyncMap[string, *model.SimpleConfigFileRelease](),
+		priorReleases:     newPriorReleaseCache(),
 	}
 
-	var err error
-	wc.subCtx, err = eventhub.Subscribe(eventhub.ConfigFilePublishTopic, wc, eventhub.WithQueueSize(QueueSize))
-	if err != nil {
-		return nil, err
+	for _, topic := range watchTopics {
+		subCtx, err := eventhub.Subscribe(topic, wc, eventhub.WithQueueSize(QueueSize))
+		if err != nil {
+			wc.cancelSubscriptions()
+			return nil, err
+		}
+		wc.subCtxs = append(wc.subCtxs, subCtx)
 	}
 	go wc.startHandleTimeoutRequestWorker(ctx)
 	return wc, nil
@@ -188,19 +392,57 @@ func (wc *watchCenter) PreProcess(_ context.Context, e any) any {
 	return e
 }
 
+// watchTopics 订阅中心监听的事件主题，每个主题的事件在 OnEvent 中按照事件类型分发
+var watchTopics = []string{
+	eventhub.ConfigFilePublishTopic,
+	eventhub.CacheNamespaceEventTopic,
+	eventhub.CacheConfigGroupEventTopic,
+}
+
 // OnEvent event process logic
 func (wc *watchCenter) OnEvent(ctx context.Context, arg any) error {
-	event, ok := arg.(*eventhub.PublishConfigFileEvent)
-	if !ok {
-		log.Warn("[Config][Watcher] receive invalid event type")
-		return nil
+	switch event := arg.(type) {
+	case *eventhub.PublishConfigFileEvent:
+		if wc.notifyPool != nil {
+			wc.notifyPool.submit(event.Message)
+			return nil
+		}
+		wc.handlePublishEvent(event.Message)
+	case *eventhub.CacheNamespaceEvent:
+		wc.handleNamespaceChange(event)
+	case *eventhub.CacheConfigGroupEvent:
+		wc.handleConfigGroupChange(event)
+	default:
+		// 未知的事件类型直接忽略，不能影响其他事件的处理
+		log.Warn("[Config][Watcher] receive unknown event type, ignore it", zap.String("type", fmt.Sprintf("%T", arg)))
 	}
-	wc.notifyToWatchers(event.Message)
 	return nil
 }
 
+func (wc *watchCenter) cancelSubscriptions() {
+	for _, subCtx := range wc.subCtxs {
+		subCtx.Cancel()
+	}
+}
+
+// startNotifyPool 开启异步通知，需要在订阅中心对外提供服务前调用
+func (wc *watchCenter) startNotifyPool(workers, queueSize int) {
+	if workers <= 0 {
+		return
+	}
+	wc.notifyPool = newNotifyPool(workers, queueSize, wc.handlePublishEvent)
+}
+
+func (wc *watchCenter) handlePublishEvent(release *model.SimpleConfigFileRelease) {
+	wc.trackReleaseExpiry(release)
+	wc.notifyToWatchers(release)
+	wc.notifyGroupWatchers(release)
+	wc.releaseWaiter.observe(release)
+}
+
 func (wc *watchCenter) checkQuickResponseClient(watchCtx WatchContext) *apiconfig.ConfigClientResponse {
-	watchFiles := watchCtx.ListWatchFiles()
+	// 多个文件同时有变更时只会响应一个，优先响应优先级高的文件
+	watchFiles := sortWatchFilesByPriority(watchCtx.ListWatchFiles())
 	if len(watchFiles) == 0 {
 		return api.NewConfigClientResponse0(apimodel.Code_InvalidWatchConfigFileFormat)
 	}
@@ -209,13 +451,19 @@ func (wc *watchCenter) checkQuickResponseClient(watchCtx WatchContext) *apiconfi
 		namespace := configFile.GetNamespace().GetValue()
 		group := configFile.GetGroup().GetValue()
 		fileName := configFile.GetFileName().GetValue()
-		if namespace == "" || group == "" || fileName == "" {
-			return api.NewConfigClientResponseWithInfo(apimodel.Code_BadRequest,
-				"namespace & group & fileName can not be empty")
+		if namespace == "" || group == "" {
+			return api.NewConfigClientResponseWithInfo(apimodel.Code_InvalidWatchConfigFileFormat,
+				"namespace & group can not be empty")
+		}
+		// 订阅整个配置分组时没有版本可以比较，只能等待分组下配置文件的新增或者删除
+		if fileName == "" {
+			continue
 		}
 		// 从缓存中获取最新的配置文件信息
-		if release := wc.fileCache.GetActiveRelease(namespace, group, fileName); release != nil {
-			if watchCtx.ShouldNotify(release.SimpleConfigFileRelease) {
+		release := effectiveRelease(wc.fileCache, wc.priorReleases, namespace, group, fileName, time.Now())
+		if release != nil {
+			simple := wc.markRollback(configFile, release.SimpleConfigFileRelease)
+			if watchCtx.ShouldNotify(simple) && matchClientSelector(watchCtx, simple) {
 				ret := &apiconfig.ClientConfigFileInfo{
 					Namespace: utils.NewStringValue(namespace),
 					Group:     utils.NewStringValue(group),
@@ -226,11 +474,52 @@ func (wc *watchCenter) checkQuickResponseClient(watchCtx WatchContext) *apiconfi
 				}
 				return api.NewConfigClientResponse(apimodel.Code_ExecuteSuccess, ret)
 			}
+			continue
+		}
+		// 配置文件还没有发布时需要继续等待发布，配置文件不存在时直接告知客户端，避免客户端订阅了错误的文件名后一直被 hold 住
+		if wc.fileExisted != nil && !wc.fileExisted(namespace, group, fileName) {
+			return api.NewConfigClientResponse(apimodel.Code_NotFoundResource, &apiconfig.ClientConfigFileInfo{
+				Namespace: utils.NewStringValue(namespace),
+				Group:     utils.NewStringValue(group),
+				FileName:  utils.NewStringValue(fileName),
+			})
 		}
 	}
 	return nil
 }
 
+// markRollback 缓存中生效的发布不带回滚标记，客户端持有的版本比生效的版本新、并且该版本确实发布过时，
+// 说明两次轮询之间发生了回滚，按照回滚处理，客户端持有的版本服务端还没有加载到时不做处理，避免通知旧版本
+func (wc *watchCenter) markRollback(watchFile *apiconfig.ClientConfigFileInfo,
+	release *model.SimpleConfigFileRelease) *model.SimpleConfigFileRelease {
+	clientVersion := watchFile.GetVersion().GetValue()
+	if release.Rollback || !release.Valid || clientVersion <= release.Version {
+		return release
+	}
+	if clientVersion > wc.fileCache.GetMaxReleaseVersion(release.Namespace, release.Group, release.FileName) {
+		return release
+	}
+	// 缓存中的数据是共享的，回滚标记只能打在副本上
+	copyRelease := *release
+	copyRelease.Rollback = true
+	return &copyRelease
+}
+
+// reconcileWatchContext 订阅关系建立之后再对比一次客户端持有的版本，首次检查之后、订阅注册之前发生的发布，
+// 以及服务端重启期间发生的发布都不会再投递给该订阅者，需要在这里补偿。客户端上报的版本是客户端最后一次收到的版本，
+// 不会因为服务端重启而丢失，只有客户端的版本落后时才通知，因此不会重复通知
+func (wc *watchCenter) reconcileWatchContext(watchCtx WatchContext) *apiconfig.ConfigClientResponse {
+	rsp := wc.checkQuickResponseClient(watchCtx)
+	if rsp == nil {
+		return nil
+	}
+	log.Info("[Config][Watcher] client missed config file publish when register, notify it.",
+		withWatchSession(watchFileLogFields(watchActionReconcile, watchCtx.ClientID(), rsp.GetConfigFile()), watchCtx)...)
+	// 长轮询只会响应一次，清理掉订阅上下文后，并发的发布事件通知不会再送达，客户端只会收到这里的响应
+	wc.removeWatchContext(watchCtx)
+	return withSessionTag(watchCtx, rsp)
+}
+
 // GetWatchContext .
 func (wc *watchCenter) GetWatchContext(clientId string) (WatchContext, bool) {
 	return wc.clients.Load(clientId)
@@ -241,40 +530,393 @@ func (wc *watchCenter) DelWatchContext(clientId string) (WatchContext, bool) {
 	return wc.clients.Delete(clientId)
 }
 
-// AddWatcher 新增订阅者
+// SelectWatchContextFactory 根据客户端声明支持的订阅协议以及当前入口支持的订阅协议，协商出 WatchContext 的实现，
+// 优先按照客户端声明的顺序选择，没有交集时使用入口支持的第一个协议
+func (wc *watchCenter) SelectWatchContextFactory(ctx context.Context, supported ...string) WatchContextFactory {
+	protocol := negotiateWatchProtocol(parseWatchProtocols(ctx), supported)
+	switch protocol {
+	case WatchProtocolStream:
+		return withWatchRequestContext(ctx, BuildStreamWatchCtx(defaultStreamSendQueueSize))
+	default:
+		watchTimeOut := defaultLongPollingTimeout
+		if timeoutVal, ok := ctx.Value(utils.WatchTimeoutCtx{}).(time.Duration); ok {
+			watchTimeOut = timeoutVal
+		}
+		jitter := time.Duration(float64(watchTimeOut) * wc.expireJitterRatio)
+		return withWatchRequestContext(ctx, BuildJitterTimeoutWatchCtx(watchTimeOut, jitter))
+	}
+}
+
+func negotiateWatchProtocol(clientProtocols, supported []string) string {
+	if len(supported) == 0 {
+		supported = []string{WatchProtocolLongPoll}
+	}
+	for _, protocol := range clientProtocols {
+		for _, item := range supported {
+			if protocol == item {
+				return protocol
+			}
+		}
+	}
+	return supported[0]
+}
+
+// parseWatchProtocols 解析客户端声明支持的订阅协议，多个协议使用逗号分隔
+func parseWatchProtocols(ctx context.Context) []string {
+	val, _ := ctx.Value(utils.ContextWatchProtocolKey).(string)
+	if val == "" {
+		if md, ok := ctx.Value(utils.ContextGrpcHeader).(metadata.MD); ok {
+			if vals := md.Get(utils.HeaderWatchProtocolKey); len(vals) > 0 {
+				val = vals[0]
+			}
+		}
+	}
+	if val == "" {
+		return []string{WatchProtocolLongPoll}
+	}
+	items := strings.Split(val, ",")
+	protocols := make([]string, 0, len(items))
+	for i := range items {
+		if item := strings.ToLower(strings.TrimSpace(items[i])); item != "" {
+			protocols = append(protocols, item)
+		}
+	}
+	return protocols
+}
+
+// SetClientIdExtractor 设置解析订阅者客户端 ID 的逻辑，传入 nil 时恢复为默认的解析逻辑
+func (wc *watchCenter) SetClientIdExtractor(extractor ClientIdExtractor) {
+	wc.clientIdExtractor.Store(extractor)
+}
+
+// ParseClientId 解析订阅者的客户端 ID，长轮询以及流式订阅使用同一套解析逻辑，保证同一个客户端对应同一个订阅上下文；
+// 解析不到时按照客户端地址随机生成
+func (wc *watchCenter) ParseClientId(ctx context.Context) string {
+	if clientId := wc.declaredClientId(ctx); clientId != "" {
+		return clientId
+	}
+	return utils.ParseClientAddress(ctx) + "@" + utils.NewUUID()[0:8]
+}
+
+// declaredClientId 获取客户端声明的 ID，没有声明时返回空字符串；客户端声明的 ID 不可信，使用鉴权后的操作者以及
+// 客户端 IP 进行区分，其他客户端声明了相同的 ID 时不会接管订阅上下文以及持久化的订阅关系
+func (wc *watchCenter) declaredClientId(ctx context.Context) string {
+	extractor, _ := wc.clientIdExtractor.Load().(ClientIdExtractor)
+	if extractor == nil {
+		extractor = parseDeclaredClientId
+	}
+	clientId := extractor(ctx)
+	if clientId == "" {
+		return ""
+	}
+	if host := parseClientHost(ctx); host != "" {
+		clientId = clientId + "@" + host
+	}
+	if principal := parseAuthPrincipal(ctx); principal != "" {
+		clientId = principal + "/" + clientId
+	}
+	return clientId
+}
+
+// parseClientHost 获取客户端的 IP，同一个客户端的长轮询每次可能使用不同的端口
+func parseClientHost(ctx context.Context) string {
+	address := utils.ParseClientAddress(ctx)
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
+
+// parseAuthPrincipal 获取请求鉴权后的操作者 ID，没有开启鉴权时返回空字符串
+func parseAuthPrincipal(ctx context.Context) string {
+	authCtx, _ := ctx.Value(utils.ContextAuthContextKey).(*model.AcquireContext)
+	if authCtx == nil {
+		return ""
+	}
+	principal, _ := authCtx.GetAttachment(model.OperatorIDKey).(string)
+	return principal
+}
+
+// parseDeclaredClientId 默认从 HTTP 请求头或者 gRPC metadata 中获取客户端声明的 ID
+func parseDeclaredClientId(ctx context.Context) string {
+	val, _ := ctx.Value(utils.ContextClientIdKey).(string)
+	if val == "" {
+		if md, ok := ctx.Value(utils.ContextGrpcHeader).(metadata.MD); ok {
+			if vals := md.Get(utils.HeaderClientIdKey); len(vals) > 0 {
+				val = vals[0]
+			}
+		}
+	}
+	return strings.TrimSpace(val)
+}
+
+// replaceWatcher 同一个客户端只保留一个订阅上下文，客户端重新发起订阅或者切换了订阅协议时，废弃旧的订阅上下文
+func replaceWatcher[T WatchContext](wc *watchCenter, clientId string,
+	watchFiles []*apiconfig.ClientConfigFileInfo, factory WatchContextFactory) (T, error) {
+	var empty T
+	if _, ok := wc.clients.Load(clientId); ok {
+		log.Info("[Config][Watcher] client watch again, replace watch context.", zap.String("client", clientId))
+		wc.removeAllWatcherWithLog(clientId)
+	}
+	watchCtx, err := wc.AddWatcher(clientId, watchFiles, factory)
+	if err != nil {
+		return empty, err
+	}
+	ret, ok := watchCtx.(T)
+	if !ok {
+		// 同一个客户端并发地使用不同的协议订阅
+		return empty, ErrWatchProtocolMismatch
+	}
+	return ret, nil
+}
+
+// removeWatchContext 只有订阅上下文没有被新的订阅替换时才删除订阅者
+func (wc *watchCenter) removeWatchContext(watchCtx WatchContext) {
+	if current, ok := wc.clients.Load(watchCtx.ClientID()); ok && current == watchCtx {
+		wc.removeAllWatcherWithLog(watchCtx.ClientID())
+	}
+}
+
+const (
+	watchActionAdd         = "add"
+	watchActionRemove      = "remove"
+	watchActionRemoveAll   = "remove-all"
+	watchActionUpdate      = "update"
+	watchActionReplace     = "replace"
+	watchActionReceive     = "receive"
+	watchActionNotify      = "notify"
+	watchActionForceNotify = "force-notify"
+	watchActionReconcile   = "reconcile"
+)
+
+// watchLogFields 构建订阅相关日志的通用字段，保证每一条日志都携带动作、客户端以及配置文件信息
+func watchLogFields(action, clientId, namespace, group, fileName string) []zap.Field {
+	return []zap.Field{
+		zap.String("action", action),
+		zap.String("clientId", clientId),
+		zap.String("namespace", namespace),
+		zap.String("group", group),
+		zap.String("fileName", fileName),
+	}
+}
+
+func watchFileLogFields(action, clientId string, file *apiconfig.ClientConfigFileInfo) []zap.Field {
+	return watchLogFields(action, clientId, file.GetNamespace().GetValue(), file.GetGroup().GetValue(),
+		file.GetFileName().GetValue())
+}
+
+// validateWatchFiles 订阅的配置文件必须指定命名空间以及分组，文件名为空时表示订阅整个分组
+func validateWatchFiles(watchFiles []*apiconfig.ClientConfigFileInfo) error {
+	for _, file := range watchFiles {
+		if file.GetNamespace().GetValue() == "" || file.GetGroup().GetValue() == "" {
+			return fmt.Errorf("%w: namespace & group can not be empty, namespace=%q group=%q fileName=%q",
+				ErrInvalidWatchFile, file.GetNamespace().GetValue(), file.GetGroup().GetValue(),
+				file.GetFileName().GetValue())
+		}
+	}
+	return nil
+}
+
+// AddWatcher 新增订阅者，订阅者数量超过上限时返回 ErrTooManyWatchers，订阅的配置文件不合法时返回 ErrInvalidWatchFile
 func (wc *watchCenter) AddWatcher(clientId string,
-	watchFiles []*apiconfig.ClientConfigFileInfo, factory WatchContextFactory) WatchContext {
+	watchFiles []*apiconfig.ClientConfigFileInfo, factory WatchContextFactory) (WatchContext, error) {
+	// 不合法的订阅会在 watchers 索引中留下空的 key，注册之前直接拒绝
+	if err := validateWatchFiles(watchFiles); err != nil {
+		log.Warn("[Config][Watcher] reject watcher, invalid watch file.", zap.String("client", clientId),
+			zap.Error(err))
+		return nil, err
+	}
+	// 大量客户端同时重连时，每个订阅者都会占用一个阻塞的协程，超过上限后直接拒绝新的订阅者，让客户端退避重试
+	if _, ok := wc.clients.Load(clientId); !ok && wc.maxWatchers > 0 && wc.clients.Len() >= wc.maxWatchers {
+		log.Warn("[Config][Watcher] reject watcher, too many watchers.", zap.String("client", clientId),
+			zap.Int("max", wc.maxWatchers))
+		return nil, ErrTooManyWatchers
+	}
 	watchCtx, _ := wc.clients.ComputeIfAbsent(clientId, func(k string) WatchContext {
-		return factory(clientId)
+		newCtx := factory(clientId)
+		if notifier, ok := newCtx.(interestRemovedNotifier); ok {
+			notifier.setInterestRemovedHook(func(item *apiconfig.ClientConfigFileInfo) {
+				wc.removeInterestIndex(newCtx, item)
+			})
+		}
+		wc.resumeWatchSession(clientId, newCtx)
+		return newCtx
 	})
 
+	wc.lock.RLock()
+	defer wc.lock.RUnlock()
+	for _, file := range watchFiles {
+		fileKey := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+
+		watchCtx.AppendInterest(file)
+		wc.trackGroupFiles(file)
+		clientIds, _ := wc.watchers.ComputeIfAbsent(fileKey, func(k string) *utils.SyncSet[string] {
+			return utils.NewSyncSet[string]()
+		})
+		clientIds.Add(clientId)
+		log.Debug("[Config][Watcher] add watcher.",
+			withWatchSession(watchFileLogFields(watchActionAdd, clientId, file), watchCtx)...)
+	}
+	return watchCtx, nil
+}
+
+// UpdateWatcher 将订阅者的订阅列表整体替换为 watchFiles，在同一把锁内计算差异并增量更新，
+// 保留的订阅不会被移除和重新添加，避免重新订阅的过程中漏掉配置变更通知，返回新增的订阅；
+// 订阅的配置文件不合法时返回 ErrInvalidWatchFile，原有的订阅保持不变
+func (wc *watchCenter) UpdateWatcher(clientId string,
+	watchFiles []*apiconfig.ClientConfigFileInfo) ([]*apiconfig.ClientConfigFileInfo, error) {
+	if err := validateWatchFiles(watchFiles); err != nil {
+		log.Warn("[Config][Watcher] reject update watcher, invalid watch file.", zap.String("client", clientId),
+			zap.Error(err))
+		return nil, err
+	}
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	watchCtx, ok := wc.clients.Load(clientId)
+	if !ok {
+		return nil, nil
+	}
+	// 客户端重新上报订阅列表也是一次活动，即使订阅列表没有变化
+	if tracker, ok := watchCtx.(activityWatchContext); ok {
+		tracker.touch()
+	}
+	exist := map[string]*apiconfig.ClientConfigFileInfo{}
+	for _, item := range watchCtx.ListWatchFiles() {
+		exist[model.BuildKeyForClientConfigFileInfo(item)] = item
+	}
+
+	added := make([]*apiconfig.ClientConfigFileInfo, 0, len(watchFiles))
 	for _, file := range watchFiles {
+		key := model.BuildKeyForClientConfigFileInfo(file)
+		if old, ok := exist[key]; ok {
+			delete(exist, key)
+			// 客户端调整了通知优先级或者持有更新的版本时更新保存的订阅信息，例如恢复的订阅被客户端上报的订阅列表覆盖
+			if watchFilePriority(old) != watchFilePriority(file) ||
+				file.GetVersion().GetValue() > old.GetVersion().GetValue() {
+				watchCtx.AppendInterest(file)
+			}
+			continue
+		}
 		fileKey := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+		// 先建立新的订阅关系，再取消不再关心的订阅
+		watchCtx.AppendInterest(file)
+		wc.trackGroupFiles(file)
+		clientIds, _ := wc.watchers.ComputeIfAbsent(fileKey, func(k string) *utils.SyncSet[string] {
+			return utils.NewSyncSet[string]()
+		})
+		clientIds.Add(clientId)
+		added = append(added, file)
+		log.Debug("[Config][Watcher] add watcher.",
+			withWatchSession(watchFileLogFields(watchActionUpdate, clientId, file), watchCtx)...)
+	}
+	// 剩下的就是不再订阅的文件
+	for _, file := range exist {
+		watchCtx.RemoveInterest(file)
+		fileKey := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+		if clientIds, ok := wc.watchers.Load(fileKey); ok {
+			clientIds.Remove(clientId)
+		}
+		log.Debug("[Config][Watcher] remove watcher.",
+			withWatchSession(watchFileLogFields(watchActionUpdate, clientId, file), watchCtx)...)
+	}
+	return added, nil
+}
 
+// ReplaceInterests 将订阅者的订阅列表整体替换为 watchFiles，订阅上下文本身保留。与 UpdateWatcher 不同，
+// 仍然保留的订阅也会使用 watchFiles 中的版本覆盖，订阅者不存在时返回 false，订阅的配置文件不合法时返回 ErrInvalidWatchFile
+func (wc *watchCenter) ReplaceInterests(clientId string, watchFiles []*apiconfig.ClientConfigFileInfo) (bool, error) {
+	if err := validateWatchFiles(watchFiles); err != nil {
+		log.Warn("[Config][Watcher] reject replace watcher, invalid watch file.", zap.String("client", clientId),
+			zap.Error(err))
+		return false, err
+	}
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	watchCtx, ok := wc.clients.Load(clientId)
+	if !ok {
+		return false, nil
+	}
+	// 先建立新的订阅关系，再取消不再关心的订阅，保证替换过程中不会漏掉仍然订阅的文件的变更
+	expect := make(map[string]struct{}, len(watchFiles))
+	for _, file := range watchFiles {
+		expect[model.BuildKeyForClientConfigFileInfo(file)] = struct{}{}
+		fileKey := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
 		watchCtx.AppendInterest(file)
+		wc.trackGroupFiles(file)
 		clientIds, _ := wc.watchers.ComputeIfAbsent(fileKey, func(k string) *utils.SyncSet[string] {
 			return utils.NewSyncSet[string]()
 		})
 		clientIds.Add(clientId)
+		log.Debug("[Config][Watcher] replace watcher.",
+			withWatchSession(watchFileLogFields(watchActionReplace, clientId, file), watchCtx)...)
+	}
+	for _, file := range watchCtx.ListWatchFiles() {
+		if _, ok := expect[model.BuildKeyForClientConfigFileInfo(file)]; ok {
+			continue
+		}
+		if revoker, ok := watchCtx.(interestRevoker); ok {
+			revoker.revokeInterest(file)
+		} else {
+			watchCtx.RemoveInterest(file)
+		}
+		fileKey := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+		if clientIds, ok := wc.watchers.Load(fileKey); ok {
+			clientIds.Remove(clientId)
+		}
+		log.Debug("[Config][Watcher] remove watcher.",
+			withWatchSession(watchFileLogFields(watchActionReplace, clientId, file), watchCtx)...)
+	}
+	return true, nil
+}
+
+// removeInterestIndex 订阅上下文直接取消对某个配置文件的订阅时，同步从 watchers 索引中移除该订阅者，
+// 订阅上下文已经被替换或者删除时不做处理，避免误删新的订阅上下文的索引
+func (wc *watchCenter) removeInterestIndex(watchCtx WatchContext, file *apiconfig.ClientConfigFileInfo) {
+	clientId := watchCtx.ClientID()
+	if current, ok := wc.clients.Load(clientId); !ok || current != watchCtx {
+		return
+	}
+	watchFileId := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+	if clientIds, ok := wc.watchers.Load(watchFileId); ok {
+		clientIds.Remove(clientId)
 	}
-	return watchCtx
 }
 
-// RemoveAllWatcher 删除订阅者
-func (wc *watchCenter) RemoveAllWatcher(clientId string) {
+// RemoveAllWatcher 删除订阅者，订阅上下文关闭失败时仍然会清理订阅关系，并将关闭的错误返回给调用方
+func (wc *watchCenter) RemoveAllWatcher(clientId string) error {
 	oldVal, exist := wc.clients.Delete(clientId)
 	if !exist {
-		return
+		return nil
 	}
-	_ = oldVal.Close()
+	var closeErr error
+	if err := oldVal.Close(); err != nil {
+		metrics.ReportConfigWatcherCleanupFailure()
+		closeErr = fmt.Errorf("close watch context of client %s: %w", clientId, err)
+	}
+	wc.touchWatchSession(clientId, oldVal)
 	for _, file := range oldVal.ListWatchFiles() {
+		log.Debug("[Config][Watcher] remove all watcher.",
+			withWatchSession(watchFileLogFields(watchActionRemoveAll, clientId, file), oldVal)...)
 		watchFileId := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
 		watchers, ok := wc.watchers.Load(watchFileId)
 		if !ok {
+			// 索引可能已经被配置删除或者定期整理清理掉了
+			log.Debug("[Config][Watcher] watchers index not found when remove all watcher.",
+				watchFileLogFields(watchActionRemoveAll, clientId, file)...)
 			continue
 		}
 		watchers.Remove(clientId)
 	}
+	return closeErr
+}
+
+// removeAllWatcherWithLog 删除订阅者，清理失败时只记录日志
+func (wc *watchCenter) removeAllWatcherWithLog(clientId string) {
+	if err := wc.RemoveAllWatcher(clientId); err != nil {
+		log.Error("[Config][Watcher] remove all watcher fail.", zap.String("client", clientId), zap.Error(err))
+	}
 }
 
 // RemoveWatcher 删除订阅者
@@ -297,58 +939,518 @@ func (wc *watchCenter) RemoveWatcher(clientId string, watchConfigFiles []*apicon
 	}
 }
 
+// AppendInterests 在订阅者现有的订阅列表上增加订阅，已经订阅的文件只在客户端调整了优先级或者持有更新的版本时更新，
+// 返回新增的订阅；订阅上下文已经被替换或者删除时不做处理
+func (wc *watchCenter) AppendInterests(watchCtx WatchContext,
+	watchFiles []*apiconfig.ClientConfigFileInfo) ([]*apiconfig.ClientConfigFileInfo, error) {
+	if err := validateWatchFiles(watchFiles); err != nil {
+		return nil, err
+	}
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	clientId := watchCtx.ClientID()
+	if current, ok := wc.clients.Load(clientId); !ok || current != watchCtx {
+		return nil, nil
+	}
+	if tracker, ok := watchCtx.(activityWatchContext); ok {
+		tracker.touch()
+	}
+	exist := map[string]*apiconfig.ClientConfigFileInfo{}
+	for _, item := range watchCtx.ListWatchFiles() {
+		exist[model.BuildKeyForClientConfigFileInfo(item)] = item
+	}
+	added := make([]*apiconfig.ClientConfigFileInfo, 0, len(watchFiles))
+	for _, file := range watchFiles {
+		if old, ok := exist[model.BuildKeyForClientConfigFileInfo(file)]; ok {
+			if watchFilePriority(old) != watchFilePriority(file) ||
+				file.GetVersion().GetValue() > old.GetVersion().GetValue() {
+				watchCtx.AppendInterest(file)
+			}
+			continue
+		}
+		fileKey := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+		watchCtx.AppendInterest(file)
+		wc.trackGroupFiles(file)
+		clientIds, _ := wc.watchers.ComputeIfAbsent(fileKey, func(k string) *utils.SyncSet[string] {
+			return utils.NewSyncSet[string]()
+		})
+		clientIds.Add(clientId)
+		added = append(added, file)
+		log.Debug("[Config][Watcher] add watcher.",
+			withWatchSession(watchFileLogFields(watchActionAdd, clientId, file), watchCtx)...)
+	}
+	return added, nil
+}
+
+// RemoveInterests 取消订阅者对部分配置文件的订阅，订阅者自身仍然保留
+func (wc *watchCenter) RemoveInterests(clientId string, watchConfigFiles []*apiconfig.ClientConfigFileInfo) {
+	if len(watchConfigFiles) == 0 {
+		return
+	}
+	watchCtx, exist := wc.clients.Load(clientId)
+	for _, file := range watchConfigFiles {
+		if exist {
+			watchCtx.RemoveInterest(file)
+		}
+		log.Debug("[Config][Watcher] remove watcher.",
+			withWatchSession(watchFileLogFields(watchActionRemove, clientId, file), watchCtx)...)
+		watchFileId := utils.GenFileId(file.Namespace.GetValue(), file.Group.GetValue(), file.FileName.GetValue())
+		watchers, ok := wc.watchers.Load(watchFileId)
+		if !ok {
+			continue
+		}
+		watchers.Remove(clientId)
+	}
+}
+
+// SetNotifyTransform 设置通知客户端前对响应的加工逻辑，传入 nil 时取消加工
+func (wc *watchCenter) SetNotifyTransform(transform NotifyTransform) {
+	wc.transform.Store(transform)
+}
+
+func (wc *watchCenter) transformResponse(watchCtx WatchContext,
+	rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+	transform, _ := wc.transform.Load().(NotifyTransform)
+	if transform == nil {
+		return rsp
+	}
+	// 响应在多个客户端之间共享，加工时只能基于副本进行
+	if ret := transform(watchCtx, proto.Clone(rsp).(*apiconfig.ConfigClientResponse)); ret != nil {
+		return ret
+	}
+	return rsp
+}
+
+// withPreviousVersion 在变更通知中携带客户端变更前持有的版本，便于客户端做审计以及回滚展示
+func withPreviousVersion(watchCtx WatchContext, event *model.SimpleConfigFileRelease,
+	rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+	key := event.ActiveKey()
+	for _, watchFile := range watchCtx.ListWatchFiles() {
+		if model.BuildKeyForClientConfigFileInfo(watchFile) != key {
+			continue
+		}
+		// 响应在多个客户端之间共享，每个客户端持有的版本不同，只能基于副本进行修改
+		ret := proto.Clone(rsp).(*apiconfig.ConfigClientResponse)
+		ret.ConfigFile.Tags = append(ret.ConfigFile.Tags, &apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyPreviousVersion),
+			Value: utils.NewStringValue(strconv.FormatUint(watchFile.GetVersion().GetValue(), 10)),
+		})
+		return ret
+	}
+	return rsp
+}
+
 func (wc *watchCenter) notifyToWatchers(publishConfigFile *model.SimpleConfigFileRelease) {
+	wc.recordPublishTime(publishConfigFile)
+	wc.doNotifyToWatchers(publishConfigFile, false)
+}
+
+// recordPublishTime 记录配置文件最近一次发布的时间，配置文件被删除后不再记录
+func (wc *watchCenter) recordPublishTime(publishConfigFile *model.SimpleConfigFileRelease) {
+	fileId := utils.GenFileId(publishConfigFile.Namespace, publishConfigFile.Group, publishConfigFile.FileName)
+	if !publishConfigFile.Valid {
+		wc.lastPublishTimes.Delete(fileId)
+		return
+	}
+	wc.lastPublishTimes.Store(fileId, time.Now())
+}
+
+// GetLastPublishTime 查询配置文件最近一次收到发布事件的时间，服务端启动之后没有发布过时返回 false
+func (wc *watchCenter) GetLastPublishTime(namespace, group, fileName string) (time.Time, bool) {
+	return wc.lastPublishTimes.Load(utils.GenFileId(namespace, group, fileName))
+}
+
+// ForceNotify 使用当前生效的配置发布强制通知所有订阅该配置文件的客户端，不比较客户端持有的版本，
+// 用于缓存不一致等异常场景下让客户端重新拉取配置；没有生效中的发布时返回 false
+func (wc *watchCenter) ForceNotify(namespace, group, fileName string) bool {
+	release := effectiveRelease(wc.fileCache, wc.priorReleases, namespace, group, fileName, time.Now())
+	if release == nil {
+		log.Warn("[Config][Watcher] force notify but not found active release.",
+			watchLogFields(watchActionForceNotify, "", namespace, group, fileName)...)
+		return false
+	}
+	wc.doNotifyToWatchers(release.SimpleConfigFileRelease, true)
+	return true
+}
+
+// PreviewNotify 预估当前生效的发布会通知到的客户端数量以及客户端 ID，和实际通知一样需要匹配客户端标签选择器、
+// 客户端持有的版本以及读取权限，但不会发送任何通知，用于发布之前评估影响范围
+func (wc *watchCenter) PreviewNotify(namespace, group, fileName string) (int, []string) {
+	release := effectiveRelease(wc.fileCache, wc.priorReleases, namespace, group, fileName, time.Now())
+	if release == nil {
+		return 0, nil
+	}
+	clientIds, ok := wc.watchers.Load(utils.GenFileId(namespace, group, fileName))
+	if !ok {
+		return 0, nil
+	}
+	ids := clientIds.ToSlice()
+	watchCtxs, founds := wc.clients.LoadBatch(ids)
+	ret := make([]string, 0, len(ids))
+	for i := range ids {
+		if !founds[i] {
+			continue
+		}
+		watchCtx := watchCtxs[i]
+		if !matchClientSelector(watchCtx, release.SimpleConfigFileRelease) ||
+			!watchCtx.ShouldNotify(release.SimpleConfigFileRelease) {
+			continue
+		}
+		// 没有读取权限的客户端只会收到鉴权失败的响应，不会收到配置变更
+		if err := wc.authorizeNotify(watchCtx, release.SimpleConfigFileRelease); err != nil {
+			continue
+		}
+		ret = append(ret, ids[i])
+	}
+	sort.Strings(ret)
+	return len(ret), ret
+}
+
+// GetActiveReleaseHistory 查询客户端已经订阅的配置文件最近的发布记录，按照版本号从新到旧排序；
+// 客户端订阅时已经完成鉴权，因此只允许查询已经订阅的配置文件
+func (wc *watchCenter) GetActiveReleaseHistory(clientId string, file *apiconfig.ClientConfigFileInfo,
+	limit uint32) ([]*model.SimpleConfigFileRelease, error) {
+	watchCtx, ok := wc.clients.Load(clientId)
+	if !ok {
+		return nil, ErrNotWatchedFile
+	}
+	fileKey := model.BuildKeyForClientConfigFileInfo(file)
+	watched := false
+	for _, item := range watchCtx.ListWatchFiles() {
+		if model.BuildKeyForClientConfigFileInfo(item) == fileKey {
+			watched = true
+			break
+		}
+	}
+	if !watched {
+		return nil, ErrNotWatchedFile
+	}
+	if limit == 0 {
+		limit = defaultReleaseHistoryLimit
+	}
+
+	_, releases, err := wc.fileCache.QueryReleases(&cachetypes.ConfigReleaseArgs{
+		BaseConfigArgs: cachetypes.BaseConfigArgs{
+			Namespace: file.GetNamespace().GetValue(),
+			Group:     file.GetGroup().GetValue(),
+		},
+		FileName: file.GetFileName().GetValue(),
+		NoPage:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*model.SimpleConfigFileRelease, 0, len(releases))
+	for _, item := range releases {
+		// 缓存按照通配符匹配，这里只保留当前文件的发布记录
+		if item.Namespace != file.GetNamespace().GetValue() || item.Group != file.GetGroup().GetValue() ||
+			item.FileName != file.GetFileName().GetValue() {
+			continue
+		}
+		ret = append(ret, item)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Version > ret[j].Version
+	})
+	if uint32(len(ret)) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (wc *watchCenter) doNotifyToWatchers(publishConfigFile *model.SimpleConfigFileRelease, force bool) {
 	watchFileId := utils.GenFileId(publishConfigFile.Namespace, publishConfigFile.Group, publishConfigFile.FileName)
 	clientIds, ok := wc.watchers.Load(watchFileId)
 	if !ok {
 		return
 	}
 
-	log.Info("[Config][Watcher] received config file publish message.", zap.String("file", watchFileId))
+	action := watchActionReceive
+	if force {
+		action = watchActionForceNotify
+	}
+	// 热点配置文件频繁发布时对日志做采样，避免日志刷屏
+	if wc.notifyLogSampler.allow(action + "|" + watchFileId) {
+		log.Info("[Config][Watcher] received config file publish message.",
+			watchLogFields(action, "", publishConfigFile.Namespace, publishConfigFile.Group,
+				publishConfigFile.FileName)...)
+	}
+	if !wc.acceptNotifyVersion(watchFileId, publishConfigFile, force) {
+		log.Info("[Config][Watcher] drop stale config file publish message.",
+			append(watchLogFields(action, "", publishConfigFile.Namespace, publishConfigFile.Group,
+				publishConfigFile.FileName), zap.Uint64("version", publishConfigFile.Version))...)
+		return
+	}
 
-	changeNotifyRequest := publishConfigFile.ToSpecNotifyClientRequest()
-	response := api.NewConfigClientResponse(apimodel.Code_ExecuteSuccess, changeNotifyRequest)
+	changeNotifyRequest := wc.buildNotifyFileInfo(publishConfigFile)
+	code := apimodel.Code_ExecuteSuccess
+	if !publishConfigFile.Valid {
+		// 配置文件被删除，通知客户端 Not_Found
+		code = apimodel.Code_NotFoundResource
+		defer wc.cleanDeletedFileWatchers(watchFileId)
+	}
+	response := api.NewConfigClientResponse(code, changeNotifyRequest)
 
-	clientIds.Range(func(clientId string) {
-		watchCtx, ok := wc.clients.Load(clientId)
+	notify := func(clientId string, watchCtx WatchContext, ok bool) {
 		if !ok {
-			log.Info("[Config][Watcher] not found client when do notify.", zap.String("clientId", clientId),
-				zap.String("file", watchFileId))
+			log.Info("[Config][Watcher] not found client when do notify.",
+				watchLogFields(watchActionNotify, clientId, publishConfigFile.Namespace, publishConfigFile.Group,
+					publishConfigFile.FileName)...)
 			clientIds.Remove(clientId)
 			return
 		}
+		// 发布只针对部分客户端时，不匹配的客户端继续等待下一次变更
+		if !matchClientSelector(watchCtx, publishConfigFile) {
+			return
+		}
 
-		if watchCtx.ShouldNotify(publishConfigFile) {
-			watchCtx.Reply(response)
+		if force || watchCtx.ShouldNotify(publishConfigFile) {
+			// 客户端在订阅之后可能已经失去了读取权限，此时只通知鉴权失败，不再下发配置
+			if err := wc.authorizeNotify(watchCtx, publishConfigFile); err != nil {
+				wc.replyNotifyDenied(watchCtx, publishConfigFile, err)
+			} else {
+				if wc.notifyLogSampler.allow(watchActionNotify + "|" + watchFileId) {
+					log.Info("[Config][Watcher] notify client config file changed.",
+						append(withWatchSession(watchLogFields(watchActionNotify, clientId, publishConfigFile.Namespace,
+							publishConfigFile.Group, publishConfigFile.FileName), watchCtx),
+							zap.Uint64("version", publishConfigFile.Version), zap.Uint32("code", uint32(code)))...)
+				}
+				rsp := withPreviousVersion(watchCtx, publishConfigFile, response)
+				rsp = wc.withContentDiff(watchCtx, publishConfigFile, rsp)
+				safeReply(watchCtx, wc.transformResponse(watchCtx, rsp))
+			}
 		}
-		// 只能用一次，通知完就要立马清理掉这个 WatchContext
+		// 只能用一次，通知完就要立马清理掉这个 WatchContext，订阅上下文已经被新的订阅替换时不能误删
 		if watchCtx.IsOnce() {
-			wc.clients.Delete(clientId)
-			wc.RemoveAllWatcher(watchCtx.ClientID())
+			wc.removeWatchContext(watchCtx)
+		}
+	}
+
+	// 热点文件的订阅者较少时，一次性获取订阅者列表以及订阅上下文，避免逐个查询 clients 带来的锁竞争
+	if clientIds.Len() <= wc.notifyFastPathMax {
+		ids := clientIds.ToSlice()
+		watchCtxs, founds := wc.clients.LoadBatch(ids)
+		for i := range ids {
+			notify(ids[i], watchCtxs[i], founds[i])
+		}
+		return
+	}
+	if wc.notifyFanOutConcurrency > 1 {
+		wc.fanOutNotify(clientIds, func(clientId string) {
+			watchCtx, ok := wc.clients.Load(clientId)
+			notify(clientId, watchCtx, ok)
+		})
+		return
+	}
+	clientIds.Range(func(clientId string) {
+		watchCtx, ok := wc.clients.Load(clientId)
+		notify(clientId, watchCtx, ok)
+	})
+}
+
+// fanOutNotify 并发通知所有订阅者，同时运行的协程数量不超过 notifyFanOutConcurrency，
+// 等待所有订阅者通知完成后才返回，保证同一个配置文件的多次发布按照顺序通知
+func (wc *watchCenter) fanOutNotify(clientIds *utils.SyncSet[string], notify func(clientId string)) {
+	sem := make(chan struct{}, wc.notifyFanOutConcurrency)
+	wg := &sync.WaitGroup{}
+	clientIds.Range(func(clientId string) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			notify(clientId)
+		}()
+	})
+	wg.Wait()
+}
+
+// cleanDeletedFileWatchers 配置文件被删除并通知完订阅者后清理 watchers 索引，长轮询的订阅者通知后就被清理，
+// 流式订阅的客户端仍然保留对该文件的订阅，等待配置文件重新创建，因此只有没有订阅者时才删除索引
+func (wc *watchCenter) cleanDeletedFileWatchers(watchFileId string) {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+	clientIds, ok := wc.watchers.Load(watchFileId)
+	if !ok {
+		return
+	}
+	for _, clientId := range clientIds.ToSlice() {
+		if _, ok := wc.clients.Load(clientId); !ok {
+			clientIds.Remove(clientId)
+		}
+	}
+	if clientIds.Len() == 0 {
+		wc.watchers.Delete(watchFileId)
+	}
+}
+
+// collectClientLabels 客户端通过订阅文件的 tags 上报自身的标签，系统内部使用的 tag 不作为客户端标签
+func collectClientLabels(watchFiles []*apiconfig.ClientConfigFileInfo) map[string]string {
+	labels := map[string]string{}
+	for _, file := range watchFiles {
+		for _, tag := range file.GetTags() {
+			key := tag.GetKey().GetValue()
+			if key == "" || strings.HasPrefix(key, utils.ConfigFileTagKeyInternalPrefix) {
+				continue
+			}
+			labels[key] = tag.GetValue().GetValue()
+		}
+	}
+	return labels
+}
+
+// matchClientSelector 发布指定了客户端标签选择器时，只有标签全部匹配的客户端才需要通知；
+// 配置文件被删除时通知所有的客户端
+func matchClientSelector(watchCtx WatchContext, event *model.SimpleConfigFileRelease) bool {
+	selector := event.Metadata[utils.ConfigFileTagKeyClientSelector]
+	if selector == "" || !event.Valid {
+		return true
+	}
+	labeled, ok := watchCtx.(labeledWatchContext)
+	if !ok {
+		return false
+	}
+	labels := labeled.ClientLabels()
+	for _, item := range strings.Split(selector, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(item, "=")
+		if val, ok := labels[strings.TrimSpace(key)]; !ok || val != strings.TrimSpace(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// safeReply 通知单个客户端，某个客户端通知时发生 panic 不能影响其他客户端的通知以及订阅关系的清理
+func safeReply(watchCtx WatchContext, rsp *apiconfig.ConfigClientResponse) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Error("[Config][Watcher] reply watch context panic",
+				zap.String("client", watchCtx.ClientID()), zap.Any("error", err))
+		}
+	}()
+	watchCtx.Reply(withSessionTag(watchCtx, rsp))
+}
+
+// acceptNotifyVersion 同一个配置文件只通知不比上一次通知更旧的版本，乱序到达的旧版本直接丢弃；强制通知不做判断。
+// 配置文件被删除后重新创建时版本会重新计数，因此删除时清理记录的版本
+func (wc *watchCenter) acceptNotifyVersion(fileId string, event *model.SimpleConfigFileRelease, force bool) bool {
+	wc.notifiedLock.Lock()
+	defer wc.notifiedLock.Unlock()
+	// 回滚的版本比已经通知过的版本旧，但不是过期的消息
+	if last, ok := wc.notifiedVersions[fileId]; ok && !force && !event.Rollback && event.Version < last {
+		return false
+	}
+	if !event.Valid {
+		delete(wc.notifiedVersions, fileId)
+		return true
+	}
+	wc.notifiedVersions[fileId] = event.Version
+	return true
+}
+
+// buildNotifyFileInfo 构建变更通知的配置文件信息，小文件直接携带配置内容，省去客户端再拉取一次配置；
+// 加密的配置需要使用每个客户端各自的公钥加密数据密钥，因此只通知元数据
+func (wc *watchCenter) buildNotifyFileInfo(event *model.SimpleConfigFileRelease) *apiconfig.ClientConfigFileInfo {
+	notifyInfo := event.ToSpecNotifyClientRequest()
+	if wc.inlineContentMaxLength <= 0 || !event.Valid {
+		return notifyInfo
+	}
+	release := effectiveRelease(wc.fileCache, wc.priorReleases, event.Namespace, event.Group, event.FileName,
+		time.Now())
+	if release == nil || release.Version != event.Version || release.IsEncrypted() ||
+		len(release.Content) > wc.inlineContentMaxLength {
+		return notifyInfo
+	}
+	fileInfo, err := toClientInfo(notifyInfo, release)
+	if err != nil {
+		log.Error("[Config][Watcher] build notify config file content.", append(watchLogFields(watchActionNotify, "",
+			event.Namespace, event.Group, event.FileName), zap.Error(err))...)
+		return notifyInfo
+	}
+	fileInfo.Name = notifyInfo.Name
+	if wc.messageMaxSize > 0 && estimateClientInfoSize(fileInfo) > wc.messageMaxSize {
+		return notifyInfo
+	}
+	return fileInfo
+}
+
+// compactWatchers 清理 watchers 索引中已经没有任何订阅者的文件，避免长时间运行后索引无限增长
+func (wc *watchCenter) compactWatchers() {
+	wc.lock.Lock()
+	defer wc.lock.Unlock()
+
+	waitRemove := make([]string, 0, 32)
+	wc.watchers.ReadRange(func(fileKey string, clientIds *utils.SyncSet[string]) {
+		if clientIds.Len() == 0 {
+			waitRemove = append(waitRemove, fileKey)
 		}
 	})
+	wc.notifiedLock.Lock()
+	for i := range waitRemove {
+		wc.watchers.Delete(waitRemove[i])
+		// 分组已经没有订阅者，不再需要记录分组下的配置文件
+		wc.groupFiles.Delete(waitRemove[i])
+		delete(wc.notifiedVersions, waitRemove[i])
+	}
+	wc.notifiedLock.Unlock()
+	if len(waitRemove) > 0 {
+		log.Info("[Config][Watcher] compact empty watchers index", zap.Int("count", len(waitRemove)))
+	}
 }
 
 func (wc *watchCenter) Close() {
 	wc.cancel()
-	wc.subCtx.Cancel()
+	// 写入最后一次变化的订阅关系
+	if wc.persistence != nil {
+		wc.persistence.close()
+	}
+	// 先关闭通知协程池，避免 eventhub 的消费协程阻塞在提交事件上
+	if wc.notifyPool != nil {
+		wc.notifyPool.close()
+	}
+	wc.cancelSubscriptions()
+	// 释放所有还在等待通知的订阅者，汇总清理失败的订阅者
+	var errs []error
+	for _, watchCtx := range wc.clients.Values() {
+		if err := wc.RemoveAllWatcher(watchCtx.ClientID()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		log.Error("[Config][Watcher] remove watchers fail when close watch center.", zap.Int("count", len(errs)),
+			zap.Error(errors.Join(errs...)))
+	}
 }
 
 func (wc *watchCenter) startHandleTimeoutRequestWorker(ctx context.Context) {
 	t := time.NewTicker(time.Second)
 	defer t.Stop()
+	lastCompact := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-t.C:
+			if time.Since(lastCompact) >= wc.compactInterval {
+				wc.compactWatchers()
+				wc.cleanExpiredSessions(time.Now())
+				lastCompact = time.Now()
+			}
+			wc.handleExpiredReleases(time.Now())
 			if wc.clients.Len() == 0 {
 				continue
 			}
 			tNow := time.Now()
 			waitRemove := make([]WatchContext, 0, 32)
 			wc.clients.Range(func(client string, watchCtx WatchContext) {
-				if !watchCtx.ShouldExpire(tNow) {
+				if !wc.shouldExpire(watchCtx, tNow) {
 					return
 				}
 				waitRemove = append(waitRemove, watchCtx)
@@ -356,9 +1458,10 @@ func (wc *watchCenter) startHandleTimeoutRequestWorker(ctx context.Context) {
 
 			for i := range waitRemove {
 				watchCtx := waitRemove[i]
-				watchCtx.Reply(notModifiedResponse)
-				wc.RemoveAllWatcher(watchCtx.ClientID())
+				safeReply(watchCtx, notModifiedResponse)
+				wc.removeAllWatcherWithLog(watchCtx.ClientID())
 			}
+			wc.shedWatchMemory()
 		}
 	}
 }
@@ -0,0 +1,89 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"go.uber.org/zap"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// NotifyAuthorizer 通知客户端之前校验客户端是否仍然有权限读取该配置文件，返回 error 时不再下发配置
+type NotifyAuthorizer func(watchCtx WatchContext, event *model.SimpleConfigFileRelease) error
+
+// requestContextWatchContext 记录了发起订阅的请求上下文的订阅上下文
+type requestContextWatchContext interface {
+	RequestContext() context.Context
+	setRequestContext(ctx context.Context)
+}
+
+// withWatchRequestContext 创建订阅上下文时记录发起订阅的请求上下文
+func withWatchRequestContext(ctx context.Context, factory WatchContextFactory) WatchContextFactory {
+	return func(clientId string) WatchContext {
+		watchCtx := factory(clientId)
+		if holder, ok := watchCtx.(requestContextWatchContext); ok {
+			holder.setRequestContext(ctx)
+		}
+		return watchCtx
+	}
+}
+
+// watchRequestContext 获取订阅上下文记录的请求上下文
+func watchRequestContext(watchCtx WatchContext) context.Context {
+	if holder, ok := watchCtx.(requestContextWatchContext); ok {
+		return holder.RequestContext()
+	}
+	return context.Background()
+}
+
+// SetNotifyAuthorizer 设置通知客户端前的鉴权逻辑，传入 nil 时不做鉴权
+func (wc *watchCenter) SetNotifyAuthorizer(authorizer NotifyAuthorizer) {
+	wc.notifyAuthorizer.Store(authorizer)
+}
+
+func (wc *watchCenter) authorizeNotify(watchCtx WatchContext, event *model.SimpleConfigFileRelease) error {
+	authorizer, _ := wc.notifyAuthorizer.Load().(NotifyAuthorizer)
+	if authorizer == nil {
+		return nil
+	}
+	return authorizer(watchCtx, event)
+}
+
+// replyNotifyDenied 通知客户端已经没有权限读取该配置文件，并取消客户端对该配置文件的订阅
+func (wc *watchCenter) replyNotifyDenied(watchCtx WatchContext, event *model.SimpleConfigFileRelease, err error) {
+	clientId := watchCtx.ClientID()
+	log.Warn("[Config][Watcher] client no permission to read config file when notify.",
+		append(withWatchSession(watchLogFields(watchActionNotify, clientId, event.Namespace, event.Group,
+			event.FileName), watchCtx), zap.Error(err))...)
+	file := &apiconfig.ClientConfigFileInfo{
+		Namespace: utils.NewStringValue(event.Namespace),
+		Group:     utils.NewStringValue(event.Group),
+		FileName:  utils.NewStringValue(event.FileName),
+	}
+	rsp := api.NewConfigClientResponseWithInfo(convertToErrCode(err), err.Error())
+	rsp.ConfigFile = file
+	safeReply(watchCtx, rsp)
+	if !watchCtx.IsOnce() {
+		wc.RemoveInterests(clientId, []*apiconfig.ClientConfigFileInfo{file})
+	}
+}
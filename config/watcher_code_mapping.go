@@ -0,0 +1,113 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// NotifyCodeMapping 按照客户端声明的版本，将订阅响应中的 polaris 响应码转换为客户端兼容的响应码
+type NotifyCodeMapping struct {
+	// ClientVersionBelow 客户端声明的版本低于该版本时使用该映射，为空时对所有声明了版本的客户端生效
+	ClientVersionBelow string `yaml:"clientVersionBelow"`
+	// MatchUndeclared 没有声明版本的客户端是否也使用该映射
+	MatchUndeclared bool `yaml:"matchUndeclared"`
+	// Codes polaris 响应码 -> 客户端兼容的响应码
+	Codes map[uint32]uint32 `yaml:"codes"`
+}
+
+// match 判断客户端是否使用该映射
+func (m *NotifyCodeMapping) match(clientVersion string) bool {
+	if clientVersion == "" {
+		return m.MatchUndeclared
+	}
+	return m.ClientVersionBelow == "" || compareClientVersion(clientVersion, m.ClientVersionBelow) < 0
+}
+
+// mapResponseCode 订阅响应返回给客户端之前转换响应码，按照配置的顺序使用第一个匹配的映射，没有匹配时原样返回
+func (wc *watchCenter) mapResponseCode(ctx context.Context,
+	rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+	if len(wc.codeMappings) == 0 || rsp == nil {
+		return rsp
+	}
+	clientVersion := parseClientVersion(ctx)
+	for i := range wc.codeMappings {
+		mapping := &wc.codeMappings[i]
+		if !mapping.match(clientVersion) {
+			continue
+		}
+		code, ok := mapping.Codes[rsp.GetCode().GetValue()]
+		if !ok {
+			return rsp
+		}
+		// 响应在多个客户端之间共享，只能基于副本进行修改
+		ret := proto.Clone(rsp).(*apiconfig.ConfigClientResponse)
+		ret.Code = utils.NewUInt32Value(code)
+		return ret
+	}
+	return rsp
+}
+
+// parseClientVersion 解析客户端声明的版本，没有声明时返回空字符串
+func parseClientVersion(ctx context.Context) string {
+	val, _ := ctx.Value(utils.ContextClientVersionKey).(string)
+	if val == "" {
+		if md, ok := ctx.Value(utils.ContextGrpcHeader).(metadata.MD); ok {
+			if vals := md.Get(utils.HeaderClientVersionKey); len(vals) > 0 {
+				val = vals[0]
+			}
+		}
+	}
+	return strings.TrimSpace(val)
+}
+
+// compareClientVersion 按照点分隔的数字逐段比较版本号，忽略 v 前缀以及 - 之后的后缀，无法解析的段按照 0 处理
+func compareClientVersion(a, b string) int {
+	parse := func(version string) []string {
+		version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+		if idx := strings.Index(version, "-"); idx >= 0 {
+			version = version[:idx]
+		}
+		return strings.Split(version, ".")
+	}
+	as, bs := parse(a), parse(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv uint64
+		if i < len(as) {
+			av, _ = strconv.ParseUint(as[i], 10, 64)
+		}
+		if i < len(bs) {
+			bv, _ = strconv.ParseUint(bs[i], 10, 64)
+		}
+		if av != bv {
+			if av < bv {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_Server_LongPullWatchFileCodeMapping(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 10),
+	}).AnyTimes()
+	wc.codeMappings = []NotifyCodeMapping{
+		{
+			ClientVersionBelow: "1.5.0",
+			Codes:              map[uint32]uint32{uint32(apimodel.Code_ExecuteSuccess): 200},
+		},
+	}
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	watch := func(clientVersion string) *apiconfig.ConfigClientResponse {
+		ctx := context.Background()
+		if clientVersion != "" {
+			ctx = context.WithValue(ctx, utils.ContextClientVersionKey, clientVersion)
+		}
+		callback, err := svr.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{
+			WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+		})
+		assert.NoError(t, err)
+		return callback()
+	}
+
+	// 低版本客户端收到转换后的响应码，配置信息保持不变
+	rsp := watch("v1.2.0")
+	assert.Equal(t, uint32(200), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(10), rsp.GetConfigFile().GetVersion().GetValue())
+	// 新版本以及没有声明版本的客户端使用原始的响应码
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), watch("1.5.0").GetCode().GetValue())
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), watch("").GetCode().GetValue())
+
+	wc.codeMappings[0].MatchUndeclared = true
+	assert.Equal(t, uint32(200), watch("").GetCode().GetValue())
+}
+
+func Test_compareClientVersion(t *testing.T) {
+	assert.Equal(t, -1, compareClientVersion("1.2.0", "1.10.0"))
+	assert.Equal(t, 0, compareClientVersion("v1.5", "1.5.0"))
+	assert.Equal(t, 0, compareClientVersion("1.5.0-beta", "1.5.0"))
+	assert.Equal(t, 1, compareClientVersion("2.0.0", "1.99.99"))
+}
@@ -0,0 +1,79 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// withContentDiff 客户端声明可以接收差异时，变更通知中携带的配置内容替换为相对于客户端持有版本的差异。
+// 找不到客户端持有版本的发布内容或者差异不比完整内容小时，继续使用完整的配置内容
+func (wc *watchCenter) withContentDiff(watchCtx WatchContext, event *model.SimpleConfigFileRelease,
+	rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+	content := rsp.GetConfigFile().GetContent().GetValue()
+	if !event.Valid || content == "" {
+		return rsp
+	}
+	key := event.ActiveKey()
+	var watchFile *apiconfig.ClientConfigFileInfo
+	for _, item := range watchCtx.ListWatchFiles() {
+		if model.BuildKeyForClientConfigFileInfo(item) == key {
+			watchFile = item
+			break
+		}
+	}
+	if watchFile == nil || !acceptContentDiff(watchFile) {
+		return rsp
+	}
+	baseVersion := watchFile.GetVersion().GetValue()
+	if baseVersion == 0 || baseVersion >= event.Version {
+		return rsp
+	}
+	base, ok := wc.fileCache.GetReleaseContentByVersion(event.Namespace, event.Group, event.FileName, baseVersion)
+	if !ok {
+		return rsp
+	}
+	diff := utils.DiffContent(base, content)
+	if len(diff) >= len(content) {
+		return rsp
+	}
+	// 响应在多个客户端之间共享，每个客户端持有的版本不同，只能基于副本进行修改
+	ret := proto.Clone(rsp).(*apiconfig.ConfigClientResponse)
+	ret.ConfigFile.Content = utils.NewStringValue(diff)
+	ret.ConfigFile.Tags = append(ret.ConfigFile.Tags, &apiconfig.ConfigFileTag{
+		Key:   utils.NewStringValue(utils.ConfigFileTagKeyDiffBaseVersion),
+		Value: utils.NewStringValue(strconv.FormatUint(baseVersion, 10)),
+	})
+	return ret
+}
+
+func acceptContentDiff(watchFile *apiconfig.ClientConfigFileInfo) bool {
+	for _, tag := range watchFile.GetTags() {
+		if tag.GetKey().GetValue() == utils.ConfigFileTagKeyAcceptDiff {
+			accept, _ := strconv.ParseBool(tag.GetValue().GetValue())
+			return accept
+		}
+	}
+	return false
+}
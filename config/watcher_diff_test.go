@@ -0,0 +1,101 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_watchCenter_NotifyContentDiff(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	wc.inlineContentMaxLength = 4096
+
+	lines := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		lines = append(lines, fmt.Sprintf("key-%d: value-%d", i, i))
+	}
+	base := strings.Join(lines, "\n") + "\n"
+	lines[25] = "key-25: changed"
+	content := strings.Join(lines, "\n") + "\n"
+
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 2),
+		Content:                 content,
+	}).AnyTimes()
+	hasHistory := true
+	fileCache.EXPECT().GetReleaseContentByVersion("default", "group", "file-1", uint64(1)).
+		DoAndReturn(func(_, _, _ string, _ uint64) (string, bool) {
+			if !hasHistory {
+				return "", false
+			}
+			return base, true
+		}).AnyTimes()
+
+	notify := func(clientId string, version uint64, acceptDiff bool) *apiconfig.ConfigClientResponse {
+		watchFile := newTestWatchFile("default", "group", "file-1", version)
+		if acceptDiff {
+			watchFile.Tags = []*apiconfig.ConfigFileTag{{
+				Key:   utils.NewStringValue(utils.ConfigFileTagKeyAcceptDiff),
+				Value: utils.NewStringValue("true"),
+			}}
+		}
+		watchCtxs := map[string]*LongPollWatchContext{
+			clientId: mustAddWatcher(t, wc, clientId, []*apiconfig.ClientConfigFileInfo{watchFile},
+				BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext),
+		}
+		go wc.doNotifyToWatchers(newTestRelease("default", "group", "file-1", 2), true)
+		return waitNotifyResults(t, watchCtxs)[clientId]
+	}
+	diffBaseVersion := func(rsp *apiconfig.ConfigClientResponse) string {
+		for _, tag := range rsp.GetConfigFile().GetTags() {
+			if tag.GetKey().GetValue() == utils.ConfigFileTagKeyDiffBaseVersion {
+				return tag.GetValue().GetValue()
+			}
+		}
+		return ""
+	}
+
+	// 小的改动只下发差异，客户端基于持有的版本可以还原出完整的内容
+	rsp := notify("client-diff", 1, true)
+	diff := rsp.GetConfigFile().GetContent().GetValue()
+	assert.Less(t, len(diff), len(content))
+	assert.Equal(t, "1", diffBaseVersion(rsp))
+	patched, err := utils.PatchContent(base, diff)
+	assert.NoError(t, err)
+	assert.Equal(t, content, patched)
+
+	// 没有声明接收差异的客户端收到完整的内容
+	rsp = notify("client-full", 1, false)
+	assert.Equal(t, content, rsp.GetConfigFile().GetContent().GetValue())
+	assert.Equal(t, "", diffBaseVersion(rsp))
+
+	// 持有的版本已经没有发布记录时回退为完整的内容
+	hasHistory = false
+	rsp = notify("client-no-history", 1, true)
+	assert.Equal(t, content, rsp.GetConfigFile().GetContent().GetValue())
+	assert.Equal(t, "", diffBaseVersion(rsp))
+}
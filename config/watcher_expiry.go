@@ -0,0 +1,213 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// ExpiryPolicyContext 由订阅上下文自身判断是否过期，长轮询到达超时时间后过期，流式订阅不过期
+	ExpiryPolicyContext = "context"
+	// ExpiryPolicyFixed 订阅上下文创建之后超过固定时间就过期
+	ExpiryPolicyFixed = "fixed"
+	// ExpiryPolicyIdle 订阅上下文超过一段时间没有任何活动就过期
+	ExpiryPolicyIdle = "idle"
+	// ExpiryPolicyNever 订阅上下文永不过期，只有客户端断开或者重新订阅时才会被清理
+	ExpiryPolicyNever = "never"
+)
+
+// ExpiryPolicy 订阅上下文的过期策略，由 watchCenter 的超时清理任务统一判断，过期的订阅者会收到 DataNoChange 后被清理
+type ExpiryPolicy interface {
+	// ShouldExpire 判断订阅上下文在 now 时刻是否已经过期
+	ShouldExpire(watchCtx WatchContext, now time.Time) bool
+}
+
+// ExpiryPolicyConfig 订阅上下文过期策略的配置
+type ExpiryPolicyConfig struct {
+	// Policy 过期策略，取值 context、fixed、idle、never，不设置时为 context
+	Policy string `yaml:"policy"`
+	// Timeout fixed 以及 idle 策略的超时时间
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// NewExpiryPolicy 根据配置创建过期策略
+func NewExpiryPolicy(cfg ExpiryPolicyConfig) (ExpiryPolicy, error) {
+	switch cfg.Policy {
+	case "", ExpiryPolicyContext:
+		return ContextExpiryPolicy{}, nil
+	case ExpiryPolicyNever:
+		return NeverExpiryPolicy{}, nil
+	case ExpiryPolicyFixed, ExpiryPolicyIdle:
+		if cfg.Timeout <= 0 {
+			return nil, fmt.Errorf("watch expiry policy %s timeout must be greater than 0", cfg.Policy)
+		}
+		if cfg.Policy == ExpiryPolicyFixed {
+			return FixedTimeoutExpiryPolicy{Timeout: cfg.Timeout}, nil
+		}
+		return IdleTimeoutExpiryPolicy{Timeout: cfg.Timeout}, nil
+	default:
+		return nil, fmt.Errorf("unknown watch expiry policy %s", cfg.Policy)
+	}
+}
+
+// ContextExpiryPolicy 使用订阅上下文自身的过期判断
+type ContextExpiryPolicy struct{}
+
+// ShouldExpire .
+func (ContextExpiryPolicy) ShouldExpire(watchCtx WatchContext, now time.Time) bool {
+	return watchCtx.ShouldExpire(now)
+}
+
+// NeverExpiryPolicy 订阅上下文永不过期
+type NeverExpiryPolicy struct{}
+
+// ShouldExpire .
+func (NeverExpiryPolicy) ShouldExpire(_ WatchContext, _ time.Time) bool {
+	return false
+}
+
+// FixedTimeoutExpiryPolicy 订阅上下文创建超过 Timeout 之后过期，没有记录创建时间的订阅上下文使用自身的过期判断
+type FixedTimeoutExpiryPolicy struct {
+	Timeout time.Duration
+}
+
+// ShouldExpire .
+func (p FixedTimeoutExpiryPolicy) ShouldExpire(watchCtx WatchContext, now time.Time) bool {
+	tracker, ok := watchCtx.(activityWatchContext)
+	if !ok {
+		return watchCtx.ShouldExpire(now)
+	}
+	return now.Sub(tracker.CreateTime()) >= p.Timeout
+}
+
+// IdleTimeoutExpiryPolicy 订阅上下文超过 Timeout 没有活动之后过期，没有记录活跃时间的订阅上下文使用自身的过期判断
+type IdleTimeoutExpiryPolicy struct {
+	Timeout time.Duration
+}
+
+// ShouldExpire .
+func (p IdleTimeoutExpiryPolicy) ShouldExpire(watchCtx WatchContext, now time.Time) bool {
+	tracker, ok := watchCtx.(activityWatchContext)
+	if !ok {
+		return watchCtx.ShouldExpire(now)
+	}
+	return now.Sub(tracker.LastActiveTime()) >= p.Timeout
+}
+
+// activityWatchContext 记录了创建时间以及最近活跃时间的订阅上下文
+type activityWatchContext interface {
+	// CreateTime 订阅上下文的创建时间
+	CreateTime() time.Time
+	// LastActiveTime 最近一次变更订阅列表或者通知客户端的时间
+	LastActiveTime() time.Time
+	// touch 记录一次活动
+	touch()
+}
+
+// watchActivity 记录订阅上下文的创建时间、最近活跃时间以及发起订阅的请求上下文，嵌入到订阅上下文中使用
+type watchActivity struct {
+	createTime time.Time
+	lastActive atomic.Int64
+	// requestCtx 发起订阅的请求上下文，通知客户端时基于该上下文重新鉴权
+	requestCtx context.Context
+	// sessionId 订阅的会话 ID，用于在日志以及通知中关联同一个订阅
+	sessionId string
+}
+
+func newWatchActivity() watchActivity {
+	return watchActivity{createTime: time.Now(), sessionId: utils.NewUUID()}
+}
+
+// CreateTime .
+func (a *watchActivity) CreateTime() time.Time {
+	return a.createTime
+}
+
+// LastActiveTime .
+func (a *watchActivity) LastActiveTime() time.Time {
+	if last := a.lastActive.Load(); last > 0 {
+		return time.Unix(0, last)
+	}
+	return a.createTime
+}
+
+func (a *watchActivity) touch() {
+	a.lastActive.Store(time.Now().UnixNano())
+}
+
+// RequestContext 发起订阅的请求上下文，没有记录时返回 context.Background()
+func (a *watchActivity) RequestContext() context.Context {
+	if a.requestCtx == nil {
+		return context.Background()
+	}
+	return a.requestCtx
+}
+
+// setRequestContext 只能在订阅上下文注册到 watchCenter 之前调用
+func (a *watchActivity) setRequestContext(ctx context.Context) {
+	a.requestCtx = ctx
+}
+
+// SessionID .
+func (a *watchActivity) SessionID() string {
+	return a.sessionId
+}
+
+// setSessionID 只能在订阅上下文注册到 watchCenter 之前调用
+func (a *watchActivity) setSessionID(sessionId string) {
+	a.sessionId = sessionId
+}
+
+// watchContextProtocol 订阅上下文使用的订阅协议，不是内置的订阅上下文时返回空
+func watchContextProtocol(watchCtx WatchContext) string {
+	switch watchCtx.(type) {
+	case *LongPollWatchContext:
+		return WatchProtocolLongPoll
+	case *StreamWatchContext:
+		return WatchProtocolStream
+	default:
+		return ""
+	}
+}
+
+// SetExpiryPolicy 设置某种订阅协议的订阅上下文的过期策略，传入 nil 时恢复为订阅上下文自身的过期判断
+func (wc *watchCenter) SetExpiryPolicy(protocol string, policy ExpiryPolicy) error {
+	if protocol != WatchProtocolLongPoll && protocol != WatchProtocolStream {
+		return fmt.Errorf("unknown watch protocol %s", protocol)
+	}
+	if policy == nil {
+		wc.expiryPolicies.Delete(protocol)
+		return nil
+	}
+	wc.expiryPolicies.Store(protocol, policy)
+	return nil
+}
+
+// shouldExpire 使用订阅上下文对应的过期策略判断是否过期
+func (wc *watchCenter) shouldExpire(watchCtx WatchContext, now time.Time) bool {
+	if policy, ok := wc.expiryPolicies.Load(watchContextProtocol(watchCtx)); ok {
+		return policy.ShouldExpire(watchCtx, now)
+	}
+	return watchCtx.ShouldExpire(now)
+}
@@ -0,0 +1,113 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewExpiryPolicy(t *testing.T) {
+	for _, item := range []struct {
+		cfg    ExpiryPolicyConfig
+		expect ExpiryPolicy
+	}{
+		{cfg: ExpiryPolicyConfig{}, expect: ContextExpiryPolicy{}},
+		{cfg: ExpiryPolicyConfig{Policy: ExpiryPolicyNever}, expect: NeverExpiryPolicy{}},
+		{cfg: ExpiryPolicyConfig{Policy: ExpiryPolicyFixed, Timeout: time.Minute},
+			expect: FixedTimeoutExpiryPolicy{Timeout: time.Minute}},
+		{cfg: ExpiryPolicyConfig{Policy: ExpiryPolicyIdle, Timeout: time.Minute},
+			expect: IdleTimeoutExpiryPolicy{Timeout: time.Minute}},
+	} {
+		policy, err := NewExpiryPolicy(item.cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, item.expect, policy)
+	}
+	// 超时类的策略必须设置超时时间
+	_, err := NewExpiryPolicy(ExpiryPolicyConfig{Policy: ExpiryPolicyIdle})
+	assert.Error(t, err)
+	_, err = NewExpiryPolicy(ExpiryPolicyConfig{Policy: "unknown"})
+	assert.Error(t, err)
+}
+
+func Test_ExpiryPolicy_Shapes(t *testing.T) {
+	longPoll := BuildTimeoutWatchCtx(time.Minute)("client-1")
+	stream := BuildStreamWatchCtx(8)("client-2")
+	now := time.Now()
+
+	// 使用订阅上下文自身的判断：长轮询到达超时时间后过期，流式订阅不过期
+	policy := ContextExpiryPolicy{}
+	assert.False(t, policy.ShouldExpire(longPoll, now))
+	assert.True(t, policy.ShouldExpire(longPoll, now.Add(2*time.Minute)))
+	assert.False(t, policy.ShouldExpire(stream, now.Add(time.Hour)))
+
+	// 永不过期
+	assert.False(t, NeverExpiryPolicy{}.ShouldExpire(longPoll, now.Add(time.Hour)))
+
+	// 固定时间过期，对流式订阅同样生效
+	fixed := FixedTimeoutExpiryPolicy{Timeout: 10 * time.Minute}
+	assert.False(t, fixed.ShouldExpire(stream, now.Add(5*time.Minute)))
+	assert.True(t, fixed.ShouldExpire(stream, now.Add(11*time.Minute)))
+	// 有活动也不会延长固定的过期时间
+	stream.AppendInterest(newTestWatchFile("default", "group", "file-1", 0))
+	assert.True(t, fixed.ShouldExpire(stream, now.Add(11*time.Minute)))
+
+	// 空闲时间过期，每次活动都会重新计算
+	idle := IdleTimeoutExpiryPolicy{Timeout: 10 * time.Minute}
+	lastActive := stream.(activityWatchContext).LastActiveTime()
+	assert.False(t, idle.ShouldExpire(stream, lastActive.Add(5*time.Minute)))
+	assert.True(t, idle.ShouldExpire(stream, lastActive.Add(11*time.Minute)))
+	time.Sleep(10 * time.Millisecond)
+	stream.Reply(&apiconfig.ConfigClientResponse{})
+	assert.True(t, stream.(activityWatchContext).LastActiveTime().After(lastActive))
+	assert.False(t, idle.ShouldExpire(stream, lastActive.Add(10*time.Minute)))
+}
+
+func Test_watchCenter_ExpiryPolicy(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	assert.Error(t, wc.SetExpiryPolicy("unknown", NeverExpiryPolicy{}))
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	// 长轮询设置为永不过期，超时时间到达之后依然保留
+	assert.NoError(t, wc.SetExpiryPolicy(WatchProtocolLongPoll, NeverExpiryPolicy{}))
+	longPoll := mustAddWatcher(t, wc, "client-long-poll", watchFiles,
+		BuildTimeoutWatchCtx(10*time.Millisecond)).(*LongPollWatchContext)
+	// 流式订阅设置为空闲过期
+	assert.NoError(t, wc.SetExpiryPolicy(WatchProtocolStream, IdleTimeoutExpiryPolicy{Timeout: 10 * time.Millisecond}))
+	mustAddWatcher(t, wc, "client-stream", watchFiles, BuildStreamWatchCtx(8))
+
+	assert.Eventually(t, func() bool {
+		_, ok := wc.clients.Load("client-stream")
+		return !ok
+	}, 3*time.Second, 50*time.Millisecond)
+	_, ok := wc.clients.Load("client-long-poll")
+	assert.True(t, ok)
+
+	// 恢复为订阅上下文自身的判断后，长轮询超时被清理
+	assert.NoError(t, wc.SetExpiryPolicy(WatchProtocolLongPoll, nil))
+	rsp, err := longPoll.GetNotifieResultWithTime(3 * time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, notModifiedResponse, rsp)
+	assert.Eventually(t, func() bool {
+		_, ok := wc.clients.Load("client-long-poll")
+		return !ok
+	}, time.Second, 50*time.Millisecond)
+}
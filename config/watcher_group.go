@@ -0,0 +1,118 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"sort"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// isGroupWatchFile 没有指定文件名的订阅表示订阅整个配置分组，只关心分组下配置文件的新增以及删除
+func isGroupWatchFile(file *apiconfig.ClientConfigFileInfo) bool {
+	return file.GetFileName().GetValue() == ""
+}
+
+// trackGroupFiles 第一次有客户端订阅配置分组时，记录分组下当前已经发布的配置文件，用于判断后续的发布是否为新增的配置文件
+func (wc *watchCenter) trackGroupFiles(file *apiconfig.ClientConfigFileInfo) {
+	if !isGroupWatchFile(file) {
+		return
+	}
+	namespace, group := file.GetNamespace().GetValue(), file.GetGroup().GetValue()
+	_, _ = wc.groupFiles.ComputeIfAbsent(utils.GenFileId(namespace, group, ""),
+		func(_ string) *utils.SyncSet[string] {
+			fileNames := utils.NewSyncSet[string]()
+			releases, _ := wc.fileCache.GetGroupActiveReleases(namespace, group)
+			for _, release := range releases {
+				fileNames.Add(release.FileName)
+			}
+			return fileNames
+		})
+}
+
+// handleConfigGroupChange 只处理配置分组的删除事件，通知订阅了该分组下配置的客户端并清理订阅关系
+func (wc *watchCenter) handleConfigGroupChange(event *eventhub.CacheConfigGroupEvent) {
+	if event.EventType != eventhub.EventDeleted || event.Item == nil {
+		return
+	}
+	wc.revokeWatchers(event.Item.Namespace, event.Item.Name, api.NewConfigClientResponse(
+		apimodel.Code_NotFoundResource, &apiconfig.ClientConfigFileInfo{
+			Namespace: utils.NewStringValue(event.Item.Namespace),
+			Group:     utils.NewStringValue(event.Item.Name),
+		}))
+}
+
+// notifyGroupWatchers 分组下新增配置文件时通知 Code_ExecuteSuccess，删除配置文件时通知 Code_NotFoundResource，
+// 通知中携带变化后的配置文件名称列表，已经存在的配置文件的再次发布只通知订阅了该配置文件的客户端
+func (wc *watchCenter) notifyGroupWatchers(release *model.SimpleConfigFileRelease) {
+	groupKey := utils.GenFileId(release.Namespace, release.Group, "")
+	clientIds, ok := wc.watchers.Load(groupKey)
+	if !ok || clientIds.Len() == 0 {
+		return
+	}
+	fileNames, ok := wc.groupFiles.Load(groupKey)
+	if !ok {
+		return
+	}
+
+	var code apimodel.Code
+	switch {
+	case !release.Valid && fileNames.Contains(release.FileName):
+		fileNames.Remove(release.FileName)
+		code = apimodel.Code_NotFoundResource
+	case release.Valid && !fileNames.Contains(release.FileName):
+		fileNames.Add(release.FileName)
+		code = apimodel.Code_ExecuteSuccess
+	default:
+		return
+	}
+	// 通知中携带变化后分组下的全部配置文件名称，客户端不需要再查询一次配置文件列表
+	notifyFile := release.ToSpecNotifyClientRequest()
+	names := fileNames.ToSlice()
+	sort.Strings(names)
+	for _, name := range names {
+		notifyFile.Tags = append(notifyFile.Tags, &apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyGroupFileName),
+			Value: utils.NewStringValue(name),
+		})
+	}
+	response := api.NewConfigClientResponse(code, notifyFile)
+
+	clientIds.Range(func(clientId string) {
+		watchCtx, ok := wc.clients.Load(clientId)
+		if !ok {
+			clientIds.Remove(clientId)
+			return
+		}
+		log.Info("[Config][Watcher] notify client config group changed.",
+			append(withWatchSession(watchLogFields(watchActionNotify, clientId, release.Namespace, release.Group,
+				release.FileName), watchCtx), zap.Uint32("code", uint32(code)))...)
+		safeReply(watchCtx, wc.transformResponse(watchCtx, response))
+		if watchCtx.IsOnce() {
+			// 只删除本次通知的订阅上下文，期间客户端重新订阅注册的新上下文需要保留
+			wc.removeWatchContext(watchCtx)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+)
+
+// NotifyLogSamplingConfig 配置变更通知日志的采样配置，同一个配置文件在每个采样周期内先完整输出 First 条日志，
+// 之后每 Thereafter 条只输出一条
+type NotifyLogSamplingConfig struct {
+	// Interval 采样周期，不设置时为 1s
+	Interval time.Duration `yaml:"interval"`
+	// First 每个采样周期内完整输出的日志条数，小于等于 0 时不做采样
+	First int `yaml:"first"`
+	// Thereafter 超过 First 之后每多少条日志输出一条，小于等于 0 时丢弃剩余的日志
+	Thereafter int `yaml:"thereafter"`
+}
+
+const (
+	// defaultNotifyLogSamplingInterval 默认的采样周期
+	defaultNotifyLogSamplingInterval = time.Second
+	// notifyLogSamplerBuckets 采样计数器的个数，按照配置文件哈希到固定数量的计数器上，避免内存随配置文件数量增长
+	notifyLogSamplerBuckets = 4096
+)
+
+// notifyLogCounter 单个采样周期内的日志计数
+type notifyLogCounter struct {
+	resetAt atomic.Int64
+	count   atomic.Uint64
+}
+
+// incCheckReset 计数加一，进入新的采样周期时重新计数
+func (c *notifyLogCounter) incCheckReset(now time.Time, interval time.Duration) uint64 {
+	tn := now.UnixNano()
+	resetAfter := c.resetAt.Load()
+	if resetAfter > tn {
+		return c.count.Add(1)
+	}
+	c.count.Store(1)
+	newResetAfter := tn + interval.Nanoseconds()
+	if !c.resetAt.CompareAndSwap(resetAfter, newResetAfter) {
+		// 其他协程已经重置了计数器
+		return c.count.Add(1)
+	}
+	return 1
+}
+
+// notifyLogSampler 配置变更通知日志的采样器，为 nil 时所有日志都会输出
+type notifyLogSampler struct {
+	interval   time.Duration
+	first      uint64
+	thereafter uint64
+	counters   [notifyLogSamplerBuckets]notifyLogCounter
+	// dropped 被采样丢弃的日志条数
+	dropped atomic.Uint64
+}
+
+func newNotifyLogSampler(cfg NotifyLogSamplingConfig) *notifyLogSampler {
+	if cfg.First <= 0 {
+		return nil
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = defaultNotifyLogSamplingInterval
+	}
+	thereafter := 0
+	if cfg.Thereafter > 0 {
+		thereafter = cfg.Thereafter
+	}
+	return &notifyLogSampler{
+		interval:   interval,
+		first:      uint64(cfg.First),
+		thereafter: uint64(thereafter),
+	}
+}
+
+// allow 判断本条日志是否需要输出，key 为日志对应的动作以及配置文件
+func (s *notifyLogSampler) allow(key string) bool {
+	if s == nil {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	n := s.counters[h.Sum32()%notifyLogSamplerBuckets].incCheckReset(time.Now(), s.interval)
+	if n <= s.first || (s.thereafter > 0 && (n-s.first)%s.thereafter == 0) {
+		return true
+	}
+	s.dropped.Add(1)
+	return false
+}
@@ -0,0 +1,69 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_notifyLogSampler(t *testing.T) {
+	assert.Nil(t, newNotifyLogSampler(NotifyLogSamplingConfig{}))
+	// 没有开启采样时所有日志都输出
+	var disabled *notifyLogSampler
+	assert.True(t, disabled.allow("file"))
+
+	sampler := newNotifyLogSampler(NotifyLogSamplingConfig{Interval: time.Minute, First: 2, Thereafter: 5})
+	allowed := 0
+	for i := 0; i < 22; i++ {
+		if sampler.allow("file") {
+			allowed++
+		}
+	}
+	// 前 2 条完整输出，之后每 5 条输出一条
+	assert.Equal(t, 6, allowed)
+	assert.Equal(t, uint64(16), sampler.dropped.Load())
+
+	// 进入新的采样周期后重新计数
+	sampler = newNotifyLogSampler(NotifyLogSamplingConfig{Interval: 10 * time.Millisecond, First: 1})
+	assert.True(t, sampler.allow("file"))
+	assert.False(t, sampler.allow("file"))
+	time.Sleep(20 * time.Millisecond)
+	assert.True(t, sampler.allow("file"))
+}
+
+func Test_watchCenter_NotifyLogSampling(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	wc.notifyLogSampler = newNotifyLogSampler(NotifyLogSamplingConfig{Interval: time.Minute, First: 2, Thereafter: 5})
+
+	const publishCount = 20
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+	}, BuildStreamWatchCtx(publishCount)).(*StreamWatchContext)
+	for i := 1; i <= publishCount; i++ {
+		wc.notifyToWatchers(newTestRelease("default", "group", "file-1", uint64(i)))
+	}
+	// 所有的通知都正常下发，只是超过阈值之后不再每一次都输出日志
+	assert.Equal(t, publishCount, len(watchCtx.sendCh))
+	// 每次发布输出接收以及通知两条日志
+	assert.Greater(t, wc.notifyLogSampler.dropped.Load(), uint64(0))
+	assert.Less(t, wc.notifyLogSampler.dropped.Load(), uint64(2*publishCount))
+}
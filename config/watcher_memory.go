@@ -0,0 +1,106 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"sort"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"go.uber.org/zap"
+)
+
+const (
+	// watchFileMemoryOverhead 单个订阅文件除去字符串内容之外的固定开销，包括 proto 对象、wrapper 以及 map 条目
+	watchFileMemoryOverhead = 256
+	// watchContextMemoryOverhead 单个订阅上下文的固定开销，包括 channel、锁以及索引中的条目
+	watchContextMemoryOverhead = 512
+)
+
+// estimateWatchFileMemory 估算单个订阅文件元数据占用的内存
+func estimateWatchFileMemory(file *apiconfig.ClientConfigFileInfo) int64 {
+	size := watchFileMemoryOverhead + len(file.GetNamespace().GetValue()) + len(file.GetGroup().GetValue()) +
+		len(file.GetFileName().GetValue()) + len(file.GetName().GetValue()) + len(file.GetMd5().GetValue())
+	for _, tag := range file.GetTags() {
+		size += len(tag.GetKey().GetValue()) + len(tag.GetValue().GetValue())
+	}
+	return int64(size)
+}
+
+// estimateWatchContextMemory 估算单个订阅上下文占用的内存，订阅的文件会在 watchers 索引中再记录一次客户端 ID
+func estimateWatchContextMemory(watchCtx WatchContext) int64 {
+	size := int64(watchContextMemoryOverhead + len(watchCtx.ClientID()))
+	for _, file := range watchCtx.ListWatchFiles() {
+		size += estimateWatchFileMemory(file) + int64(len(watchCtx.ClientID()))
+	}
+	return size
+}
+
+// EstimateWatchMemory 估算所有订阅上下文占用的内存
+func (wc *watchCenter) EstimateWatchMemory() int64 {
+	var total int64
+	wc.clients.ReadRange(func(_ string, watchCtx WatchContext) {
+		total += estimateWatchContextMemory(watchCtx)
+	})
+	return total
+}
+
+// shedWatchMemory 订阅上下文占用的内存超过软上限时，按照最近一次活跃时间从旧到新淘汰订阅上下文，
+// 被淘汰的客户端收到未变更的响应后重新发起订阅。无法获取活跃时间的订阅上下文不参与淘汰
+func (wc *watchCenter) shedWatchMemory() {
+	limit := wc.watchMemorySoftLimit.Load()
+	if limit <= 0 {
+		return
+	}
+	type candidate struct {
+		watchCtx WatchContext
+		size     int64
+		active   int64
+	}
+	var total int64
+	candidates := make([]candidate, 0, 32)
+	wc.clients.ReadRange(func(_ string, watchCtx WatchContext) {
+		size := estimateWatchContextMemory(watchCtx)
+		total += size
+		if activity, ok := watchCtx.(activityWatchContext); ok {
+			candidates = append(candidates, candidate{
+				watchCtx: watchCtx,
+				size:     size,
+				active:   activity.LastActiveTime().UnixNano(),
+			})
+		}
+	})
+	if total <= limit {
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].active < candidates[j].active
+	})
+	shed := 0
+	before := total
+	for _, item := range candidates {
+		if total <= limit {
+			break
+		}
+		safeReply(item.watchCtx, notModifiedResponse)
+		wc.removeWatchContext(item.watchCtx)
+		total -= item.size
+		shed++
+	}
+	log.Warn("[Config][Watcher] watch contexts memory exceed soft limit, shed idle watchers.",
+		zap.Int64("estimate", before), zap.Int64("limit", limit), zap.Int("shed", shed))
+}
@@ -0,0 +1,68 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_estimateWatchFileMemory(t *testing.T) {
+	small := newTestWatchFile("default", "group", "file", 0)
+	large := newTestWatchFile("default", "group", "file-with-a-much-longer-name", 0)
+	assert.Greater(t, estimateWatchFileMemory(small), int64(watchFileMemoryOverhead))
+	assert.Greater(t, estimateWatchFileMemory(large), estimateWatchFileMemory(small))
+}
+
+func Test_watchCenter_ShedWatchMemory(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	watchCtxs := make([]*LongPollWatchContext, 0, 4)
+	for i := 0; i < 4; i++ {
+		watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+		watchCtx := mustAddWatcher(t, wc, fmt.Sprintf("client-%d", i), watchFiles,
+			BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+		watchCtxs = append(watchCtxs, watchCtx)
+		time.Sleep(5 * time.Millisecond)
+	}
+	total := wc.EstimateWatchMemory()
+	perCtx := estimateWatchContextMemory(watchCtxs[0])
+	assert.Equal(t, 4*perCtx, total)
+
+	// 软上限只能容纳两个订阅，最早的两个订阅被淘汰并收到未变更的响应
+	wc.watchMemorySoftLimit.Store(2 * perCtx)
+	for _, watchCtx := range watchCtxs[:2] {
+		rsp, err := watchCtx.GetNotifieResultWithTime(3 * time.Second)
+		assert.NoError(t, err)
+		assert.Equal(t, notModifiedResponse, rsp)
+	}
+	assert.Eventually(t, func() bool {
+		_, ok0 := wc.clients.Load("client-0")
+		_, ok1 := wc.clients.Load("client-1")
+		return !ok0 && !ok1
+	}, 3*time.Second, 50*time.Millisecond)
+	for _, id := range []string{"client-2", "client-3"} {
+		_, ok := wc.clients.Load(id)
+		assert.True(t, ok, id)
+	}
+	assert.LessOrEqual(t, wc.EstimateWatchMemory(), 2*perCtx)
+}
@@ -0,0 +1,98 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// handleNamespaceChange 只处理命名空间的删除事件
+func (wc *watchCenter) handleNamespaceChange(event *eventhub.CacheNamespaceEvent) {
+	if event.EventType != eventhub.EventDeleted || event.Item == nil {
+		return
+	}
+	wc.revokeNamespaceWatchers(event.Item.Name)
+}
+
+// revokeNamespaceWatchers 命名空间被删除后，通知订阅了该命名空间下配置的客户端并清理订阅关系
+func (wc *watchCenter) revokeNamespaceWatchers(namespace string) {
+	wc.revokeWatchers(namespace, "", api.NewConfigClientResponse(apimodel.Code_NotFoundNamespace,
+		&apiconfig.ClientConfigFileInfo{Namespace: utils.NewStringValue(namespace)}))
+}
+
+// revokeWatchers 通知订阅了命名空间下（group 不为空时为该配置分组下）配置的客户端并清理订阅关系。
+// 长轮询等一次性的订阅上下文直接移除；流式订阅只取消对应的订阅，没有其他订阅时再移除
+func (wc *watchCenter) revokeWatchers(namespace, group string, rsp *apiconfig.ConfigClientResponse) {
+	clientFiles := map[string][]*apiconfig.ClientConfigFileInfo{}
+	func() {
+		wc.lock.Lock()
+		defer wc.lock.Unlock()
+
+		waitRemove := make([]string, 0, 8)
+		wc.watchers.ReadRange(func(fileId string, clientIds *utils.SyncSet[string]) {
+			ns, fileGroup, fileName := utils.ParseFileId(fileId)
+			if ns != namespace || (group != "" && fileGroup != group) {
+				return
+			}
+			waitRemove = append(waitRemove, fileId)
+			for _, clientId := range clientIds.ToSlice() {
+				clientFiles[clientId] = append(clientFiles[clientId], &apiconfig.ClientConfigFileInfo{
+					Namespace: utils.NewStringValue(ns),
+					Group:     utils.NewStringValue(fileGroup),
+					FileName:  utils.NewStringValue(fileName),
+				})
+			}
+		})
+		wc.notifiedLock.Lock()
+		for _, fileId := range waitRemove {
+			wc.watchers.Delete(fileId)
+			wc.groupFiles.Delete(fileId)
+			delete(wc.notifiedVersions, fileId)
+		}
+		wc.notifiedLock.Unlock()
+	}()
+	if len(clientFiles) == 0 {
+		return
+	}
+
+	log.Info("[Config][Watcher] resource deleted, revoke watchers.", utils.ZapNamespace(namespace),
+		utils.ZapGroup(group), zap.Int("clients", len(clientFiles)))
+	for clientId, files := range clientFiles {
+		watchCtx, ok := wc.clients.Load(clientId)
+		if !ok {
+			continue
+		}
+		safeReply(watchCtx, rsp)
+		if watchCtx.IsOnce() {
+			wc.removeWatchContext(watchCtx)
+			continue
+		}
+		for _, file := range files {
+			watchCtx.RemoveInterest(file)
+		}
+		if len(watchCtx.ListWatchFiles()) == 0 {
+			wc.removeWatchContext(watchCtx)
+		}
+	}
+}
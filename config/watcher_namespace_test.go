@@ -0,0 +1,89 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_watchCenter_RevokeDeletedNamespace(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetGroupActiveReleases("ns-1", "group").Return(nil, "").AnyTimes()
+
+	longPoll := mustAddWatcher(t, wc, "client-long-poll", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("ns-1", "group", "file-1", 1),
+	}, BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	stream := mustAddWatcher(t, wc, "client-stream", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("ns-1", "group", "file-1", 1),
+		newTestWatchFile("ns-1", "group", "", 0),
+		newTestWatchFile("ns-2", "group", "file-1", 1),
+	}, BuildStreamWatchCtx(8)).(*StreamWatchContext)
+	mustAddWatcher(t, wc, "client-other", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("ns-2", "group", "file-1", 1),
+	}, BuildTimeoutWatchCtx(time.Minute))
+
+	// 其他命名空间的事件不影响订阅
+	_ = eventhub.Publish(eventhub.CacheNamespaceEventTopic, &eventhub.CacheNamespaceEvent{
+		Item:      &model.Namespace{Name: "ns-2"},
+		EventType: eventhub.EventUpdated,
+	})
+	_ = eventhub.Publish(eventhub.CacheNamespaceEventTopic, &eventhub.CacheNamespaceEvent{
+		Item:      &model.Namespace{Name: "ns-1"},
+		EventType: eventhub.EventDeleted,
+	})
+
+	rsp := waitNotifyResults(t, map[string]*LongPollWatchContext{"client-long-poll": longPoll})["client-long-poll"]
+	assert.Equal(t, uint32(apimodel.Code_NotFoundNamespace), rsp.GetCode().GetValue())
+	assert.Equal(t, "ns-1", rsp.GetConfigFile().GetNamespace().GetValue())
+	select {
+	case rsp := <-stream.sendCh:
+		assert.Equal(t, uint32(apimodel.Code_NotFoundNamespace), rsp.GetCode().GetValue())
+	case <-time.After(time.Second):
+		t.Fatal("stream watcher should be notified")
+	}
+
+	assert.Eventually(t, func() bool {
+		_, ok := wc.clients.Load("client-long-poll")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+	// 流式订阅只保留其他命名空间的订阅
+	_, ok := wc.clients.Load("client-stream")
+	assert.True(t, ok)
+	files := stream.ListWatchFiles()
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "ns-2", files[0].GetNamespace().GetValue())
+	_, ok = wc.clients.Load("client-other")
+	assert.True(t, ok)
+
+	wc.watchers.ReadRange(func(fileId string, _ *utils.SyncSet[string]) {
+		ns, _, _ := utils.ParseFileId(fileId)
+		assert.NotEqual(t, "ns-1", ns, fileId)
+	})
+	clientIds, ok := wc.watchers.Load(utils.GenFileId("ns-2", "group", "file-1"))
+	assert.True(t, ok)
+	assert.ElementsMatch(t, []string{"client-stream", "client-other"}, clientIds.ToSlice())
+}
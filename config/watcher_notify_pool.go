@@ -0,0 +1,90 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// defaultNotifyQueueSize 通知协程池中每个协程的队列长度
+	defaultNotifyQueueSize = 1024
+)
+
+// notifyPool 按照配置文件分片的通知协程池，同一个配置文件的发布事件总是交给同一个协程处理，
+// 从而保证单个配置文件的通知顺序和事件的发布顺序一致
+type notifyPool struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	queues []chan *model.SimpleConfigFileRelease
+	handle func(*model.SimpleConfigFileRelease)
+	wait   sync.WaitGroup
+}
+
+func newNotifyPool(workers, queueSize int, handle func(*model.SimpleConfigFileRelease)) *notifyPool {
+	if queueSize <= 0 {
+		queueSize = defaultNotifyQueueSize
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &notifyPool{
+		ctx:    ctx,
+		cancel: cancel,
+		queues: make([]chan *model.SimpleConfigFileRelease, 0, workers),
+		handle: handle,
+	}
+	for i := 0; i < workers; i++ {
+		queue := make(chan *model.SimpleConfigFileRelease, queueSize)
+		p.queues = append(p.queues, queue)
+		p.wait.Add(1)
+		go p.run(queue)
+	}
+	return p
+}
+
+func (p *notifyPool) run(queue chan *model.SimpleConfigFileRelease) {
+	defer p.wait.Done()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case release := <-queue:
+			p.handle(release)
+		}
+	}
+}
+
+// submit 提交配置发布事件，队列满时阻塞调用方，由 eventhub 的队列承担背压
+func (p *notifyPool) submit(release *model.SimpleConfigFileRelease) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(utils.GenFileId(release.Namespace, release.Group, release.FileName)))
+	queue := p.queues[hash.Sum32()%uint32(len(p.queues))]
+	select {
+	case queue <- release:
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *notifyPool) close() {
+	p.cancel()
+	p.wait.Wait()
+}
@@ -0,0 +1,355 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+	"github.com/polarismesh/polaris/store"
+)
+
+const (
+	// defaultWatchPersistenceFlushInterval 默认的订阅关系写入存储的周期
+	defaultWatchPersistenceFlushInterval = 10 * time.Second
+	// defaultWatchPersistenceRetention 默认的客户端没有再订阅后保留订阅关系的时间
+	defaultWatchPersistenceRetention = 10 * time.Minute
+)
+
+// WatchPersistenceConfig 客户端订阅关系的持久化配置，开启后服务端重启时可以恢复客户端的订阅关系以及已经通知过的版本
+type WatchPersistenceConfig struct {
+	Open bool `yaml:"open"`
+	// FlushInterval 订阅关系写入存储的周期，不设置时默认为 10s
+	FlushInterval time.Duration `yaml:"flushInterval"`
+	// Retention 客户端超过该时间没有订阅时清理持久化的订阅关系，不设置时默认为 10m
+	Retention time.Duration `yaml:"retention"`
+}
+
+// persistedSubscription 单个客户端持久化的订阅关系
+type persistedSubscription struct {
+	// fileKey -> 订阅的配置文件以及已经通知过的版本
+	files map[string]WatchFileSnapshot
+	// lastSeen 最近一次订阅或者仍然在线的时间
+	lastSeen time.Time
+	// savedAt 最近一次写入存储的时间
+	savedAt time.Time
+	dirty   bool
+}
+
+// watchPersistence 只记录客户端声明了 ID 的订阅关系，定期批量写入存储，服务端重启后流式订阅的客户端重连时
+// 按照持久化的订阅列表以及已经通知过的版本恢复订阅，期间错过的配置发布会通知一次
+type watchPersistence struct {
+	store         store.ConfigWatchSubscriptionStore
+	flushInterval time.Duration
+	retention     time.Duration
+	lock          sync.Mutex
+	// clientId -> 客户端的订阅关系
+	subscriptions map[string]*persistedSubscription
+	// removed 已经过期等待从存储中删除的客户端
+	removed   []string
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	doneCh    chan struct{}
+}
+
+func newWatchPersistence(s store.ConfigWatchSubscriptionStore, cfg WatchPersistenceConfig) *watchPersistence {
+	p := &watchPersistence{
+		store:         s,
+		flushInterval: cfg.FlushInterval,
+		retention:     cfg.Retention,
+		subscriptions: map[string]*persistedSubscription{},
+		closeCh:       make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+	if p.flushInterval <= 0 {
+		p.flushInterval = defaultWatchPersistenceFlushInterval
+	}
+	if p.retention <= 0 {
+		p.retention = defaultWatchPersistenceRetention
+	}
+	return p
+}
+
+// load 加载存储中的订阅关系，超过保留时间的订阅关系在下一次写入时删除
+func (p *watchPersistence) load() error {
+	subs, err := p.store.GetAllConfigWatchSubscriptions()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, sub := range subs {
+		if now.Sub(sub.ModifyTime) > p.retention {
+			p.removed = append(p.removed, sub.ClientId)
+			continue
+		}
+		var files []WatchFileSnapshot
+		if err := json.Unmarshal([]byte(sub.Files), &files); err != nil {
+			log.Error("[Config][Watcher] unmarshal persisted watch subscription fail", zap.String("clientId", sub.ClientId),
+				zap.Error(err))
+			p.removed = append(p.removed, sub.ClientId)
+			continue
+		}
+		item := &persistedSubscription{
+			files:    make(map[string]WatchFileSnapshot, len(files)),
+			lastSeen: sub.ModifyTime,
+			savedAt:  sub.ModifyTime,
+		}
+		for _, file := range files {
+			item.files[model.BuildKeyForClientConfigFileInfo(file.toClientConfigFileInfo())] = file
+		}
+		p.subscriptions[sub.ClientId] = item
+	}
+	log.Info("[Config][Watcher] load persisted watch subscriptions", zap.Int("count", len(p.subscriptions)))
+	return nil
+}
+
+// observe 记录客户端当前完整的订阅列表，客户端上报的版本原样作为基线，上报版本为 0 时客户端会重新收到配置内容，
+// 服务端记录的版本只用于流式订阅重连时恢复订阅
+func (p *watchPersistence) observe(clientId string, watchFiles []*apiconfig.ClientConfigFileInfo) {
+	if p == nil || clientId == "" {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	item, ok := p.subscriptions[clientId]
+	if !ok {
+		item = &persistedSubscription{}
+		p.subscriptions[clientId] = item
+	}
+	files := make(map[string]WatchFileSnapshot, len(watchFiles))
+	for _, watchFile := range watchFiles {
+		files[model.BuildKeyForClientConfigFileInfo(watchFile)] = newWatchFileSnapshot(watchFile)
+	}
+	if !equalWatchFileSnapshots(item.files, files) {
+		item.files = files
+		item.dirty = true
+	}
+	item.lastSeen = time.Now()
+}
+
+// observeDelta 记录流式订阅的客户端新增以及取消的订阅，新增订阅的版本记录规则与 observe 一致
+func (p *watchPersistence) observeDelta(clientId string, added, removed []*apiconfig.ClientConfigFileInfo) {
+	if p == nil || clientId == "" {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	item, ok := p.subscriptions[clientId]
+	if !ok {
+		item = &persistedSubscription{}
+		p.subscriptions[clientId] = item
+	}
+	if item.files == nil {
+		item.files = map[string]WatchFileSnapshot{}
+	}
+	for _, watchFile := range removed {
+		key := model.BuildKeyForClientConfigFileInfo(watchFile)
+		if _, ok := item.files[key]; ok {
+			delete(item.files, key)
+			item.dirty = true
+		}
+	}
+	for _, watchFile := range added {
+		key := model.BuildKeyForClientConfigFileInfo(watchFile)
+		snapshot := newWatchFileSnapshot(watchFile)
+		if old, ok := item.files[key]; !ok || old != snapshot {
+			item.files[key] = snapshot
+			item.dirty = true
+		}
+	}
+	item.lastSeen = time.Now()
+}
+
+func newWatchFileSnapshot(watchFile *apiconfig.ClientConfigFileInfo) WatchFileSnapshot {
+	return WatchFileSnapshot{
+		Namespace: watchFile.GetNamespace().GetValue(),
+		Group:     watchFile.GetGroup().GetValue(),
+		FileName:  watchFile.GetFileName().GetValue(),
+		Version:   watchFile.GetVersion().GetValue(),
+	}
+}
+
+// delivered 记录已经成功通知给客户端的版本
+func (p *watchPersistence) delivered(clientId string, rsp *apiconfig.ConfigClientResponse) {
+	if p == nil || clientId == "" || rsp.GetCode().GetValue() != uint32(apimodel.Code_ExecuteSuccess) {
+		return
+	}
+	file := rsp.GetConfigFile()
+	if file == nil {
+		return
+	}
+	key := model.BuildKeyForClientConfigFileInfo(file)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	item, ok := p.subscriptions[clientId]
+	if !ok {
+		return
+	}
+	watchFile, ok := item.files[key]
+	if !ok || watchFile.Version == file.GetVersion().GetValue() {
+		return
+	}
+	watchFile.Version = file.GetVersion().GetValue()
+	item.files[key] = watchFile
+	item.dirty = true
+}
+
+// restore 获取客户端持久化的订阅列表，用于流式订阅的客户端重连后在发送订阅列表之前恢复订阅
+func (p *watchPersistence) restore(clientId string) []*apiconfig.ClientConfigFileInfo {
+	if p == nil || clientId == "" {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	item, ok := p.subscriptions[clientId]
+	if !ok {
+		return nil
+	}
+	ret := make([]*apiconfig.ClientConfigFileInfo, 0, len(item.files))
+	for _, file := range item.files {
+		ret = append(ret, file.toClientConfigFileInfo())
+	}
+	return ret
+}
+
+// run 定期将发生变化的订阅关系写入存储，online 判断客户端当前是否仍然在线
+func (p *watchPersistence) run(online func(clientId string) bool) {
+	defer close(p.doneCh)
+	ticker := time.NewTicker(p.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.flush(online)
+		case <-p.closeCh:
+			p.flush(online)
+			return
+		}
+	}
+}
+
+// flush 写入发生变化的订阅关系，在线的客户端每隔半个保留时间刷新一次，避免重启后被当做过期的订阅关系清理
+func (p *watchPersistence) flush(online func(clientId string) bool) {
+	now := time.Now()
+	saves := make([]*model.ConfigWatchSubscription, 0, 8)
+	p.lock.Lock()
+	removed := p.removed
+	p.removed = nil
+	for clientId, item := range p.subscriptions {
+		if online(clientId) {
+			item.lastSeen = now
+		} else if now.Sub(item.lastSeen) > p.retention {
+			delete(p.subscriptions, clientId)
+			removed = append(removed, clientId)
+			continue
+		}
+		if !item.dirty && (now.Sub(item.savedAt) < p.retention/2 || !item.lastSeen.After(item.savedAt)) {
+			continue
+		}
+		files := make([]WatchFileSnapshot, 0, len(item.files))
+		for _, file := range item.files {
+			files = append(files, file)
+		}
+		data, err := json.Marshal(files)
+		if err != nil {
+			log.Error("[Config][Watcher] marshal watch subscription fail", zap.String("clientId", clientId), zap.Error(err))
+			continue
+		}
+		item.dirty = false
+		item.savedAt = now
+		saves = append(saves, &model.ConfigWatchSubscription{ClientId: clientId, Files: string(data)})
+	}
+	p.lock.Unlock()
+
+	for _, sub := range saves {
+		if err := p.store.UpsertConfigWatchSubscription(sub); err != nil {
+			log.Error("[Config][Watcher] save watch subscription fail", zap.String("clientId", sub.ClientId),
+				zap.Error(err))
+			p.markDirty(sub.ClientId)
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	if err := p.store.DeleteConfigWatchSubscriptions(removed); err != nil {
+		log.Error("[Config][Watcher] delete expired watch subscriptions fail", zap.Int("count", len(removed)),
+			zap.Error(err))
+		p.lock.Lock()
+		p.removed = append(p.removed, removed...)
+		p.lock.Unlock()
+	}
+}
+
+func (p *watchPersistence) markDirty(clientId string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if item, ok := p.subscriptions[clientId]; ok {
+		item.dirty = true
+	}
+}
+
+// close 停止定期写入，并且写入最后一次变化
+func (p *watchPersistence) close() {
+	p.closeOnce.Do(func() {
+		close(p.closeCh)
+	})
+	<-p.doneCh
+}
+
+func (f WatchFileSnapshot) toClientConfigFileInfo() *apiconfig.ClientConfigFileInfo {
+	return &apiconfig.ClientConfigFileInfo{
+		Namespace: utils.NewStringValue(f.Namespace),
+		Group:     utils.NewStringValue(f.Group),
+		FileName:  utils.NewStringValue(f.FileName),
+		Version:   utils.NewUInt64Value(f.Version),
+	}
+}
+
+func equalWatchFileSnapshots(a, b map[string]WatchFileSnapshot) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, file := range a {
+		if other, ok := b[key]; !ok || other != file {
+			return false
+		}
+	}
+	return true
+}
+
+// startWatchPersistence 加载持久化的订阅关系并开始定期写入存储
+func (wc *watchCenter) startWatchPersistence(p *watchPersistence) error {
+	if err := p.load(); err != nil {
+		return err
+	}
+	wc.persistence = p
+	go p.run(func(clientId string) bool {
+		_, ok := wc.clients.Load(clientId)
+		return ok
+	})
+	return nil
+}
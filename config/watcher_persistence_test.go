@@ -0,0 +1,169 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// testWatchSubscriptionStore 在多个 watchCenter 之间共享的订阅关系存储，模拟服务端重启
+type testWatchSubscriptionStore struct {
+	lock sync.Mutex
+	subs map[string]model.ConfigWatchSubscription
+}
+
+func (s *testWatchSubscriptionStore) UpsertConfigWatchSubscription(sub *model.ConfigWatchSubscription) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	item := *sub
+	item.ModifyTime = time.Now()
+	s.subs[sub.ClientId] = item
+	return nil
+}
+
+func (s *testWatchSubscriptionStore) DeleteConfigWatchSubscriptions(clientIds []string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, clientId := range clientIds {
+		delete(s.subs, clientId)
+	}
+	return nil
+}
+
+func (s *testWatchSubscriptionStore) GetAllConfigWatchSubscriptions() ([]*model.ConfigWatchSubscription, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make([]*model.ConfigWatchSubscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		item := sub
+		ret = append(ret, &item)
+	}
+	return ret, nil
+}
+
+func Test_Server_WatchPersistenceAcrossRestart(t *testing.T) {
+	subStore := &testWatchSubscriptionStore{subs: map[string]model.ConfigWatchSubscription{}}
+	startServer := func(releaseVersion uint64) (*Server, *watchPersistence) {
+		wc, fileCache := newTestWatchCenter(t)
+		fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(&model.ConfigFileRelease{
+			SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", releaseVersion),
+		}).AnyTimes()
+		persistence := newWatchPersistence(subStore, WatchPersistenceConfig{Open: true, FlushInterval: time.Hour})
+		assert.NoError(t, wc.startWatchPersistence(persistence))
+		return &Server{watchCenter: wc, fileCache: fileCache}, persistence
+	}
+	watch := func(svr *Server, version uint64) *apiconfig.ConfigClientResponse {
+		ctx := context.WithValue(context.Background(), utils.ContextClientIdKey, "client-1")
+		ctx = context.WithValue(ctx, utils.WatchTimeoutCtx{}, 100*time.Millisecond)
+		callback, err := svr.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{
+			WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", version)},
+		})
+		assert.NoError(t, err)
+		return callback()
+	}
+
+	svr, persistence := startServer(1)
+	rsp := watch(svr, 0)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+	// 关闭时写入最后一次变化的订阅关系
+	persistence.close()
+	assert.Contains(t, subStore.subs, "client-1")
+
+	// 重启期间发布了新的版本，客户端重新订阅后收到一次错过的发布
+	svr, _ = startServer(2)
+	rsp = watch(svr, 1)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(2), rsp.GetConfigFile().GetVersion().GetValue())
+	rsp = watch(svr, 2)
+	assert.Equal(t, uint32(apimodel.Code_DataNoChange), rsp.GetCode().GetValue())
+	// 客户端重启后丢失了本地缓存，上报的版本为 0 时不能以服务端记录的版本为准，需要重新下发配置内容
+	rsp = watch(svr, 0)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(2), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 流式订阅的客户端重连后恢复之前的订阅关系
+	restored := svr.watchCenter.persistence.restore("client-1")
+	if assert.Len(t, restored, 1) {
+		assert.Equal(t, "file-1", restored[0].GetFileName().GetValue())
+		assert.Equal(t, uint64(2), restored[0].GetVersion().GetValue())
+	}
+	// 没有声明客户端 ID 的订阅不做持久化
+	assert.Nil(t, svr.watchCenter.persistence.restore(""))
+}
+
+func Test_watchPersistence_flushExpired(t *testing.T) {
+	subStore := &testWatchSubscriptionStore{subs: map[string]model.ConfigWatchSubscription{
+		"client-1": {ClientId: "client-1", Files: "[]", ModifyTime: time.Now().Add(-time.Hour)},
+	}}
+	p := newWatchPersistence(subStore, WatchPersistenceConfig{Retention: time.Minute})
+	assert.NoError(t, p.load())
+	assert.Nil(t, p.restore("client-1"))
+
+	p.observe("client-2", []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 3)})
+	p.flush(func(string) bool { return false })
+	assert.NotContains(t, subStore.subs, "client-1")
+	assert.Contains(t, subStore.subs, "client-2")
+
+	// 客户端超过保留时间没有订阅后清理持久化的订阅关系
+	p.subscriptions["client-2"].lastSeen = time.Now().Add(-time.Hour)
+	p.flush(func(string) bool { return false })
+	assert.NotContains(t, subStore.subs, "client-2")
+}
+
+func Test_watchPersistence_observe(t *testing.T) {
+	p := newWatchPersistence(&testWatchSubscriptionStore{subs: map[string]model.ConfigWatchSubscription{}},
+		WatchPersistenceConfig{Open: true})
+	p.observe("client-1", []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 5)})
+	key := model.BuildKeyForClientConfigFileInfo(newTestWatchFile("default", "group", "file-1", 0))
+
+	// 客户端丢失本地缓存上报版本为 0 时，记录客户端上报的版本，不能沿用服务端记录的版本
+	p.observe("client-1", []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)})
+	assert.Equal(t, uint64(0), p.subscriptions["client-1"].files[key].Version)
+
+	// 客户端显式声明了更低的版本（例如回滚了本地缓存），保留客户端声明的版本
+	p.observe("client-1", []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 3)})
+	assert.Equal(t, uint64(3), p.subscriptions["client-1"].files[key].Version)
+}
+
+func Test_watchCenter_DeclaredClientIdScopedByPrincipal(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	withPrincipal := func(principal string) context.Context {
+		authCtx := model.NewAcquireContext()
+		authCtx.SetAttachment(model.OperatorIDKey, principal)
+		ctx := context.WithValue(context.Background(), utils.ContextClientIdKey, "client-1")
+		return context.WithValue(ctx, utils.ContextAuthContextKey, authCtx)
+	}
+	// 不同操作者声明相同的客户端 ID 时使用不同的持久化订阅关系
+	assert.Equal(t, "user-1/client-1", wc.declaredClientId(withPrincipal("user-1")))
+	assert.NotEqual(t, wc.declaredClientId(withPrincipal("user-1")), wc.declaredClientId(withPrincipal("user-2")))
+	// 没有开启鉴权时保持客户端声明的 ID
+	assert.Equal(t, "client-1",
+		wc.declaredClientId(context.WithValue(context.Background(), utils.ContextClientIdKey, "client-1")))
+}
@@ -0,0 +1,59 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// watchFilePriority 客户端通过订阅文件的标签声明通知优先级，没有声明或者不合法时为 0
+func watchFilePriority(item *apiconfig.ClientConfigFileInfo) int {
+	for _, tag := range item.GetTags() {
+		if tag.GetKey().GetValue() != utils.ConfigFileTagKeyWatchPriority {
+			continue
+		}
+		priority, err := strconv.Atoi(strings.TrimSpace(tag.GetValue().GetValue()))
+		if err != nil {
+			return 0
+		}
+		return priority
+	}
+	return 0
+}
+
+// sortWatchFilesByPriority 按照通知优先级从高到低排序，优先级相同时按照文件排序，保证多个文件同时变更时关键的配置先通知
+func sortWatchFilesByPriority(files []*apiconfig.ClientConfigFileInfo) []*apiconfig.ClientConfigFileInfo {
+	priorities := make(map[*apiconfig.ClientConfigFileInfo]int, len(files))
+	for _, item := range files {
+		priorities[item] = watchFilePriority(item)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if priorities[files[i]] != priorities[files[j]] {
+			return priorities[files[i]] > priorities[files[j]]
+		}
+		return model.BuildKeyForClientConfigFileInfo(files[i]) < model.BuildKeyForClientConfigFileInfo(files[j])
+	})
+	return files
+}
@@ -0,0 +1,81 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func newTestPriorityWatchFile(fileName string, priority int) *apiconfig.ClientConfigFileInfo {
+	file := newTestWatchFile("default", "group", fileName, 0)
+	file.Tags = []*apiconfig.ConfigFileTag{
+		{
+			Key:   utils.NewStringValue(utils.ConfigFileTagKeyWatchPriority),
+			Value: utils.NewStringValue(strconv.Itoa(priority)),
+		},
+	}
+	return file
+}
+
+func Test_watchCenter_WatchPriority(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(namespace, group, fileName string) *model.ConfigFileRelease {
+			return &model.ConfigFileRelease{SimpleConfigFileRelease: newTestRelease(namespace, group, fileName, 1)}
+		}).AnyTimes()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+	watchFiles := []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "logging.yaml", 0),
+		newTestPriorityWatchFile("feature-flags.yaml", 100),
+		newTestPriorityWatchFile("database.yaml", 10),
+	}
+
+	// 流式订阅时多个文件同时有变更，按照优先级从高到低依次通知
+	watchCtx := mustAddWatcher(t, wc, "client-1", nil, BuildStreamWatchCtx(10)).(*StreamWatchContext)
+	svr.applyStreamWatchFiles(watchCtx, watchFiles, nil)
+	notified := make([]string, 0, len(watchFiles))
+	for range watchFiles {
+		notified = append(notified, (<-watchCtx.sendCh).GetConfigFile().GetFileName().GetValue())
+	}
+	assert.Equal(t, []string{"feature-flags.yaml", "database.yaml", "logging.yaml"}, notified)
+
+	// 长轮询只会响应一个文件，优先响应优先级最高的文件
+	for i := 0; i < 10; i++ {
+		longPollCtx := BuildTimeoutWatchCtx(0)("")
+		for _, file := range watchFiles {
+			longPollCtx.AppendInterest(file)
+		}
+		rsp := wc.checkQuickResponseClient(longPollCtx)
+		assert.Equal(t, "feature-flags.yaml", rsp.GetConfigFile().GetFileName().GetValue())
+	}
+
+	// 客户端调整优先级后更新保存的订阅信息
+	watchFiles[0] = newTestPriorityWatchFile("logging.yaml", 1000)
+	added, err := wc.UpdateWatcher("client-1", watchFiles)
+	assert.NoError(t, err)
+	assert.Empty(t, added)
+	assert.Equal(t, "logging.yaml", sortWatchFilesByPriority(watchCtx.ListWatchFiles())[0].GetFileName().GetValue())
+}
@@ -0,0 +1,174 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	cachetypes "github.com/polarismesh/polaris/cache/api"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const watchActionReleaseExpire = "release-expire"
+
+// effectiveRelease 获取配置文件当前对客户端生效的发布，生效的发布已经过期时回退到之前最近一次没有过期的发布，
+// 回退后的发布按照回滚处理；没有可以回退的发布时返回 nil
+func effectiveRelease(fileCache cachetypes.ConfigFileCache, priors *priorReleaseCache,
+	namespace, group, fileName string, now time.Time) *model.ConfigFileRelease {
+	release := fileCache.GetActiveRelease(namespace, group, fileName)
+	if release == nil || !release.IsExpired(now) {
+		return release
+	}
+	prior := priors.resolve(fileCache, release.SimpleConfigFileRelease, now)
+	if prior == nil {
+		return nil
+	}
+	found := fileCache.GetRelease(*prior.ConfigFileReleaseKey)
+	if found == nil {
+		return nil
+	}
+	// 缓存中的发布对象是共享的，不能直接修改
+	simple := *found.SimpleConfigFileRelease
+	simple.Rollback = true
+	return &model.ConfigFileRelease{SimpleConfigFileRelease: &simple, Content: found.Content}
+}
+
+// priorReleaseEntry 过期的发布版本以及需要回退到的发布，prior 为 nil 表示没有可以回退的发布
+type priorReleaseEntry struct {
+	expired uint64
+	prior   *model.SimpleConfigFileRelease
+}
+
+// priorReleaseCache 按照配置文件缓存过期发布需要回退到的发布，发布过期时查询一次，
+// 之后客户端的每次轮询都直接使用缓存的结果，不再查询全部的发布记录
+type priorReleaseCache struct {
+	// fileId -> 回退到的发布
+	entries *utils.SyncMap[string, *priorReleaseEntry]
+}
+
+func newPriorReleaseCache() *priorReleaseCache {
+	return &priorReleaseCache{entries: utils.NewSyncMap[string, *priorReleaseEntry]()}
+}
+
+// resolve 获取 expired 需要回退到的发布，缓存中没有或者缓存的发布也已经过期时重新查询一次
+func (c *priorReleaseCache) resolve(fileCache cachetypes.ConfigFileCache, expired *model.SimpleConfigFileRelease,
+	now time.Time) *model.SimpleConfigFileRelease {
+	if c != nil {
+		fileId := utils.GenFileId(expired.Namespace, expired.Group, expired.FileName)
+		if entry, ok := c.entries.Load(fileId); ok && entry.expired == expired.Version &&
+			(entry.prior == nil || !entry.prior.IsExpired(now)) {
+			return entry.prior
+		}
+	}
+	return c.load(fileCache, expired, now)
+}
+
+// load 查询 expired 需要回退到的发布并缓存，查询失败时不缓存，下一次获取时重新查询
+func (c *priorReleaseCache) load(fileCache cachetypes.ConfigFileCache, expired *model.SimpleConfigFileRelease,
+	now time.Time) *model.SimpleConfigFileRelease {
+	prior, err := priorRelease(fileCache, expired, now)
+	if err != nil || c == nil {
+		return prior
+	}
+	c.entries.Store(utils.GenFileId(expired.Namespace, expired.Group, expired.FileName),
+		&priorReleaseEntry{expired: expired.Version, prior: prior})
+	return prior
+}
+
+// priorRelease 查询版本比 expired 旧并且没有过期的最新一次发布
+func priorRelease(fileCache cachetypes.ConfigFileCache, expired *model.SimpleConfigFileRelease,
+	now time.Time) (*model.SimpleConfigFileRelease, error) {
+	_, releases, err := fileCache.QueryReleases(&cachetypes.ConfigReleaseArgs{
+		BaseConfigArgs: cachetypes.BaseConfigArgs{
+			Namespace: expired.Namespace,
+			Group:     expired.Group,
+		},
+		FileName: expired.FileName,
+		NoPage:   true,
+	})
+	if err != nil {
+		log.Error("[Config][Watcher] query releases to find prior release fail.",
+			append(watchLogFields(watchActionReleaseExpire, "", expired.Namespace, expired.Group,
+				expired.FileName), zap.Error(err))...)
+		return nil, err
+	}
+	var prior *model.SimpleConfigFileRelease
+	for _, item := range releases {
+		// 缓存按照通配符匹配，这里只保留当前文件的发布记录
+		if item.Namespace != expired.Namespace || item.Group != expired.Group || item.FileName != expired.FileName {
+			continue
+		}
+		if !item.Valid || item.Version >= expired.Version || item.IsExpired(now) {
+			continue
+		}
+		if prior == nil || item.Version > prior.Version {
+			prior = item
+		}
+	}
+	return prior, nil
+}
+
+// priorReleaseCache 获取过期发布回退的缓存，没有监听中心时返回 nil，每次都会重新查询
+func (wc *watchCenter) priorReleaseCache() *priorReleaseCache {
+	if wc == nil {
+		return nil
+	}
+	return wc.priorReleases
+}
+
+// trackReleaseExpiry 记录设置了过期时间的发布，同一个配置文件之后的发布会覆盖之前的记录；
+// 过期记录只保存在内存中，服务端重启之后客户端重新订阅时通过 effectiveRelease 对比版本得到回退后的发布
+func (wc *watchCenter) trackReleaseExpiry(release *model.SimpleConfigFileRelease) {
+	fileId := utils.GenFileId(release.Namespace, release.Group, release.FileName)
+	if _, ok := release.ExpireTime(); !ok || !release.Valid {
+		wc.releaseExpiries.Delete(fileId)
+		return
+	}
+	wc.releaseExpiries.Store(fileId, release)
+}
+
+// handleExpiredReleases 通知订阅了已经过期发布的客户端回退到之前的发布，没有可以回退的发布时按照删除通知
+func (wc *watchCenter) handleExpiredReleases(now time.Time) {
+	expired := make([]*model.SimpleConfigFileRelease, 0, 4)
+	wc.releaseExpiries.Range(func(fileId string, release *model.SimpleConfigFileRelease) {
+		if release.IsExpired(now) {
+			expired = append(expired, release)
+			wc.releaseExpiries.Delete(fileId)
+		}
+	})
+	for _, release := range expired {
+		var event model.SimpleConfigFileRelease
+		// 发布过期时查询一次回退到的发布，之后客户端轮询时直接使用缓存的结果
+		if prior := wc.priorReleases.load(wc.fileCache, release, now); prior != nil {
+			event = *prior
+			event.Rollback = true
+		} else {
+			event = *release
+			event.Valid = false
+		}
+		log.Info("[Config][Watcher] config file release expired, notify clients to revert.",
+			append(watchLogFields(watchActionReleaseExpire, "", release.Namespace, release.Group, release.FileName),
+				zap.Uint64("expired", release.Version), zap.Uint64("version", event.Version),
+				zap.Bool("deleted", !event.Valid))...)
+		wc.notifyToWatchers(&event)
+		wc.notifyGroupWatchers(&event)
+	}
+}
@@ -0,0 +1,97 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+func Test_watchCenter_ReleaseExpiry(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	prior := newTestRelease("default", "group", "file-1", 1)
+	prior.Name = "stable"
+	prior.Active = false
+	temporary := newTestRelease("default", "group", "file-1", 2)
+	temporary.Name = "feature-flag"
+	temporary.Metadata = map[string]string{
+		utils.ConfigFileTagKeyReleaseExpireTime: time.Now().Add(300 * time.Millisecond).Format(time.RFC3339Nano),
+	}
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: temporary,
+		Content:                 "feature=on",
+	}).AnyTimes()
+	// 发布过期时只查询一次回退到的发布，之后客户端的轮询都使用缓存的结果
+	fileCache.EXPECT().QueryReleases(gomock.Any()).Return(uint32(2),
+		[]*model.SimpleConfigFileRelease{prior, temporary}, nil).Times(1)
+	fileCache.EXPECT().GetRelease(*prior.ConfigFileReleaseKey).Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: prior,
+		Content:                 "feature=off",
+	}).AnyTimes()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{Message: temporary}))
+	_, ok := wc.releaseExpiries.Load(utils.GenFileId("default", "group", "file-1"))
+	assert.True(t, ok)
+	// 客户端已经拉取到了临时发布
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 2)}
+	watchCtx := mustAddWatcher(t, wc, "client-1", watchFiles,
+		BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+
+	// 临时发布过期后通知客户端回退到之前的发布
+	rsp, err := watchCtx.GetNotifieResultWithTime(3 * time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+	_, ok = wc.releaseExpiries.Load(utils.GenFileId("default", "group", "file-1"))
+	assert.False(t, ok)
+
+	// 客户端拉取配置时得到回退后的配置内容
+	for i := 0; i < 3; i++ {
+		fetched := svr.GetConfigFileForClient(context.Background(), newTestWatchFile("default", "group", "file-1", 2))
+		assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), fetched.GetCode().GetValue())
+		assert.Equal(t, uint64(1), fetched.GetConfigFile().GetVersion().GetValue())
+		assert.Equal(t, "feature=off", fetched.GetConfigFile().GetContent().GetValue())
+	}
+}
+
+func Test_watchCenter_ReleaseExpirySuperseded(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	temporary := newTestRelease("default", "group", "file-1", 2)
+	temporary.Metadata = map[string]string{
+		utils.ConfigFileTagKeyReleaseExpireTime: time.Now().Add(time.Minute).Format(time.RFC3339),
+	}
+	fileId := utils.GenFileId("default", "group", "file-1")
+	wc.trackReleaseExpiry(temporary)
+	_, ok := wc.releaseExpiries.Load(fileId)
+	assert.True(t, ok)
+
+	// 之后没有过期时间的发布覆盖之前的临时发布
+	wc.trackReleaseExpiry(newTestRelease("default", "group", "file-1", 3))
+	_, ok = wc.releaseExpiries.Load(fileId)
+	assert.False(t, ok)
+}
@@ -0,0 +1,115 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"time"
+
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	"go.uber.org/zap"
+
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+// sessionWatchContext 带有会话 ID 的订阅上下文，会话 ID 用于在日志以及通知中关联同一个订阅的完整生命周期
+type sessionWatchContext interface {
+	// SessionID 订阅上下文的会话 ID
+	SessionID() string
+	// setSessionID 恢复之前的会话 ID，只能在订阅上下文注册到 watchCenter 之前调用
+	setSessionID(sessionId string)
+}
+
+// watchSessionRecord 客户端最近一次订阅的会话
+type watchSessionRecord struct {
+	sessionId string
+	seen      time.Time
+}
+
+// watchSessionID 获取订阅上下文的会话 ID，不支持会话的订阅上下文返回空字符串
+func watchSessionID(watchCtx WatchContext) string {
+	if sessionCtx, ok := watchCtx.(sessionWatchContext); ok {
+		return sessionCtx.SessionID()
+	}
+	return ""
+}
+
+// withWatchSession 在订阅日志的通用字段中追加会话 ID
+func withWatchSession(fields []zap.Field, watchCtx WatchContext) []zap.Field {
+	return append(fields, zap.String("sessionId", watchSessionID(watchCtx)))
+}
+
+// withSessionTag 在通知中携带会话 ID，响应在多个客户端之间共享，只复制配置文件信息本身而不复制配置内容
+func withSessionTag(watchCtx WatchContext, rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+	sessionId := watchSessionID(watchCtx)
+	if sessionId == "" || rsp.GetConfigFile() == nil {
+		return rsp
+	}
+	file := *rsp.GetConfigFile()
+	file.Tags = make([]*apiconfig.ConfigFileTag, 0, len(rsp.GetConfigFile().GetTags())+1)
+	file.Tags = append(file.Tags, rsp.GetConfigFile().GetTags()...)
+	file.Tags = append(file.Tags, &apiconfig.ConfigFileTag{
+		Key:   utils.NewStringValue(utils.ConfigFileTagKeyWatchSessionId),
+		Value: utils.NewStringValue(sessionId),
+	})
+	return &apiconfig.ConfigClientResponse{
+		Code:       rsp.GetCode(),
+		Info:       rsp.GetInfo(),
+		ConfigFile: &file,
+	}
+}
+
+// resumeWatchSession 开启会话恢复时，同一个客户端在恢复窗口内重新订阅沿用之前的会话 ID，并记录本次订阅的会话
+func (wc *watchCenter) resumeWatchSession(clientId string, watchCtx WatchContext) {
+	if wc.sessionResumeWindow <= 0 {
+		return
+	}
+	sessionCtx, ok := watchCtx.(sessionWatchContext)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if record, ok := wc.sessions.Load(clientId); ok && now.Sub(record.seen) <= wc.sessionResumeWindow {
+		sessionCtx.setSessionID(record.sessionId)
+	}
+	wc.sessions.Store(clientId, watchSessionRecord{sessionId: sessionCtx.SessionID(), seen: now})
+}
+
+// touchWatchSession 订阅上下文被清理时刷新会话记录，恢复窗口从客户端断开时开始计算
+func (wc *watchCenter) touchWatchSession(clientId string, watchCtx WatchContext) {
+	if wc.sessionResumeWindow <= 0 {
+		return
+	}
+	if sessionId := watchSessionID(watchCtx); sessionId != "" {
+		wc.sessions.Store(clientId, watchSessionRecord{sessionId: sessionId, seen: time.Now()})
+	}
+}
+
+// cleanExpiredSessions 清理超过恢复窗口的会话记录
+func (wc *watchCenter) cleanExpiredSessions(now time.Time) {
+	if wc.sessionResumeWindow <= 0 {
+		return
+	}
+	wc.sessions.Range(func(clientId string, record watchSessionRecord) {
+		if _, ok := wc.clients.Load(clientId); ok {
+			return
+		}
+		if now.Sub(record.seen) > wc.sessionResumeWindow {
+			wc.sessions.Delete(clientId)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"sort"
+	"time"
+)
+
+var _ ExpirableWatchContext = (*LongPollWatchContext)(nil)
+
+type (
+	// ExpirableWatchContext 有超时时间的订阅上下文，例如长轮询
+	ExpirableWatchContext interface {
+		// ExpireTime 订阅的超时时间
+		ExpireTime() time.Time
+	}
+
+	// WatchContextSnapshot 订阅上下文的快照，用于排查问题
+	WatchContextSnapshot struct {
+		ClientID string `json:"client_id"`
+		// Once 是否为通知一次后就结束的订阅，例如长轮询；否则为流式订阅
+		Once bool `json:"once"`
+		// ExpireTime 订阅的超时时间，流式订阅没有超时时间
+		ExpireTime time.Time           `json:"expire_time,omitempty"`
+		Files      []WatchFileSnapshot `json:"files"`
+	}
+
+	// WatchFileSnapshot 客户端订阅的配置文件
+	WatchFileSnapshot struct {
+		Namespace string `json:"namespace"`
+		Group     string `json:"group"`
+		FileName  string `json:"file_name"`
+		Version   uint64 `json:"version"`
+	}
+)
+
+// Snapshot 获取当前所有订阅上下文的快照，按照客户端 ID 排序；遍历 clients 时只持有极短时间的读锁，
+// 不会阻塞配置变更的通知
+func (wc *watchCenter) Snapshot() []WatchContextSnapshot {
+	ret := make([]WatchContextSnapshot, 0, wc.clients.Len())
+	wc.clients.Range(func(clientId string, watchCtx WatchContext) {
+		item := WatchContextSnapshot{
+			ClientID: clientId,
+			Once:     watchCtx.IsOnce(),
+		}
+		if expirable, ok := watchCtx.(ExpirableWatchContext); ok {
+			item.ExpireTime = expirable.ExpireTime()
+		}
+		for _, file := range watchCtx.ListWatchFiles() {
+			item.Files = append(item.Files, WatchFileSnapshot{
+				Namespace: file.GetNamespace().GetValue(),
+				Group:     file.GetGroup().GetValue(),
+				FileName:  file.GetFileName().GetValue(),
+				Version:   file.GetVersion().GetValue(),
+			})
+		}
+		sort.Slice(item.Files, func(i, j int) bool {
+			a, b := item.Files[i], item.Files[j]
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			if a.Group != b.Group {
+				return a.Group < b.Group
+			}
+			return a.FileName < b.FileName
+		})
+		ret = append(ret, item)
+	})
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].ClientID < ret[j].ClientID
+	})
+	return ret
+}
@@ -0,0 +1,310 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"go.uber.org/zap"
+
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+)
+
+const (
+	// defaultStreamSendQueueSize 每个流式订阅客户端待发送通知的队列长度
+	defaultStreamSendQueueSize = 128
+)
+
+var (
+	// ErrStreamSendQueueFull 客户端消费过慢，待发送的通知已经堆满
+	ErrStreamSendQueueFull = errors.New("stream watch send queue is full")
+)
+
+// WatchFileStream 流式订阅配置文件的双向流，和具体的传输协议无关
+type WatchFileStream interface {
+	// Context .
+	Context() context.Context
+	// Send 推送配置变更通知
+	Send(*apiconfig.ConfigClientResponse) error
+	// Recv 接收客户端的订阅列表
+	Recv() (*apiconfig.ClientWatchConfigFileRequest, error)
+}
+
+// StreamWatchContext 流式订阅的 WatchContext，在整个流的生命周期内持续有效
+type StreamWatchContext struct {
+	watchActivity
+	clientId         string
+	watchConfigFiles *utils.SyncMap[string, *apiconfig.ClientConfigFileInfo]
+	// sendCh 待发送的通知，Reply 不会阻塞在这里，队列满时直接断开客户端让其重新订阅
+	sendCh    chan *apiconfig.ConfigClientResponse
+	closeOnce sync.Once
+	closeCh   chan struct{}
+	err       error
+	// interestRemovedHook 取消对某个配置文件的订阅后的回调，由 watchCenter 设置用于同步清理 watchers 索引
+	interestRemovedHook func(item *apiconfig.ClientConfigFileInfo)
+}
+
+// BuildStreamWatchCtx .
+func BuildStreamWatchCtx(queueSize int) WatchContextFactory {
+	if queueSize <= 0 {
+		queueSize = defaultStreamSendQueueSize
+	}
+	return func(clientId string) WatchContext {
+		return &StreamWatchContext{
+			watchActivity:    newWatchActivity(),
+			clientId:         clientId,
+			watchConfigFiles: utils.NewSyncMap[string, *apiconfig.ClientConfigFileInfo](),
+			sendCh:           make(chan *apiconfig.ConfigClientResponse, queueSize),
+			closeCh:          make(chan struct{}),
+		}
+	}
+}
+
+// IsOnce
+func (c *StreamWatchContext) IsOnce() bool {
+	return false
+}
+
+func (c *StreamWatchContext) ShouldExpire(now time.Time) bool {
+	return false
+}
+
+// ClientID .
+func (c *StreamWatchContext) ClientID() string {
+	return c.clientId
+}
+
+// ShouldNotify .
+func (c *StreamWatchContext) ShouldNotify(event *model.SimpleConfigFileRelease) bool {
+	key := event.ActiveKey()
+	watchFile, ok := c.watchConfigFiles.Load(key)
+	if !ok {
+		return false
+	}
+	// 删除操作，直接通知
+	if !event.Valid {
+		return true
+	}
+	if event.DataKeyRotated {
+		return watchFile.GetVersion().GetValue() <= event.Version
+	}
+	if event.Rollback {
+		return watchFile.GetVersion().GetValue() != event.Version
+	}
+	return watchFile.GetVersion().GetValue() < event.Version
+}
+
+// ClientLabels .
+func (c *StreamWatchContext) ClientLabels() map[string]string {
+	return collectClientLabels(c.ListWatchFiles())
+}
+
+// ListWatchFiles .
+func (c *StreamWatchContext) ListWatchFiles() []*apiconfig.ClientConfigFileInfo {
+	return c.watchConfigFiles.Values()
+}
+
+// AppendInterest .
+func (c *StreamWatchContext) AppendInterest(item *apiconfig.ClientConfigFileInfo) {
+	key := model.BuildKeyForClientConfigFileInfo(item)
+	c.watchConfigFiles.Store(key, item)
+}
+
+// RemoveInterest 取消对配置文件的订阅，同步清理 watchCenter 中的 watchers 索引
+func (c *StreamWatchContext) RemoveInterest(item *apiconfig.ClientConfigFileInfo) {
+	key := model.BuildKeyForClientConfigFileInfo(item)
+	if _, ok := c.watchConfigFiles.Delete(key); !ok {
+		return
+	}
+	if c.interestRemovedHook != nil {
+		c.interestRemovedHook(item)
+	}
+}
+
+func (c *StreamWatchContext) setInterestRemovedHook(hook func(item *apiconfig.ClientConfigFileInfo)) {
+	c.interestRemovedHook = hook
+}
+
+// revokeInterest 服务端主动取消客户端的订阅，客户端没有重新上报订阅列表，需要显式推送订阅被移除的通知，
+// 否则客户端会一直等待该文件的变更
+func (c *StreamWatchContext) revokeInterest(item *apiconfig.ClientConfigFileInfo) {
+	key := model.BuildKeyForClientConfigFileInfo(item)
+	if _, ok := c.watchConfigFiles.Load(key); !ok {
+		return
+	}
+	c.RemoveInterest(item)
+	c.Reply(api.NewConfigClientResponse(apimodel.Code(api.WatchInterestRemoved), &apiconfig.ClientConfigFileInfo{
+		Namespace: item.GetNamespace(),
+		Group:     item.GetGroup(),
+		FileName:  item.GetFileName(),
+	}))
+}
+
+// Close .
+func (c *StreamWatchContext) Close() error {
+	c.closeWithErr(nil)
+	return nil
+}
+
+func (c *StreamWatchContext) closeWithErr(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.closeCh)
+	})
+}
+
+// Reply 将通知放入发送队列，不会阻塞 notifyToWatchers
+func (c *StreamWatchContext) Reply(rsp *apiconfig.ConfigClientResponse) {
+	select {
+	case <-c.closeCh:
+		return
+	default:
+	}
+	select {
+	case c.sendCh <- rsp:
+		c.touch()
+		c.markNotified(rsp.GetConfigFile())
+	default:
+		log.Warn("[Config][Watcher] stream client consume too slow, close it", zap.String("clientId", c.clientId))
+		c.closeWithErr(ErrStreamSendQueueFull)
+	}
+}
+
+// markNotified 记录已经推送过的版本，避免同一个版本重复通知
+func (c *StreamWatchContext) markNotified(file *apiconfig.ClientConfigFileInfo) {
+	if file == nil {
+		return
+	}
+	key := model.BuildKeyForClientConfigFileInfo(file)
+	watchFile, ok := c.watchConfigFiles.Load(key)
+	if !ok {
+		return
+	}
+	// 只更新版本信息，客户端订阅时携带的标签需要保留，标签选择器以及通知优先级都依赖这些标签
+	notified := proto.Clone(watchFile).(*apiconfig.ClientConfigFileInfo)
+	notified.Version = file.GetVersion()
+	notified.Md5 = file.GetMd5()
+	c.watchConfigFiles.Store(key, notified)
+}
+
+// StreamWatchFile 流式订阅配置文件，客户端每次只发送订阅的变化：没有声明订阅动作的文件为新增订阅，
+// 通过 tag internal-watch-action=remove 声明的文件为取消订阅
+func (s *Server) StreamWatchFile(ctx context.Context, stream WatchFileStream) error {
+	clientId := s.WatchCenter().ParseClientId(ctx)
+	watchCtx, err := replaceWatcher[*StreamWatchContext](s.WatchCenter(), clientId, nil,
+		s.WatchCenter().SelectWatchContextFactory(ctx, WatchProtocolStream))
+	if err != nil {
+		if errors.Is(err, ErrTooManyWatchers) {
+			// 告知客户端服务端繁忙后直接断开，由客户端退避重连
+			_ = stream.Send(s.WatchCenter().mapResponseCode(ctx, tooBusyResponse))
+		}
+		return err
+	}
+	defer s.WatchCenter().removeWatchContext(watchCtx)
+	// 开启订阅关系持久化时，客户端重连后先恢复之前的订阅，期间错过的配置发布立即通知
+	persistId := s.WatchCenter().declaredClientId(ctx)
+	if restored := s.WatchCenter().persistence.restore(persistId); len(restored) != 0 {
+		s.applyStreamWatchFiles(watchCtx, restored, nil)
+	}
+
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			added, removed := splitStreamWatchFiles(req.GetWatchFiles())
+			s.WatchCenter().persistence.observeDelta(persistId, added, removed)
+			s.applyStreamWatchFiles(watchCtx, added, removed)
+		}
+	}()
+
+	for {
+		select {
+		case rsp := <-watchCtx.sendCh:
+			if err := stream.Send(s.WatchCenter().mapResponseCode(ctx, rsp)); err != nil {
+				return err
+			}
+			s.WatchCenter().persistence.delivered(persistId, rsp)
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case <-watchCtx.closeCh:
+			return watchCtx.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// applyStreamWatchFiles 增量更新订阅关系，新订阅的文件如果已经有变更则立即通知
+func (s *Server) applyStreamWatchFiles(watchCtx *StreamWatchContext, added, removed []*apiconfig.ClientConfigFileInfo) {
+	s.WatchCenter().RemoveInterests(watchCtx.ClientID(), removed)
+	added, err := s.WatchCenter().AppendInterests(watchCtx, added)
+	if err != nil {
+		// 不合法的订阅不影响已经建立的订阅关系，告知客户端后继续处理后续的订阅变化
+		watchCtx.Reply(api.NewConfigClientResponseWithInfo(apimodel.Code_InvalidWatchConfigFileFormat, err.Error()))
+		return
+	}
+	// 多个新订阅的文件同时有变更时，按照优先级依次通知
+	for _, item := range sortWatchFilesByPriority(added) {
+		release := effectiveRelease(s.fileCache, s.watchCenter.priorReleaseCache(), item.GetNamespace().GetValue(),
+			item.GetGroup().GetValue(), item.GetFileName().GetValue(), time.Now())
+		if release == nil || !watchCtx.ShouldNotify(release.SimpleConfigFileRelease) ||
+			!matchClientSelector(watchCtx, release.SimpleConfigFileRelease) {
+			continue
+		}
+		watchCtx.Reply(withSessionTag(watchCtx, api.NewConfigClientResponse(apimodel.Code_ExecuteSuccess,
+			release.SimpleConfigFileRelease.ToSpecNotifyClientRequest())))
+	}
+}
+
+// splitStreamWatchFiles 按照客户端声明的订阅动作拆分为新增以及取消的订阅
+func splitStreamWatchFiles(watchFiles []*apiconfig.ClientConfigFileInfo) (added,
+	removed []*apiconfig.ClientConfigFileInfo) {
+	for _, item := range watchFiles {
+		if isRemoveWatchAction(item) {
+			removed = append(removed, item)
+			continue
+		}
+		added = append(added, item)
+	}
+	return added, removed
+}
+
+func isRemoveWatchAction(item *apiconfig.ClientConfigFileInfo) bool {
+	for _, tag := range item.GetTags() {
+		if tag.GetKey().GetValue() == utils.ConfigFileTagKeyWatchAction {
+			return tag.GetValue().GetValue() == utils.ConfigFileWatchActionRemove
+		}
+	}
+	return false
+}
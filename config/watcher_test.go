@@ -0,0 +1,1851 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	apiconfig "github.com/polarismesh/specification/source/go/api/v1/config_manage"
+	apimodel "github.com/polarismesh/specification/source/go/api/v1/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/polarismesh/polaris/cache/mock"
+	api "github.com/polarismesh/polaris/common/api/v1"
+	"github.com/polarismesh/polaris/common/eventhub"
+	"github.com/polarismesh/polaris/common/metrics"
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/common/utils"
+	storemock "github.com/polarismesh/polaris/store/mock"
+)
+
+func newTestWatchCenter(t *testing.T) (*watchCenter, *mock.MockConfigFileCache) {
+	eventhub.InitEventHub()
+	ctrl := gomock.NewController(t)
+	fileCache := mock.NewMockConfigFileCache(ctrl)
+	wc, err := NewWatchCenter(fileCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		wc.Close()
+		ctrl.Finish()
+	})
+	return wc, fileCache
+}
+
+func mustAddWatcher(t *testing.T, wc *watchCenter, clientId string,
+	watchFiles []*apiconfig.ClientConfigFileInfo, factory WatchContextFactory) WatchContext {
+	watchCtx, err := wc.AddWatcher(clientId, watchFiles, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return watchCtx
+}
+
+func newTestWatchFile(namespace, group, fileName string, version uint64) *apiconfig.ClientConfigFileInfo {
+	return &apiconfig.ClientConfigFileInfo{
+		Namespace: utils.NewStringValue(namespace),
+		Group:     utils.NewStringValue(group),
+		FileName:  utils.NewStringValue(fileName),
+		Version:   utils.NewUInt64Value(version),
+	}
+}
+
+func Test_watchCenter_compactWatchers(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+	}
+	fileKey := utils.GenFileId("default", "group", "file-1")
+
+	for _, clientId := range []string{"client-1", "client-2"} {
+		wc.AddWatcher(clientId, watchFiles, BuildTimeoutWatchCtx(30*time.Second))
+	}
+	_, ok := wc.watchers.Load(fileKey)
+	assert.True(t, ok)
+
+	// 还存在订阅者时不能被清理
+	wc.RemoveAllWatcher("client-1")
+	wc.compactWatchers()
+	_, ok = wc.watchers.Load(fileKey)
+	assert.True(t, ok)
+
+	wc.RemoveAllWatcher("client-2")
+	wc.compactWatchers()
+	_, ok = wc.watchers.Load(fileKey)
+	assert.False(t, ok)
+
+	// 清理之后重新订阅，索引需要能够重新建立
+	wc.AddWatcher("client-3", watchFiles, BuildTimeoutWatchCtx(30*time.Second))
+	clientIds, ok := wc.watchers.Load(fileKey)
+	assert.True(t, ok)
+	assert.True(t, clientIds.Contains("client-3"))
+}
+
+func Test_watchCenter_RemoveInterestIndex(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	file1 := newTestWatchFile("default", "group", "file-1", 0)
+	file2 := newTestWatchFile("default", "group", "file-2", 0)
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{file1, file2},
+		BuildTimeoutWatchCtx(30*time.Second))
+	mustAddWatcher(t, wc, "client-2", []*apiconfig.ClientConfigFileInfo{file1}, BuildTimeoutWatchCtx(30*time.Second))
+
+	// 直接在订阅上下文上取消订阅，watchers 索引需要同步更新
+	watchCtx.RemoveInterest(file1)
+	clientIds, ok := wc.watchers.Load(utils.GenFileId("default", "group", "file-1"))
+	assert.True(t, ok)
+	assert.False(t, clientIds.Contains("client-1"))
+	assert.True(t, clientIds.Contains("client-2"))
+	clientIds, _ = wc.watchers.Load(utils.GenFileId("default", "group", "file-2"))
+	assert.True(t, clientIds.Contains("client-1"))
+	assert.Equal(t, 1, len(watchCtx.ListWatchFiles()))
+
+	// 被替换的旧订阅上下文不能影响新订阅上下文的索引
+	wc.RemoveAllWatcher("client-1")
+	mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{file2}, BuildTimeoutWatchCtx(30*time.Second))
+	watchCtx.RemoveInterest(file2)
+	clientIds, _ = wc.watchers.Load(utils.GenFileId("default", "group", "file-2"))
+	assert.True(t, clientIds.Contains("client-1"))
+}
+
+func Test_watchCenter_NotifyFastPath(t *testing.T) {
+	for _, fastPathMax := range []int{0, defaultNotifyFastPathMax} {
+		wc, _ := newTestWatchCenter(t)
+		wc.notifyFastPathMax = fastPathMax
+
+		watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+		watchCtxs := map[string]*LongPollWatchContext{}
+		for _, clientId := range []string{"client-1", "client-2", "client-3"} {
+			watchCtxs[clientId] = mustAddWatcher(t, wc, clientId, watchFiles,
+				BuildTimeoutWatchCtx(30*time.Second)).(*LongPollWatchContext)
+		}
+		// 索引中残留的订阅者在通知时被清理
+		clientIds, _ := wc.watchers.Load(utils.GenFileId("default", "group", "file-1"))
+		clientIds.Add("client-removed")
+
+		go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+		for clientId, rsp := range waitNotifyResults(t, watchCtxs) {
+			assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue(), clientId)
+		}
+		// 长轮询的订阅上下文通知一次后就被清理
+		assert.Eventually(t, func() bool {
+			return wc.clients.Len() == 0 && !clientIds.Contains("client-removed")
+		}, time.Second, 10*time.Millisecond, "fastPathMax=%d", fastPathMax)
+	}
+}
+
+func Test_watchCenter_NotifyFanOut(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	wc.notifyFanOutConcurrency = 8
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	watchCtxs := map[string]*LongPollWatchContext{}
+	for i := 0; i < 500; i++ {
+		clientId := fmt.Sprintf("client-%d", i)
+		watchCtxs[clientId] = mustAddWatcher(t, wc, clientId, watchFiles,
+			BuildTimeoutWatchCtx(30*time.Second)).(*LongPollWatchContext)
+	}
+	clientIds, _ := wc.watchers.Load(utils.GenFileId("default", "group", "file-1"))
+	clientIds.Add("client-removed")
+
+	go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	for clientId, rsp := range waitNotifyResults(t, watchCtxs) {
+		assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue(), clientId)
+	}
+	// 并发通知后长轮询的订阅上下文以及索引都被清理
+	assert.Eventually(t, func() bool {
+		return wc.clients.Len() == 0 && clientIds.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_watchCenter_NotifyDeletedFile(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	// 客户端持有的版本比删除事件的版本更新，也需要收到通知
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 5)}
+	watchCtxs := map[string]*LongPollWatchContext{}
+	for _, clientId := range []string{"client-1", "client-2"} {
+		watchCtxs[clientId] = mustAddWatcher(t, wc, clientId, watchFiles,
+			BuildTimeoutWatchCtx(30*time.Second)).(*LongPollWatchContext)
+	}
+
+	release := newTestRelease("default", "group", "file-1", 1)
+	release.Valid = false
+	go wc.notifyToWatchers(release)
+	for clientId, rsp := range waitNotifyResults(t, watchCtxs) {
+		assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue(), clientId)
+		assert.Equal(t, "file-1", rsp.GetConfigFile().GetFileName().GetValue(), clientId)
+	}
+	// 通知完成后清理该配置文件的订阅索引
+	assert.Eventually(t, func() bool {
+		_, ok := wc.watchers.Load(utils.GenFileId("default", "group", "file-1"))
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_watchCenter_NotifyClientSelector(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	newLabeledWatchFile := func(env string) *apiconfig.ClientConfigFileInfo {
+		file := newTestWatchFile("default", "group", "file-1", 1)
+		file.Tags = []*apiconfig.ConfigFileTag{
+			{Key: utils.NewStringValue("env"), Value: utils.NewStringValue(env)},
+		}
+		return file
+	}
+	stagingCtx := mustAddWatcher(t, wc, "client-staging",
+		[]*apiconfig.ClientConfigFileInfo{newLabeledWatchFile("staging")},
+		BuildTimeoutWatchCtx(30*time.Second)).(*LongPollWatchContext)
+	prodCtx := mustAddWatcher(t, wc, "client-prod",
+		[]*apiconfig.ClientConfigFileInfo{newLabeledWatchFile("prod")},
+		BuildTimeoutWatchCtx(30*time.Second)).(*LongPollWatchContext)
+
+	release := newTestRelease("default", "group", "file-1", 2)
+	release.Metadata = map[string]string{utils.ConfigFileTagKeyClientSelector: "env=staging"}
+	go wc.notifyToWatchers(release)
+
+	rsp := waitNotifyResults(t, map[string]*LongPollWatchContext{"client-staging": stagingCtx})["client-staging"]
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(2), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 标签不匹配的客户端继续等待，订阅关系保持不变
+	_, err := prodCtx.GetNotifieResultWithTime(200 * time.Millisecond)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, ok := wc.clients.Load("client-prod")
+	assert.True(t, ok)
+
+	// 面向所有客户端的发布依然可以通知到
+	go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 3))
+	rsp = waitNotifyResults(t, map[string]*LongPollWatchContext{"client-prod": prodCtx})["client-prod"]
+	assert.Equal(t, uint64(3), rsp.GetConfigFile().GetVersion().GetValue())
+}
+
+func Test_watchCenter_StreamClientSelector(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	file := newTestWatchFile("default", "group", "file-1", 1)
+	file.Tags = []*apiconfig.ConfigFileTag{
+		{Key: utils.NewStringValue("env"), Value: utils.NewStringValue("staging")},
+	}
+	streamCtx := mustAddWatcher(t, wc, "client-stream", []*apiconfig.ClientConfigFileInfo{file},
+		BuildStreamWatchCtx(10)).(*StreamWatchContext)
+
+	// 连续两次只面向 staging 的发布都需要通知到流式订阅的客户端，推送之后客户端的标签不能丢失
+	for _, version := range []uint64{2, 3} {
+		release := newTestRelease("default", "group", "file-1", version)
+		release.Metadata = map[string]string{utils.ConfigFileTagKeyClientSelector: "env=staging"}
+		wc.notifyToWatchers(release)
+		select {
+		case rsp := <-streamCtx.sendCh:
+			assert.Equal(t, version, rsp.GetConfigFile().GetVersion().GetValue())
+		case <-time.After(time.Second):
+			t.Fatalf("stream watcher should be notified of version %d", version)
+		}
+		assert.Equal(t, map[string]string{"env": "staging"}, streamCtx.ClientLabels())
+	}
+}
+
+func Benchmark_watchCenter_NotifyToWatchers(b *testing.B) {
+	for _, item := range []struct {
+		name        string
+		fastPathMax int
+	}{
+		{name: "fast-path", fastPathMax: defaultNotifyFastPathMax},
+		{name: "range", fastPathMax: 0},
+	} {
+		b.Run(item.name, func(b *testing.B) {
+			eventhub.InitEventHub()
+			ctrl := gomock.NewController(b)
+			defer ctrl.Finish()
+			wc, err := NewWatchCenter(mock.NewMockConfigFileCache(ctrl))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer wc.Close()
+			wc.notifyFastPathMax = item.fastPathMax
+
+			// 流式订阅的上下文不会在通知后被清理，客户端已经持有最新版本，因此只统计查找订阅者的开销
+			watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "hot-file", 1)}
+			for i := 0; i < 4; i++ {
+				if _, err := wc.AddWatcher(fmt.Sprintf("client-%d", i), watchFiles, BuildStreamWatchCtx(10)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			release := newTestRelease("default", "group", "hot-file", 1)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					wc.notifyToWatchers(release)
+				}
+			})
+		})
+	}
+}
+
+type testWatchFileStream struct {
+	ctx    context.Context
+	recvCh chan *apiconfig.ClientWatchConfigFileRequest
+	sendCh chan *apiconfig.ConfigClientResponse
+}
+
+func (s *testWatchFileStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testWatchFileStream) Send(rsp *apiconfig.ConfigClientResponse) error {
+	s.sendCh <- rsp
+	return nil
+}
+
+func (s *testWatchFileStream) Recv() (*apiconfig.ClientWatchConfigFileRequest, error) {
+	req, ok := <-s.recvCh
+	if !ok {
+		return nil, io.EOF
+	}
+	return req, nil
+}
+
+func newTestRelease(namespace, group, fileName string, version uint64) *model.SimpleConfigFileRelease {
+	return &model.SimpleConfigFileRelease{
+		ConfigFileReleaseKey: &model.ConfigFileReleaseKey{
+			Name:      "release",
+			Namespace: namespace,
+			Group:     group,
+			FileName:  fileName,
+		},
+		Version: version,
+		Valid:   true,
+		Active:  true,
+	}
+}
+
+func newTestRemoveWatchFile(namespace, group, fileName string) *apiconfig.ClientConfigFileInfo {
+	file := newTestWatchFile(namespace, group, fileName, 0)
+	file.Tags = append(file.Tags, &apiconfig.ConfigFileTag{
+		Key:   utils.NewStringValue(utils.ConfigFileTagKeyWatchAction),
+		Value: utils.NewStringValue(utils.ConfigFileWatchActionRemove),
+	})
+	return file
+}
+
+func Test_StreamWatchFile(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	stream := &testWatchFileStream{
+		ctx:    context.Background(),
+		recvCh: make(chan *apiconfig.ClientWatchConfigFileRequest),
+		sendCh: make(chan *apiconfig.ConfigClientResponse, 8),
+	}
+	finish := make(chan error, 1)
+	go func() {
+		finish <- svr.StreamWatchFile(stream.ctx, stream)
+	}()
+
+	watchedBy := func(fileName string) bool {
+		clientIds, ok := wc.watchers.Load(utils.GenFileId("default", "group", fileName))
+		return ok && clientIds.Len() == 1
+	}
+	expectPush := func(fileName string) {
+		select {
+		case rsp := <-stream.sendCh:
+			assert.Equal(t, fileName, rsp.GetConfigFile().GetFileName().GetValue())
+		case <-time.After(time.Second):
+			t.Fatalf("not receive notify of %s", fileName)
+		}
+	}
+	expectNoPush := func() {
+		select {
+		case rsp := <-stream.sendCh:
+			t.Fatalf("unexpected notify %+v", rsp)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// 订阅 file-1
+	stream.recvCh <- &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+	}
+	assert.Eventually(t, func() bool { return watchedBy("file-1") }, time.Second, 10*time.Millisecond)
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	expectPush("file-1")
+	// 同一个版本不会重复推送
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	expectNoPush()
+
+	// 只发送新增的 file-2，之前订阅的 file-1 仍然保留
+	stream.recvCh <- &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-2", 0)},
+	}
+	assert.Eventually(t, func() bool { return watchedBy("file-2") && watchedBy("file-1") },
+		time.Second, 10*time.Millisecond)
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 2))
+	expectPush("file-1")
+
+	// 取消 file-1
+	stream.recvCh <- &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestRemoveWatchFile("default", "group", "file-1")},
+	}
+	assert.Eventually(t, func() bool { return watchedBy("file-2") && !watchedBy("file-1") },
+		time.Second, 10*time.Millisecond)
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 3))
+	expectNoPush()
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-2", 1))
+	expectPush("file-2")
+	assert.Equal(t, 1, wc.clients.Len())
+
+	// 不合法的订阅只告知客户端，不影响已经建立的订阅
+	stream.recvCh <- &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "", "file-3", 0)},
+	}
+	select {
+	case rsp := <-stream.sendCh:
+		assert.Equal(t, uint32(apimodel.Code_InvalidWatchConfigFileFormat), rsp.GetCode().GetValue())
+	case <-time.After(time.Second):
+		t.Fatal("not receive invalid watch file response")
+	}
+	assert.True(t, watchedBy("file-2"))
+
+	// 服务端主动替换订阅列表时，客户端收到订阅被移除的通知，watchers 索引同步清理
+	var streamCtx WatchContext
+	wc.clients.Range(func(_ string, val WatchContext) { streamCtx = val })
+	replaced, err := wc.ReplaceInterests(streamCtx.ClientID(), nil)
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+	select {
+	case rsp := <-stream.sendCh:
+		assert.Equal(t, api.WatchInterestRemoved, rsp.GetCode().GetValue())
+		assert.Equal(t, "file-2", rsp.GetConfigFile().GetFileName().GetValue())
+	case <-time.After(time.Second):
+		t.Fatal("not receive interest removed notify")
+	}
+	assert.False(t, watchedBy("file-2"))
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-2", 2))
+	expectNoPush()
+
+	// 订阅上下文自己取消订阅时同步清理 watchers 索引
+	stream.recvCh <- &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-2", 0)},
+	}
+	assert.Eventually(t, func() bool { return watchedBy("file-2") }, time.Second, 10*time.Millisecond)
+	streamCtx.RemoveInterest(newTestWatchFile("default", "group", "file-2", 0))
+	assert.False(t, watchedBy("file-2"))
+	expectNoPush()
+
+	// 客户端关闭流后清理订阅者
+	close(stream.recvCh)
+	select {
+	case err := <-finish:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("stream watch not finish")
+	}
+	assert.Equal(t, 0, wc.clients.Len())
+	assert.False(t, watchedBy("file-2"))
+}
+
+func Test_StreamWatchContext_SlowClient(t *testing.T) {
+	watchCtx := BuildStreamWatchCtx(1)("client-1").(*StreamWatchContext)
+	watchCtx.AppendInterest(newTestWatchFile("default", "group", "file-1", 0))
+
+	rsp := &apiconfig.ConfigClientResponse{ConfigFile: newTestWatchFile("default", "group", "file-1", 1)}
+	watchCtx.Reply(rsp)
+	// 队列已满时 Reply 不能阻塞，并且会断开该客户端
+	done := make(chan struct{})
+	go func() {
+		watchCtx.Reply(rsp)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reply blocked by slow client")
+	}
+	select {
+	case <-watchCtx.closeCh:
+	default:
+		t.Fatal("slow client not closed")
+	}
+	assert.ErrorIs(t, watchCtx.err, ErrStreamSendQueueFull)
+}
+
+func Test_watchCenter_SelectWatchContextFactory(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	supported := []string{WatchProtocolStream, WatchProtocolLongPoll}
+
+	// 老版本客户端没有声明订阅能力，使用长轮询
+	watchCtx := wc.SelectWatchContextFactory(context.Background(), supported...)("client-1")
+	_, ok := watchCtx.(*LongPollWatchContext)
+	assert.True(t, ok)
+
+	// 新版本客户端通过 http header 声明支持流式订阅
+	ctx := context.WithValue(context.Background(), utils.ContextWatchProtocolKey, "stream, long-poll")
+	watchCtx = wc.SelectWatchContextFactory(ctx, supported...)("client-2")
+	_, ok = watchCtx.(*StreamWatchContext)
+	assert.True(t, ok)
+
+	// 新版本客户端通过 grpc header 声明支持流式订阅
+	ctx = context.WithValue(context.Background(), utils.ContextGrpcHeader,
+		metadata.Pairs(utils.HeaderWatchProtocolKey, WatchProtocolStream))
+	watchCtx = wc.SelectWatchContextFactory(ctx, supported...)("client-3")
+	_, ok = watchCtx.(*StreamWatchContext)
+	assert.True(t, ok)
+
+	// 入口只支持长轮询时，即使客户端支持流式订阅也只能使用长轮询
+	watchCtx = wc.SelectWatchContextFactory(ctx, WatchProtocolLongPoll)("client-4")
+	_, ok = watchCtx.(*LongPollWatchContext)
+	assert.True(t, ok)
+}
+
+// waitNotifyResults 并发等待所有客户端的通知结果，LongPollWatchContext 的通知是同步投递的，不能逐个等待
+func waitNotifyResults[K comparable](t *testing.T,
+	watchCtxs map[K]*LongPollWatchContext) map[K]*apiconfig.ConfigClientResponse {
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	ret := make(map[K]*apiconfig.ConfigClientResponse, len(watchCtxs))
+	for key, watchCtx := range watchCtxs {
+		wg.Add(1)
+		go func(key K, watchCtx *LongPollWatchContext) {
+			defer wg.Done()
+			rsp, err := watchCtx.GetNotifieResultWithTime(time.Second)
+			assert.NoError(t, err)
+			lock.Lock()
+			defer lock.Unlock()
+			ret[key] = rsp
+		}(key, watchCtx)
+	}
+	wg.Wait()
+	return ret
+}
+
+func Test_watchCenter_NotifyTransform(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	wc.SetNotifyTransform(func(watchCtx WatchContext, rsp *apiconfig.ConfigClientResponse) *apiconfig.ConfigClientResponse {
+		rsp.ConfigFile.Tags = append(rsp.ConfigFile.Tags, &apiconfig.ConfigFileTag{
+			Key:   utils.NewStringValue("client"),
+			Value: utils.NewStringValue(watchCtx.ClientID()),
+		})
+		return rsp
+	})
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	watchCtxs := map[string]*LongPollWatchContext{}
+	for _, clientId := range []string{"client-1", "client-2"} {
+		watchCtxs[clientId] = mustAddWatcher(t, wc, clientId, watchFiles,
+			BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	}
+	go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+
+	for clientId, rsp := range waitNotifyResults(t, watchCtxs) {
+		// 每个客户端只能看到属于自己的加工结果
+		tags := map[string]string{}
+		for _, tag := range rsp.GetConfigFile().GetTags() {
+			tags[tag.GetKey().GetValue()] = tag.GetValue().GetValue()
+		}
+		assert.Equal(t, clientId, tags["client"])
+	}
+}
+
+func Test_watchCenter_NotifyPreviousVersion(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	watchCtxs := map[uint64]*LongPollWatchContext{}
+	for _, version := range []uint64{1, 3} {
+		clientId := fmt.Sprintf("client-%d", version)
+		watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", version)}
+		watchCtxs[version] = mustAddWatcher(t, wc, clientId, watchFiles,
+			BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	}
+	go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 5))
+
+	for version, rsp := range waitNotifyResults(t, watchCtxs) {
+		// 通知中同时携带新版本以及客户端自己变更前持有的版本
+		assert.Equal(t, uint64(5), rsp.GetConfigFile().GetVersion().GetValue())
+		tags := map[string]string{}
+		for _, tag := range rsp.GetConfigFile().GetTags() {
+			tags[tag.GetKey().GetValue()] = tag.GetValue().GetValue()
+		}
+		assert.Equal(t, 2, len(tags))
+		assert.Equal(t, strconv.FormatUint(version, 10), tags[utils.ConfigFileTagKeyPreviousVersion])
+		assert.Equal(t, watchSessionID(watchCtxs[version]), tags[utils.ConfigFileTagKeyWatchSessionId])
+	}
+}
+
+func Test_watchCenter_DataKeyRotated(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 1)}
+	watchCtx := mustAddWatcher(t, wc, "client-1", watchFiles,
+		BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+
+	// 版本没有变化的普通发布不会通知客户端
+	release := newTestRelease("default", "group", "file-1", 1)
+	assert.False(t, watchCtx.ShouldNotify(release))
+
+	// 数据密钥轮转时即使版本没有变化也需要通知客户端
+	release.DataKeyRotated = true
+	go wc.notifyToWatchers(release)
+	rsp, err := watchCtx.GetNotifieResultWithTime(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+}
+
+func Test_watchCenter_Rollback(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	watchCtx := mustAddWatcher(t, wc, "client-1", watchFiles, BuildStreamWatchCtx(8)).(*StreamWatchContext)
+
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 5))
+	rsp := <-watchCtx.sendCh
+	assert.Equal(t, uint64(5), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 版本更旧的普通发布是过期的消息，不会通知客户端
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 3))
+	assert.Equal(t, 0, len(watchCtx.sendCh))
+
+	// 回滚到更旧的版本时需要通知客户端
+	rollback := newTestRelease("default", "group", "file-1", 3)
+	rollback.Rollback = true
+	wc.notifyToWatchers(rollback)
+	assert.Equal(t, 1, len(watchCtx.sendCh))
+	rsp = <-watchCtx.sendCh
+	assert.Equal(t, uint64(3), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 客户端已经收到了回滚后的版本，重复的回滚消息不再通知
+	wc.notifyToWatchers(rollback)
+	assert.Equal(t, 0, len(watchCtx.sendCh))
+	// 回滚之后的新发布正常通知
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 4))
+	rsp = <-watchCtx.sendCh
+	assert.Equal(t, uint64(4), rsp.GetConfigFile().GetVersion().GetValue())
+}
+
+func Test_watchCenter_LastPublishTime(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	_, ok := wc.GetLastPublishTime("default", "group", "file-1")
+	assert.False(t, ok)
+
+	// 没有订阅者的配置文件同样记录发布时间
+	before := time.Now()
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	first, ok := wc.GetLastPublishTime("default", "group", "file-1")
+	assert.True(t, ok)
+	assert.False(t, first.Before(before))
+
+	time.Sleep(10 * time.Millisecond)
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 2))
+	second, ok := wc.GetLastPublishTime("default", "group", "file-1")
+	assert.True(t, ok)
+	assert.True(t, second.After(first))
+
+	// 配置文件删除之后不再记录
+	deleted := newTestRelease("default", "group", "file-1", 3)
+	deleted.Valid = false
+	wc.notifyToWatchers(deleted)
+	_, ok = wc.GetLastPublishTime("default", "group", "file-1")
+	assert.False(t, ok)
+}
+
+func Test_watchCenter_OnEventDispatch(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	streamCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+	}, BuildStreamWatchCtx(8)).(*StreamWatchContext)
+	mustAddWatcher(t, wc, "client-2", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("deleted", "group", "file-1", 0),
+	}, BuildStreamWatchCtx(8))
+
+	// 配置发布事件通知订阅者
+	assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{
+		Message: newTestRelease("default", "group", "file-1", 1),
+	}))
+	rsp := <-streamCtx.sendCh
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 命名空间删除事件清理该命名空间下的订阅者
+	assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.CacheNamespaceEvent{
+		Item:      &model.Namespace{Name: "deleted"},
+		EventType: eventhub.EventDeleted,
+	}))
+	_, ok := wc.clients.Load("client-2")
+	assert.False(t, ok)
+	_, ok = wc.clients.Load("client-1")
+	assert.True(t, ok)
+
+	// 配置分组删除事件通知并清理该分组下的订阅，其他分组以及更新事件不受影响
+	groupCtx := mustAddWatcher(t, wc, "client-3", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "deleted-group", "file-1", 0),
+		newTestWatchFile("default", "group", "file-2", 0),
+	}, BuildStreamWatchCtx(8)).(*StreamWatchContext)
+	assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.CacheConfigGroupEvent{
+		Item:      &model.ConfigFileGroup{Namespace: "default", Name: "group"},
+		EventType: eventhub.EventUpdated,
+	}))
+	assert.Equal(t, 0, len(groupCtx.sendCh))
+	assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.CacheConfigGroupEvent{
+		Item:      &model.ConfigFileGroup{Namespace: "default", Name: "deleted-group"},
+		EventType: eventhub.EventDeleted,
+	}))
+	rsp = <-groupCtx.sendCh
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+	assert.Equal(t, "deleted-group", rsp.GetConfigFile().GetGroup().GetValue())
+	_, ok = wc.watchers.Load(utils.GenFileId("default", "deleted-group", "file-1"))
+	assert.False(t, ok)
+	assert.Equal(t, 1, len(groupCtx.ListWatchFiles()))
+	_, ok = wc.clients.Load("client-3")
+	assert.True(t, ok)
+
+	// 未知的事件类型被忽略
+	assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.CacheInstanceEvent{}))
+	assert.NoError(t, wc.OnEvent(context.Background(), nil))
+	_, ok = wc.clients.Load("client-1")
+	assert.True(t, ok)
+}
+
+// failCloseWatchContext 关闭时返回错误的订阅上下文
+type failCloseWatchContext struct {
+	*StreamWatchContext
+}
+
+func (c *failCloseWatchContext) Close() error {
+	_ = c.StreamWatchContext.Close()
+	return errors.New("close stream fail")
+}
+
+func Test_watchCenter_RemoveAllWatcherCloseError(t *testing.T) {
+	metrics.InitMetrics()
+	wc, _ := newTestWatchCenter(t)
+	failures := testutil.ToFloat64(metrics.GetConfigWatcherCleanupFailureTotal())
+
+	mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+	}, func(clientId string) WatchContext {
+		return &failCloseWatchContext{StreamWatchContext: BuildStreamWatchCtx(8)(clientId).(*StreamWatchContext)}
+	})
+
+	// 关闭失败的错误返回给调用方，订阅关系仍然被清理
+	err := wc.RemoveAllWatcher("client-1")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "close stream fail")
+	assert.Contains(t, err.Error(), "client-1")
+	_, ok := wc.clients.Load("client-1")
+	assert.False(t, ok)
+	clientIds, ok := wc.watchers.Load(utils.GenFileId("default", "group", "file-1"))
+	assert.True(t, ok)
+	assert.False(t, clientIds.Contains("client-1"))
+	assert.Equal(t, failures+1, testutil.ToFloat64(metrics.GetConfigWatcherCleanupFailureTotal()))
+
+	// 订阅者不存在时没有错误
+	assert.NoError(t, wc.RemoveAllWatcher("client-1"))
+	mustAddWatcher(t, wc, "client-2", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+	}, BuildStreamWatchCtx(8))
+	assert.NoError(t, wc.RemoveAllWatcher("client-2"))
+}
+
+func Test_LongPollWatchContext_GetNotifieResultWithContext(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	callback, err := svr.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, wc.clients.Len())
+
+	ret := make(chan *apiconfig.ConfigClientResponse, 1)
+	go func() {
+		ret <- callback()
+	}()
+	// 客户端断开后等待方需要立即返回，并且清理掉订阅者
+	cancel()
+	select {
+	case rsp := <-ret:
+		assert.Equal(t, uint32(apimodel.Code_DataNoChange), rsp.GetCode().GetValue())
+	case <-time.After(time.Second):
+		t.Fatal("watch callback not return after context cancel")
+	}
+	assert.Equal(t, 0, wc.clients.Len())
+
+	// 等待方已经返回，之后的通知不能阻塞通知协程
+	watchCtx := BuildTimeoutWatchCtx(time.Minute)("client-1")
+	replied := make(chan struct{})
+	go func() {
+		defer close(replied)
+		watchCtx.Reply(notModifiedResponse)
+	}()
+	select {
+	case <-replied:
+	case <-time.After(time.Second):
+		t.Fatal("reply blocked after watch callback returned")
+	}
+}
+
+func Test_LongPullWatchFile_ReconcileAfterRestart(t *testing.T) {
+	// 模拟服务端重启：新的订阅中心没有任何订阅状态，客户端携带自己最后一次收到的版本重新订阅
+	wc, fileCache := newTestWatchCenter(t)
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 1)}
+
+	// 首次检查时还是旧版本，检查之后、订阅注册之前发布了新版本，发布事件没有任何订阅者可以通知
+	var calls atomic.Int32
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").DoAndReturn(
+		func(_, _, _ string) *model.ConfigFileRelease {
+			version := uint64(1)
+			if calls.Add(1) > 1 {
+				version = 2
+			}
+			return &model.ConfigFileRelease{SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", version)}
+		}).AnyTimes()
+
+	ctx := context.WithValue(context.Background(), utils.ContextClientIdKey, "client-a")
+	callback, err := svr.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{WatchFiles: watchFiles})
+	assert.NoError(t, err)
+	// 重新注册订阅后立即补偿错过的版本，并且清理掉订阅上下文，后续的发布事件不会重复通知
+	assert.Equal(t, 0, wc.clients.Len())
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 2))
+	rsp := callback()
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(2), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 客户端已经持有最新的版本，重新订阅后不会再被通知
+	watchCtx, cancel := context.WithCancel(ctx)
+	callback, err = svr.LongPullWatchFile(watchCtx, &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 2)},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, wc.clients.Len())
+	cancel()
+	assert.Equal(t, uint32(apimodel.Code_DataNoChange), callback().GetCode().GetValue())
+}
+
+func Test_LongPullWatchFile_CallbackBounded(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	// 停掉超时清理任务，模拟清理任务没有按时执行的场景
+	wc.cancel()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	timeout := 100 * time.Millisecond
+	ctx := context.WithValue(context.Background(), utils.WatchTimeoutCtx{}, timeout)
+	callback, err := svr.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)},
+	})
+	assert.NoError(t, err)
+
+	start := time.Now()
+	ret := make(chan *apiconfig.ConfigClientResponse, 1)
+	go func() {
+		ret <- callback()
+	}()
+	// 没有任何发布，调用方也没有设置超时，等待时间仍然不会超过长轮询超时时间加上最大延迟
+	select {
+	case rsp := <-ret:
+		assert.Equal(t, uint32(apimodel.Code_DataNoChange), rsp.GetCode().GetValue())
+		assert.Less(t, time.Since(start), timeout+maxWatchCallbackDelay+time.Second)
+	case <-time.After(timeout + maxWatchCallbackDelay + 2*time.Second):
+		t.Fatal("watch callback not return after long poll timeout")
+	}
+	assert.Equal(t, 0, wc.clients.Len())
+}
+
+func Test_watchCenter_ExpireJitter(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	timeout := 30 * time.Second
+	ctx := context.WithValue(context.Background(), utils.WatchTimeoutCtx{}, timeout)
+
+	start := time.Now()
+	factory := wc.SelectWatchContextFactory(ctx, WatchProtocolLongPoll)
+	finishTimes := map[time.Time]struct{}{}
+	for i := 0; i < 100; i++ {
+		watchCtx := factory(fmt.Sprintf("client-%d", i)).(*LongPollWatchContext)
+		// 超时时间只会提前，不会超过客户端要求的超时时间
+		jitterLow := start.Add(timeout - time.Duration(float64(timeout)*defaultExpireJitterRatio))
+		assert.False(t, watchCtx.finishTime.Before(jitterLow))
+		assert.False(t, watchCtx.finishTime.After(time.Now().Add(timeout)))
+		assert.False(t, watchCtx.ShouldExpire(jitterLow.Add(-time.Second)))
+		assert.True(t, watchCtx.ShouldExpire(time.Now().Add(timeout+time.Second)))
+		finishTimes[watchCtx.finishTime] = struct{}{}
+	}
+	// 同一批建立的长轮询超时时间被打散
+	assert.Greater(t, len(finishTimes), 90)
+}
+
+func Test_watchCenter_NotifyLogFields(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer log.TestCapture(core)()
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	watchCtx := mustAddWatcher(t, wc, "client-1", watchFiles,
+		BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	_, err := watchCtx.GetNotifieResultWithTime(time.Second)
+	assert.NoError(t, err)
+
+	entries := logs.FilterField(zap.String("action", watchActionNotify)).All()
+	assert.Equal(t, 1, len(entries))
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "client-1", fields["clientId"])
+	assert.Equal(t, "default", fields["namespace"])
+	assert.Equal(t, "group", fields["group"])
+	assert.Equal(t, "file-1", fields["fileName"])
+}
+
+func Test_watchCenter_WatchSession(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	defer log.TestCapture(core)()
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	watchCtx := mustAddWatcher(t, wc, "client-1", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	sessionId := watchSessionID(watchCtx)
+	assert.NotEmpty(t, sessionId)
+	// 同一个订阅上下文的会话 ID 保持不变
+	assert.Equal(t, sessionId, watchSessionID(watchCtx))
+
+	go wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	rsp, err := watchCtx.(*LongPollWatchContext).GetNotifieResultWithTime(time.Second)
+	assert.NoError(t, err)
+	var tagged string
+	for _, tag := range rsp.GetConfigFile().GetTags() {
+		if tag.GetKey().GetValue() == utils.ConfigFileTagKeyWatchSessionId {
+			tagged = tag.GetValue().GetValue()
+		}
+	}
+	assert.Equal(t, sessionId, tagged)
+
+	entries := logs.FilterField(zap.String("action", watchActionNotify)).All()
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, sessionId, entries[0].ContextMap()["sessionId"])
+
+	// 没有开启会话恢复时重新订阅生成新的会话 ID
+	assert.NoError(t, wc.RemoveAllWatcher("client-1"))
+	renewed := mustAddWatcher(t, wc, "client-1", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.NotEqual(t, sessionId, watchSessionID(renewed))
+
+	// 开启会话恢复后，恢复窗口内重新订阅沿用之前的会话 ID
+	wc.sessionResumeWindow = time.Minute
+	assert.NoError(t, wc.RemoveAllWatcher("client-1"))
+	first := mustAddWatcher(t, wc, "client-1", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.NoError(t, wc.RemoveAllWatcher("client-1"))
+	resumed := mustAddWatcher(t, wc, "client-1", watchFiles, BuildStreamWatchCtx(10))
+	assert.Equal(t, watchSessionID(first), watchSessionID(resumed))
+
+	// 超过恢复窗口的会话记录被清理后生成新的会话 ID
+	assert.NoError(t, wc.RemoveAllWatcher("client-1"))
+	wc.cleanExpiredSessions(time.Now().Add(2 * time.Minute))
+	expired := mustAddWatcher(t, wc, "client-1", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.NotEqual(t, watchSessionID(first), watchSessionID(expired))
+}
+
+func Test_watchCenter_PreviewNotify(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	release := newTestRelease("default", "group", "file-1", 2)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: release,
+	}).AnyTimes()
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-2").Return(nil).AnyTimes()
+
+	watchCtxs := map[string]*StreamWatchContext{}
+	for clientId, file := range map[string]*apiconfig.ClientConfigFileInfo{
+		"client-0": newTestWatchFile("default", "group", "file-1", 0),
+		"client-1": newTestWatchFile("default", "group", "file-1", 1),
+		"client-2": newTestWatchFile("default", "group", "file-1", 2),
+		"client-3": newTestWatchFile("default", "group", "file-1", 3),
+		"client-4": newTestWatchFile("default", "group", "file-2", 0),
+	} {
+		watchCtxs[clientId] = mustAddWatcher(t, wc, clientId, []*apiconfig.ClientConfigFileInfo{file},
+			BuildStreamWatchCtx(10)).(*StreamWatchContext)
+	}
+
+	count, clientIds := wc.PreviewNotify("default", "group", "file-1")
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"client-0", "client-1"}, clientIds)
+	// 预估不会发送任何通知
+	for _, watchCtx := range watchCtxs {
+		assert.Equal(t, 0, len(watchCtx.sendCh))
+	}
+
+	// 预估的结果和实际通知的客户端一致
+	wc.notifyToWatchers(release)
+	notified := make([]string, 0, len(watchCtxs))
+	for clientId, watchCtx := range watchCtxs {
+		if len(watchCtx.sendCh) != 0 {
+			notified = append(notified, clientId)
+		}
+	}
+	sort.Strings(notified)
+	assert.Equal(t, clientIds, notified)
+
+	// 没有生效的发布时不会通知任何客户端
+	count, clientIds = wc.PreviewNotify("default", "group", "file-2")
+	assert.Equal(t, 0, count)
+	assert.Empty(t, clientIds)
+}
+
+func Test_watchCenter_ForceNotify(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 3),
+	}).AnyTimes()
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-2").Return(nil).AnyTimes()
+
+	// 客户端已经持有最新的版本，正常的发布通知不会推送
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 3)}
+	watchCtxs := map[string]*LongPollWatchContext{}
+	for _, clientId := range []string{"client-1", "client-2", "client-3"} {
+		watchCtxs[clientId] = mustAddWatcher(t, wc, clientId, watchFiles,
+			BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	}
+	// 没有生效的配置发布时不做任何处理
+	assert.False(t, wc.ForceNotify("default", "group", "file-2"))
+	assert.Equal(t, 3, wc.clients.Len())
+
+	go wc.ForceNotify("default", "group", "file-1")
+	for _, rsp := range waitNotifyResults(t, watchCtxs) {
+		assert.Equal(t, uint64(3), rsp.GetConfigFile().GetVersion().GetValue())
+	}
+	// 长轮询的客户端通知完之后就会被清理
+	assert.Eventually(t, func() bool {
+		return wc.clients.Len() == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func Test_Server_ForceNotifyConfigFile(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 3),
+	}).AnyTimes()
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-2").Return(nil).AnyTimes()
+	svr := &Server{watchCenter: wc}
+
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 3),
+	}, BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+
+	rsp := svr.ForceNotifyConfigFile(context.Background(), &apiconfig.ConfigFileRelease{
+		Namespace: utils.NewStringValue("default"),
+		FileName:  utils.NewStringValue("file-1"),
+	})
+	assert.Equal(t, uint32(apimodel.Code_BadRequest), rsp.GetCode().GetValue())
+
+	rsp = svr.ForceNotifyConfigFile(context.Background(), &apiconfig.ConfigFileRelease{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue("file-2"),
+	})
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+
+	go svr.ForceNotifyConfigFile(context.Background(), &apiconfig.ConfigFileRelease{
+		Namespace: utils.NewStringValue("default"),
+		Group:     utils.NewStringValue("group"),
+		FileName:  utils.NewStringValue("file-1"),
+	})
+	notifyRsp := waitNotifyResults(t, map[string]*LongPollWatchContext{"client-1": watchCtx})
+	assert.Equal(t, uint64(3), notifyRsp["client-1"].GetConfigFile().GetVersion().GetValue())
+}
+
+func Test_watchCenter_UpdateWatcher(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	const publishCount = 200
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+		newTestWatchFile("default", "group", "file-2", 0),
+	}, BuildStreamWatchCtx(publishCount*2)).(*StreamWatchContext)
+
+	// 客户端不断地重新订阅，file-1 一直保留在订阅列表中
+	stop := make(chan struct{})
+	updateDone := make(chan struct{})
+	go func() {
+		defer close(updateDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			other := newTestWatchFile("default", "group", fmt.Sprintf("file-%d", 2+i%2), 0)
+			_, _ = wc.UpdateWatcher("client-1", []*apiconfig.ClientConfigFileInfo{
+				newTestWatchFile("default", "group", "file-1", 0), other,
+			})
+		}
+	}()
+	for i := 1; i <= publishCount; i++ {
+		wc.notifyToWatchers(newTestRelease("default", "group", "file-1", uint64(i)))
+	}
+	close(stop)
+	<-updateDone
+
+	received := 0
+	for len(watchCtx.sendCh) > 0 {
+		rsp := <-watchCtx.sendCh
+		if rsp.GetConfigFile().GetFileName().GetValue() == "file-1" {
+			received++
+		}
+	}
+	// 重新订阅的过程中保留的订阅不会漏掉任何一次通知
+	assert.Equal(t, publishCount, received)
+
+	// 订阅关系按照最后一次的订阅列表增量更新
+	added, err := wc.UpdateWatcher("client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+		newTestWatchFile("default", "group", "file-4", 0),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(added))
+	assert.Equal(t, "file-4", added[0].GetFileName().GetValue())
+	assert.Equal(t, 2, len(watchCtx.ListWatchFiles()))
+	for _, fileName := range []string{"file-2", "file-3"} {
+		if clientIds, ok := wc.watchers.Load(utils.GenFileId("default", "group", fileName)); ok {
+			assert.False(t, clientIds.Contains("client-1"))
+		}
+	}
+	added, err = wc.UpdateWatcher("client-2", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, added)
+}
+
+func Test_watchCenter_ReplaceInterests(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 1),
+		newTestWatchFile("default", "group", "file-2", 1),
+		newTestWatchFile("default", "group", "file-3", 1),
+	}, BuildStreamWatchCtx(8))
+
+	replaced, err := wc.ReplaceInterests("client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-3", 5),
+	})
+	assert.NoError(t, err)
+	assert.True(t, replaced)
+	// 订阅上下文保留，订阅列表以及 watchers 索引只包含新的文件
+	current, ok := wc.clients.Load("client-1")
+	assert.True(t, ok)
+	assert.Same(t, watchCtx, current)
+	files := watchCtx.ListWatchFiles()
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, "file-3", files[0].GetFileName().GetValue())
+	assert.Equal(t, uint64(5), files[0].GetVersion().GetValue())
+	for _, fileName := range []string{"file-1", "file-2"} {
+		if clientIds, ok := wc.watchers.Load(utils.GenFileId("default", "group", fileName)); ok {
+			assert.False(t, clientIds.Contains("client-1"), fileName)
+		}
+	}
+	clientIds, ok := wc.watchers.Load(utils.GenFileId("default", "group", "file-3"))
+	assert.True(t, ok)
+	assert.True(t, clientIds.Contains("client-1"))
+	// 服务端移除的订阅需要显式告知流式订阅的客户端
+	removed := []string{}
+	for len(watchCtx.(*StreamWatchContext).sendCh) > 0 {
+		rsp := <-watchCtx.(*StreamWatchContext).sendCh
+		assert.Equal(t, api.WatchInterestRemoved, rsp.GetCode().GetValue())
+		removed = append(removed, rsp.GetConfigFile().GetFileName().GetValue())
+	}
+	assert.ElementsMatch(t, []string{"file-1", "file-2"}, removed)
+
+	replaced, err = wc.ReplaceInterests("client-2", nil)
+	assert.NoError(t, err)
+	assert.False(t, replaced)
+}
+
+func Test_watchCenter_InvalidWatchFile(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetGroupActiveReleases("default", "group").Return(nil, "").AnyTimes()
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+		newTestWatchFile("default", "", "file-2", 0),
+	}
+	_, err := wc.AddWatcher("client-1", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidWatchFile)
+	// 订阅被整体拒绝，不会注册订阅上下文以及任何索引
+	_, ok := wc.clients.Load("client-1")
+	assert.False(t, ok)
+	assert.Equal(t, 0, wc.watchers.Len())
+
+	_, err = wc.AddWatcher("client-1", []*apiconfig.ClientConfigFileInfo{newTestWatchFile("", "group", "", 0)},
+		BuildTimeoutWatchCtx(time.Minute))
+	assert.ErrorIs(t, err, ErrInvalidWatchFile)
+
+	// 文件名为空表示订阅整个分组
+	watchCtx := mustAddWatcher(t, wc, "client-1",
+		[]*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "", 0)}, BuildTimeoutWatchCtx(time.Minute))
+
+	// 更新以及替换订阅列表时同样拒绝不合法的订阅，原有的订阅保持不变
+	_, err = wc.UpdateWatcher("client-1", watchFiles)
+	assert.ErrorIs(t, err, ErrInvalidWatchFile)
+	_, err = wc.ReplaceInterests("client-1", watchFiles)
+	assert.ErrorIs(t, err, ErrInvalidWatchFile)
+	assert.Len(t, watchCtx.ListWatchFiles(), 1)
+	_, ok = wc.watchers.Load(utils.GenFileId("default", "", "file-2"))
+	assert.False(t, ok)
+}
+
+func Test_LongPollWatchFile_InvalidWatchFile(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	callback, err := svr.LongPullWatchFile(context.Background(), &apiconfig.ClientWatchConfigFileRequest{
+		WatchFiles: []*apiconfig.ClientConfigFileInfo{
+			newTestWatchFile("default", "group", "file-1", 0),
+			newTestWatchFile("default", "", "file-2", 0),
+		},
+	})
+	assert.NoError(t, err)
+	// 不合法的订阅返回订阅格式错误，而不是笼统的请求错误
+	assert.Equal(t, uint32(apimodel.Code_InvalidWatchConfigFileFormat), callback().GetCode().GetValue())
+}
+
+func Test_watchCenter_MaxWatchers(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	wc.maxWatchers = 3
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+	for i := 0; i < wc.maxWatchers; i++ {
+		mustAddWatcher(t, wc, fmt.Sprintf("client-%d", i), watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	}
+	// 已经存在的订阅者更新订阅不受上限影响
+	_, err := wc.AddWatcher("client-0", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.NoError(t, err)
+	_, err = wc.AddWatcher("client-overflow", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.ErrorIs(t, err, ErrTooManyWatchers)
+	assert.Equal(t, wc.maxWatchers, wc.clients.Len())
+
+	// 超过上限的长轮询请求立即返回繁忙，而不是被 hold 住排队
+	for i := 0; i < 10; i++ {
+		callback, err := svr.LongPullWatchFile(context.Background(), &apiconfig.ClientWatchConfigFileRequest{
+			WatchFiles: watchFiles,
+		})
+		assert.NoError(t, err)
+		done := make(chan *apiconfig.ConfigClientResponse, 1)
+		go func() {
+			done <- callback()
+		}()
+		select {
+		case rsp := <-done:
+			assert.Equal(t, api.ServiceTooBusy, rsp.GetCode().GetValue())
+		case <-time.After(time.Second):
+			t.Fatal("overflow watcher should be rejected immediately")
+		}
+	}
+	assert.Equal(t, wc.maxWatchers, wc.clients.Len())
+
+	// 有订阅者退出后，新的订阅者可以正常加入
+	wc.RemoveAllWatcher("client-1")
+	_, err = wc.AddWatcher("client-overflow", watchFiles, BuildTimeoutWatchCtx(time.Minute))
+	assert.NoError(t, err)
+}
+
+func Test_watchCenter_GetActiveReleaseHistory(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	var releases []*model.SimpleConfigFileRelease
+	for _, version := range []uint64{3, 1, 5, 2, 4} {
+		releases = append(releases, newTestRelease("default", "group", "file-1", version))
+	}
+	// 通配符匹配到的其他文件的发布记录不能返回
+	releases = append(releases, newTestRelease("default", "group", "file-10", 10))
+	fileCache.EXPECT().QueryReleases(gomock.Any()).Return(uint32(len(releases)), releases, nil).AnyTimes()
+
+	watchFile := newTestWatchFile("default", "group", "file-1", 0)
+	mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{watchFile}, BuildTimeoutWatchCtx(time.Minute))
+
+	history, err := wc.GetActiveReleaseHistory("client-1", watchFile, 0)
+	assert.NoError(t, err)
+	versions := make([]uint64, 0, len(history))
+	for _, item := range history {
+		versions = append(versions, item.Version)
+	}
+	assert.Equal(t, []uint64{5, 4, 3, 2, 1}, versions)
+
+	history, err = wc.GetActiveReleaseHistory("client-1", watchFile, 2)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, uint64(5), history[0].Version)
+
+	// 只能查询自己已经订阅的配置文件
+	_, err = wc.GetActiveReleaseHistory("client-1", newTestWatchFile("default", "group", "file-2", 0), 0)
+	assert.ErrorIs(t, err, ErrNotWatchedFile)
+	_, err = wc.GetActiveReleaseHistory("client-2", watchFile, 0)
+	assert.ErrorIs(t, err, ErrNotWatchedFile)
+}
+
+func Test_Server_GetConfigFileReleaseHistoryForClient(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	releases := []*model.SimpleConfigFileRelease{
+		newTestRelease("default", "group", "file-1", 1),
+		newTestRelease("default", "group", "file-1", 3),
+		newTestRelease("default", "group", "file-1", 2),
+	}
+	fileCache.EXPECT().QueryReleases(gomock.Any()).Return(uint32(len(releases)), releases, nil).AnyTimes()
+	svr := &Server{watchCenter: wc}
+
+	watchFile := newTestWatchFile("default", "group", "file-1", 0)
+	ctx := context.WithValue(context.Background(), utils.ContextClientIdKey, "client-1")
+	mustAddWatcher(t, wc, wc.declaredClientId(ctx), []*apiconfig.ClientConfigFileInfo{watchFile},
+		BuildTimeoutWatchCtx(time.Minute))
+
+	rsp := svr.GetConfigFileReleaseHistoryForClient(ctx, watchFile)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	versions := make([]uint64, 0, len(rsp.GetConfigFileInfos()))
+	for _, item := range rsp.GetConfigFileInfos() {
+		versions = append(versions, item.GetVersion().GetValue())
+	}
+	assert.Equal(t, []uint64{3, 2, 1}, versions)
+
+	// 没有声明客户端 ID 时找不到对应的订阅上下文
+	rsp = svr.GetConfigFileReleaseHistoryForClient(context.Background(), watchFile)
+	assert.Equal(t, uint32(apimodel.Code_BadRequest), rsp.GetCode().GetValue())
+	// 没有订阅的配置文件不允许查询
+	rsp = svr.GetConfigFileReleaseHistoryForClient(ctx, newTestWatchFile("default", "group", "file-2", 0))
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+}
+
+func Test_watchCenter_GroupWatcher(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetGroupActiveReleases("default", "group").Return([]*model.ConfigFileRelease{
+		{SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 1)},
+	}, "revision").AnyTimes()
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	groupWatchFile := newTestWatchFile("default", "group", "", 0)
+	streamCtx := mustAddWatcher(t, wc, "client-stream", []*apiconfig.ClientConfigFileInfo{groupWatchFile},
+		BuildStreamWatchCtx(10)).(*StreamWatchContext)
+	// 只订阅分组时没有可以立即响应的版本，需要 hold 住等待分组的变化
+	assert.Nil(t, wc.checkQuickResponseClient(streamCtx))
+
+	publish := func(release *model.SimpleConfigFileRelease) {
+		assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{Message: release}))
+	}
+	receive := func() *apiconfig.ConfigClientResponse {
+		select {
+		case rsp := <-streamCtx.sendCh:
+			return rsp
+		case <-time.After(time.Second):
+			t.Fatal("group watcher should be notified")
+			return nil
+		}
+	}
+
+	// 已经存在的配置文件再次发布，分组订阅者不会收到通知
+	publish(newTestRelease("default", "group", "file-1", 2))
+	// 其他分组的配置文件新增不影响
+	publish(newTestRelease("default", "other-group", "file-2", 1))
+	assert.Len(t, streamCtx.sendCh, 0)
+
+	// 新增配置文件
+	publish(newTestRelease("default", "group", "file-2", 1))
+	rsp := receive()
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, "file-2", rsp.GetConfigFile().GetFileName().GetValue())
+	// 同一个配置文件的后续发布不再作为新增通知
+	publish(newTestRelease("default", "group", "file-2", 2))
+	assert.Len(t, streamCtx.sendCh, 0)
+
+	// 删除配置文件
+	deleted := newTestRelease("default", "group", "file-1", 3)
+	deleted.Valid = false
+	publish(deleted)
+	rsp = receive()
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+	assert.Equal(t, "file-1", rsp.GetConfigFile().GetFileName().GetValue())
+	publish(deleted)
+	assert.Len(t, streamCtx.sendCh, 0)
+
+	// 长轮询订阅分组时同样可以收到新增配置文件的通知
+	pollCtx := mustAddWatcher(t, wc, "client-poll", []*apiconfig.ClientConfigFileInfo{groupWatchFile},
+		BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	go publish(newTestRelease("default", "group", "file-3", 1))
+	rsp, err := pollCtx.GetNotifieResultWithTime(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, "file-3", rsp.GetConfigFile().GetFileName().GetValue())
+	assert.Equal(t, "file-3", receive().GetConfigFile().GetFileName().GetValue())
+}
+
+func Test_watchCenter_GroupWatcherFileNames(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetGroupActiveReleases("default", "group").Return([]*model.ConfigFileRelease{
+		{SimpleConfigFileRelease: newTestRelease("default", "group", "file-1", 1)},
+	}, "revision").AnyTimes()
+
+	streamCtx := mustAddWatcher(t, wc, "client-stream",
+		[]*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "", 0)},
+		BuildStreamWatchCtx(10)).(*StreamWatchContext)
+	publish := func(release *model.SimpleConfigFileRelease) []string {
+		assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{Message: release}))
+		select {
+		case rsp := <-streamCtx.sendCh:
+			var names []string
+			for _, tag := range rsp.GetConfigFile().GetTags() {
+				if tag.GetKey().GetValue() == utils.ConfigFileTagKeyGroupFileName {
+					names = append(names, tag.GetValue().GetValue())
+				}
+			}
+			return names
+		case <-time.After(time.Second):
+			t.Fatal("group watcher should be notified")
+			return nil
+		}
+	}
+
+	// 新增配置文件后通知分组下新的配置文件列表
+	assert.Equal(t, []string{"file-0", "file-1"}, publish(newTestRelease("default", "group", "file-0", 1)))
+	deleted := newTestRelease("default", "group", "file-1", 2)
+	deleted.Valid = false
+	assert.Equal(t, []string{"file-0"}, publish(deleted))
+}
+
+func Test_watchCenter_ParseClientId(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+
+	// HTTP 请求头以及 gRPC metadata 中声明的同一个客户端 ID 解析结果一致
+	httpCtx := context.WithValue(context.Background(), utils.ContextClientIdKey, "client-a")
+	grpcCtx := context.WithValue(context.Background(), utils.ContextGrpcHeader,
+		metadata.Pairs(utils.HeaderClientIdKey, "client-a"))
+	assert.Equal(t, "client-a", wc.ParseClientId(httpCtx))
+	assert.Equal(t, "client-a", wc.ParseClientId(grpcCtx))
+
+	// 没有声明时每次请求都生成新的 ID
+	assert.NotEqual(t, wc.ParseClientId(context.Background()), wc.ParseClientId(context.Background()))
+
+	wc.SetClientIdExtractor(func(ctx context.Context) string {
+		return "custom"
+	})
+	assert.Equal(t, "custom", wc.ParseClientId(httpCtx))
+	wc.SetClientIdExtractor(nil)
+	assert.Equal(t, "client-a", wc.ParseClientId(grpcCtx))
+
+	// 客户端声明的 ID 按照客户端 IP 以及鉴权后的操作者区分，同一个客户端使用不同的端口时解析结果一致
+	withAddress := func(ctx context.Context, address string) context.Context {
+		return context.WithValue(ctx, utils.ContextClientAddress, address)
+	}
+	assert.Equal(t, "client-a@10.0.0.1", wc.ParseClientId(withAddress(httpCtx, "10.0.0.1:5000")))
+	assert.Equal(t, "client-a@10.0.0.1", wc.ParseClientId(withAddress(grpcCtx, "10.0.0.1:5001")))
+	assert.NotEqual(t, wc.ParseClientId(withAddress(httpCtx, "10.0.0.1:5000")),
+		wc.ParseClientId(withAddress(httpCtx, "10.0.0.2:5000")))
+	authCtx := model.NewAcquireContext()
+	authCtx.SetAttachment(model.OperatorIDKey, "user-1")
+	assert.Equal(t, "user-1/client-a@10.0.0.1", wc.ParseClientId(
+		context.WithValue(withAddress(httpCtx, "10.0.0.1:5000"), utils.ContextAuthContextKey, authCtx)))
+}
+
+func Test_watchCenter_SameClientAcrossTransports(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	svr := &Server{watchCenter: wc, fileCache: fileCache}
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+
+	longPoll := func() <-chan *apiconfig.ConfigClientResponse {
+		ctx := context.WithValue(context.Background(), utils.ContextClientIdKey, "client-a")
+		callback, err := svr.LongPullWatchFile(ctx, &apiconfig.ClientWatchConfigFileRequest{WatchFiles: watchFiles})
+		assert.NoError(t, err)
+		ret := make(chan *apiconfig.ConfigClientResponse, 1)
+		go func() {
+			ret <- callback()
+		}()
+		return ret
+	}
+	expectReleased := func(ret <-chan *apiconfig.ConfigClientResponse) {
+		select {
+		case rsp := <-ret:
+			assert.Equal(t, uint32(apimodel.Code_DataNoChange), rsp.GetCode().GetValue())
+		case <-time.After(time.Second):
+			t.Fatal("replaced long poll should be released")
+		}
+	}
+
+	// 同一个客户端重新发起长轮询，复用同一个订阅者，旧的请求立即返回
+	first := longPoll()
+	second := longPoll()
+	expectReleased(first)
+	assert.Equal(t, 1, wc.clients.Len())
+
+	// 同一个客户端切换为流式订阅
+	stream := &testWatchFileStream{
+		ctx: context.WithValue(context.Background(), utils.ContextGrpcHeader,
+			metadata.Pairs(utils.HeaderClientIdKey, "client-a")),
+		recvCh: make(chan *apiconfig.ClientWatchConfigFileRequest),
+		sendCh: make(chan *apiconfig.ConfigClientResponse, 8),
+	}
+	finish := make(chan error, 1)
+	go func() {
+		finish <- svr.StreamWatchFile(stream.ctx, stream)
+	}()
+	expectReleased(second)
+	stream.recvCh <- &apiconfig.ClientWatchConfigFileRequest{WatchFiles: watchFiles}
+	assert.Eventually(t, func() bool {
+		watchCtx, ok := wc.GetWatchContext("client-a")
+		if !ok {
+			return false
+		}
+		_, isStream := watchCtx.(*StreamWatchContext)
+		return isStream && len(watchCtx.ListWatchFiles()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, wc.clients.Len())
+
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1))
+	select {
+	case rsp := <-stream.sendCh:
+		assert.Equal(t, "file-1", rsp.GetConfigFile().GetFileName().GetValue())
+	case <-time.After(time.Second):
+		t.Fatal("stream watcher not receive notify")
+	}
+
+	close(stream.recvCh)
+	assert.NoError(t, <-finish)
+	assert.Equal(t, 0, wc.clients.Len())
+}
+
+func Test_watchCenter_QuickResponseRollbackBetweenPolls(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	// 两次轮询之间发生了回滚，缓存中生效的发布是版本更旧的 2，并且没有回滚标记
+	active := newTestRelease("default", "group", "file-1", 2)
+	fileCache.EXPECT().GetActiveRelease("default", "group", "file-1").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: active,
+	}).AnyTimes()
+	fileCache.EXPECT().GetMaxReleaseVersion("default", "group", "file-1").Return(uint64(3)).AnyTimes()
+
+	quickResponse := func(version uint64) *apiconfig.ConfigClientResponse {
+		watchCtx := BuildTimeoutWatchCtx(0)("")
+		watchCtx.AppendInterest(newTestWatchFile("default", "group", "file-1", version))
+		return wc.checkQuickResponseClient(watchCtx)
+	}
+
+	// 客户端持有回滚前的版本 3，需要拿到回滚后的版本
+	rsp := quickResponse(3)
+	assert.Equal(t, uint32(apimodel.Code_ExecuteSuccess), rsp.GetCode().GetValue())
+	assert.Equal(t, uint64(2), rsp.GetConfigFile().GetVersion().GetValue())
+	// 缓存中共享的发布对象不会被打上回滚标记
+	assert.False(t, active.Rollback)
+
+	// 客户端已经是回滚后的版本，继续等待
+	assert.Nil(t, quickResponse(2))
+	// 客户端持有的版本服务端还没有加载到，不能通知更旧的版本
+	assert.Nil(t, quickResponse(5))
+	// 客户端的版本落后时正常通知
+	assert.Equal(t, uint64(2), quickResponse(1).GetConfigFile().GetVersion().GetValue())
+}
+
+func Test_watchCenter_QuickResponseNotFound(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	groupCache := mock.NewMockConfigGroupCache(ctrl)
+	groupCache.EXPECT().GetGroupByName("default", "group").Return(&model.ConfigFileGroup{
+		Namespace: "default", Name: "group",
+	}).AnyTimes()
+	groupCache.EXPECT().GetGroupByName("default", "not-exist-group").Return(nil).AnyTimes()
+	// 配置分组以缓存为准，配置文件查询存储的结果在缓存时间内复用
+	storage := storemock.NewMockStore(ctrl)
+	storage.EXPECT().GetConfigFile("default", "group", "unreleased").Return(&model.ConfigFile{
+		Namespace: "default", Group: "group", Name: "unreleased",
+	}, nil).Times(1)
+	storage.EXPECT().GetConfigFile("default", "group", "typo").Return(nil, nil).Times(1)
+	svr := &Server{watchCenter: wc, fileCache: fileCache, groupCache: groupCache, storage: storage,
+		fileExistCache: newFileExistCache(time.Minute)}
+	wc.fileExisted = svr.isConfigFileExisted
+
+	quickResponse := func(group, fileName string) *apiconfig.ConfigClientResponse {
+		watchCtx := BuildTimeoutWatchCtx(0)("")
+		watchCtx.AppendInterest(newTestWatchFile("default", group, fileName, 0))
+		return wc.checkQuickResponseClient(watchCtx)
+	}
+
+	// 配置文件已经创建但是还没有发布，需要继续等待发布
+	assert.Nil(t, quickResponse("group", "unreleased"))
+	assert.Nil(t, quickResponse("group", "unreleased"))
+
+	// 配置文件从来没有存在过，直接告知客户端
+	rsp := quickResponse("group", "typo")
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+	assert.Equal(t, "typo", rsp.GetConfigFile().GetFileName().GetValue())
+	rsp = quickResponse("group", "typo")
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+	rsp = quickResponse("not-exist-group", "file-1")
+	assert.Equal(t, uint32(apimodel.Code_NotFoundResource), rsp.GetCode().GetValue())
+}
+
+func Test_watchCenter_NotifyPoolOrdering(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	fileCache.EXPECT().GetActiveRelease(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	wc.startNotifyPool(4, 8)
+
+	const versions = 50
+	streamCtx := mustAddWatcher(t, wc, "client-stream", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+		newTestWatchFile("default", "group", "file-2", 0),
+		newTestWatchFile("default", "group", "file-3", 0),
+	}, BuildStreamWatchCtx(3*versions)).(*StreamWatchContext)
+
+	for i := 1; i <= versions; i++ {
+		for _, fileName := range []string{"file-1", "file-2", "file-3"} {
+			assert.NoError(t, wc.OnEvent(context.Background(), &eventhub.PublishConfigFileEvent{
+				Message: newTestRelease("default", "group", fileName, uint64(i)),
+			}))
+		}
+	}
+
+	// 客户端只接收比当前版本更新的通知，如果同一个配置文件的事件乱序，收到的通知数量会变少
+	received := map[string][]uint64{}
+	for i := 0; i < 3*versions; i++ {
+		select {
+		case rsp := <-streamCtx.sendCh:
+			fileName := rsp.GetConfigFile().GetFileName().GetValue()
+			received[fileName] = append(received[fileName], rsp.GetConfigFile().GetVersion().GetValue())
+		case <-time.After(time.Second):
+			t.Fatalf("receive %d notify, expect %d", i, 3*versions)
+		}
+	}
+	for _, fileName := range []string{"file-1", "file-2", "file-3"} {
+		assert.Len(t, received[fileName], versions)
+		for i, version := range received[fileName] {
+			assert.Equal(t, uint64(i+1), version)
+		}
+	}
+}
+
+func Test_watchCenter_Snapshot(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	assert.Empty(t, wc.Snapshot())
+
+	before := time.Now()
+	mustAddWatcher(t, wc, "client-poll", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-2", 2),
+		newTestWatchFile("default", "group", "file-1", 1),
+	}, BuildTimeoutWatchCtx(time.Minute))
+	mustAddWatcher(t, wc, "client-stream", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 3),
+	}, BuildStreamWatchCtx(10))
+
+	snapshot := wc.Snapshot()
+	assert.Len(t, snapshot, 2)
+
+	poll := snapshot[0]
+	assert.Equal(t, "client-poll", poll.ClientID)
+	assert.True(t, poll.Once)
+	assert.True(t, poll.ExpireTime.After(before.Add(50*time.Second)))
+	assert.Equal(t, []WatchFileSnapshot{
+		{Namespace: "default", Group: "group", FileName: "file-1", Version: 1},
+		{Namespace: "default", Group: "group", FileName: "file-2", Version: 2},
+	}, poll.Files)
+
+	stream := snapshot[1]
+	assert.Equal(t, "client-stream", stream.ClientID)
+	assert.False(t, stream.Once)
+	assert.True(t, stream.ExpireTime.IsZero())
+	assert.Equal(t, []WatchFileSnapshot{
+		{Namespace: "default", Group: "group", FileName: "file-1", Version: 3},
+	}, stream.Files)
+
+	// 客户端取消订阅后不再出现在快照中
+	wc.RemoveAllWatcher("client-poll")
+	snapshot = wc.Snapshot()
+	assert.Len(t, snapshot, 1)
+	assert.Equal(t, "client-stream", snapshot[0].ClientID)
+}
+
+func Test_Server_DebugWatchContexts(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	svr := &Server{watchCenter: wc}
+	for _, clientId := range []string{"client-1", "client-2"} {
+		mustAddWatcher(t, wc, clientId, []*apiconfig.ClientConfigFileInfo{
+			newTestWatchFile("default", "group", "file-1", 1),
+		}, BuildStreamWatchCtx(10))
+	}
+	handlers := svr.DebugHandlers()
+	assert.Len(t, handlers, 1)
+
+	describe := func(target string) []WatchContextSnapshot {
+		recorder := httptest.NewRecorder()
+		handlers[0].Handler(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var ret []WatchContextSnapshot
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &ret))
+		return ret
+	}
+	assert.Len(t, describe(handlers[0].Path), 2)
+	snapshot := describe(handlers[0].Path + "?client_id=client-2")
+	assert.Len(t, snapshot, 1)
+	assert.Equal(t, "client-2", snapshot[0].ClientID)
+	assert.Equal(t, "file-1", snapshot[0].Files[0].FileName)
+}
+
+func Test_watchCenter_NotifyInlineContent(t *testing.T) {
+	wc, fileCache := newTestWatchCenter(t)
+	wc.inlineContentMaxLength = 8
+	fileCache.EXPECT().GetActiveRelease("default", "group", "small").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "small", 1),
+		Content:                 "k: v",
+	}).AnyTimes()
+	fileCache.EXPECT().GetActiveRelease("default", "group", "large").Return(&model.ConfigFileRelease{
+		SimpleConfigFileRelease: newTestRelease("default", "group", "large", 1),
+		Content:                 "key: large value",
+	}).AnyTimes()
+
+	notify := func(fileName string) *apiconfig.ConfigClientResponse {
+		watchCtxs := map[string]*LongPollWatchContext{
+			"client-" + fileName: mustAddWatcher(t, wc, "client-"+fileName, []*apiconfig.ClientConfigFileInfo{
+				newTestWatchFile("default", "group", fileName, 0),
+			}, BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext),
+		}
+		go wc.notifyToWatchers(newTestRelease("default", "group", fileName, 1))
+		return waitNotifyResults(t, watchCtxs)["client-"+fileName]
+	}
+
+	// 小文件直接在通知中携带配置内容
+	rsp := notify("small")
+	assert.Equal(t, "k: v", rsp.GetConfigFile().GetContent().GetValue())
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+	// 超过阈值的配置文件只通知元数据
+	rsp = notify("large")
+	assert.Nil(t, rsp.GetConfigFile().GetContent())
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+}
+
+// testUnversionedWatchContext 不在客户端维度比较版本的订阅上下文，完全依赖订阅中心保证通知的顺序
+type testUnversionedWatchContext struct {
+	*StreamWatchContext
+}
+
+func (c *testUnversionedWatchContext) ShouldNotify(event *model.SimpleConfigFileRelease) bool {
+	_, ok := c.watchConfigFiles.Load(event.ActiveKey())
+	return ok
+}
+
+func Test_watchCenter_DropStaleNotify(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	watchCtx := mustAddWatcher(t, wc, "client-1", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 0),
+		newTestWatchFile("default", "group", "file-2", 0),
+	}, func(clientId string) WatchContext {
+		return &testUnversionedWatchContext{StreamWatchContext: BuildStreamWatchCtx(10)(clientId).(*StreamWatchContext)}
+	}).(*testUnversionedWatchContext)
+
+	received := func() []uint64 {
+		var versions []uint64
+		for {
+			select {
+			case rsp := <-watchCtx.sendCh:
+				versions = append(versions, rsp.GetConfigFile().GetVersion().GetValue())
+			default:
+				return versions
+			}
+		}
+	}
+
+	// 版本 5 的通知先到达，之后乱序到达的版本 4 直接丢弃，客户端最终停留在版本 5
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 5))
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 4))
+	assert.Equal(t, []uint64{5}, received())
+	// 其他配置文件不受影响，同一个版本允许再次通知
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-2", 1))
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 5))
+	assert.Equal(t, []uint64{1, 5}, received())
+
+	// 强制通知不受限制
+	wc.doNotifyToWatchers(newTestRelease("default", "group", "file-1", 3), true)
+	assert.Equal(t, []uint64{3}, received())
+
+	// 配置文件删除后重新创建，版本重新计数
+	deleted := newTestRelease("default", "group", "file-2", 6)
+	deleted.Valid = false
+	wc.notifyToWatchers(deleted)
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-2", 1))
+	assert.Equal(t, []uint64{6, 1}, received())
+}
+
+// testPanicWatchContext 通知时总是 panic 的订阅上下文
+type testPanicWatchContext struct {
+	*LongPollWatchContext
+}
+
+func (c *testPanicWatchContext) Reply(rsp *apiconfig.ConfigClientResponse) {
+	panic("reply panic")
+}
+
+func Test_watchCenter_ReplyPanic(t *testing.T) {
+	wc, _ := newTestWatchCenter(t)
+	watchFiles := []*apiconfig.ClientConfigFileInfo{newTestWatchFile("default", "group", "file-1", 0)}
+
+	// finishChan 已经被关闭，发送通知时会 panic
+	brokenCtx := mustAddWatcher(t, wc, "client-1", watchFiles,
+		BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	close(brokenCtx.finishChan)
+	mustAddWatcher(t, wc, "client-2", watchFiles, func(clientId string) WatchContext {
+		return &testPanicWatchContext{LongPollWatchContext: BuildTimeoutWatchCtx(time.Minute)(clientId).(*LongPollWatchContext)}
+	})
+	normalCtx := mustAddWatcher(t, wc, "client-3", watchFiles,
+		BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+
+	// finishChan 带有缓冲，同步通知不会阻塞，测试结束前通知已经全部处理完成
+	assert.NotPanics(t, func() { wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 1)) })
+	rsp, err := normalCtx.GetNotifieResultWithTime(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), rsp.GetConfigFile().GetVersion().GetValue())
+
+	// 发生 panic 的客户端同样被清理，再次 Reply 不会 panic
+	assert.Equal(t, 0, wc.clients.Len())
+	assert.NotPanics(t, func() { brokenCtx.Reply(notModifiedResponse) })
+	_, ok := <-brokenCtx.finishChan
+	assert.False(t, ok)
+
+	// 订阅中心仍然可以正常工作
+	nextCtx := mustAddWatcher(t, wc, "client-4", []*apiconfig.ClientConfigFileInfo{
+		newTestWatchFile("default", "group", "file-1", 1),
+	}, BuildTimeoutWatchCtx(time.Minute)).(*LongPollWatchContext)
+	wc.notifyToWatchers(newTestRelease("default", "group", "file-1", 2))
+	rsp, err = nextCtx.GetNotifieResultWithTime(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), rsp.GetConfigFile().GetVersion().GetValue())
+}
@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"golang.org/x/sync/singleflight"
 
@@ -59,6 +60,8 @@ var (
 type Config struct {
 	L5Open bool                   `yaml:"l5Open"`
 	Batch  map[string]interface{} `yaml:"batch"`
+	// CacheWarmUpTimeout 启动时等待缓存首次加载完成的超时时间，为 0 时不等待
+	CacheWarmUpTimeout time.Duration `yaml:"cacheWarmUpTimeout"`
 }
 
 // Initialize 初始化
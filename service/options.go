@@ -18,6 +18,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/polarismesh/polaris/cache"
 	cachetypes "github.com/polarismesh/polaris/cache/api"
 	"github.com/polarismesh/polaris/namespace"
@@ -37,6 +39,9 @@ var (
 	l5CacheEntry = cache.ConfigEntry{
 		Name: cachetypes.L5Name,
 	}
+	clientCacheEntry = cache.ConfigEntry{
+		Name: cachetypes.ClientName,
+	}
 	namingCacheEntries = []cache.ConfigEntry{
 		{
 			Name: cachetypes.ServiceName,
@@ -94,16 +99,50 @@ func WithStorage(storage store.Store) InitOption {
 
 func WithCacheManager(cacheOpt *cache.Config, c *cache.CacheManager) InitOption {
 	return func(s *Server) {
-		log.Infof("[Naming][Server] cache is open, can access the client api function")
+		if s.cacheWarmUpTimeout <= 0 {
+			log.Infof("[Naming][Server] cache is open, can access the client api function")
+		}
 		c.OpenResourceCache(namingCacheEntries...)
 		c.OpenResourceCache(governanceCacheEntries...)
-		if s.isSupportL5() {
+		if s.isOpenL5Cache() {
 			c.OpenResourceCache(l5CacheEntry)
 		}
+		if s.clientCacheOpen {
+			c.OpenResourceCache(clientCacheEntry)
+		}
 		s.caches = c
 	}
 }
 
+// WithClientCache 是否缓存 Client-SDK 的实例数据，默认不开启，不需要的部署可以节省内存
+func WithClientCache(open bool) InitOption {
+	return func(s *Server) {
+		s.clientCacheOpen = open
+		// 兼容 WithClientCache 在 WithCacheManager 之后设置的场景
+		if open && s.caches != nil {
+			s.caches.OpenResourceCache(clientCacheEntry)
+		}
+	}
+}
+
+// WithL5Cache 是否开启 L5 缓存，未设置时根据是否支持 L5 决定，关闭时需要在 WithCacheManager 之前设置
+func WithL5Cache(enabled bool) InitOption {
+	return func(s *Server) {
+		s.l5CacheOpen = &enabled
+		if enabled && s.caches != nil {
+			s.caches.OpenResourceCache(l5CacheEntry)
+		}
+	}
+}
+
+// WithCacheWarmUp 开启缓存预热门禁，WaitCacheWarmUp 最多等待 timeout 时间直到开启的缓存完成首次加载，
+// 需要在 WithCacheManager 之前设置
+func WithCacheWarmUp(timeout time.Duration) InitOption {
+	return func(s *Server) {
+		s.cacheWarmUpTimeout = timeout
+	}
+}
+
 func WithBatchController(c *batch.Controller) InitOption {
 	return func(s *Server) {
 		s.bc = c
@@ -0,0 +1,173 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/cache"
+	cachetypes "github.com/polarismesh/polaris/cache/api"
+	"github.com/polarismesh/polaris/store/mock"
+)
+
+func Test_WithClientCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newCacheMgr := func() *cache.CacheManager {
+		ctx, cancel := context.WithCancel(context.Background())
+		// 只需要检查缓存是否被开启，不需要后台的定时更新
+		defer cancel()
+		cacheMgr, err := cache.TestCacheInitialize(ctx, &cache.Config{}, mock.NewMockStore(ctrl))
+		assert.NoError(t, err)
+		return cacheMgr
+	}
+
+	// 默认不开启 client 缓存
+	cacheMgr := newCacheMgr()
+	WithCacheManager(&cache.Config{}, cacheMgr)(&Server{})
+	assert.True(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.InstanceName))
+	assert.False(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.ClientName))
+
+	cacheMgr = newCacheMgr()
+	svr := &Server{}
+	WithClientCache(false)(svr)
+	WithCacheManager(&cache.Config{}, cacheMgr)(svr)
+	assert.False(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.ClientName))
+
+	cacheMgr = newCacheMgr()
+	svr = &Server{}
+	WithClientCache(true)(svr)
+	WithCacheManager(&cache.Config{}, cacheMgr)(svr)
+	assert.True(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.ClientName))
+
+	// 与 WithCacheManager 的先后顺序无关
+	cacheMgr = newCacheMgr()
+	svr = &Server{}
+	WithCacheManager(&cache.Config{}, cacheMgr)(svr)
+	WithClientCache(true)(svr)
+	assert.True(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.ClientName))
+}
+
+func Test_WithL5Cache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newCacheMgr := func() *cache.CacheManager {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cacheMgr, err := cache.TestCacheInitialize(ctx, &cache.Config{}, mock.NewMockStore(ctrl))
+		assert.NoError(t, err)
+		return cacheMgr
+	}
+
+	// 未设置时根据是否支持 L5 决定
+	cacheMgr := newCacheMgr()
+	WithCacheManager(&cache.Config{}, cacheMgr)(&Server{config: Config{L5Open: true}})
+	assert.True(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.L5Name))
+
+	cacheMgr = newCacheMgr()
+	WithCacheManager(&cache.Config{}, cacheMgr)(&Server{})
+	assert.False(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.L5Name))
+
+	// 支持 L5 时也可以关闭 L5 缓存
+	cacheMgr = newCacheMgr()
+	svr := &Server{config: Config{L5Open: true}}
+	WithL5Cache(false)(svr)
+	WithCacheManager(&cache.Config{}, cacheMgr)(svr)
+	assert.False(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.L5Name))
+	assert.True(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.InstanceName))
+
+	cacheMgr = newCacheMgr()
+	svr = &Server{}
+	WithL5Cache(true)(svr)
+	WithCacheManager(&cache.Config{}, cacheMgr)(svr)
+	assert.True(t, cacheMgr.TestIsResourceCacheOpen(cachetypes.L5Name))
+}
+
+// testWarmUpCache 在 loadedAt 之前一直处于首次加载中的缓存
+type testWarmUpCache struct {
+	cachetypes.Cache
+	loadedAt time.Time
+}
+
+func (c *testWarmUpCache) IsFirstUpdate() bool {
+	return time.Now().Before(c.loadedAt)
+}
+
+func Test_WithCacheWarmUp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// 缓存开启后替换为测试缓存，其中实例缓存加载缓慢
+	newServer := func(timeout, slowLoad time.Duration) *Server {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cacheMgr, err := cache.TestCacheInitialize(ctx, &cache.Config{}, mock.NewMockStore(ctrl))
+		assert.NoError(t, err)
+
+		svr := &Server{}
+		WithCacheWarmUp(timeout)(svr)
+		WithCacheManager(&cache.Config{}, cacheMgr)(svr)
+		now := time.Now()
+		for i := cachetypes.CacheService; i < cachetypes.CacheLast; i++ {
+			loadedAt := now
+			if i == cachetypes.CacheInstance {
+				loadedAt = now.Add(slowLoad)
+			}
+			cacheMgr.RegisterCacher(i, &testWarmUpCache{Cache: cacheMgr.GetCacher(i), loadedAt: loadedAt})
+		}
+		return svr
+	}
+
+	t.Run("等待缓存加载完成", func(t *testing.T) {
+		svr := newServer(5*time.Second, 300*time.Millisecond)
+
+		done := make(chan error, 1)
+		start := time.Now()
+		go func() {
+			done <- svr.WaitCacheWarmUp(context.Background())
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("cache warm up should wait for the slow cache")
+		case <-time.After(100 * time.Millisecond):
+		}
+		assert.NoError(t, <-done)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("等待超时", func(t *testing.T) {
+		svr := newServer(200*time.Millisecond, time.Hour)
+
+		err := svr.WaitCacheWarmUp(context.Background())
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), cachetypes.InstanceName)
+	})
+
+	t.Run("未开启时不等待", func(t *testing.T) {
+		svr := newServer(0, time.Hour)
+		assert.NoError(t, svr.WaitCacheWarmUp(context.Background()))
+	})
+}
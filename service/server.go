@@ -19,8 +19,11 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	apiservice "github.com/polarismesh/specification/source/go/api/v1/service_manage"
+	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/polarismesh/polaris/cache"
@@ -45,6 +48,12 @@ type Server struct {
 
 	caches *cache.CacheManager
 	bc     *batch.Controller
+	// clientCacheOpen 是否开启 Client-SDK 实例数据的缓存
+	clientCacheOpen bool
+	// l5CacheOpen 是否开启 L5 缓存，为空时根据是否支持 L5 决定
+	l5CacheOpen *bool
+	// cacheWarmUpTimeout 等待缓存首次加载完成的超时时间，为 0 时不等待
+	cacheWarmUpTimeout time.Duration
 
 	healthServer *healthcheck.Server
 
@@ -67,6 +76,13 @@ func (s *Server) isSupportL5() bool {
 	return s.config.L5Open
 }
 
+func (s *Server) isOpenL5Cache() bool {
+	if s.l5CacheOpen != nil {
+		return *s.l5CacheOpen
+	}
+	return s.isSupportL5()
+}
+
 // HealthServer 健康检查Server
 func (s *Server) HealthServer() *healthcheck.Server {
 	return s.healthServer
@@ -77,6 +93,45 @@ func (s *Server) Cache() *cache.CacheManager {
 	return s.caches
 }
 
+// WaitCacheWarmUp 阻塞等待开启的缓存完成首次加载，超时后打印仍未完成加载的缓存并返回错误；
+// 启动时在 apiserver 开始监听之前调用，从而保证客户端接口在缓存加载完成之后才对外提供
+func (s *Server) WaitCacheWarmUp(ctx context.Context) error {
+	if s.cacheWarmUpTimeout <= 0 || s.caches == nil {
+		return nil
+	}
+
+	start := time.Now()
+	waitCtx, cancel := context.WithTimeout(ctx, s.cacheWarmUpTimeout)
+	defer cancel()
+	lagging := s.caches.WaitFirstUpdate(waitCtx, s.openedCacheNames()...)
+	if len(lagging) != 0 {
+		log.Warn("[Naming][Server] cache warm up timeout, client api will be available before cache loaded",
+			zap.Duration("timeout", s.cacheWarmUpTimeout), zap.Strings("caches", lagging))
+		return fmt.Errorf("cache warm up timeout, lagging caches: %v", lagging)
+	}
+	log.Info("[Naming][Server] cache warm up done, can access the client api function",
+		zap.Duration("cost", time.Since(start)))
+	return nil
+}
+
+// openedCacheNames 获取 naming 模块开启的缓存
+func (s *Server) openedCacheNames() []string {
+	names := make([]string, 0, len(namingCacheEntries)+len(governanceCacheEntries)+2)
+	for _, entry := range namingCacheEntries {
+		names = append(names, entry.Name)
+	}
+	for _, entry := range governanceCacheEntries {
+		names = append(names, entry.Name)
+	}
+	if s.isOpenL5Cache() {
+		names = append(names, l5CacheEntry.Name)
+	}
+	if s.clientCacheOpen {
+		names = append(names, clientCacheEntry.Name)
+	}
+	return names
+}
+
 // Namespace 返回NamespaceOperateServer
 func (s *Server) Namespace() namespace.NamespaceOperateServer {
 	return s.namespaceSvr
@@ -0,0 +1,68 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package boltdb
+
+import (
+	"time"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/store"
+)
+
+const (
+	tblConfigWatchSubscription string = "ConfigWatchSubscription"
+)
+
+type configWatchSubscriptionStore struct {
+	handler BoltHandler
+}
+
+func newConfigWatchSubscriptionStore(handler BoltHandler) *configWatchSubscriptionStore {
+	s := &configWatchSubscriptionStore{handler: handler}
+	return s
+}
+
+// UpsertConfigWatchSubscription 保存客户端的订阅关系，已经存在时覆盖
+func (cw *configWatchSubscriptionStore) UpsertConfigWatchSubscription(sub *model.ConfigWatchSubscription) error {
+	sub.ModifyTime = time.Now()
+	if err := cw.handler.SaveValue(tblConfigWatchSubscription, sub.ClientId, sub); err != nil {
+		return store.Error(err)
+	}
+	return nil
+}
+
+// DeleteConfigWatchSubscriptions 删除客户端的订阅关系
+func (cw *configWatchSubscriptionStore) DeleteConfigWatchSubscriptions(clientIds []string) error {
+	if err := cw.handler.DeleteValues(tblConfigWatchSubscription, clientIds); err != nil {
+		return store.Error(err)
+	}
+	return nil
+}
+
+// GetAllConfigWatchSubscriptions 获取全部客户端的订阅关系
+func (cw *configWatchSubscriptionStore) GetAllConfigWatchSubscriptions() ([]*model.ConfigWatchSubscription, error) {
+	ret, err := cw.handler.LoadValuesAll(tblConfigWatchSubscription, &model.ConfigWatchSubscription{})
+	if err != nil {
+		return nil, store.Error(err)
+	}
+	subs := make([]*model.ConfigWatchSubscription, 0, len(ret))
+	for _, v := range ret {
+		subs = append(subs, v.(*model.ConfigWatchSubscription))
+	}
+	return subs, nil
+}
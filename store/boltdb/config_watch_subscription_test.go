@@ -0,0 +1,62 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package boltdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/polarismesh/polaris/common/model"
+)
+
+func Test_configWatchSubscriptionStore(t *testing.T) {
+	CreateTableDBHandlerAndRun(t, tblConfigWatchSubscription, func(t *testing.T, handler BoltHandler) {
+		store := newConfigWatchSubscriptionStore(handler)
+
+		assert.NoError(t, store.UpsertConfigWatchSubscription(&model.ConfigWatchSubscription{
+			ClientId: "client-1",
+			Files:    `[{"file_name":"a","version":1}]`,
+		}))
+		assert.NoError(t, store.UpsertConfigWatchSubscription(&model.ConfigWatchSubscription{
+			ClientId: "client-2",
+			Files:    `[{"file_name":"b","version":1}]`,
+		}))
+		// 同一个客户端再次保存时覆盖之前的订阅关系
+		assert.NoError(t, store.UpsertConfigWatchSubscription(&model.ConfigWatchSubscription{
+			ClientId: "client-1",
+			Files:    `[{"file_name":"a","version":2}]`,
+		}))
+
+		subs, err := store.GetAllConfigWatchSubscriptions()
+		assert.NoError(t, err)
+		assert.Len(t, subs, 2)
+		files := map[string]string{}
+		for _, sub := range subs {
+			files[sub.ClientId] = sub.Files
+			assert.False(t, sub.ModifyTime.IsZero())
+		}
+		assert.Equal(t, `[{"file_name":"a","version":2}]`, files["client-1"])
+
+		assert.NoError(t, store.DeleteConfigWatchSubscriptions([]string{"client-1"}))
+		subs, err = store.GetAllConfigWatchSubscriptions()
+		assert.NoError(t, err)
+		assert.Len(t, subs, 1)
+		assert.Equal(t, "client-2", subs[0].ClientId)
+	})
+}
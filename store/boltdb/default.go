@@ -79,6 +79,7 @@ type boltStore struct {
 	*configFileReleaseStore
 	*configFileReleaseHistoryStore
 	*configFileTemplateStore
+	*configWatchSubscriptionStore
 
 	// adminStore store
 	*adminStore
@@ -343,6 +344,7 @@ func (m *boltStore) newConfigModuleStore() {
 	m.configFileReleaseHistoryStore = newConfigFileReleaseHistoryStore(m.handler)
 	m.configFileReleaseStore = newConfigFileReleaseStore(m.handler)
 	m.configFileTemplateStore = newConfigFileTemplateStore(m.handler)
+	m.configWatchSubscriptionStore = newConfigWatchSubscriptionStore(m.handler)
 }
 
 func (m *boltStore) newMaintainModuleStore() {
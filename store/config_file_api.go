@@ -30,6 +30,7 @@ type ConfigFileModuleStore interface {
 	ConfigFileReleaseStore
 	ConfigFileReleaseHistoryStore
 	ConfigFileTemplateStore
+	ConfigWatchSubscriptionStore
 }
 
 // ConfigFileGroupStore 配置文件组存储接口
@@ -113,3 +114,13 @@ type ConfigFileTemplateStore interface {
 	// GetConfigFileTemplate get config file template by name
 	GetConfigFileTemplate(name string) (*model.ConfigFileTemplate, error)
 }
+
+// ConfigWatchSubscriptionStore 客户端订阅关系存储接口
+type ConfigWatchSubscriptionStore interface {
+	// UpsertConfigWatchSubscription 保存客户端的订阅关系，已经存在时覆盖
+	UpsertConfigWatchSubscription(sub *model.ConfigWatchSubscription) error
+	// DeleteConfigWatchSubscriptions 删除客户端的订阅关系
+	DeleteConfigWatchSubscriptions(clientIds []string) error
+	// GetAllConfigWatchSubscriptions 获取全部客户端的订阅关系
+	GetAllConfigWatchSubscriptions() ([]*model.ConfigWatchSubscription, error)
+}
@@ -662,6 +662,20 @@ func (mr *MockStoreMockRecorder) DeleteConfigFileTx(tx, namespace, group, name i
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConfigFileTx", reflect.TypeOf((*MockStore)(nil).DeleteConfigFileTx), tx, namespace, group, name)
 }
 
+// DeleteConfigWatchSubscriptions mocks base method.
+func (m *MockStore) DeleteConfigWatchSubscriptions(clientIds []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteConfigWatchSubscriptions", clientIds)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteConfigWatchSubscriptions indicates an expected call of DeleteConfigWatchSubscriptions.
+func (mr *MockStoreMockRecorder) DeleteConfigWatchSubscriptions(clientIds interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteConfigWatchSubscriptions", reflect.TypeOf((*MockStore)(nil).DeleteConfigWatchSubscriptions), clientIds)
+}
+
 // DeleteFaultDetectRule mocks base method.
 func (m *MockStore) DeleteFaultDetectRule(id string) error {
 	m.ctrl.T.Helper()
@@ -915,6 +929,21 @@ func (mr *MockStoreMockRecorder) GenNextL5Sid(layoutID interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenNextL5Sid", reflect.TypeOf((*MockStore)(nil).GenNextL5Sid), layoutID)
 }
 
+// GetAllConfigWatchSubscriptions mocks base method.
+func (m *MockStore) GetAllConfigWatchSubscriptions() ([]*model.ConfigWatchSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAllConfigWatchSubscriptions")
+	ret0, _ := ret[0].([]*model.ConfigWatchSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAllConfigWatchSubscriptions indicates an expected call of GetAllConfigWatchSubscriptions.
+func (mr *MockStoreMockRecorder) GetAllConfigWatchSubscriptions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllConfigWatchSubscriptions", reflect.TypeOf((*MockStore)(nil).GetAllConfigWatchSubscriptions))
+}
+
 // GetCircuitBreakerRules mocks base method.
 func (m *MockStore) GetCircuitBreakerRules(filter map[string]string, offset, limit uint32) (uint32, []*model.CircuitBreakerRule, error) {
 	m.ctrl.T.Helper()
@@ -2537,6 +2566,20 @@ func (mr *MockStoreMockRecorder) UpdateUser(user interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), user)
 }
 
+// UpsertConfigWatchSubscription mocks base method.
+func (m *MockStore) UpsertConfigWatchSubscription(sub *model.ConfigWatchSubscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertConfigWatchSubscription", sub)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertConfigWatchSubscription indicates an expected call of UpsertConfigWatchSubscription.
+func (mr *MockStoreMockRecorder) UpsertConfigWatchSubscription(sub interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertConfigWatchSubscription", reflect.TypeOf((*MockStore)(nil).UpsertConfigWatchSubscription), sub)
+}
+
 // MockNamespaceStore is a mock of NamespaceStore interface.
 type MockNamespaceStore struct {
 	ctrl     *gomock.Controller
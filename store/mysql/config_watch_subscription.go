@@ -0,0 +1,90 @@
+/**
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+
+package sqldb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/polarismesh/polaris/common/model"
+	"github.com/polarismesh/polaris/store"
+)
+
+type configWatchSubscriptionStore struct {
+	master *BaseDB
+	slave  *BaseDB
+}
+
+// UpsertConfigWatchSubscription 保存客户端的订阅关系，已经存在时覆盖
+func (cw *configWatchSubscriptionStore) UpsertConfigWatchSubscription(sub *model.ConfigWatchSubscription) error {
+	upsertSql := "REPLACE INTO config_watch_subscription(`client_id`, `files`, `modify_time`) VALUES (?, ?, sysdate())"
+	if _, err := cw.master.Exec(upsertSql, sub.ClientId, sub.Files); err != nil {
+		return store.Error(err)
+	}
+	return nil
+}
+
+// DeleteConfigWatchSubscriptions 删除客户端的订阅关系
+func (cw *configWatchSubscriptionStore) DeleteConfigWatchSubscriptions(clientIds []string) error {
+	if len(clientIds) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(clientIds))
+	for i := range clientIds {
+		args = append(args, clientIds[i])
+	}
+	deleteSql := "DELETE FROM config_watch_subscription WHERE client_id IN (" + placeholders(len(clientIds)) + ")"
+	if _, err := cw.master.Exec(deleteSql, args...); err != nil {
+		return store.Error(err)
+	}
+	return nil
+}
+
+// GetAllConfigWatchSubscriptions 获取全部客户端的订阅关系
+func (cw *configWatchSubscriptionStore) GetAllConfigWatchSubscriptions() ([]*model.ConfigWatchSubscription, error) {
+	querySql := "SELECT client_id, files, UNIX_TIMESTAMP(modify_time) FROM config_watch_subscription"
+	rows, err := cw.master.Query(querySql)
+	if err != nil {
+		return nil, store.Error(err)
+	}
+	return cw.transferRows(rows)
+}
+
+func (cw *configWatchSubscriptionStore) transferRows(rows *sql.Rows) ([]*model.ConfigWatchSubscription, error) {
+	if rows == nil {
+		return nil, nil
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var subs []*model.ConfigWatchSubscription
+	for rows.Next() {
+		sub := &model.ConfigWatchSubscription{}
+		var mtime int64
+		if err := rows.Scan(&sub.ClientId, &sub.Files, &mtime); err != nil {
+			return nil, err
+		}
+		sub.ModifyTime = time.Unix(mtime, 0)
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
@@ -65,6 +65,7 @@ type stableStore struct {
 	*configFileReleaseStore
 	*configFileReleaseHistoryStore
 	*configFileTemplateStore
+	*configWatchSubscriptionStore
 
 	*clientStore
 	*adminStore
@@ -265,6 +266,7 @@ func (s *stableStore) newStore() {
 	s.configFileReleaseStore = &configFileReleaseStore{master: s.master, slave: s.slave}
 	s.configFileReleaseHistoryStore = &configFileReleaseHistoryStore{master: s.master, slave: s.slave}
 	s.configFileTemplateStore = &configFileTemplateStore{master: s.master, slave: s.slave}
+	s.configWatchSubscriptionStore = &configWatchSubscriptionStore{master: s.master, slave: s.slave}
 	s.clientStore = &clientStore{master: s.master, slave: s.slave}
 
 	s.adminStore = newAdminStore(s.master)
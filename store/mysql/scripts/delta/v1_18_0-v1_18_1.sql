@@ -0,0 +1,30 @@
+/*
+ * Tencent is pleased to support the open source community by making Polaris available.
+ *
+ * Copyright (C) 2019 THL A29 Limited, a Tencent company. All rights reserved.
+ *
+ * Licensed under the BSD 3-Clause License (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * https://opensource.org/licenses/BSD-3-Clause
+ *
+ * Unless required by applicable law or agreed to in writing, software distributed
+ * under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR
+ * CONDITIONS OF ANY KIND, either express or implied. See the License for the
+ * specific language governing permissions and limitations under the License.
+ */
+--
+-- Database: `polaris_server`
+--
+USE `polaris_server`;
+
+/* 配置中心客户端订阅关系表，用于服务端重启后恢复客户端的订阅关系 */
+CREATE TABLE config_watch_subscription
+(
+    `client_id`   VARCHAR(128) NOT NULL COMMENT '客户端 ID',
+    `files`       LONGTEXT     NOT NULL COMMENT '订阅的配置文件以及已经通知过的版本，JSON 格式',
+    `modify_time` TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后更新时间',
+    PRIMARY KEY (`client_id`),
+    KEY `mtime` (`modify_time`)
+) ENGINE = InnoDB;
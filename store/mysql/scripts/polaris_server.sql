@@ -914,4 +914,14 @@ CREATE TABLE service_contract_detail
     PRIMARY KEY (`id`),
     -- 服务契约id + method + path + source 需保证唯一
     KEY (`contract_id`, `path`, `method`)
-) ENGINE = InnoDB;
\ No newline at end of file
+) ENGINE = InnoDB;
+
+/* 配置中心客户端订阅关系表，用于服务端重启后恢复客户端的订阅关系 */
+CREATE TABLE config_watch_subscription
+(
+    `client_id`   VARCHAR(128) NOT NULL COMMENT '客户端 ID',
+    `files`       LONGTEXT     NOT NULL COMMENT '订阅的配置文件以及已经通知过的版本，JSON 格式',
+    `modify_time` TIMESTAMP    NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '最后更新时间',
+    PRIMARY KEY (`client_id`),
+    KEY `mtime` (`modify_time`)
+) ENGINE = InnoDB;